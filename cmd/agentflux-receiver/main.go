@@ -0,0 +1,134 @@
+// Package main provides agentflux-receiver, a reference implementation of the
+// AgentFlux ingestion API for local testing. It stores received batches and
+// events as JSONL files and can inject faults to exercise client retries.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"net/http"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/vtriple/agentflux/pkg/api"
+	"github.com/vtriple/agentflux/pkg/common/logging"
+	"github.com/vtriple/agentflux/pkg/receiver"
+)
+
+func main() {
+	logger := logging.NewLogger("receiver")
+
+	addr := flag.String("addr", "127.0.0.1:8800", "Address to listen on")
+	dataDir := flag.String("data-dir", "./receiver-data", "Directory where received data is stored")
+	authMethod := flag.String("auth-method", "", "Required auth method (bearer, basic, api-key, oauth2, hmac; empty for none)")
+	token := flag.String("token", "", "Expected token, API key, username:password or HMAC secret (literal, env:NAME or file:/path)")
+	hmacKeyID := flag.String("hmac-key-id", "", "Key ID accepted for HMAC auth")
+	clientID := flag.String("oauth-client-id", "", "OAuth2 client ID accepted by the token endpoint")
+	clientSecret := flag.String("oauth-client-secret", "", "OAuth2 client secret (literal, env:NAME or file:/path)")
+	tokenTTL := flag.Duration("oauth-token-ttl", receiver.DefaultTokenTTL, "Lifetime of issued OAuth2 tokens")
+	latency := flag.Duration("fault-latency", 0, "Delay added to every request")
+	errorRate := flag.Float64("fault-error-rate", 0, "Probability of answering 500")
+	rateLimitRate := flag.Float64("fault-429-rate", 0, "Probability of answering 429")
+	dropRate := flag.Float64("fault-drop-rate", 0, "Probability of dropping the connection")
+	logLevel := flag.String("log-level", "info", "Log level (debug, info, warn, error), optionally with per-component overrides such as info,receiver=debug")
+	logFormat := flag.String("log-format", string(logging.FormatText), "Log format (text, json, logfmt)")
+	flag.Parse()
+
+	if err := logging.SetLevels(*logLevel); err != nil {
+		logger.Fatal("Invalid configuration: %v", err)
+	}
+	format, err := logging.ParseFormat(*logFormat)
+	if err != nil {
+		logger.Fatal("Invalid configuration: %v", err)
+	}
+	logging.SetFormat(format)
+
+	config, err := buildConfig(*dataDir, *authMethod, *token, *hmacKeyID, *clientID, *clientSecret)
+	if err != nil {
+		logger.Fatal("Invalid configuration: %v", err)
+	}
+	config.TokenTTL = *tokenTTL
+	config.Faults = receiver.Faults{
+		LatencyMS:     int(*latency / time.Millisecond),
+		ErrorRate:     *errorRate,
+		RateLimitRate: *rateLimitRate,
+		DropRate:      *dropRate,
+	}
+
+	server, err := receiver.NewServer(config)
+	if err != nil {
+		logger.Fatal("Failed to create receiver: %v", err)
+	}
+	defer server.Close()
+
+	httpServer := &http.Server{
+		Addr:              *addr,
+		Handler:           server.Handler(),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	// Shut down gracefully on SIGINT/SIGTERM
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		httpServer.Shutdown(shutdownCtx)
+	}()
+
+	logger.Info("Listening on %s (results: %s, events: %s, faults: %s), storing data in %s",
+		*addr, receiver.ResultsPath, receiver.EventsPath, receiver.FaultsPath, *dataDir)
+	if err := httpServer.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		logger.Fatal("Server error: %v", err)
+	}
+
+	stats := server.Stats()
+	fmt.Printf("Batches: %d, results: %d, duplicates: %d, events: %d, rejected: %d, faults: %d\n",
+		stats.Batches, stats.Results, stats.Duplicates, stats.Events, stats.Rejected, stats.Faults)
+}
+
+// buildConfig converts the auth flags into a receiver configuration.
+func buildConfig(dataDir, authMethod, tokenSpec, hmacKeyID, clientID, clientSecretSpec string) (receiver.Config, error) {
+	config := receiver.Config{
+		DataDir:    dataDir,
+		AuthMethod: api.AuthType(authMethod),
+	}
+
+	token, err := api.ResolveSecret(tokenSpec)
+	if err != nil {
+		return config, err
+	}
+
+	switch config.AuthMethod {
+	case api.AuthBasic:
+		username, password, ok := strings.Cut(token, ":")
+		if !ok {
+			return config, fmt.Errorf("basic auth requires a token of the form username:password")
+		}
+		config.Basic = api.BasicAuth{Username: username, Password: password}
+
+	case api.AuthHMAC:
+		if hmacKeyID == "" || token == "" {
+			return config, fmt.Errorf("hmac auth requires --hmac-key-id and a shared secret token")
+		}
+		config.HMACSecrets = map[string]string{hmacKeyID: token}
+
+	case api.AuthOAuth2:
+		clientSecret, err := api.ResolveSecret(clientSecretSpec)
+		if err != nil {
+			return config, err
+		}
+		config.ClientID = clientID
+		config.ClientSecret = clientSecret
+
+	default:
+		config.Token = token
+	}
+
+	return config, nil
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"github.com/vtriple/agentflux/pkg/execanalyzer"
+	"github.com/vtriple/agentflux/pkg/processor"
+)
+
+// setupAnalyzers adds the named built-in analyzers and the per-file command
+// analyzers defined in execFile, if set, to hashProcessor, so they run
+// while files are hashed. The batch command analyzers are returned as
+// stages to run on the results afterwards.
+func setupAnalyzers(hashProcessor *processor.HashProcessor, names, execFile string) ([]*execanalyzer.BatchStage, error) {
+	analyzers, err := processor.DefaultAnalyzers.Select(splitCSV(names))
+	if err != nil {
+		return nil, err
+	}
+	hashProcessor.Analyzers = analyzers
+	if execFile == "" {
+		return nil, nil
+	}
+
+	specs, err := execanalyzer.LoadFile(execFile)
+	if err != nil {
+		return nil, err
+	}
+	var batchStages []*execanalyzer.BatchStage
+	for _, spec := range specs {
+		if spec.BatchSize > 0 {
+			batchStages = append(batchStages, execanalyzer.NewBatchStage(spec))
+		} else {
+			hashProcessor.Analyzers = append(hashProcessor.Analyzers, spec.Factory())
+		}
+	}
+	return batchStages, nil
+}
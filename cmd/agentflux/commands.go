@@ -0,0 +1,226 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/vtriple/agentflux/pkg/common/config"
+)
+
+// command is an agentflux subcommand.
+type command struct {
+	// name is the word that selects the command.
+	name string
+	// args describes the arguments in the usage line.
+	args string
+	// summary is a one-line description shown in help.
+	summary string
+	// words are the fixed arguments offered by shell completion, if any.
+	words []string
+	// files indicates whether shell completion offers file names.
+	files bool
+	// flags returns the command's flag set, for help and completion. It is
+	// nil for commands without flags.
+	flags func() *flag.FlagSet
+	// run runs the command and returns the process exit code.
+	run func(args []string) int
+}
+
+// commands lists the subcommands in the order they are shown in help. It is
+// set in init because help and completion refer back to it.
+var commands []*command
+
+func init() {
+	commands = []*command{
+		{
+			name:    "scan",
+			args:    "[flags]",
+			summary: "Scan paths, hash files and send the results to the API.",
+			files:   true,
+			flags:   func() *flag.FlagSet { return newFlagSet("scan", &config.Config{}) },
+			run:     runScan,
+		},
+		{
+			name:    "hash",
+			args:    "[flags] <files...>",
+			summary: "Print the hashes of files locally, like sha256sum.",
+			files:   true,
+			flags:   func() *flag.FlagSet { return newHashFlagSet(&hashOptions{}) },
+			run:     runHash,
+		},
+		{
+			name:    "verify",
+			args:    "[flags] <manifest>",
+			summary: "Check files against a checksum manifest written by sha256sum or agentflux hash.",
+			files:   true,
+			flags:   func() *flag.FlagSet { return newVerifyFlagSet(&verifyOptions{}) },
+			run:     runVerify,
+		},
+		{
+			name:    "render",
+			args:    "[flags] [results.jsonl...]",
+			summary: "Turn saved results into CSV or a self-contained HTML report.",
+			files:   true,
+			flags:   func() *flag.FlagSet { return newRenderFlagSet(&renderOptions{}) },
+			run:     runRender,
+		},
+		{
+			name:    "dupes",
+			args:    "[flags]",
+			summary: "List the largest groups of duplicate files locally, holding every unique file in memory.",
+			files:   true,
+			flags:   func() *flag.FlagSet { return newDupesFlagSet(&dupesOptions{}) },
+			run:     runDupes,
+		},
+		{
+			name:    "daemon",
+			args:    "[flags]",
+			summary: "Run the profiles of a config file on their schedules.",
+			files:   true,
+			flags:   func() *flag.FlagSet { return newDaemonFlagSet("daemon", &config.Config{}) },
+			run:     runDaemon,
+		},
+		{
+			name:    "config",
+			args:    "print [flags]",
+			summary: "Print the effective configuration and where each setting came from.",
+			words:   []string{"print"},
+			files:   true,
+			flags:   func() *flag.FlagSet { return newDaemonFlagSet("config print", &config.Config{}) },
+			run:     runConfigCommand,
+		},
+		{
+			name:    "completion",
+			args:    "bash|zsh|fish",
+			summary: "Print a shell completion script.",
+			words:   completionShells,
+			run:     runCompletion,
+		},
+		{
+			name:    "version",
+			summary: "Print version information.",
+			run: func([]string) int {
+				printVersion()
+				return 0
+			},
+		},
+		{
+			name:    "help",
+			args:    "[command]",
+			summary: "Show help for agentflux or one of its commands.",
+			run:     runHelp,
+		},
+	}
+	for _, cmd := range commands {
+		if cmd.name == "help" {
+			cmd.words = commandNames()
+		}
+	}
+}
+
+// dispatch runs the subcommand named by the first argument and returns the
+// process exit code. Arguments starting with a flag run a scan, as before
+// subcommands were introduced.
+func dispatch(args []string) int {
+	if len(args) == 0 {
+		printUsage(os.Stderr)
+		return 2
+	}
+	switch args[0] {
+	case "-h", "-help", "--help":
+		printUsage(os.Stdout)
+		return 0
+	}
+	if strings.HasPrefix(args[0], "-") {
+		return runScan(args)
+	}
+
+	cmd := lookupCommand(args[0])
+	if cmd == nil {
+		fmt.Fprintf(os.Stderr, "agentflux: unknown command %q\n\n", args[0])
+		printUsage(os.Stderr)
+		return 2
+	}
+	return cmd.run(args[1:])
+}
+
+// lookupCommand returns the command with the given name, or nil.
+func lookupCommand(name string) *command {
+	for _, cmd := range commands {
+		if cmd.name == name {
+			return cmd
+		}
+	}
+	return nil
+}
+
+// commandNames returns the names of all commands.
+func commandNames() []string {
+	names := make([]string, len(commands))
+	for i, cmd := range commands {
+		names[i] = cmd.name
+	}
+	return names
+}
+
+// setUsage makes fs print the usage of the named command on -h or a
+// parse error, and returns fs.
+func setUsage(fs *flag.FlagSet, name string) *flag.FlagSet {
+	fs.Usage = func() {
+		if cmd := lookupCommand(name); cmd != nil {
+			printCommandUsage(fs.Output(), cmd, fs)
+		}
+	}
+	return fs
+}
+
+// printUsage writes the top-level help listing the commands.
+func printUsage(w io.Writer) {
+	fmt.Fprintf(w, "Usage: agentflux <command> [flags] [arguments]\n\nCommands:\n")
+	for _, cmd := range commands {
+		fmt.Fprintf(w, "  %-11s %s\n", cmd.name, cmd.summary)
+	}
+	fmt.Fprintf(w, "\nRun \"agentflux help <command>\" for the flags of a command. Flags without a\ncommand run a scan.\n")
+}
+
+// printCommandUsage writes the usage line, summary and flags of a command.
+func printCommandUsage(w io.Writer, cmd *command, fs *flag.FlagSet) {
+	fmt.Fprintf(w, "Usage: agentflux %s\n\n%s\n", strings.TrimSpace(cmd.name+" "+cmd.args), cmd.summary)
+	if fs == nil {
+		return
+	}
+	fmt.Fprintf(w, "\nFlags:\n")
+	output := fs.Output()
+	fs.SetOutput(w)
+	fs.PrintDefaults()
+	fs.SetOutput(output)
+}
+
+// runHelp implements "agentflux help [command]".
+func runHelp(args []string) int {
+	if len(args) == 0 {
+		printUsage(os.Stdout)
+		return 0
+	}
+	cmd := lookupCommand(args[0])
+	if cmd == nil {
+		fmt.Fprintf(os.Stderr, "agentflux: unknown command %q\n", args[0])
+		return 2
+	}
+	var fs *flag.FlagSet
+	if cmd.flags != nil {
+		fs = cmd.flags()
+	}
+	printCommandUsage(os.Stdout, cmd, fs)
+	return 0
+}
+
+// printVersion writes the version and build information.
+func printVersion() {
+	fmt.Printf("AgentFlux v%s\n", Version)
+	fmt.Printf("Build Date: %s\n", BuildDate)
+	fmt.Printf("Git Commit: %s\n", GitCommit)
+}
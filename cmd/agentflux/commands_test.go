@@ -0,0 +1,169 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/vtriple/agentflux/pkg/common/config"
+	"github.com/vtriple/agentflux/pkg/common/logging"
+	"github.com/vtriple/agentflux/pkg/manifest"
+	"github.com/vtriple/agentflux/pkg/processor"
+)
+
+func TestDispatchUnknownCommand(t *testing.T) {
+	if code := dispatch([]string{"bogus"}); code != 2 {
+		t.Errorf("Expected exit code 2 for an unknown command, got %d", code)
+	}
+	if code := dispatch([]string{"help", "hash"}); code != 0 {
+		t.Errorf("Expected exit code 0 for help, got %d", code)
+	}
+}
+
+func TestDupesRejectsUnknownAlgorithm(t *testing.T) {
+	if code := dispatch([]string{"dupes", "--algorithm=crc32", "--paths=" + t.TempDir()}); code != 2 {
+		t.Errorf("Expected exit code 2 for an unsupported algorithm, got %d", code)
+	}
+}
+
+func TestHashAndVerify(t *testing.T) {
+	dir := t.TempDir()
+	first := filepath.Join(dir, "first.txt")
+	second := filepath.Join(dir, "second.txt")
+	third := filepath.Join(dir, "third.txt")
+	for path, content := range map[string]string{first: "hello\n", second: "world\n", third: "!\n"} {
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write file: %v", err)
+		}
+	}
+
+	var out bytes.Buffer
+	writer := manifest.NewWriter(&out, manifest.FormatCoreutils)
+	failed := hashFiles(&out, writer, processor.NewHashProcessor("sha256", 1), []string{first, filepath.Join(dir, "missing"), second}, logging.NewLogger("test"))
+	expected := "5891b5b522d5df086d0ff0b110fbd9d21bb4fc7163af34d08286a2e846f6be03  " + first + "\n" +
+		"e258d248fda94c63753607f7c4494ee0fcbe92f1a76bfdac795c9d84101eb317  " + second + "\n"
+	if failed != 1 || out.String() != expected {
+		t.Errorf("Expected one failure and output\n%s\ngot %d failures and\n%s", expected, failed, out.String())
+	}
+
+	// Mix in a BSD line for another algorithm
+	writer = manifest.NewWriter(&out, manifest.FormatBSD)
+	hashFiles(&out, writer, processor.NewHashProcessor("md5", 1), []string{third}, logging.NewLogger("test"))
+	entries, err := manifest.Parse(strings.NewReader(out.String()))
+	if err != nil {
+		t.Fatalf("Failed to parse hash output: %v", err)
+	}
+	if len(entries) != 3 || entries[2].Algorithm != "md5" {
+		t.Fatalf("Expected two sha256 entries and one md5 entry, got %+v", entries)
+	}
+
+	if err := os.WriteFile(second, []byte("changed\n"), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+	if err := os.Remove(third); err != nil {
+		t.Fatalf("Failed to remove file: %v", err)
+	}
+	out.Reset()
+	verifier := manifest.NewVerifier()
+	counts := printVerifyResults(&out, verifier.Verify(context.Background(), entries), false)
+	if counts[manifest.StatusOK] != 1 || counts[manifest.StatusFailed] != 1 || counts[manifest.StatusMissing] != 1 {
+		t.Errorf("Expected one file per status, got %v", counts)
+	}
+	if want := first + ": OK\n" + second + ": FAILED\n" + third + ": MISSING\n"; out.String() != want {
+		t.Errorf("Expected\n%s\ngot\n%s", want, out.String())
+	}
+}
+
+func TestHashAnalyzers(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "file.bin")
+	if err := os.WriteFile(path, []byte("abcd"), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+
+	hashProcessor := processor.NewHashProcessor("sha256", 1)
+	if _, err := setupAnalyzers(hashProcessor, "entropy", ""); err != nil {
+		t.Fatalf("Failed to set up analyzers: %v", err)
+	}
+	var out bytes.Buffer
+	if failed := hashFiles(&out, nil, hashProcessor, []string{path}, logging.NewLogger("test")); failed != 0 {
+		t.Fatalf("Expected no failures, got %d", failed)
+	}
+	var result struct {
+		Analysis map[string]struct {
+			Entropy float64 `json:"entropy"`
+		} `json:"analysis"`
+	}
+	if err := json.Unmarshal(out.Bytes(), &result); err != nil {
+		t.Fatalf("Failed to decode hash output %q: %v", out.String(), err)
+	}
+	if got := result.Analysis["entropy"].Entropy; got != 2 {
+		t.Errorf("Expected an entropy of 2 in the JSON output, got %s", out.String())
+	}
+
+	if code := dispatch([]string{"hash", "--analyzers=bogus", path}); code != 2 {
+		t.Errorf("Expected exit code 2 for an unknown analyzer, got %d", code)
+	}
+}
+
+func TestWriteCompletion(t *testing.T) {
+	for _, shell := range completionShells {
+		var out bytes.Buffer
+		if err := writeCompletion(&out, shell); err != nil {
+			t.Fatalf("%s: %v", shell, err)
+		}
+		for _, want := range append(commandNames(), "algorithm", "max-errors") {
+			if !strings.Contains(out.String(), want) {
+				t.Errorf("%s completion does not mention %q", shell, want)
+			}
+		}
+	}
+	if err := writeCompletion(&bytes.Buffer{}, "tcsh"); err == nil {
+		t.Error("Expected error for unsupported shell")
+	}
+}
+
+func TestResultOutputs(t *testing.T) {
+	dir := t.TempDir()
+	cfg := &config.Config{
+		CSVFile:         filepath.Join(dir, "results.csv"),
+		CSVColumns:      "path,size",
+		HTMLFile:        filepath.Join(dir, "results.html"),
+		ParsedRootPaths: []string{"/srv"},
+	}
+	outputs, err := newResultOutputs(cfg)
+	if err != nil {
+		t.Fatalf("Failed to create outputs: %v", err)
+	}
+
+	in := make(chan processor.FileResult, 2)
+	in <- processor.FileResult{Path: "/srv/a", Size: 1, Hash: "aa"}
+	in <- processor.FileResult{Path: "/srv/b", Size: 2, Hash: "bb"}
+	close(in)
+	passed := 0
+	for range outputs.Stage().Run(context.Background(), in) {
+		passed++
+	}
+	if err := outputs.Close(); err != nil {
+		t.Fatalf("Failed to close outputs: %v", err)
+	}
+	if passed != 2 {
+		t.Errorf("Expected results to pass through, got %d", passed)
+	}
+
+	csvData, _ := os.ReadFile(cfg.CSVFile)
+	if string(csvData) != "path,size\n/srv/a,1\n/srv/b,2\n" {
+		t.Errorf("Unexpected CSV %q", csvData)
+	}
+	htmlData, _ := os.ReadFile(cfg.HTMLFile)
+	if !strings.Contains(string(htmlData), "AgentFlux scan of /srv") {
+		t.Errorf("Expected HTML report titled after the scanned paths")
+	}
+
+	if outputs, err := newResultOutputs(&config.Config{}); outputs != nil || err != nil {
+		t.Errorf("Expected no outputs by default, got %v (%v)", outputs, err)
+	}
+}
@@ -0,0 +1,171 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+)
+
+// completionShells are the shells "agentflux completion" generates scripts for.
+var completionShells = []string{"bash", "zsh", "fish"}
+
+// runCompletion implements "agentflux completion <shell>", which prints a
+// completion script for the shell to stdout.
+func runCompletion(args []string) int {
+	if len(args) != 1 {
+		fmt.Fprintln(os.Stderr, "Usage: agentflux completion bash|zsh|fish")
+		return 2
+	}
+	if err := writeCompletion(os.Stdout, args[0]); err != nil {
+		fmt.Fprintf(os.Stderr, "agentflux: %v\n", err)
+		return 2
+	}
+	return 0
+}
+
+// writeCompletion writes the completion script for shell to w.
+func writeCompletion(w io.Writer, shell string) error {
+	switch shell {
+	case "bash":
+		writeBashCompletion(w)
+	case "zsh":
+		writeZshCompletion(w)
+	case "fish":
+		writeFishCompletion(w)
+	default:
+		return fmt.Errorf("unsupported shell %q (expected %s)", shell, strings.Join(completionShells, ", "))
+	}
+	return nil
+}
+
+// completionFlag is a flag offered by shell completion.
+type completionFlag struct {
+	name    string
+	usage   string
+	boolean bool
+}
+
+// completionFlags returns the flags of cmd sorted by name.
+func completionFlags(cmd *command) []completionFlag {
+	if cmd.flags == nil {
+		return nil
+	}
+	var flags []completionFlag
+	cmd.flags().VisitAll(func(f *flag.Flag) {
+		boolFlag, ok := f.Value.(interface{ IsBoolFlag() bool })
+		flags = append(flags, completionFlag{name: f.Name, usage: f.Usage, boolean: ok && boolFlag.IsBoolFlag()})
+	})
+	sort.Slice(flags, func(i, j int) bool { return flags[i].name < flags[j].name })
+	return flags
+}
+
+func writeBashCompletion(w io.Writer) {
+	fmt.Fprintf(w, "# bash completion for agentflux\n")
+	fmt.Fprintf(w, "_agentflux() {\n")
+	fmt.Fprintf(w, "    local cur=\"${COMP_WORDS[COMP_CWORD]}\"\n")
+	fmt.Fprintf(w, "    if [ \"$COMP_CWORD\" -eq 1 ]; then\n")
+	fmt.Fprintf(w, "        COMPREPLY=($(compgen -W \"%s\" -- \"$cur\"))\n", strings.Join(commandNames(), " "))
+	fmt.Fprintf(w, "        return\n")
+	fmt.Fprintf(w, "    fi\n")
+	fmt.Fprintf(w, "    local words=\"\"\n")
+	fmt.Fprintf(w, "    case \"${COMP_WORDS[1]}\" in\n")
+	for _, cmd := range commands {
+		var words []string
+		if len(cmd.words) > 0 {
+			words = append(words, cmd.words...)
+		}
+		for _, f := range completionFlags(cmd) {
+			words = append(words, "--"+f.name)
+		}
+		if len(words) == 0 {
+			continue
+		}
+		fmt.Fprintf(w, "        %s) words=\"%s\" ;;\n", cmd.name, strings.Join(words, " "))
+	}
+	fmt.Fprintf(w, "    esac\n")
+	fmt.Fprintf(w, "    COMPREPLY=($(compgen -W \"$words\" -- \"$cur\"))\n")
+	fmt.Fprintf(w, "}\n")
+	fmt.Fprintf(w, "complete -o default -F _agentflux agentflux\n")
+}
+
+func writeZshCompletion(w io.Writer) {
+	fmt.Fprintf(w, "#compdef agentflux\n\n")
+	fmt.Fprintf(w, "_agentflux() {\n")
+	fmt.Fprintf(w, "  local -a commands\n")
+	fmt.Fprintf(w, "  commands=(\n")
+	for _, cmd := range commands {
+		fmt.Fprintf(w, "    %s\n", zshQuote(cmd.name+":"+cmd.summary))
+	}
+	fmt.Fprintf(w, "  )\n")
+	fmt.Fprintf(w, "  if (( CURRENT == 2 )); then\n")
+	fmt.Fprintf(w, "    _describe 'command' commands\n")
+	fmt.Fprintf(w, "    return\n")
+	fmt.Fprintf(w, "  fi\n")
+	fmt.Fprintf(w, "  local cmd=$words[2]\n")
+	fmt.Fprintf(w, "  shift words\n")
+	fmt.Fprintf(w, "  (( CURRENT-- ))\n")
+	fmt.Fprintf(w, "  case $cmd in\n")
+	for _, cmd := range commands {
+		fmt.Fprintf(w, "    %s)\n", cmd.name)
+		fmt.Fprintf(w, "      _arguments -s")
+		for _, f := range completionFlags(cmd) {
+			spec := "--" + f.name + "[" + zshEscape(f.usage) + "]"
+			if !f.boolean {
+				spec = "--" + f.name + "=[" + zshEscape(f.usage) + "]:value:_files"
+			}
+			fmt.Fprintf(w, " \\\n        %s", zshQuote(spec))
+		}
+		switch {
+		case len(cmd.words) > 0:
+			fmt.Fprintf(w, " \\\n        %s", zshQuote("1:argument:("+strings.Join(cmd.words, " ")+")"))
+		case cmd.files:
+			fmt.Fprintf(w, " \\\n        %s", zshQuote("*:file:_files"))
+		}
+		fmt.Fprintf(w, "\n      ;;\n")
+	}
+	fmt.Fprintf(w, "  esac\n")
+	fmt.Fprintf(w, "}\n\n")
+	fmt.Fprintf(w, "_agentflux \"$@\"\n")
+}
+
+func writeFishCompletion(w io.Writer) {
+	fmt.Fprintf(w, "# fish completion for agentflux\n")
+	fmt.Fprintf(w, "complete -c agentflux -f\n")
+	for _, cmd := range commands {
+		fmt.Fprintf(w, "complete -c agentflux -n __fish_use_subcommand -a %s -d %s\n", cmd.name, fishQuote(cmd.summary))
+	}
+	for _, cmd := range commands {
+		condition := fishQuote("__fish_seen_subcommand_from " + cmd.name)
+		if len(cmd.words) > 0 {
+			fmt.Fprintf(w, "complete -c agentflux -n %s -a %s\n", condition, fishQuote(strings.Join(cmd.words, " ")))
+		} else if cmd.files {
+			fmt.Fprintf(w, "complete -c agentflux -n %s -F\n", condition)
+		}
+		for _, f := range completionFlags(cmd) {
+			requires := ""
+			if !f.boolean {
+				requires = " -r"
+			}
+			fmt.Fprintf(w, "complete -c agentflux -n %s -l %s -d %s%s\n", condition, f.name, fishQuote(f.usage), requires)
+		}
+	}
+}
+
+// zshQuote quotes s as a single-quoted zsh word.
+func zshQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// zshEscape escapes the characters _arguments treats specially in a
+// flag description.
+func zshEscape(s string) string {
+	return strings.NewReplacer("[", `\[`, "]", `\]`, ":", `\:`).Replace(s)
+}
+
+// fishQuote quotes s as a single-quoted fish word.
+func fishQuote(s string) string {
+	return "'" + strings.NewReplacer(`\`, `\\`, "'", `\'`).Replace(s) + "'"
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/vtriple/agentflux/pkg/common/config"
+	"github.com/vtriple/agentflux/pkg/common/logging"
+)
+
+// runConfigCommand implements "agentflux config print", which prints the
+// effective configuration after merging the flag defaults, config file,
+// environment and flags, with secrets redacted. It returns the process exit code.
+func runConfigCommand(args []string) int {
+	logger := logging.NewLogger("config")
+	if len(args) == 0 || args[0] != "print" {
+		fmt.Fprintln(os.Stderr, "Usage: agentflux config print [--config=FILE] [flags]")
+		return 2
+	}
+
+	cfg := &config.Config{}
+	fs := setUsage(newDaemonFlagSet("config print", cfg), "config")
+	sources, err := loadConfig(fs, cfg, args[1:])
+	if err != nil {
+		logger.Error("Failed to load configuration: %v", err)
+		return 1
+	}
+
+	values := effectiveValues(fs)
+	for key := range values {
+		if sources[key] == "" {
+			sources[key] = "default"
+		}
+	}
+
+	if cfg.ConfigFile != "" {
+		fmt.Printf("# Config file: %s\n", cfg.ConfigFile)
+	}
+	if err := config.WriteYAML(os.Stdout, values, sources); err != nil {
+		logger.Error("Failed to print configuration: %v", err)
+		return 1
+	}
+
+	// Report problems without failing, so incomplete configs can be inspected
+	if err := validateConfig(cfg); err != nil {
+		logger.Warn("Configuration is not valid for a scan: %v", err)
+	}
+	return 0
+}
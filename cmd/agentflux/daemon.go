@@ -0,0 +1,232 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sync"
+	"syscall"
+
+	"github.com/vtriple/agentflux/pkg/common/config"
+	"github.com/vtriple/agentflux/pkg/common/logging"
+	"github.com/vtriple/agentflux/pkg/scheduler"
+)
+
+// defaultProfile names the job that runs the top-level settings of a config
+// file without profiles.
+const defaultProfile = "default"
+
+// newDaemonFlagSet defines the scan flags plus the scheduling flags used by
+// "agentflux daemon".
+func newDaemonFlagSet(name string, cfg *config.Config) *flag.FlagSet {
+	fs := newFlagSet(name, cfg)
+	fs.StringVar(&cfg.Schedule, "schedule", "", "Cron expression, @hourly-style shorthand or interval (e.g. @every 6h) on which to run")
+	fs.DurationVar(&cfg.Jitter, "jitter", 0, "Maximum random delay added to each scheduled run")
+	fs.StringVar(&cfg.Window, "window", "", "Daily HH:MM-HH:MM window in which scheduled runs may start (empty for any time)")
+	fs.Float64Var(&cfg.MaxLoad, "max-load", 0, "Delay scheduled runs while the load average is above this (0 to disable)")
+	fs.StringVar(&cfg.StateFile, "state-file", defaultStateFile(), "Path to the file recording the status of scheduled runs")
+	return fs
+}
+
+// defaultStateFile returns the state file path in the user cache directory.
+func defaultStateFile() string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		dir = os.TempDir()
+	}
+	return filepath.Join(dir, "agentflux", "daemon-state.json")
+}
+
+// runDaemon implements "agentflux daemon", which runs the profiles of a
+// config file on their schedules until interrupted. Every profile with a
+// daemon.schedule becomes a job; --profile limits the daemon to one profile.
+// It returns the process exit code.
+func runDaemon(args []string) int {
+	logger := logging.NewLogger("daemon")
+
+	cfg := &config.Config{}
+	if _, err := loadConfig(setUsage(newDaemonFlagSet("daemon", cfg), "daemon"), cfg, args); err != nil {
+		logger.Error("Failed to load configuration: %v", err)
+		return 1
+	}
+	if cfg.ConfigFile == "" {
+		logger.Error("Daemon mode requires a config file (--config or $%s)", config.EnvConfigFile)
+		return 1
+	}
+	if err := configureLogging(cfg); err != nil {
+		logger.Error("Failed to configure logging: %v", err)
+		return 1
+	}
+	metricsServer, err := startMetrics(cfg, logger)
+	if err != nil {
+		logger.Error("Failed to start metrics listener: %v", err)
+		return 1
+	}
+	if metricsServer != nil {
+		defer metricsServer.Close()
+	}
+
+	file, err := config.LoadFile(cfg.ConfigFile)
+	if err != nil {
+		logger.Error("Failed to load configuration: %v", err)
+		return 1
+	}
+	names := file.ProfileNames()
+	switch {
+	case cfg.Profile != "":
+		names = []string{cfg.Profile}
+	case len(names) == 0:
+		// Without profiles the top-level settings form the only job
+		names = []string{""}
+	}
+
+	sched, err := scheduler.New(cfg.StateFile)
+	if err != nil {
+		logger.Error("Failed to start scheduler: %v", err)
+		return 1
+	}
+	sched.SetLogger(logging.NewLogger("scheduler"))
+
+	jobs := 0
+	for _, name := range names {
+		job, err := daemonJob(name, args)
+		if name == "" {
+			name = defaultProfile
+		}
+		if err != nil {
+			logger.Error("Invalid profile %s: %v", name, err)
+			return 1
+		}
+		if job == nil {
+			logger.Info("Profile %s has no schedule, skipping", name)
+			continue
+		}
+		if err := sched.Add(*job); err != nil {
+			logger.Error("Failed to schedule profile %s: %v", name, err)
+			return 1
+		}
+		jobs++
+	}
+	if jobs == 0 {
+		logger.Error("No profiles have a schedule; set daemon.schedule in %s", cfg.ConfigFile)
+		return 1
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	logger.Info("Daemon started with %d scheduled profiles, state in %s", jobs, cfg.StateFile)
+	if err := sched.Run(ctx); err != nil {
+		logger.Error("Scheduler error: %v", err)
+		return 1
+	}
+	logger.Info("Daemon stopped")
+	return 0
+}
+
+// daemonJob builds the scheduled job for a profile from the config file with
+// the profile applied and args on top. An empty profile name uses the
+// top-level settings. It returns nil if the profile has no schedule.
+func daemonJob(profile string, args []string) (*scheduler.Job, error) {
+	name := defaultProfile
+	if profile != "" {
+		name = profile
+		// A later --profile overrides any given in args
+		args = append(append([]string{}, args...), "--profile="+profile)
+	}
+
+	cfg := &config.Config{}
+	if _, err := loadConfig(newDaemonFlagSet(name, cfg), cfg, args); err != nil {
+		return nil, err
+	}
+	if cfg.Schedule == "" {
+		return nil, nil
+	}
+	if err := validateConfig(cfg); err != nil {
+		return nil, err
+	}
+
+	schedule, err := scheduler.ParseSchedule(cfg.Schedule)
+	if err != nil {
+		return nil, err
+	}
+	var window *scheduler.Window
+	if cfg.Window != "" {
+		if window, err = scheduler.ParseWindow(cfg.Window); err != nil {
+			return nil, err
+		}
+	}
+	if cfg.Jitter < 0 || cfg.MaxLoad < 0 {
+		return nil, fmt.Errorf("jitter and max load must not be negative")
+	}
+
+	logger := logging.NewLogger(name)
+	return &scheduler.Job{
+		Name:     name,
+		Schedule: schedule,
+		Jitter:   cfg.Jitter,
+		Window:   window,
+		MaxLoad:  cfg.MaxLoad,
+		Run: func(ctx context.Context) error {
+			runCfg, err := expandRunPaths(cfg)
+			if err != nil {
+				return err
+			}
+			if cfg.DedupStore != "" {
+				defer lockStore(cfg.DedupStore, logger)()
+			}
+			return run(ctx, runCfg, logger)
+		},
+	}, nil
+}
+
+// storeLocks serializes the runs of profiles sharing a dedup store, as a
+// store is compacted when opened, which loses the records another run
+// appends to the old file meanwhile.
+var storeLocks struct {
+	sync.Mutex
+	paths map[string]*sync.Mutex
+}
+
+// lockStore waits until no other run uses the dedup store at path and
+// returns the function releasing it.
+func lockStore(path string, logger *logging.Logger) func() {
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+	storeLocks.Lock()
+	if storeLocks.paths == nil {
+		storeLocks.paths = make(map[string]*sync.Mutex)
+	}
+	lock := storeLocks.paths[path]
+	if lock == nil {
+		lock = &sync.Mutex{}
+		storeLocks.paths[path] = lock
+	}
+	storeLocks.Unlock()
+
+	if !lock.TryLock() {
+		logger.Info("Waiting for another profile using the dedup store %s", path)
+		lock.Lock()
+	}
+	return lock.Unlock
+}
+
+// expandRunPaths returns a copy of cfg for one scheduled run with the path
+// patterns expanded again, so directories created since the daemon started,
+// such as new home directories, are scanned.
+func expandRunPaths(cfg *config.Config) (*config.Config, error) {
+	rootPaths, err := expandPaths(splitCSV(cfg.RootPaths))
+	if err != nil {
+		return nil, err
+	}
+	if len(rootPaths) == 0 {
+		return nil, fmt.Errorf("no paths match %s", cfg.RootPaths)
+	}
+	runCfg := *cfg
+	runCfg.ParsedRootPaths = rootPaths
+	return &runCfg, nil
+}
@@ -0,0 +1,227 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/vtriple/agentflux/pkg/common/config"
+	"github.com/vtriple/agentflux/pkg/dedup"
+)
+
+func TestDaemonJob(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "agentflux.yaml")
+	content := `
+api:
+  endpoint: https://api.example.com
+daemon:
+  jitter: 5m
+profiles:
+  hourly:
+    scanner:
+      paths: [/tmp]
+    daemon:
+      schedule: "@every 1h"
+      max_load: 4
+  nightly:
+    daemon:
+      schedule: "0 2 * * *"
+      window: 01:00-05:00
+  manual:
+    scanner:
+      paths: [/srv]
+  broken:
+    daemon:
+      schedule: "0 25 * * *"
+`
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write config: %v", err)
+	}
+	args := []string{"--config=" + path}
+
+	hourly, err := daemonJob("hourly", args)
+	if err != nil || hourly == nil {
+		t.Fatalf("Expected hourly job, got %v, %v", hourly, err)
+	}
+	if hourly.Name != "hourly" || hourly.Jitter != 5*time.Minute || hourly.MaxLoad != 4 || hourly.Window != nil {
+		t.Errorf("Unexpected hourly job %+v", hourly)
+	}
+	start := time.Date(2024, time.March, 15, 10, 0, 0, 0, time.UTC)
+	if next := hourly.Schedule.Next(start); !next.Equal(start.Add(time.Hour)) {
+		t.Errorf("Unexpected next hourly run %s", next)
+	}
+
+	nightly, err := daemonJob("nightly", args)
+	if err != nil || nightly == nil {
+		t.Fatalf("Expected nightly job, got %v, %v", nightly, err)
+	}
+	if nightly.Window == nil || nightly.Window.String() != "01:00-05:00" {
+		t.Errorf("Expected nightly window, got %v", nightly.Window)
+	}
+
+	if job, err := daemonJob("manual", args); job != nil || err != nil {
+		t.Errorf("Expected profile without schedule to be skipped, got %v, %v", job, err)
+	}
+	if _, err := daemonJob("broken", args); err == nil || !strings.Contains(err.Error(), "hour field") {
+		t.Errorf("Expected invalid schedule error, got %v", err)
+	}
+
+	// The schedule flag applies to every profile
+	manual, err := daemonJob("manual", append(args, "--schedule=@daily"))
+	if err != nil || manual == nil {
+		t.Fatalf("Expected manual job from --schedule, got %v, %v", manual, err)
+	}
+}
+
+func TestWatchQueuesPerProfile(t *testing.T) {
+	stopHourly := watchQueues("hourly", map[string]func() int{"files": func() int { return 3 }})
+	stopNightly := watchQueues("nightly", map[string]func() int{"files": func() int { return 7 }})
+	defer stopNightly()
+
+	if got := queueDepth.With("hourly", "files").Value(); got != 3 {
+		t.Errorf("Expected hourly queue depth 3, got %v", got)
+	}
+	if got := queueDepth.With("nightly", "files").Value(); got != 7 {
+		t.Errorf("Expected nightly queue depth 7, got %v", got)
+	}
+
+	// A finished run must not affect the gauges of another profile
+	stopHourly()
+	if got := queueDepth.With("hourly", "files").Value(); got != 0 {
+		t.Errorf("Expected detached queue depth 0, got %v", got)
+	}
+	if got := queueDepth.With("nightly", "files").Value(); got != 7 {
+		t.Errorf("Expected nightly queue depth 7 after hourly finished, got %v", got)
+	}
+}
+
+func TestExpandRunPaths(t *testing.T) {
+	home := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(home, "alice", "Downloads"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	// The daemon expands the pattern once at startup
+	cfg := &config.Config{RootPaths: filepath.Join(home, "*", "Downloads")}
+	cfg.ParsedRootPaths, _ = expandPaths(splitCSV(cfg.RootPaths))
+	if err := os.MkdirAll(filepath.Join(home, "bob", "Downloads"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	// A home directory created after the daemon started is scanned
+	runCfg, err := expandRunPaths(cfg)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	expected := []string{filepath.Join(home, "alice", "Downloads"), filepath.Join(home, "bob", "Downloads")}
+	if strings.Join(runCfg.ParsedRootPaths, ",") != strings.Join(expected, ",") {
+		t.Errorf("Expected %v, got %v", expected, runCfg.ParsedRootPaths)
+	}
+	if len(cfg.ParsedRootPaths) != 1 {
+		t.Errorf("Expected the daemon's config to be left unchanged, got %v", cfg.ParsedRootPaths)
+	}
+
+	if _, err := expandRunPaths(&config.Config{RootPaths: filepath.Join(home, "*", "Missing")}); err == nil {
+		t.Error("Expected an error when no paths match")
+	}
+}
+
+func TestDaemonProfilesShareStore(t *testing.T) {
+	// Slow deliveries keep the first run's store open when the second starts
+	var inFlight, maxInFlight int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&inFlight, 1)
+		defer atomic.AddInt32(&inFlight, -1)
+		for max := atomic.LoadInt32(&maxInFlight); n > max && !atomic.CompareAndSwapInt32(&maxInFlight, max, n); {
+			max = atomic.LoadInt32(&maxInFlight)
+		}
+		time.Sleep(300 * time.Millisecond)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	dir := t.TempDir()
+	files := 0
+	for _, profile := range []string{"first", "second"} {
+		if err := os.MkdirAll(filepath.Join(dir, profile), 0755); err != nil {
+			t.Fatal(err)
+		}
+		for i := 0; i < 20; i++ {
+			name := filepath.Join(dir, profile, fmt.Sprintf("file%d.txt", i))
+			if err := os.WriteFile(name, []byte(profile+name), 0644); err != nil {
+				t.Fatal(err)
+			}
+			files++
+		}
+	}
+	// A superseded record makes every run compact the store when opening it
+	store := filepath.Join(dir, "store.jsonl")
+	expires := time.Now().Add(time.Hour).Unix()
+	seed := fmt.Sprintf("{\"k\":\"old\",\"e\":%d}\n{\"k\":\"old\",\"e\":%d}\n", expires, expires)
+	if err := os.WriteFile(store, []byte(seed), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	path := filepath.Join(dir, "agentflux.yaml")
+	content := fmt.Sprintf(`
+api:
+  endpoint: %s
+dedup:
+  store: %s
+logging:
+  level: error
+progress:
+  interval: 0s
+profiles:
+  first:
+    scanner:
+      paths: [%s]
+    daemon:
+      schedule: "@every 1h"
+  second:
+    scanner:
+      paths: [%s]
+    daemon:
+      schedule: "@every 1h"
+`, server.URL, store, filepath.Join(dir, "first"), filepath.Join(dir, "second"))
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write config: %v", err)
+	}
+
+	var wg sync.WaitGroup
+	for _, profile := range []string{"first", "second"} {
+		job, err := daemonJob(profile, []string{"--config=" + path})
+		if err != nil || job == nil {
+			t.Fatalf("Expected %s job, got %v, %v", profile, job, err)
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := job.Run(context.Background()); err != nil {
+				t.Errorf("%s: unexpected error %v", profile, err)
+			}
+		}()
+		time.Sleep(100 * time.Millisecond)
+	}
+	wg.Wait()
+	if atomic.LoadInt32(&maxInFlight) != 1 {
+		t.Error("Expected the runs of profiles sharing a store not to overlap")
+	}
+
+	// Every delivered file must be recorded, whichever run compacted last
+	loaded, err := dedup.OpenFileStore(store, 0)
+	if err != nil {
+		t.Fatalf("Failed to open store: %v", err)
+	}
+	defer loaded.Close()
+	if loaded.Len() != files+1 {
+		t.Errorf("Expected %d keys in the shared store, got %d", files+1, loaded.Len())
+	}
+}
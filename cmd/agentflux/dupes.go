@@ -0,0 +1,132 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"runtime"
+	"strings"
+	"syscall"
+
+	"github.com/vtriple/agentflux/pkg/common/logging"
+	"github.com/vtriple/agentflux/pkg/dedup"
+	"github.com/vtriple/agentflux/pkg/processor"
+	"github.com/vtriple/agentflux/pkg/progress"
+	"github.com/vtriple/agentflux/pkg/scanner"
+)
+
+// dupesOptions holds the flags of "agentflux dupes".
+type dupesOptions struct {
+	paths     string
+	exclude   string
+	algorithm string
+	workers   int
+	depth     int
+	maxSize   int64
+	top       int
+	logLevel  string
+}
+
+// newDupesFlagSet defines the flags of "agentflux dupes".
+func newDupesFlagSet(opts *dupesOptions) *flag.FlagSet {
+	flags := setUsage(flag.NewFlagSet("dupes", flag.ContinueOnError), "dupes")
+	flags.StringVar(&opts.paths, "paths", ".", "Comma-separated list of paths to scan")
+	flags.StringVar(&opts.exclude, "exclude", "", "Comma-separated list of glob patterns to exclude")
+	flags.StringVar(&opts.algorithm, "algorithm", "sha256", "Hash algorithm (md5, sha1, sha256, sha512)")
+	flags.IntVar(&opts.workers, "workers", runtime.NumCPU(), "Number of worker goroutines")
+	flags.IntVar(&opts.depth, "depth", -1, "Maximum directory depth (-1 for unlimited)")
+	flags.Int64Var(&opts.maxSize, "max-size", 100*1024*1024, "Maximum file size to process in bytes")
+	flags.IntVar(&opts.top, "top", 20, "Number of duplicate groups to list (0 for all)")
+	flags.StringVar(&opts.logLevel, "log-level", "warn", "Log level (debug, info, warn, error), optionally with per-component overrides such as warn,scanner=debug")
+	return flags
+}
+
+// runDupes implements the "agentflux dupes" subcommand, which scans paths
+// locally and reports the largest groups of duplicate files. It returns the
+// process exit code. Every unique file is held in memory until the scan
+// ends, as a duplicate may be found at any point.
+func runDupes(args []string) int {
+	logger := logging.NewLogger("dupes")
+	opts := &dupesOptions{}
+	flags := newDupesFlagSet(opts)
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if err := logging.SetLevels(opts.logLevel); err != nil {
+		logger.Error("%v", err)
+		return 2
+	}
+
+	rootPaths := splitCSV(opts.paths)
+	if len(rootPaths) == 0 {
+		logger.Error("At least one path must be specified")
+		return 2
+	}
+	if algorithmDigestLength(opts.algorithm) == 0 {
+		logger.Error("Unsupported hash algorithm: %s", opts.algorithm)
+		return 2
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	fileScanner := scanner.NewFileScanner(ctx, rootPaths)
+	fileScanner.ExcludePaths = splitCSV(opts.exclude)
+	fileScanner.MaxDepth = opts.depth
+	fileScanner.MaxFileSize = opts.maxSize
+
+	hashProcessor := processor.NewHashProcessor(strings.ToLower(opts.algorithm), opts.workers)
+	hashProcessor.MaxFileSize = opts.maxSize
+
+	dedupEngine := dedup.NewDeduplicationEngine(dedup.HashDedup)
+	dedupEngine.Duplicates = dedup.FoldDuplicates
+
+	fileChannel, scanErrors := fileScanner.Scan()
+	go func() {
+		for err := range scanErrors {
+			logger.Warn("Scan error: %v", err)
+		}
+	}()
+
+	// Only results with duplicates form groups
+	var results []processor.FileResult
+	for result := range dedupEngine.Deduplicate(ctx, hashProcessor.Process(fileChannel)) {
+		if len(result.AlsoSeenAt) > 0 {
+			results = append(results, result)
+		}
+	}
+	if ctx.Err() != nil {
+		logger.Error("Scan interrupted")
+		return 1
+	}
+
+	total, _ := dedupEngine.GetStats()
+	printDuplicateGroups(os.Stdout, dedup.DuplicateGroups(results), total, opts.top)
+	return 0
+}
+
+// printDuplicateGroups writes the duplicate group report, listing at most top groups.
+func printDuplicateGroups(w io.Writer, groups []dedup.DuplicateGroup, totalFiles, top int) {
+	var wasted int64
+	var duplicates int
+	for _, group := range groups {
+		wasted += group.WastedBytes()
+		duplicates += len(group.Paths) - 1
+	}
+	fmt.Fprintf(w, "Scanned %d files: %d duplicate groups, %d redundant copies, %s wasted\n",
+		totalFiles, len(groups), duplicates, progress.FormatBytes(float64(wasted)))
+
+	if top > 0 && len(groups) > top {
+		groups = groups[:top]
+	}
+	for _, group := range groups {
+		fmt.Fprintf(w, "\n%s wasted: %d copies of %s (%s:%s)\n", progress.FormatBytes(float64(group.WastedBytes())),
+			len(group.Paths), progress.FormatBytes(float64(group.Size)), group.HashAlgorithm, group.Hash)
+		for _, path := range group.Paths {
+			fmt.Fprintf(w, "  %s\n", path)
+		}
+	}
+}
@@ -0,0 +1,318 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/vtriple/agentflux/pkg/api"
+	"github.com/vtriple/agentflux/pkg/common/config"
+	"github.com/vtriple/agentflux/pkg/common/fileutils"
+	"github.com/vtriple/agentflux/pkg/common/logging"
+	"github.com/vtriple/agentflux/pkg/dedup"
+	"github.com/vtriple/agentflux/pkg/execanalyzer"
+	"github.com/vtriple/agentflux/pkg/processor"
+	"github.com/vtriple/agentflux/pkg/progress"
+	"github.com/vtriple/agentflux/pkg/render"
+)
+
+// parseFlags parses command line flags, merges the config file and
+// environment, and validates the result.
+func parseFlags(args []string) (*config.Config, error) {
+	cfg := &config.Config{}
+	fs := setUsage(newFlagSet("scan", cfg), "scan")
+	if _, err := loadConfig(fs, cfg, args); err != nil {
+		return nil, err
+	}
+
+	// Handle version flag
+	if cfg.ShowVersion {
+		printVersion()
+		os.Exit(0)
+	}
+
+	if err := validateConfig(cfg); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// newFlagSet defines the scan flags on a new flag set that stores into cfg.
+func newFlagSet(name string, cfg *config.Config) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ExitOnError)
+	fs.StringVar(&cfg.ConfigFile, "config", "", "Path to a YAML, JSON or TOML config file (default $"+config.EnvConfigFile+")")
+	fs.StringVar(&cfg.Profile, "profile", "", "Name of a profile in the config file to apply")
+
+	// Basic options
+	fs.StringVar(&cfg.RootPaths, "paths", ".", "Comma-separated list of paths to scan")
+	fs.StringVar(&cfg.ExcludePaths, "exclude", "", "Comma-separated list of glob patterns to exclude")
+	fs.StringVar(&cfg.HashAlgorithm, "algorithm", "sha256", "Hash algorithm (md5, sha1, sha256, sha512)")
+	fs.IntVar(&cfg.WorkerCount, "workers", runtime.NumCPU(), "Number of worker goroutines")
+	fs.IntVar(&cfg.MaxDepth, "depth", -1, "Maximum directory depth (-1 for unlimited)")
+
+	// API options
+	fs.StringVar(&cfg.APIEndpoint, "api", "", "API endpoint URL")
+	fs.StringVar(&cfg.APIToken, "token", "", "API authentication token (literal, env:NAME or file:/path)")
+	fs.StringVar(&cfg.APITokenFile, "token-file", "", "Path to a file containing the API authentication token")
+	fs.StringVar(&cfg.APIAuthMethod, "auth-method", "bearer", "API auth method (bearer, basic, api-key, oauth2, hmac)")
+	fs.IntVar(&cfg.APIBatchSize, "batch", 100, "API batch size")
+	fs.StringVar(&cfg.EventsEndpoint, "events-endpoint", "", "URL for scan lifecycle events (empty to disable)")
+	fs.DurationVar(&cfg.HeartbeatInterval, "heartbeat", api.DefaultHeartbeatInterval, "Interval between heartbeat events (0 to disable)")
+	fs.Var((*stringListFlag)(&cfg.Labels), "label", "Label attached to every batch as key=value (repeatable or comma-separated)")
+
+	// OAuth2 options
+	fs.StringVar(&cfg.OAuthTokenURL, "oauth-token-url", "", "OAuth2 token endpoint URL")
+	fs.StringVar(&cfg.OAuthClientID, "oauth-client-id", "", "OAuth2 client ID (literal, env:NAME or file:/path)")
+	fs.StringVar(&cfg.OAuthClientSecret, "oauth-client-secret", "", "OAuth2 client secret (literal, env:NAME or file:/path)")
+	fs.StringVar(&cfg.OAuthScopes, "oauth-scopes", "", "Comma-separated list of OAuth2 scopes")
+
+	// HMAC signing options
+	fs.StringVar(&cfg.HMACKeyID, "hmac-key-id", "", "Key ID for HMAC request signing (the token is the shared secret)")
+
+	// String extraction options
+	fs.BoolVar(&cfg.ExtractStrings, "strings", false, "Extract strings from files")
+	fs.IntVar(&cfg.StringMinLength, "string-min", 4, "Minimum string length to extract")
+
+	// Analyzer options
+	fs.StringVar(&cfg.Analyzers, "analyzers", "", "Comma-separated analyzers to run on file content, from "+strings.Join(processor.DefaultAnalyzers.Names(), ", "))
+	fs.DurationVar(&cfg.AnalyzerTimeout, "analyzer-timeout", processor.DefaultAnalyzerTimeout, "Time each analyzer may take for one file")
+	fs.StringVar(&cfg.ExecAnalyzers, "exec-analyzers", "", "Path to a JSON file defining external command analyzers (empty to disable)")
+
+	// Deduplication options
+	fs.StringVar(&cfg.DedupType, "dedup", string(dedup.HashDedup), "What makes two files duplicates (hash, path, name, none, composite)")
+	fs.StringVar(&cfg.DedupKey, "dedup-key", "", "Composite key expression for --dedup=composite, e.g. hash+name or hash+dir")
+	fs.StringVar(&cfg.DedupStore, "dedup-store", "", "Path to a persistent store of delivered files to skip in later runs (empty to disable)")
+	fs.DurationVar(&cfg.DedupTTL, "dedup-ttl", dedup.DefaultStoreTTL, "How long delivered files are remembered by the dedup store")
+	fs.StringVar(&cfg.DedupMode, "dedup-mode", string(dedup.ModeExact), "How seen files are remembered (exact, bloom, lru)")
+	fs.StringVar(&cfg.DedupMemory, "dedup-memory", "256MB", "Memory budget for bloom and lru dedup modes")
+	fs.Float64Var(&cfg.DedupFPRate, "dedup-fp-rate", dedup.DefaultFalsePositiveRate, "Target false-positive rate for bloom dedup mode")
+	fs.StringVar(&cfg.Duplicates, "duplicates", string(dedup.DropDuplicates), "What to do with duplicates: drop, fold into alsoSeenAt (holds every unique result in memory until the scan ends, so only with --dedup-mode=exact), or send as sighting records")
+
+	// File processing options
+	fs.Int64Var(&cfg.MaxFileSize, "max-size", 100*1024*1024, "Maximum file size to process in bytes")
+	fs.IntVar(&cfg.MaxErrors, "max-errors", -1, "Scan and file errors tolerated before the run exits with code 3 (-1 for no limit)")
+	fs.StringVar(&cfg.LogLevel, "log-level", "info", "Log level (debug, info, warn, error), optionally with per-component overrides such as info,api=debug")
+	fs.StringVar(&cfg.LogFile, "log-file", "", "Path to log file (empty for stderr)")
+	fs.StringVar(&cfg.LogFormat, "log-format", string(logging.FormatText), "Log format (text, json, logfmt)")
+	fs.StringVar(&cfg.LogOutput, "log-output", "", "Log destination: stderr, file, syslog, syslog+udp://host:port, syslog+tcp://host:port, syslog+unix:///path or journald (default file if --log-file is set, else stderr)")
+	fs.StringVar(&cfg.LogMaxSize, "log-max-size", "0", "Rotate the log file when it reaches this size, e.g. 100MB (0 to disable)")
+	fs.DurationVar(&cfg.LogMaxAge, "log-max-age", 0, "Rotate the log file after this long, e.g. 24h (0 to disable)")
+	fs.IntVar(&cfg.LogMaxBackups, "log-max-backups", 5, "Number of rotated log files to keep (0 to keep all)")
+	fs.BoolVar(&cfg.LogCompress, "log-compress", false, "Gzip rotated log files")
+
+	// Report options
+	fs.StringVar(&cfg.ReportFile, "report", "", "Path to write a JSON report of the run to (empty to disable)")
+	fs.StringVar(&cfg.CSVFile, "csv", "", "Path to write the unique results to as CSV (empty to disable)")
+	fs.StringVar(&cfg.CSVColumns, "csv-columns", strings.Join(render.DefaultColumns, ","), "Comma-separated CSV columns, from "+strings.Join(render.ColumnNames(), ", "))
+	fs.StringVar(&cfg.HTMLFile, "html", "", "Path to write an HTML report of the unique results to (empty to disable)")
+
+	// Progress options
+	fs.BoolVar(&cfg.Estimate, "estimate", false, "Count files in a fast first pass so progress shows a percentage and ETA")
+	fs.DurationVar(&cfg.ProgressInterval, "progress-interval", progress.DefaultInterval, "Interval between progress log lines; a status line is shown instead when stderr is a terminal (0 to disable)")
+	fs.IntVar(&cfg.ProgressFD, "progress-fd", 0, "File descriptor to write JSON progress records to, e.g. 3 (0 to disable)")
+
+	// Metrics options
+	fs.StringVar(&cfg.MetricsAddr, "metrics-addr", "", "Address to serve Prometheus metrics on at /metrics, e.g. :9090 (empty to disable)")
+
+	// Misc options
+	fs.BoolVar(&cfg.ShowVersion, "version", false, "Show version information")
+
+	return fs
+}
+
+// loadConfig parses args and merges in settings from the config file, the
+// selected profile and AGENTFLUX_* environment variables. Flags take
+// precedence over the environment, then the profile, the rest of the config
+// file and finally the flag defaults. It returns where each setting that is
+// not a default came from.
+func loadConfig(fs *flag.FlagSet, cfg *config.Config, args []string) (map[string]string, error) {
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+
+	explicit := map[string]bool{}
+	fs.Visit(func(f *flag.Flag) { explicit[f.Name] = true })
+
+	merged := config.Values{}
+	sources := map[string]string{}
+	if cfg.ConfigFile == "" {
+		cfg.ConfigFile = os.Getenv(config.EnvConfigFile)
+	}
+	if cfg.ConfigFile != "" {
+		file, err := config.LoadFile(cfg.ConfigFile)
+		if err != nil {
+			return nil, err
+		}
+		fileValues, err := file.Profile(cfg.Profile)
+		if err != nil {
+			return nil, err
+		}
+		profileValues := file.Profiles[cfg.Profile]
+		for key, value := range fileValues {
+			merged[key] = value
+			sources[key] = "file"
+			if _, ok := profileValues[key]; ok {
+				sources[key] = "profile " + cfg.Profile
+			}
+		}
+	} else if cfg.Profile != "" {
+		return nil, fmt.Errorf("--profile requires a config file")
+	}
+	for key, value := range config.EnvValues(os.LookupEnv) {
+		merged[key] = value
+		sources[key] = "env"
+	}
+
+	for _, setting := range config.Settings {
+		if fs.Lookup(setting.Flag) == nil {
+			continue
+		}
+		if explicit[setting.Flag] {
+			sources[setting.Key] = "flag"
+			continue
+		}
+		value, ok := merged[setting.Key]
+		if !ok {
+			continue
+		}
+		if err := fs.Set(setting.Flag, value); err != nil {
+			return nil, fmt.Errorf("invalid %s from %s: %w", setting.Key, sources[setting.Key], err)
+		}
+	}
+	cfg.ConfigHash = effectiveValues(fs).Hash()
+	return sources, nil
+}
+
+// effectiveValues returns the current value of every setting in fs.
+func effectiveValues(fs *flag.FlagSet) config.Values {
+	values := config.Values{}
+	for _, setting := range config.Settings {
+		if f := fs.Lookup(setting.Flag); f != nil {
+			values[setting.Key] = f.Value.String()
+		}
+	}
+	return values
+}
+
+// validateConfig checks the merged configuration and fills in parsed fields.
+func validateConfig(cfg *config.Config) error {
+	// Validate arguments
+	if cfg.APIEndpoint == "" {
+		return fmt.Errorf("API endpoint is required")
+	}
+
+	// Validate hash algorithm
+	validAlgs := map[string]bool{"md5": true, "sha1": true, "sha256": true, "sha512": true}
+	if !validAlgs[strings.ToLower(cfg.HashAlgorithm)] {
+		return fmt.Errorf("unsupported hash algorithm: %s", cfg.HashAlgorithm)
+	}
+
+	// Parse root paths
+	rootPaths, err := expandPaths(splitCSV(cfg.RootPaths))
+	if err != nil {
+		return err
+	}
+	cfg.ParsedRootPaths = rootPaths
+	if len(cfg.ParsedRootPaths) == 0 {
+		return fmt.Errorf("at least one path must be specified")
+	}
+
+	// Parse exclude paths
+	cfg.ParsedExcludePaths = splitCSV(cfg.ExcludePaths)
+
+	// Validate analyzers
+	if _, err := processor.DefaultAnalyzers.Select(splitCSV(cfg.Analyzers)); err != nil {
+		return err
+	}
+	if cfg.AnalyzerTimeout < 0 {
+		return fmt.Errorf("analyzer timeout must not be negative")
+	}
+	if cfg.ExecAnalyzers != "" {
+		if _, err := execanalyzer.LoadFile(cfg.ExecAnalyzers); err != nil {
+			return err
+		}
+	}
+
+	// Validate labels
+	if _, err := parseLabels(cfg.Labels); err != nil {
+		return err
+	}
+
+	// Validate deduplication options
+	if _, err := newDedupEngine(cfg); err != nil {
+		return err
+	}
+	mode, err := dedup.ParseMode(cfg.DedupMode)
+	if err != nil {
+		return err
+	}
+	memory, err := fileutils.ParseSize(cfg.DedupMemory)
+	if err != nil || memory <= 0 {
+		return fmt.Errorf("invalid dedup memory: %s", cfg.DedupMemory)
+	}
+	cfg.ParsedDedupMemory = memory
+	if cfg.DedupFPRate <= 0 || cfg.DedupFPRate >= 1 {
+		return fmt.Errorf("dedup false-positive rate must be between 0 and 1")
+	}
+	duplicates, err := dedup.ParseDuplicateHandling(cfg.Duplicates)
+	if err != nil {
+		return err
+	}
+	// Folding holds every unique result, which would undo the memory bound
+	if duplicates == dedup.FoldDuplicates && mode != dedup.ModeExact {
+		return fmt.Errorf("--duplicates=fold cannot be combined with --dedup-mode=%s, as it holds every unique result in memory", mode)
+	}
+
+	// Validate logging options
+	if _, err := logging.ParseFormat(cfg.LogFormat); err != nil {
+		return err
+	}
+	if _, _, err := logging.ParseLevels(cfg.LogLevel); err != nil {
+		return err
+	}
+	if _, err := logRotateOptions(cfg); err != nil {
+		return err
+	}
+	if err := validateLogOutput(cfg); err != nil {
+		return err
+	}
+
+	// Validate result outputs
+	if _, err := render.ParseColumns(cfg.CSVColumns); err != nil {
+		return err
+	}
+
+	if cfg.MaxErrors < -1 {
+		return fmt.Errorf("max errors must be -1 (no limit) or more")
+	}
+
+	// Validate progress options
+	if cfg.ProgressInterval < 0 {
+		return fmt.Errorf("progress interval must not be negative")
+	}
+	if cfg.ProgressFD < 0 {
+		return fmt.Errorf("invalid progress file descriptor: %d", cfg.ProgressFD)
+	}
+	return nil
+}
+
+// expandPaths expands glob patterns such as /home/*/Downloads in root paths.
+// Patterns that match nothing are dropped; plain paths are kept as given.
+func expandPaths(paths []string) ([]string, error) {
+	var expanded []string
+	for _, path := range paths {
+		if !strings.ContainsAny(path, "*?[") {
+			expanded = append(expanded, path)
+			continue
+		}
+		matches, err := filepath.Glob(path)
+		if err != nil {
+			return nil, fmt.Errorf("invalid path pattern %q: %w", path, err)
+		}
+		expanded = append(expanded, matches...)
+	}
+	return expanded, nil
+}
@@ -0,0 +1,321 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/vtriple/agentflux/pkg/common/config"
+	"github.com/vtriple/agentflux/pkg/report"
+)
+
+func TestLoadConfigPrecedence(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "agentflux.yaml")
+	content := `
+scanner:
+  paths: [/srv, /home]
+  depth: 3
+processor:
+  workers: 8
+api:
+  endpoint: https://file.example.com
+  labels:
+    env: prod
+`
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write config: %v", err)
+	}
+	t.Setenv(config.EnvConfigFile, path)
+	t.Setenv("AGENTFLUX_PROCESSOR_WORKERS", "2")
+	t.Setenv("AGENTFLUX_SCANNER_DEPTH", "5")
+
+	cfg := &config.Config{}
+	fs := newFlagSet("test", cfg)
+	sources, err := loadConfig(fs, cfg, []string{"--depth=7", "--label=team=sec"})
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+
+	if cfg.RootPaths != "/srv,/home" || sources["scanner.paths"] != "file" {
+		t.Errorf("Expected paths from file, got %q (%s)", cfg.RootPaths, sources["scanner.paths"])
+	}
+	if cfg.WorkerCount != 2 || sources["processor.workers"] != "env" {
+		t.Errorf("Expected env to override file, got %d (%s)", cfg.WorkerCount, sources["processor.workers"])
+	}
+	if cfg.MaxDepth != 7 || sources["scanner.depth"] != "flag" {
+		t.Errorf("Expected flag to override env, got %d (%s)", cfg.MaxDepth, sources["scanner.depth"])
+	}
+	if strings.Join(cfg.Labels, ",") != "team=sec" {
+		t.Errorf("Expected labels from flag only, got %v", cfg.Labels)
+	}
+	if cfg.HashAlgorithm != "sha256" || sources["processor.algorithm"] != "" {
+		t.Errorf("Expected default algorithm, got %q (%s)", cfg.HashAlgorithm, sources["processor.algorithm"])
+	}
+	if err := validateConfig(cfg); err != nil {
+		t.Errorf("Expected merged config to be valid: %v", err)
+	}
+}
+
+func TestLoadConfigInvalidValue(t *testing.T) {
+	t.Setenv("AGENTFLUX_PROCESSOR_WORKERS", "many")
+
+	cfg := &config.Config{}
+	_, err := loadConfig(newFlagSet("test", cfg), cfg, nil)
+	if err == nil || !strings.Contains(err.Error(), "processor.workers from env") {
+		t.Errorf("Expected error naming the setting and source, got %v", err)
+	}
+}
+
+func TestSettingsMatchFlags(t *testing.T) {
+	fs := newDaemonFlagSet("test", &config.Config{})
+	for _, setting := range config.Settings {
+		if fs.Lookup(setting.Flag) == nil {
+			t.Errorf("Setting %s refers to unknown flag --%s", setting.Key, setting.Flag)
+		}
+	}
+}
+
+func TestLoadConfigProfile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "agentflux.yaml")
+	content := `
+api:
+  endpoint: https://api.example.com
+processor:
+  workers: 8
+profiles:
+  hourly:
+    scanner:
+      paths: [/tmp]
+    processor:
+      workers: 2
+  nightly:
+    processor:
+      strings: true
+`
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write config: %v", err)
+	}
+	t.Setenv("AGENTFLUX_SCANNER_PATHS", "/var/tmp")
+
+	cfg := &config.Config{}
+	sources, err := loadConfig(newFlagSet("test", cfg), cfg, []string{"--config=" + path, "--profile=hourly"})
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+	if cfg.WorkerCount != 2 || sources["processor.workers"] != "profile hourly" {
+		t.Errorf("Expected profile to override file, got %d (%s)", cfg.WorkerCount, sources["processor.workers"])
+	}
+	if cfg.RootPaths != "/var/tmp" || sources["scanner.paths"] != "env" {
+		t.Errorf("Expected env to override profile, got %q (%s)", cfg.RootPaths, sources["scanner.paths"])
+	}
+	if cfg.APIEndpoint != "https://api.example.com" || cfg.ExtractStrings {
+		t.Errorf("Expected shared settings without other profiles, got %+v", cfg)
+	}
+
+	cfg = &config.Config{}
+	if _, err := loadConfig(newFlagSet("test", cfg), cfg, []string{"--config=" + path, "--profile=weekly"}); err == nil {
+		t.Error("Expected error for unknown profile")
+	}
+	cfg = &config.Config{}
+	if _, err := loadConfig(newFlagSet("test", cfg), cfg, []string{"--profile=hourly"}); err == nil {
+		t.Error("Expected error for profile without config file")
+	}
+}
+
+func TestExpandPaths(t *testing.T) {
+	dir := t.TempDir()
+	for _, user := range []string{"alice", "bob"} {
+		if err := os.MkdirAll(filepath.Join(dir, user, "Downloads"), 0755); err != nil {
+			t.Fatalf("Failed to create directory: %v", err)
+		}
+	}
+
+	paths, err := expandPaths([]string{"/tmp", filepath.Join(dir, "*", "Downloads"), filepath.Join(dir, "nobody*")})
+	if err != nil {
+		t.Fatalf("Failed to expand paths: %v", err)
+	}
+	expected := []string{"/tmp", filepath.Join(dir, "alice", "Downloads"), filepath.Join(dir, "bob", "Downloads")}
+	if strings.Join(paths, ",") != strings.Join(expected, ",") {
+		t.Errorf("Expected %v, got %v", expected, paths)
+	}
+}
+
+func TestValidateConfigLogging(t *testing.T) {
+	tests := []struct {
+		args    []string
+		errText string
+	}{
+		{args: []string{"--log-level=info,api=debug", "--log-max-size=10MB", "--log-compress"}},
+		{args: []string{"--log-level=info,api=loud"}, errText: "invalid log level"},
+		{args: []string{"--log-max-size=lots"}, errText: "invalid log max size"},
+		{args: []string{"--log-max-backups=-1"}, errText: "must not be negative"},
+		{args: []string{"--log-output=syslog+udp://logs.example.com:514"}},
+		{args: []string{"--log-output=journald"}},
+		{args: []string{"--log-output=syslog+udp://logs.example.com"}, errText: "invalid syslog destination"},
+		{args: []string{"--log-output=file"}, errText: "requires --log-file"},
+		{args: []string{"--log-output=kafka"}, errText: "unsupported log output"},
+	}
+
+	for _, tt := range tests {
+		cfg := &config.Config{}
+		args := append([]string{"--api=https://api.example.com"}, tt.args...)
+		if _, err := loadConfig(newFlagSet("test", cfg), cfg, args); err != nil {
+			t.Fatalf("Failed to load config: %v", err)
+		}
+		err := validateConfig(cfg)
+		if tt.errText == "" && err != nil {
+			t.Errorf("%v: unexpected error %v", tt.args, err)
+		}
+		if tt.errText != "" && (err == nil || !strings.Contains(err.Error(), tt.errText)) {
+			t.Errorf("%v: expected error containing %q, got %v", tt.args, tt.errText, err)
+		}
+	}
+}
+
+func TestValidateConfigProgress(t *testing.T) {
+	tests := []struct {
+		args    []string
+		errText string
+	}{
+		{args: []string{"--estimate", "--progress-interval=0", "--progress-fd=3"}},
+		{args: []string{"--progress-interval=-1s"}, errText: "must not be negative"},
+		{args: []string{"--progress-fd=-1"}, errText: "invalid progress file descriptor"},
+	}
+
+	for _, tt := range tests {
+		cfg := &config.Config{}
+		args := append([]string{"--api=https://api.example.com"}, tt.args...)
+		if _, err := loadConfig(newFlagSet("test", cfg), cfg, args); err != nil {
+			t.Fatalf("Failed to load config: %v", err)
+		}
+		err := validateConfig(cfg)
+		if tt.errText == "" && err != nil {
+			t.Errorf("%v: unexpected error %v", tt.args, err)
+		}
+		if tt.errText != "" && (err == nil || !strings.Contains(err.Error(), tt.errText)) {
+			t.Errorf("%v: expected error containing %q, got %v", tt.args, tt.errText, err)
+		}
+	}
+}
+
+func TestValidateConfigOutputs(t *testing.T) {
+	tests := []struct {
+		args    []string
+		errText string
+	}{
+		{args: []string{"--csv=out.csv", "--csv-columns=path,size,owner", "--html=out.html"}},
+		{args: []string{"--csv-columns=path,colour"}, errText: "unknown CSV column"},
+	}
+
+	for _, tt := range tests {
+		cfg := &config.Config{}
+		args := append([]string{"--api=https://api.example.com"}, tt.args...)
+		if _, err := loadConfig(newFlagSet("test", cfg), cfg, args); err != nil {
+			t.Fatalf("Failed to load config: %v", err)
+		}
+		err := validateConfig(cfg)
+		if tt.errText == "" && err != nil {
+			t.Errorf("%v: unexpected error %v", tt.args, err)
+		}
+		if tt.errText != "" && (err == nil || !strings.Contains(err.Error(), tt.errText)) {
+			t.Errorf("%v: expected error containing %q, got %v", tt.args, tt.errText, err)
+		}
+	}
+}
+
+func TestValidateConfigDuplicates(t *testing.T) {
+	tests := []struct {
+		args    []string
+		errText string
+	}{
+		{args: []string{"--duplicates=fold"}},
+		{args: []string{"--duplicates=sighting", "--dedup-mode=bloom"}},
+		{args: []string{"--duplicates=fold", "--dedup-mode=lru"}, errText: "cannot be combined with --dedup-mode=lru"},
+		{args: []string{"--duplicates=fold", "--dedup-mode=bloom"}, errText: "cannot be combined with --dedup-mode=bloom"},
+	}
+
+	for _, tt := range tests {
+		cfg := &config.Config{}
+		args := append([]string{"--api=https://api.example.com"}, tt.args...)
+		if _, err := loadConfig(newFlagSet("test", cfg), cfg, args); err != nil {
+			t.Fatalf("Failed to load config: %v", err)
+		}
+		err := validateConfig(cfg)
+		if tt.errText == "" && err != nil {
+			t.Errorf("%v: unexpected error %v", tt.args, err)
+		}
+		if tt.errText != "" && (err == nil || !strings.Contains(err.Error(), tt.errText)) {
+			t.Errorf("%v: expected error containing %q, got %v", tt.args, tt.errText, err)
+		}
+	}
+}
+
+func TestValidateConfigAnalyzers(t *testing.T) {
+	dir := t.TempDir()
+	specs := filepath.Join(dir, "analyzers.json")
+	badSpecs := filepath.Join(dir, "bad.json")
+	if err := os.WriteFile(specs, []byte(`[{"name": "size", "command": ["wc", "-c"], "input": "content"}]`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(badSpecs, []byte(`[{"name": "size"}]`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		args    []string
+		errText string
+	}{
+		{args: []string{"--analyzers=entropy", "--analyzer-timeout=5s"}},
+		{args: []string{"--analyzers=entropy,magic"}, errText: "unknown analyzer: magic"},
+		{args: []string{"--analyzer-timeout=-1s"}, errText: "must not be negative"},
+		{args: []string{"--exec-analyzers=" + specs}},
+		{args: []string{"--exec-analyzers=" + badSpecs}, errText: "needs a command"},
+		{args: []string{"--exec-analyzers=" + filepath.Join(dir, "missing.json")}, errText: "failed to read analyzer specs"},
+	}
+
+	for _, tt := range tests {
+		cfg := &config.Config{}
+		args := append([]string{"--api=https://api.example.com"}, tt.args...)
+		if _, err := loadConfig(newFlagSet("test", cfg), cfg, args); err != nil {
+			t.Fatalf("Failed to load config: %v", err)
+		}
+		err := validateConfig(cfg)
+		if tt.errText == "" && err != nil {
+			t.Errorf("%v: unexpected error %v", tt.args, err)
+		}
+		if tt.errText != "" && (err == nil || !strings.Contains(err.Error(), tt.errText)) {
+			t.Errorf("%v: expected error containing %q, got %v", tt.args, tt.errText, err)
+		}
+	}
+}
+
+func TestRunOutcome(t *testing.T) {
+	tests := []struct {
+		maxErrors   int
+		scanErrors  int
+		failBatches int
+		code        int
+	}{
+		{maxErrors: -1, scanErrors: 5, code: report.ExitOK},
+		{maxErrors: 5, scanErrors: 5, code: report.ExitOK},
+		{maxErrors: 4, scanErrors: 5, code: report.ExitTooManyErrors},
+		{maxErrors: 0, scanErrors: 1, failBatches: 1, code: report.ExitPartialDelivery},
+	}
+	for _, tt := range tests {
+		collector := report.NewCollector(Version, "")
+		for i := 0; i < tt.scanErrors; i++ {
+			collector.ScanError(errors.New("error reading directory"))
+		}
+		for i := 0; i < tt.failBatches; i++ {
+			collector.DeliveryError(errors.New("status=500"))
+		}
+		collector.Delivered(10)
+
+		err := runOutcome(&config.Config{MaxErrors: tt.maxErrors}, collector)
+		if got := report.ExitCode(err); got != tt.code {
+			t.Errorf("%+v: exit code %d (%v), want %d", tt, got, err, tt.code)
+		}
+	}
+}
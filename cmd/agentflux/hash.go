@@ -0,0 +1,262 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"runtime"
+	"sort"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/vtriple/agentflux/pkg/common/logging"
+	"github.com/vtriple/agentflux/pkg/execanalyzer"
+	"github.com/vtriple/agentflux/pkg/manifest"
+	"github.com/vtriple/agentflux/pkg/processor"
+)
+
+// hashOptions holds the flags of "agentflux hash".
+type hashOptions struct {
+	algorithm       string
+	format          string
+	strings         bool
+	stringMin       int
+	analyzers       string
+	analyzerTimeout time.Duration
+	execAnalyzers   string
+	maxSize         int64
+	logLevel        string
+}
+
+// newHashFlagSet defines the flags of "agentflux hash".
+func newHashFlagSet(opts *hashOptions) *flag.FlagSet {
+	fs := setUsage(flag.NewFlagSet("hash", flag.ContinueOnError), "hash")
+	fs.StringVar(&opts.algorithm, "algorithm", "sha256", "Hash algorithm (md5, sha1, sha256, sha512)")
+	fs.StringVar(&opts.format, "format", string(manifest.FormatCoreutils), "Output format: coreutils (HASH  PATH, as sha256sum), bsd (SHA256 (PATH) = HASH) or json (a result per line with size, owner and other details)")
+	fs.BoolVar(&opts.strings, "strings", false, "Extract strings from files (shown with --format=json)")
+	fs.IntVar(&opts.stringMin, "string-min", 4, "Minimum string length to extract")
+	fs.StringVar(&opts.analyzers, "analyzers", "", "Comma-separated analyzers to run on file content (shown with --format=json), from "+strings.Join(processor.DefaultAnalyzers.Names(), ", "))
+	fs.DurationVar(&opts.analyzerTimeout, "analyzer-timeout", processor.DefaultAnalyzerTimeout, "Time each analyzer may take for one file")
+	fs.StringVar(&opts.execAnalyzers, "exec-analyzers", "", "Path to a JSON file defining external command analyzers (empty to disable)")
+	fs.Int64Var(&opts.maxSize, "max-size", 0, "Skip files larger than this many bytes (0 for no limit)")
+	fs.StringVar(&opts.logLevel, "log-level", "warn", "Log level (debug, info, warn, error)")
+	return fs
+}
+
+// runHash implements "agentflux hash", which prints the hash of each file in
+// the format of sha256sum or BSD cksum so the output can be checked with
+// "agentflux verify" or coreutils. It returns the process exit code.
+func runHash(args []string) int {
+	logger := logging.NewLogger("hash")
+	opts := &hashOptions{}
+	fs := newHashFlagSet(opts)
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if err := logging.SetLevels(opts.logLevel); err != nil {
+		logger.Error("%v", err)
+		return 2
+	}
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return 2
+	}
+	if algorithmDigestLength(opts.algorithm) == 0 {
+		logger.Error("Unsupported hash algorithm: %s", opts.algorithm)
+		return 2
+	}
+	var writer *manifest.Writer
+	if opts.format != "json" {
+		format, err := manifest.ParseFormat(opts.format)
+		if err != nil {
+			logger.Error("%v", err)
+			return 2
+		}
+		writer = manifest.NewWriter(os.Stdout, format)
+	}
+
+	hashProcessor := processor.NewHashProcessor(strings.ToLower(opts.algorithm), 1)
+	hashProcessor.ExtractStrings = opts.strings
+	hashProcessor.StringMinLength = opts.stringMin
+	hashProcessor.SkipLargeFiles = opts.maxSize > 0
+	hashProcessor.MaxFileSize = opts.maxSize
+	hashProcessor.AnalyzerTimeout = opts.analyzerTimeout
+	batchStages, err := setupAnalyzers(hashProcessor, opts.analyzers, opts.execAnalyzers)
+	if err != nil {
+		logger.Error("%v", err)
+		return 2
+	}
+
+	if failed := hashFiles(os.Stdout, writer, hashProcessor, fs.Args(), logger, batchStages...); failed > 0 {
+		return 1
+	}
+	return 0
+}
+
+// hashFiles hashes each path in order and writes a manifest line per file
+// with writer, or a JSON result per file to w if writer is nil. Files that
+// cannot be hashed are logged and left out of the manifest. Batch analyzers
+// run once every file is hashed. It returns the number of files that failed.
+func hashFiles(w io.Writer, writer *manifest.Writer, hashProcessor *processor.HashProcessor, paths []string,
+	logger *logging.Logger, batchStages ...*execanalyzer.BatchStage) int {
+	results := make([]processor.FileResult, 0, len(paths))
+	for _, path := range paths {
+		results = append(results, hashProcessor.ProcessFile(path))
+	}
+	if len(batchStages) > 0 {
+		results = analyzeBatches(results, batchStages)
+	}
+
+	failed := 0
+	encoder := json.NewEncoder(w)
+	for _, result := range results {
+		path := result.Path
+		if result.Error != "" {
+			logger.Error("%s: %s", path, result.Error)
+			failed++
+		}
+		switch {
+		case writer == nil:
+			encoder.Encode(result)
+		case result.Error == "":
+			writer.Write(result.HashAlgorithm, result.Hash, path)
+		}
+	}
+	return failed
+}
+
+// analyzeBatches runs the batch analyzers on results and returns them in
+// their original order, as batch stages may reorder them.
+func analyzeBatches(results []processor.FileResult, batchStages []*execanalyzer.BatchStage) []processor.FileResult {
+	in := make(chan processor.FileResult, len(results))
+	for _, result := range results {
+		in <- result
+	}
+	close(in)
+	var out <-chan processor.FileResult = in
+	for _, stage := range batchStages {
+		out = stage.Run(context.Background(), out)
+	}
+
+	order := make(map[string]int, len(results))
+	for i := len(results) - 1; i >= 0; i-- {
+		order[results[i].Path] = i
+	}
+	analyzed := make([]processor.FileResult, 0, len(results))
+	for result := range out {
+		analyzed = append(analyzed, result)
+	}
+	sort.SliceStable(analyzed, func(i, j int) bool { return order[analyzed[i].Path] < order[analyzed[j].Path] })
+	return analyzed
+}
+
+// algorithmDigestLength returns the length of a hex digest of algorithm, or
+// 0 if it is not supported.
+func algorithmDigestLength(algorithm string) int {
+	switch strings.ToLower(algorithm) {
+	case "md5":
+		return 32
+	case "sha1":
+		return 40
+	case "sha256":
+		return 64
+	case "sha512":
+		return 128
+	default:
+		return 0
+	}
+}
+
+// verifyOptions holds the flags of "agentflux verify".
+type verifyOptions struct {
+	quiet    bool
+	workers  int
+	dir      string
+	logLevel string
+}
+
+// newVerifyFlagSet defines the flags of "agentflux verify".
+func newVerifyFlagSet(opts *verifyOptions) *flag.FlagSet {
+	fs := setUsage(flag.NewFlagSet("verify", flag.ContinueOnError), "verify")
+	fs.BoolVar(&opts.quiet, "quiet", false, "Only print files that fail verification")
+	fs.IntVar(&opts.workers, "workers", runtime.NumCPU(), "Number of files hashed concurrently")
+	fs.StringVar(&opts.dir, "dir", "", "Directory relative paths in the manifest are resolved against (default the current directory)")
+	fs.StringVar(&opts.logLevel, "log-level", "warn", "Log level (debug, info, warn, error)")
+	return fs
+}
+
+// runVerify implements "agentflux verify", which hashes the files listed in
+// a manifest in parallel and reports each as OK, FAILED or MISSING. A
+// manifest of "-" is read from stdin. It returns 1 if any file is not OK.
+func runVerify(args []string) int {
+	logger := logging.NewLogger("verify")
+	opts := &verifyOptions{}
+	fs := newVerifyFlagSet(opts)
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if err := logging.SetLevels(opts.logLevel); err != nil {
+		logger.Error("%v", err)
+		return 2
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return 2
+	}
+
+	var input io.Reader = os.Stdin
+	if name := fs.Arg(0); name != "-" {
+		file, err := os.Open(name)
+		if err != nil {
+			logger.Error("Failed to open manifest: %v", err)
+			return 2
+		}
+		defer file.Close()
+		input = file
+	}
+	entries, err := manifest.Parse(input)
+	if err != nil {
+		logger.Error("Invalid manifest: %v", err)
+		return 2
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	verifier := manifest.NewVerifier()
+	verifier.Workers = opts.workers
+	verifier.Dir = opts.dir
+	counts := printVerifyResults(os.Stdout, verifier.Verify(ctx, entries), opts.quiet)
+	if ctx.Err() != nil {
+		logger.Error("Verification interrupted")
+		return 1
+	}
+
+	if failed, missing := counts[manifest.StatusFailed], counts[manifest.StatusMissing]; failed+missing > 0 {
+		fmt.Fprintf(os.Stderr, "agentflux: WARNING: %d of %d files FAILED, %d MISSING\n", failed, len(entries), missing)
+		return 1
+	}
+	return 0
+}
+
+// printVerifyResults writes a "PATH: STATUS" line per result to w, leaving
+// out OK files if quiet is set. It returns the number of files per status.
+func printVerifyResults(w io.Writer, results <-chan manifest.Result, quiet bool) map[manifest.Status]int {
+	counts := map[manifest.Status]int{}
+	for result := range results {
+		counts[result.Status]++
+		switch {
+		case result.Status == manifest.StatusOK && quiet:
+		case result.Status == manifest.StatusFailed && result.Error != "":
+			fmt.Fprintf(w, "%s: %s (%s)\n", result.Path, result.Status, result.Error)
+		default:
+			fmt.Fprintf(w, "%s: %s\n", result.Path, result.Status)
+		}
+	}
+	return counts
+}
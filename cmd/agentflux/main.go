@@ -5,20 +5,25 @@ package main
 
 import (
 	"context"
-	"flag"
 	"fmt"
 	"os"
 	"os/signal"
-	"runtime"
 	"strings"
+	"sync/atomic"
 	"syscall"
 	"time"
 
 	"github.com/vtriple/agentflux/pkg/api"
 	"github.com/vtriple/agentflux/pkg/common/config"
+	"github.com/vtriple/agentflux/pkg/common/fileutils"
+	"github.com/vtriple/agentflux/pkg/common/hostinfo"
 	"github.com/vtriple/agentflux/pkg/common/logging"
 	"github.com/vtriple/agentflux/pkg/dedup"
+	"github.com/vtriple/agentflux/pkg/metrics"
+	"github.com/vtriple/agentflux/pkg/pipeline"
 	"github.com/vtriple/agentflux/pkg/processor"
+	"github.com/vtriple/agentflux/pkg/progress"
+	"github.com/vtriple/agentflux/pkg/report"
 	"github.com/vtriple/agentflux/pkg/scanner"
 )
 
@@ -30,13 +35,32 @@ var (
 )
 
 func main() {
+	os.Exit(dispatch(os.Args[1:]))
+}
+
+// runScan implements "agentflux scan", which scans paths, hashes files and
+// sends the results to the API. It returns the process exit code.
+func runScan(args []string) int {
 	// Set up a logger
 	logger := logging.NewLogger("main")
 	
 	// Parse command line flags
-	cfg, err := parseFlags()
+	cfg, err := parseFlags(args)
+	if err != nil {
+		logger.Error("Error parsing flags: %v", err)
+		return 1
+	}
+	if err := configureLogging(cfg); err != nil {
+		logger.Error("Error configuring logging: %v", err)
+		return 1
+	}
+	metricsServer, err := startMetrics(cfg, logger)
 	if err != nil {
-		logger.Fatal("Error parsing flags: %v", err)
+		logger.Error("Error starting metrics listener: %v", err)
+		return 1
+	}
+	if metricsServer != nil {
+		defer metricsServer.Close()
 	}
 	
 	// Set up context with cancellation
@@ -46,6 +70,7 @@ func main() {
 	// Set up signal handling for graceful shutdown
 	signalChan := make(chan os.Signal, 1)
 	signal.Notify(signalChan, syscall.SIGINT, syscall.SIGTERM)
+	defer signal.Stop(signalChan)
 	go func() {
 		sig := <-signalChan
 		logger.Info("Received signal %v, initiating shutdown...", sig)
@@ -60,80 +85,23 @@ func main() {
 	
 	// Run the main application
 	if err := run(ctx, cfg, logger); err != nil {
-		logger.Fatal("Application error: %v", err)
+		logger.Error("Application error: %v", err)
+		return report.ExitCode(err)
 	}
+	return 0
 }
 
-// parseFlags parses command line flags and validates them
-func parseFlags() (*config.Config, error) {
-	cfg := &config.Config{}
-	
-	// Basic options
-	flag.StringVar(&cfg.RootPaths, "paths", ".", "Comma-separated list of paths to scan")
-	flag.StringVar(&cfg.ExcludePaths, "exclude", "", "Comma-separated list of glob patterns to exclude")
-	flag.StringVar(&cfg.HashAlgorithm, "algorithm", "sha256", "Hash algorithm (md5, sha1, sha256, sha512)")
-	flag.IntVar(&cfg.WorkerCount, "workers", runtime.NumCPU(), "Number of worker goroutines")
-	flag.IntVar(&cfg.MaxDepth, "depth", -1, "Maximum directory depth (-1 for unlimited)")
-	
-	// API options
-	flag.StringVar(&cfg.APIEndpoint, "api", "", "API endpoint URL")
-	flag.StringVar(&cfg.APIToken, "token", "", "API authentication token")
-	flag.StringVar(&cfg.APIAuthMethod, "auth-method", "bearer", "API auth method (bearer, basic, api-key)")
-	flag.IntVar(&cfg.APIBatchSize, "batch", 100, "API batch size")
-	
-	// String extraction options
-	flag.BoolVar(&cfg.ExtractStrings, "strings", false, "Extract strings from files")
-	flag.IntVar(&cfg.StringMinLength, "string-min", 4, "Minimum string length to extract")
-	
-	// File processing options
-	flag.Int64Var(&cfg.MaxFileSize, "max-size", 100*1024*1024, "Maximum file size to process in bytes")
-	flag.StringVar(&cfg.LogLevel, "log-level", "info", "Log level (debug, info, warn, error)")
-	flag.StringVar(&cfg.LogFile, "log-file", "", "Path to log file (empty for stderr)")
-	
-	// Misc options
-	flag.BoolVar(&cfg.ShowVersion, "version", false, "Show version information")
-	
-	flag.Parse()
-	
-	// Handle version flag
-	if cfg.ShowVersion {
-		fmt.Printf("AgentFlux v%s\n", Version)
-		fmt.Printf("Build Date: %s\n", BuildDate)
-		fmt.Printf("Git Commit: %s\n", GitCommit)
-		os.Exit(0)
-	}
-	
-	// Validate arguments
-	if cfg.APIEndpoint == "" {
-		return nil, fmt.Errorf("API endpoint is required")
-	}
-	
-	// Validate hash algorithm
-	validAlgs := map[string]bool{"md5": true, "sha1": true, "sha256": true, "sha512": true}
-	if !validAlgs[strings.ToLower(cfg.HashAlgorithm)] {
-		return nil, fmt.Errorf("unsupported hash algorithm: %s", cfg.HashAlgorithm)
-	}
-	
-	// Parse root paths
-	cfg.ParsedRootPaths = splitCSV(cfg.RootPaths)
-	if len(cfg.ParsedRootPaths) == 0 {
-		return nil, fmt.Errorf("at least one path must be specified")
-	}
-	
-	// Parse exclude paths
-	cfg.ParsedExcludePaths = splitCSV(cfg.ExcludePaths)
-	
-	return cfg, nil
-}
-
-// run executes the main application logic with the parsed configuration
-func run(ctx context.Context, cfg *config.Config, logger *logging.Logger) error {
-	// Configure logging
-	logging.SetGlobalLevel(cfg.LogLevel)
-	if cfg.LogFile != "" {
-		if err := logging.SetLogFile(cfg.LogFile); err != nil {
-			return fmt.Errorf("failed to set log file: %w", err)
-		}
+// run executes the main application logic with the parsed configuration.
+// Failures that still let the scan finish are returned as a report.ExitError
+// carrying the exit code; other errors are fatal.
+func run(ctx context.Context, cfg *config.Config, logger *logging.Logger) (err error) {
+	collector := report.NewCollector(Version, cfg.ConfigHash)
+	if cfg.ReportFile != "" {
+		defer func() {
+			if writeErr := report.WriteFile(cfg.ReportFile, collector.Finish(err)); writeErr != nil {
+				logger.Error("%v", writeErr)
+			}
+		}()
 	}
 	
 	// Create file scanner
@@ -150,66 +118,458 @@ func run(ctx context.Context, cfg *config.Config, logger *logging.Logger) error
 	hashProcessor := processor.NewHashProcessor(cfg.HashAlgorithm, cfg.WorkerCount)
 	hashProcessor.ExtractStrings = cfg.ExtractStrings
 	hashProcessor.StringMinLength = cfg.StringMinLength
+	hashProcessor.AnalyzerTimeout = cfg.AnalyzerTimeout
+	batchStages, err := setupAnalyzers(hashProcessor, cfg.Analyzers, cfg.ExecAnalyzers)
+	if err != nil {
+		return err
+	}
 	hashProcessor.SetLogger(logging.NewLogger("processor"))
 	
 	// Create deduplication engine
-	logger.Info("Initializing deduplication engine")
-	dedupEngine := dedup.NewDeduplicationEngine(dedup.HashDedup)
+	dedupMode, _ := dedup.ParseMode(cfg.DedupMode)
+	dedupEngine, _ := newDedupEngine(cfg)
+	logger.Info("Initializing deduplication engine by %s in %s mode", dedupEngine.DedupType, dedupMode)
+	dedupEngine.Mode = dedupMode
+	dedupEngine.MemoryLimit = cfg.ParsedDedupMemory
+	dedupEngine.FalsePositiveRate = cfg.DedupFPRate
+	dedupEngine.Duplicates, _ = dedup.ParseDuplicateHandling(cfg.Duplicates)
 	dedupEngine.SetLogger(logging.NewLogger("dedup"))
+	if cfg.DedupStore != "" {
+		store, err := dedup.OpenFileStore(cfg.DedupStore, cfg.DedupTTL)
+		if err != nil {
+			return fmt.Errorf("failed to open dedup store: %w", err)
+		}
+		defer store.Close()
+		dedupEngine.Store = store
+	}
 	
 	// Create API client
 	logger.Info("Initializing API client with endpoint %s", cfg.APIEndpoint)
-	apiClient := api.NewAPIClient(cfg.APIEndpoint, api.AuthType(cfg.APIAuthMethod), cfg.APIToken)
+	credentials, err := buildCredentials(cfg)
+	if err != nil {
+		return fmt.Errorf("failed to load API credentials: %w", err)
+	}
+	apiClient := api.NewAPIClient(cfg.APIEndpoint, api.AuthType(cfg.APIAuthMethod), credentials)
 	apiClient.BatchSize = cfg.APIBatchSize
 	apiClient.SetLogger(logging.NewLogger("api"))
+	labels, _ := parseLabels(cfg.Labels)
+	apiClient.Metadata = &api.BatchMetadata{
+		Agent:  api.AgentInfo{Name: "agentflux", Version: Version, Commit: GitCommit},
+		Host:   hostinfo.Collect(),
+		ScanID: api.NewScanID(),
+		Labels: labels,
+	}
+	apiClient.EventsEndpoint = cfg.EventsEndpoint
+	apiClient.OnDelivered = func(batch []processor.FileResult) {
+		collector.Delivered(len(batch))
+		// Only acknowledged files are remembered so failed batches are retried next run
+		if err := dedupEngine.MarkDelivered(batch); err != nil {
+			logger.Error("Failed to record delivered files: %v", err)
+		}
+	}
+	var apiErrorCount int64
+	apiClient.OnFailed = func(batch []processor.FileResult, err error) {
+		atomic.AddInt64(&apiErrorCount, 1)
+		collector.DeliveryError(err)
+	}
+	logger.Info("Scan ID: %s", apiClient.Metadata.ScanID)
+	collector.Update(func(r *report.Report) { r.ScanID = apiClient.Metadata.ScanID })
+	
+	// Create progress reporter
+	reporter, err := newProgressReporter(cfg)
+	if err != nil {
+		return err
+	}
+	outputs, err := newResultOutputs(cfg)
+	if err != nil {
+		return err
+	}
 	
 	// Start the scanning process
 	logger.Info("Starting file scan...")
 	startTime := time.Now()
+	if err := apiClient.SendEvent(ctx, api.EventScanStarted, nil); err != nil {
+		logger.Warn("Failed to send scan start event: %v", err)
+	}
 	
-	// Set up the processing pipeline
-	fileChannel, scanErrors := fileScanner.Scan()
-	resultChannel := hashProcessor.Process(fileChannel)
-	uniqueChannel := dedupEngine.Deduplicate(ctx, resultChannel)
-	apiErrors := apiClient.SendResults(ctx, uniqueChannel)
+	// Count files alongside the scan so an ETA is shown once the count is done
+	if cfg.Estimate {
+		estimateCtx, cancelEstimate := context.WithCancel(ctx)
+		defer cancelEstimate()
+		go func() {
+			estimate, err := fileScanner.Estimate(estimateCtx)
+			if err != nil {
+				if estimateCtx.Err() == nil {
+					logger.Warn("Failed to estimate scan size: %v", err)
+				}
+				return
+			}
+			logger.Info("Estimated %d files, %s", estimate.Files, progress.FormatBytes(float64(estimate.Bytes)))
+			reporter.SetEstimate(estimate.Files, estimate.Bytes)
+		}()
+	}
 	
-	// Monitor for scan errors
-	scanErrorCount := 0
-	go func() {
-		for err := range scanErrors {
-			scanErrorCount++
+	// Set up the processing pipeline. Batch analyzers run after
+	// deduplication so duplicates are not analyzed
+	var scanErrorCount int64
+	stages := []pipeline.Stage{pipeline.Deduplicate(dedupEngine)}
+	for _, stage := range batchStages {
+		stages = append(stages, stage)
+	}
+	if outputs != nil {
+		stages = append(stages, outputs.Stage())
+	}
+	options := []pipeline.Option{pipeline.WithScanner(fileScanner), pipeline.WithProcessor(hashProcessor)}
+	queueNames := []string{pipeline.ScanStage, pipeline.ProcessStage}
+	for _, stage := range stages {
+		options = append(options, pipeline.WithStage(stage))
+		queueNames = append(queueNames, stage.Name())
+	}
+	options = append(options, pipeline.WithClient(apiClient), pipeline.WithHooks(pipeline.Hooks{
+		OnScanError: func(err error) {
+			atomic.AddInt64(&scanErrorCount, 1)
+			reporter.AddError()
+			collector.ScanError(err)
 			logger.Error("Scan error: %v", err)
-		}
-	}()
-	
-	// Monitor for API errors
-	apiErrorCount := 0
-	go func() {
-		for err := range apiErrors {
-			apiErrorCount++
+		},
+		OnResult: func(stage string, result processor.FileResult) {
+			if stage == pipeline.ProcessStage {
+				reporter.Observe(result)
+				collector.Result(result)
+			}
+		},
+		OnStageDone: func(stage pipeline.StageSummary) {
+			collector.StageDone(stage.Name)
+		},
+		OnDeliveryError: func(err error) {
 			logger.Error("API error: %v", err)
+		},
+	}))
+	scanPipeline, err := pipeline.New(options...)
+	if err != nil {
+		return err
+	}
+	reporter.Queued = func() int { return scanPipeline.Queues()[pipeline.ScanStage] }
+	reporter.Start()
+	queues := make(map[string]func() int, len(queueNames))
+	for _, name := range queueNames {
+		queues[name] = func() int { return scanPipeline.Queues()[name] }
+	}
+	stopWatching := watchQueues(cfg.Profile, queues)
+	defer stopWatching()
+	
+	// Collect the running totals for heartbeats and the final event
+	currentStats := func() api.ScanStats {
+		dedupStats := dedupEngine.Stats()
+		return api.ScanStats{
+			TotalFiles:      dedupStats.Total,
+			UniqueFiles:     dedupStats.Unique,
+			DuplicateFiles:  dedupStats.Total - dedupStats.Unique - dedupStats.Suppressed,
+			SuppressedFiles: dedupStats.Suppressed,
+			ScanErrors:      int(atomic.LoadInt64(&scanErrorCount)),
+			APIErrors:       int(atomic.LoadInt64(&apiErrorCount)),
+			ElapsedSeconds:  time.Since(startTime).Seconds(),
 		}
-	}()
+	}
+	stopHeartbeat := apiClient.StartHeartbeat(ctx, cfg.HeartbeatInterval, currentStats)
 	
-	// Wait for API client to finish
-	apiClient.Wait()
+	// Cancellation and delivery failures are reported from the collector below
+	scanPipeline.Run(ctx)
+	stopHeartbeat()
+	reporter.Stop()
+	collector.StageDone("deliver")
+	if err := outputs.Close(); err != nil {
+		logger.Error("Failed to write result files: %v", err)
+	}
 	
 	// Print summary
 	elapsed := time.Since(startTime)
-	totalFiles, uniqueFiles := dedupEngine.GetStats()
+	stats := currentStats()
+	
+	// Report the outcome; the scan context may already be cancelled
+	finalEvent := api.EventScanCompleted
+	if ctx.Err() != nil {
+		finalEvent = api.EventScanCancelled
+	}
+	eventCtx, cancelEvent := context.WithTimeout(context.Background(), 10*time.Second)
+	if err := apiClient.SendEvent(eventCtx, finalEvent, &stats); err != nil {
+		logger.Warn("Failed to send scan completion event: %v", err)
+	}
+	cancelEvent()
 	
 	logger.Info("Scan completed in %s", elapsed)
-	logger.Info("Total files processed: %d", totalFiles)
-	logger.Info("Unique files found: %d", uniqueFiles)
-	logger.Info("Duplicate files: %d", totalFiles-uniqueFiles)
-	logger.Info("Scan errors: %d", scanErrorCount)
-	logger.Info("API errors: %d", apiErrorCount)
+	logger.Info("Total files processed: %d", stats.TotalFiles)
+	logger.Info("Unique files found: %d", stats.UniqueFiles)
+	logger.Info("Duplicate files: %d", stats.DuplicateFiles)
+	if dedupEngine.Store != nil {
+		logger.Info("Previously delivered files: %d", stats.SuppressedFiles)
+	}
+	logDedupStats(logger, dedupEngine.Stats())
+	logger.Info("Scan errors: %d", stats.ScanErrors)
+	logger.Info("API errors: %d", stats.APIErrors)
 	
 	fmt.Printf("\nScan completed in %s\n", elapsed)
-	fmt.Printf("Total files processed: %d\n", totalFiles)
-	fmt.Printf("Unique files found: %d\n", uniqueFiles)
-	fmt.Printf("Duplicate files: %d\n", totalFiles-uniqueFiles)
+	fmt.Printf("Total files processed: %d\n", stats.TotalFiles)
+	fmt.Printf("Unique files found: %d\n", stats.UniqueFiles)
+	fmt.Printf("Duplicate files: %d\n", stats.DuplicateFiles)
+	if dedupEngine.Store != nil {
+		fmt.Printf("Previously delivered files: %d\n", stats.SuppressedFiles)
+	}
+	
+	scanStats := fileScanner.Stats()
+	collector.Update(func(r *report.Report) {
+		r.Cancelled = ctx.Err() != nil
+		r.Counts.Discovered = scanStats.Discovered
+		r.Counts.Unique = stats.UniqueFiles
+		r.Counts.Duplicates = stats.DuplicateFiles
+		r.Counts.Suppressed = stats.SuppressedFiles
+		r.Skipped = scanStats.Skipped
+	})
+	return runOutcome(cfg, collector)
+}
+
+// runOutcome returns the error ending a scan that ran to completion: a
+// delivery failure takes precedence over too many scan errors.
+func runOutcome(cfg *config.Config, collector *report.Collector) error {
+	var counts report.Counts
+	collector.Update(func(r *report.Report) { counts = r.Counts })
+	if counts.BatchesFailed > 0 {
+		return &report.ExitError{Code: report.ExitPartialDelivery,
+			Err: fmt.Errorf("%d of %d batches failed to deliver", counts.BatchesFailed, counts.BatchesFailed+counts.BatchesDelivered)}
+	}
+	if errCount := collector.Errors(); cfg.MaxErrors >= 0 && errCount > int64(cfg.MaxErrors) {
+		return &report.ExitError{Code: report.ExitTooManyErrors,
+			Err: fmt.Errorf("%d scan errors exceed the limit of %d", errCount, cfg.MaxErrors)}
+	}
+	return nil
+}
+
+// queueDepth reports the number of items waiting in the channels between
+// stages. The profile label keeps the runs of daemon profiles apart.
+var queueDepth = metrics.Default.NewGaugeVec("agentflux_queue_depth",
+	"Items waiting in the channel between pipeline stages.", "profile", "queue")
+
+// watchQueues makes the queue depth gauges of a profile report the lengths
+// of the given channels. An empty profile is the default one. It returns a
+// function that detaches the gauges once the run is over.
+func watchQueues(profile string, queues map[string]func() int) func() {
+	if profile == "" {
+		profile = defaultProfile
+	}
+	for name, length := range queues {
+		queueDepth.With(profile, name).SetFunc(func() float64 { return float64(length()) })
+	}
+	return func() {
+		for name := range queues {
+			queueDepth.With(profile, name).SetFunc(nil)
+		}
+	}
+}
+
+// startMetrics starts the Prometheus metrics listener if an address is
+// configured. It returns nil if metrics are disabled.
+func startMetrics(cfg *config.Config, logger *logging.Logger) (*metrics.Server, error) {
+	if cfg.MetricsAddr == "" {
+		return nil, nil
+	}
+	server, err := metrics.Serve(cfg.MetricsAddr, metrics.Default)
+	if err != nil {
+		return nil, err
+	}
+	logger.Info("Serving metrics on http://%s/metrics", server.Addr())
+	return server, nil
+}
+
+// configureLogging applies the configured log format, levels and file.
+func configureLogging(cfg *config.Config) error {
+	format, err := logging.ParseFormat(cfg.LogFormat)
+	if err != nil {
+		return err
+	}
+	logging.SetFormat(format)
+	if err := logging.SetLevels(cfg.LogLevel); err != nil {
+		return err
+	}
+	if err := validateLogOutput(cfg); err != nil {
+		return err
+	}
+
+	switch output := cfg.LogOutput; {
+	case output == "journald":
+		handler, err := logging.NewJournalHandler("")
+		if err != nil {
+			return err
+		}
+		logging.SetHandler(handler)
+	case strings.HasPrefix(output, "syslog"):
+		network, address, _ := logging.ParseSyslogURL(output)
+		handler, err := logging.NewSyslogHandler(network, address, logging.SyslogOptions{AppName: "agentflux"})
+		if err != nil {
+			return err
+		}
+		logging.SetHandler(handler)
+	case cfg.LogFile != "" && output != "stderr":
+		opts, err := logRotateOptions(cfg)
+		if err != nil {
+			return err
+		}
+		if err := logging.SetRotatingLogFile(cfg.LogFile, opts); err != nil {
+			return fmt.Errorf("failed to set log file: %w", err)
+		}
+	}
+	return nil
+}
+
+// validateLogOutput checks the log destination against the log file setting.
+func validateLogOutput(cfg *config.Config) error {
+	switch output := cfg.LogOutput; {
+	case output == "", output == "stderr", output == "journald":
+		return nil
+	case output == "file":
+		if cfg.LogFile == "" {
+			return fmt.Errorf("--log-output=file requires --log-file")
+		}
+		return nil
+	case strings.HasPrefix(output, "syslog"):
+		_, _, err := logging.ParseSyslogURL(output)
+		return err
+	default:
+		return fmt.Errorf("unsupported log output: %s", output)
+	}
+}
+
+// logRotateOptions returns the configured log rotation options.
+func logRotateOptions(cfg *config.Config) (logging.RotateOptions, error) {
+	maxSize, err := fileutils.ParseSize(cfg.LogMaxSize)
+	if err != nil || maxSize < 0 {
+		return logging.RotateOptions{}, fmt.Errorf("invalid log max size: %s", cfg.LogMaxSize)
+	}
+	if cfg.LogMaxAge < 0 || cfg.LogMaxBackups < 0 {
+		return logging.RotateOptions{}, fmt.Errorf("log max age and max backups must not be negative")
+	}
+	return logging.RotateOptions{
+		MaxSize:    maxSize,
+		MaxAge:     cfg.LogMaxAge,
+		MaxBackups: cfg.LogMaxBackups,
+		Compress:   cfg.LogCompress,
+	}, nil
+}
+
+// newDedupEngine creates the deduplication engine for the configured dedup type and key.
+func newDedupEngine(cfg *config.Config) (*dedup.DeduplicationEngine, error) {
+	dedupType, err := dedup.ParseDeduplicationType(cfg.DedupType)
+	if err != nil {
+		return nil, err
+	}
+	engine := dedup.NewDeduplicationEngine(dedupType)
+	
+	switch {
+	case dedupType == dedup.CompositeDedup:
+		if cfg.DedupKey == "" {
+			return nil, fmt.Errorf("--dedup=composite requires --dedup-key")
+		}
+		engine.KeyFunc, err = dedup.ParseKeyExpression(cfg.DedupKey)
+		if err != nil {
+			return nil, err
+		}
+	case cfg.DedupKey != "":
+		return nil, fmt.Errorf("--dedup-key is only used with --dedup=composite")
+	}
+	return engine, nil
+}
+
+// logDedupStats reports the accuracy and memory use of bounded dedup modes.
+func logDedupStats(logger *logging.Logger, stats dedup.Stats) {
+	switch stats.Mode {
+	case dedup.ModeBloom:
+		logger.Info("Dedup bloom filter: %d bytes, estimated false-positive rate %.6f, ~%d files wrongly filtered",
+			stats.MemoryBytes, stats.FalsePositiveRate, stats.EstimatedFalsePositives)
+	case dedup.ModeLRU:
+		logger.Info("Dedup LRU cache: %d bytes, %d evictions", stats.MemoryBytes, stats.Evictions)
+	}
+}
+
+// buildCredentials resolves the API credentials for the configured auth method.
+// Secrets may be given literally or as env:NAME or file:/path references.
+func buildCredentials(cfg *config.Config) (interface{}, error) {
+	if api.AuthType(cfg.APIAuthMethod) == api.AuthOAuth2 {
+		clientID, err := api.ResolveSecret(cfg.OAuthClientID)
+		if err != nil {
+			return nil, fmt.Errorf("oauth2 client ID: %w", err)
+		}
+		clientSecret, err := api.ResolveSecret(cfg.OAuthClientSecret)
+		if err != nil {
+			return nil, fmt.Errorf("oauth2 client secret: %w", err)
+		}
+		if cfg.OAuthTokenURL == "" || clientID == "" {
+			return nil, fmt.Errorf("oauth2 auth requires --oauth-token-url and --oauth-client-id")
+		}
+		return api.OAuth2Config{
+			TokenURL:     cfg.OAuthTokenURL,
+			ClientID:     clientID,
+			ClientSecret: clientSecret,
+			Scopes:       splitCSV(cfg.OAuthScopes),
+		}, nil
+	}
 	
+	token, err := api.ResolveSecret(cfg.APIToken)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.APITokenFile != "" {
+		if token, err = api.ReadSecretFile(cfg.APITokenFile); err != nil {
+			return nil, err
+		}
+	}
+	
+	switch api.AuthType(cfg.APIAuthMethod) {
+	case api.AuthBasic:
+		// Basic auth takes the token in username:password form
+		username, password, ok := strings.Cut(token, ":")
+		if !ok {
+			return nil, fmt.Errorf("basic auth requires a token of the form username:password")
+		}
+		return api.BasicAuth{Username: username, Password: password}, nil
+		
+	case api.AuthHMAC:
+		if cfg.HMACKeyID == "" || token == "" {
+			return nil, fmt.Errorf("hmac auth requires --hmac-key-id and a shared secret token")
+		}
+		return api.HMACCredentials{KeyID: cfg.HMACKeyID, Secret: token}, nil
+	}
+	
+	return token, nil
+}
+
+// parseLabels converts key=value strings into a map.
+func parseLabels(entries []string) (map[string]string, error) {
+	labels := make(map[string]string, len(entries))
+	for _, entry := range entries {
+		key, value, ok := strings.Cut(entry, "=")
+		key = strings.TrimSpace(key)
+		if !ok || key == "" {
+			return nil, fmt.Errorf("invalid label %q, expected key=value", entry)
+		}
+		labels[key] = strings.TrimSpace(value)
+	}
+	return labels, nil
+}
+
+// stringListFlag is a flag.Value that collects comma-separated values
+// across repeated uses of the flag.
+type stringListFlag []string
+
+// String returns the collected values as a comma-separated string.
+func (f *stringListFlag) String() string {
+	if f == nil {
+		return ""
+	}
+	return strings.Join(*f, ",")
+}
+
+// Set appends the comma-separated values in s.
+func (f *stringListFlag) Set(s string) error {
+	*f = append(*f, splitCSV(s)...)
 	return nil
 }
 
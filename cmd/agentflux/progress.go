@@ -0,0 +1,58 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sync"
+
+	"github.com/vtriple/agentflux/pkg/common/config"
+	"github.com/vtriple/agentflux/pkg/progress"
+)
+
+var (
+	// progressFiles holds the files opened for --progress-fd. They are kept
+	// open for the life of the process so daemon runs can share them.
+	progressFiles   = map[int]*os.File{}
+	progressFilesMu sync.Mutex
+)
+
+// newProgressReporter creates the progress reporter for a scan: a status
+// line when stderr is a terminal, log lines otherwise, and JSON records on
+// --progress-fd if set.
+func newProgressReporter(cfg *config.Config) (*progress.Reporter, error) {
+	reporter := progress.NewReporter()
+	if cfg.ProgressInterval > 0 {
+		reporter.Interval = cfg.ProgressInterval
+		if progress.IsTerminal(os.Stderr) {
+			reporter.Terminal = os.Stderr
+		} else {
+			reporter.LogLines = true
+		}
+	}
+	if cfg.ProgressFD > 0 {
+		file, err := progressFile(cfg.ProgressFD)
+		if err != nil {
+			return nil, err
+		}
+		reporter.JSON = file
+	}
+	return reporter, nil
+}
+
+// progressFile returns the file for descriptor fd, checking that it is open.
+func progressFile(fd int) (*os.File, error) {
+	progressFilesMu.Lock()
+	defer progressFilesMu.Unlock()
+	if file, ok := progressFiles[fd]; ok {
+		return file, nil
+	}
+	file := os.NewFile(uintptr(fd), fmt.Sprintf("progress-fd-%d", fd))
+	if file == nil {
+		return nil, fmt.Errorf("invalid progress file descriptor: %d", fd)
+	}
+	if _, err := file.Stat(); err != nil {
+		return nil, fmt.Errorf("progress file descriptor %d is not open: %w", fd, err)
+	}
+	progressFiles[fd] = file
+	return file, nil
+}
@@ -0,0 +1,202 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/vtriple/agentflux/pkg/common/config"
+	"github.com/vtriple/agentflux/pkg/common/logging"
+	"github.com/vtriple/agentflux/pkg/pipeline"
+	"github.com/vtriple/agentflux/pkg/processor"
+	"github.com/vtriple/agentflux/pkg/render"
+)
+
+// resultOutputs writes the results of a scan to the CSV and HTML files
+// selected with --csv and --html.
+type resultOutputs struct {
+	csv      *render.CSVWriter
+	csvFile  *os.File
+	html     *render.HTMLReport
+	htmlFile *os.File
+	err      error
+}
+
+// newResultOutputs creates the output files of cfg, or returns nil if none
+// are selected. The files are created up front so a bad path fails the run
+// before scanning.
+func newResultOutputs(cfg *config.Config) (*resultOutputs, error) {
+	if cfg.CSVFile == "" && cfg.HTMLFile == "" {
+		return nil, nil
+	}
+	outputs := &resultOutputs{}
+	if cfg.CSVFile != "" {
+		columns, err := render.ParseColumns(cfg.CSVColumns)
+		if err != nil {
+			return nil, err
+		}
+		if outputs.csvFile, err = os.Create(cfg.CSVFile); err != nil {
+			return nil, fmt.Errorf("failed to create CSV file: %w", err)
+		}
+		outputs.csv = render.NewCSVWriter(outputs.csvFile, columns)
+	}
+	if cfg.HTMLFile != "" {
+		var err error
+		if outputs.htmlFile, err = os.Create(cfg.HTMLFile); err != nil {
+			outputs.Close()
+			return nil, fmt.Errorf("failed to create HTML report: %w", err)
+		}
+		outputs.html = render.NewHTMLReport("AgentFlux scan of " + strings.Join(cfg.ParsedRootPaths, ", "))
+	}
+	return outputs, nil
+}
+
+// Stage returns a pipeline stage that passes results on unchanged while
+// writing them to the outputs. After a write error the remaining results
+// are only passed on.
+func (o *resultOutputs) Stage() pipeline.Stage {
+	return pipeline.Map("output", func(result processor.FileResult) (processor.FileResult, bool) {
+		o.add(result)
+		return result, true
+	})
+}
+
+// add writes one result to the outputs.
+func (o *resultOutputs) add(result processor.FileResult) {
+	if o.html != nil {
+		o.html.Add(result)
+	}
+	if o.csv != nil && o.err == nil {
+		o.err = o.csv.Write(result)
+	}
+}
+
+// Close finishes the outputs once all results are written and returns any
+// errors writing them.
+func (o *resultOutputs) Close() error {
+	if o == nil {
+		return nil
+	}
+	errs := []error{o.err}
+	if o.csvFile != nil {
+		if o.csv != nil {
+			errs = append(errs, o.csv.Flush())
+		}
+		errs = append(errs, o.csvFile.Close())
+	}
+	if o.htmlFile != nil {
+		if o.html != nil {
+			errs = append(errs, o.html.Write(o.htmlFile))
+		}
+		errs = append(errs, o.htmlFile.Close())
+	}
+	return errors.Join(errs...)
+}
+
+// renderOptions holds the flags of "agentflux render".
+type renderOptions struct {
+	format   string
+	columns  string
+	output   string
+	title    string
+	top      int
+	logLevel string
+}
+
+// newRenderFlagSet defines the flags of "agentflux render".
+func newRenderFlagSet(opts *renderOptions) *flag.FlagSet {
+	fs := setUsage(flag.NewFlagSet("render", flag.ContinueOnError), "render")
+	fs.StringVar(&opts.format, "format", "html", "Output format (csv, html)")
+	fs.StringVar(&opts.columns, "columns", strings.Join(render.DefaultColumns, ","), "Comma-separated CSV columns, from "+strings.Join(render.ColumnNames(), ", "))
+	fs.StringVar(&opts.output, "output", "", "File to write to (default stdout)")
+	fs.StringVar(&opts.title, "title", "AgentFlux scan report", "Title of the HTML report")
+	fs.IntVar(&opts.top, "top", render.DefaultTopFiles, "Number of largest files and duplicate groups in the HTML report")
+	fs.StringVar(&opts.logLevel, "log-level", "warn", "Log level (debug, info, warn, error)")
+	return fs
+}
+
+// runRender implements "agentflux render", which turns saved results in
+// JSONL, such as the receiver's results.jsonl, into CSV or an HTML report.
+// Without files, results are read from stdin. It returns the process exit code.
+func runRender(args []string) int {
+	logger := logging.NewLogger("render")
+	opts := &renderOptions{}
+	fs := newRenderFlagSet(opts)
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if err := logging.SetLevels(opts.logLevel); err != nil {
+		logger.Error("%v", err)
+		return 2
+	}
+
+	var w io.Writer = os.Stdout
+	if opts.output != "" {
+		file, err := os.Create(opts.output)
+		if err != nil {
+			logger.Error("Failed to create output: %v", err)
+			return 1
+		}
+		defer file.Close()
+		w = file
+	}
+
+	// CSV rows are written as they are read; the HTML report is written at the end
+	var add func(processor.FileResult) error
+	var finish func() error
+	switch opts.format {
+	case "csv":
+		columns, err := render.ParseColumns(opts.columns)
+		if err != nil {
+			logger.Error("%v", err)
+			return 2
+		}
+		writer := render.NewCSVWriter(w, columns)
+		add = writer.Write
+		finish = writer.Flush
+	case "html":
+		report := render.NewHTMLReport(opts.title)
+		report.TopFiles = opts.top
+		report.TopGroups = opts.top
+		add = func(result processor.FileResult) error {
+			report.Add(result)
+			return nil
+		}
+		finish = func() error { return report.Write(w) }
+	default:
+		logger.Error("Unsupported format: %s (expected csv or html)", opts.format)
+		return 2
+	}
+
+	inputs := fs.Args()
+	if len(inputs) == 0 {
+		inputs = []string{"-"}
+	}
+	for _, name := range inputs {
+		if err := readResults(name, add); err != nil {
+			logger.Error("%s: %v", name, err)
+			return 1
+		}
+	}
+	if err := finish(); err != nil {
+		logger.Error("Failed to write %s: %v", opts.format, err)
+		return 1
+	}
+	return 0
+}
+
+// readResults reads JSONL results from the named file, or stdin for "-".
+func readResults(name string, fn func(processor.FileResult) error) error {
+	if name == "-" {
+		return render.ReadJSONL(os.Stdin, fn)
+	}
+	file, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	return render.ReadJSONL(file, fn)
+}
@@ -9,9 +9,12 @@ import (
 	"io"
 	"math/rand"
 	"net/http"
+	"strconv"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/vtriple/agentflux/pkg/common/errcode"
 	"github.com/vtriple/agentflux/pkg/common/logging"
 	"github.com/vtriple/agentflux/pkg/processor"
 )
@@ -26,6 +29,10 @@ const (
 	AuthBasic AuthType = "basic"
 	// AuthAPIKey uses an API key for authentication.
 	AuthAPIKey AuthType = "api-key"
+	// AuthOAuth2 uses OAuth2 client-credentials tokens for authentication.
+	AuthOAuth2 AuthType = "oauth2"
+	// AuthHMAC signs each request with an HMAC-SHA256 shared secret.
+	AuthHMAC AuthType = "hmac"
 
 	// DefaultBatchSize is the default number of results to send in a single batch.
 	DefaultBatchSize = 100
@@ -57,12 +64,32 @@ type APIClient struct {
 	MaxRetries int
 	// UserAgent is the user agent string sent with requests.
 	UserAgent string
+	// EventsEndpoint is the URL where scan lifecycle events are sent.
+	// When empty, events are not sent.
+	EventsEndpoint string
+	// OnDelivered, when set, is called with every batch the API accepted.
+	OnDelivered func(batch []processor.FileResult)
+	// OnFailed, when set, is called with every batch that could not be
+	// delivered. Unlike the error channel of SendResults, it never drops a
+	// failure.
+	OnFailed func(batch []processor.FileResult, err error)
+	// Metadata, when set, wraps every batch in a versioned Envelope.
+	// When nil, batches are sent as a bare JSON array of results.
+	Metadata *BatchMetadata
 
 	httpClient   *http.Client
 	currentBatch []processor.FileResult
 	batchMutex   sync.Mutex
 	wg           sync.WaitGroup
 	logger       *logging.Logger
+
+	tokenSource *oauth2TokenSource
+	authMutex   sync.Mutex
+	sequence    int64
+
+	failureMutex  sync.Mutex
+	failedBatches int
+	lastFailure   error
 }
 
 // NewAPIClient creates a new instance of APIClient.
@@ -206,14 +233,60 @@ func (a *APIClient) sendBatch(ctx context.Context, batch []processor.FileResult)
 		return nil
 	}
 	
-	// Marshal the batch to JSON
-	jsonData, err := json.Marshal(batch)
+	// Marshal the batch to JSON, wrapped in an envelope if metadata is set
+	var payload interface{} = batch
+	idempotencyKey := ""
+	if a.Metadata != nil {
+		envelope := NewEnvelope(a.Metadata, atomic.AddInt64(&a.sequence, 1), batch)
+		idempotencyKey = envelope.IdempotencyKey
+		payload = envelope
+	}
+	
+	jsonData, err := json.Marshal(payload)
 	if err != nil {
-		return fmt.Errorf("error marshaling batch: %w", err)
+		err = fmt.Errorf("error marshaling batch: %w", err)
+		a.batchFailed(batch, err)
+		return err
+	}
+	
+	// Send request with retries
+	a.logger.Debug("Sending batch of %d items to API", len(batch))
+	if err := a.postJSON(ctx, a.Endpoint, jsonData, idempotencyKey); err != nil {
+		a.batchFailed(batch, err)
+		return err
 	}
+	batchesSent.With("ok").Inc()
 	
+	if a.OnDelivered != nil {
+		a.OnDelivered(batch)
+	}
+	return nil
+}
+
+// batchFailed records a batch that could not be delivered.
+func (a *APIClient) batchFailed(batch []processor.FileResult, err error) {
+	batchesSent.With("error").Inc()
+	a.failureMutex.Lock()
+	a.failedBatches++
+	a.lastFailure = err
+	a.failureMutex.Unlock()
+	if a.OnFailed != nil {
+		a.OnFailed(batch, err)
+	}
+}
+
+// Failures returns the number of batches that could not be delivered since
+// the client was created, and the error of the last one.
+func (a *APIClient) Failures() (int, error) {
+	a.failureMutex.Lock()
+	defer a.failureMutex.Unlock()
+	return a.failedBatches, a.lastFailure
+}
+
+// postJSON posts a JSON payload to url with authentication and retries.
+func (a *APIClient) postJSON(ctx context.Context, url string, jsonData []byte, idempotencyKey string) error {
 	// Create request
-	req, err := http.NewRequestWithContext(ctx, http.MethodPost, a.Endpoint, bytes.NewBuffer(jsonData))
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewBuffer(jsonData))
 	if err != nil {
 		return fmt.Errorf("error creating request: %w", err)
 	}
@@ -221,14 +294,15 @@ func (a *APIClient) sendBatch(ctx context.Context, batch []processor.FileResult)
 	// Set headers
 	req.Header.Set("Content-Type", "application/json")
 	req.Header.Set("User-Agent", a.UserAgent)
+	if idempotencyKey != "" {
+		req.Header.Set(HeaderIdempotencyKey, idempotencyKey)
+	}
 	
 	// Add authentication
 	if err := a.addAuthToRequest(req); err != nil {
 		return fmt.Errorf("authentication error: %w", err)
 	}
 	
-	// Send request with retries
-	a.logger.Debug("Sending batch of %d items to API", len(batch))
 	return a.sendWithRetries(req, a.MaxRetries)
 }
 
@@ -264,6 +338,30 @@ func (a *APIClient) addAuthToRequest(req *http.Request) error {
 		}
 		req.Header.Set("X-API-Key", key)
 		
+	case AuthOAuth2:
+		source, err := a.oauth2TokenSource()
+		if err != nil {
+			return err
+		}
+		token, err := source.Token(req.Context())
+		if err != nil {
+			return fmt.Errorf("failed to obtain oauth2 token: %w", err)
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+		
+	case AuthHMAC:
+		switch creds := a.Credentials.(type) {
+		case HMACCredentials:
+			return signRequest(req, creds, time.Now())
+		case *HMACCredentials:
+			if creds == nil {
+				return fmt.Errorf("hmac auth requires HMACCredentials")
+			}
+			return signRequest(req, *creds, time.Now())
+		default:
+			return fmt.Errorf("hmac auth requires HMACCredentials")
+		}
+		
 	default:
 		return fmt.Errorf("unsupported authentication method: %s", a.AuthMethod)
 	}
@@ -271,27 +369,91 @@ func (a *APIClient) addAuthToRequest(req *http.Request) error {
 	return nil
 }
 
+// oauth2TokenSource returns the client's token source, creating it on first use.
+func (a *APIClient) oauth2TokenSource() (*oauth2TokenSource, error) {
+	a.authMutex.Lock()
+	defer a.authMutex.Unlock()
+	
+	if a.tokenSource != nil {
+		return a.tokenSource, nil
+	}
+	
+	var config OAuth2Config
+	switch creds := a.Credentials.(type) {
+	case OAuth2Config:
+		config = creds
+	case *OAuth2Config:
+		if creds == nil {
+			return nil, fmt.Errorf("oauth2 auth requires an OAuth2Config")
+		}
+		config = *creds
+	default:
+		return nil, fmt.Errorf("oauth2 auth requires an OAuth2Config")
+	}
+	
+	a.tokenSource = newOAuth2TokenSource(config, a.httpClient)
+	return a.tokenSource, nil
+}
+
+// rewindBody resets the request body so the request can be sent again.
+func rewindBody(req *http.Request) error {
+	if req.Body == nil || req.GetBody == nil {
+		return nil
+	}
+	body, err := req.GetBody()
+	if err != nil {
+		return fmt.Errorf("error rewinding request body: %w", err)
+	}
+	req.Body = body
+	return nil
+}
+
 // sendWithRetries sends a request with retries on failure.
+// With OAuth2 authentication a 401 response invalidates the cached token and
+// the request is retried once with a fresh token.
 func (a *APIClient) sendWithRetries(req *http.Request, maxRetries int) error {
 	var lastErr error
+	refreshedAuth := false
 	
 	for retries := 0; retries <= maxRetries; retries++ {
 		if retries > 0 {
 			// Apply exponential backoff with jitter
 			backoff := calculateBackoff(retries, DefaultMaxBackoff)
 			a.logger.Debug("Retrying request after %v (attempt %d/%d)", backoff, retries, maxRetries)
+			requestRetries.Inc()
 			time.Sleep(backoff)
+			
+			if err := rewindBody(req); err != nil {
+				return err
+			}
+			
+			// Each attempt needs a fresh signature and nonce
+			if a.AuthMethod == AuthHMAC {
+				if err := a.addAuthToRequest(req); err != nil {
+					return fmt.Errorf("authentication error: %w", err)
+				}
+			}
 		}
 		
 		// Send request
+		if req.ContentLength > 0 {
+			bytesSent.Add(float64(req.ContentLength))
+		}
+		start := time.Now()
 		resp, err := a.httpClient.Do(req)
+		requestDuration.Observe(time.Since(start).Seconds())
 		if err != nil {
-			lastErr = fmt.Errorf("request error (attempt %d/%d): %w", retries+1, maxRetries+1, err)
+			code := errcode.Of(err)
+			if code == errcode.Unknown {
+				code = errcode.Network
+			}
+			lastErr = errcode.Errorf(code, "request error (attempt %d/%d): %w", retries+1, maxRetries+1, err)
 			a.logger.Debug("HTTP request failed: %v", err)
 			continue
 		}
 		
 		// Check response status
+		responseCodes.With(strconv.Itoa(resp.StatusCode)).Inc()
 		if resp.StatusCode >= 200 && resp.StatusCode < 300 {
 			// Success, drain and close the body
 			_, _ = io.Copy(io.Discard, resp.Body)
@@ -306,11 +468,28 @@ func (a *APIClient) sendWithRetries(req *http.Request, maxRetries int) error {
 			respBody = []byte("[error reading response body]")
 		}
 		
-		lastErr = fmt.Errorf("API error (attempt %d/%d): status=%d, body=%s", 
+		lastErr = errcode.Errorf(errcode.ForStatus(resp.StatusCode), "API error (attempt %d/%d): status=%d, body=%s",
 			retries+1, maxRetries+1, resp.StatusCode, string(respBody))
 		
 		a.logger.Debug("API request failed: %v", lastErr)
 		
+		// Refresh the OAuth2 token once and retry immediately
+		if resp.StatusCode == http.StatusUnauthorized && a.AuthMethod == AuthOAuth2 && !refreshedAuth {
+			refreshedAuth = true
+			if source, err := a.oauth2TokenSource(); err == nil {
+				source.Invalidate()
+			}
+			if err := a.addAuthToRequest(req); err != nil {
+				return fmt.Errorf("authentication error: %w", err)
+			}
+			if err := rewindBody(req); err != nil {
+				return err
+			}
+			a.logger.Debug("Retrying request with refreshed oauth2 token")
+			retries--
+			continue
+		}
+		
 		// Don't retry if client error (except 429 Too Many Requests)
 		if resp.StatusCode >= 400 && resp.StatusCode < 500 && resp.StatusCode != 429 {
 			break
@@ -341,9 +520,14 @@ func (a *APIClient) Wait() {
 	a.wg.Wait()
 }
 
-// SetHTTPClient allows setting a custom HTTP client.
+// SetHTTPClient allows setting a custom HTTP client. An OAuth2 token source
+// created with the previous client is discarded, so tokens are also fetched
+// with the new one.
 func (a *APIClient) SetHTTPClient(client *http.Client) {
+	a.authMutex.Lock()
+	defer a.authMutex.Unlock()
 	a.httpClient = client
+	a.tokenSource = nil
 }
 
 // SetLogger sets a custom logger for the API client.
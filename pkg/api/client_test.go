@@ -13,6 +13,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/vtriple/agentflux/pkg/common/errcode"
 	"github.com/vtriple/agentflux/pkg/common/logging"
 	"github.com/vtriple/agentflux/pkg/processor"
 )
@@ -322,6 +323,7 @@ func TestSendWithRetries(t *testing.T) {
 		name            string
 		serverResponses []int
 		expectError     bool
+		errorCode       errcode.Code
 		maxRetries      int
 	}{
 		{
@@ -340,12 +342,14 @@ func TestSendWithRetries(t *testing.T) {
 			name:            "All retries failed with 5xx",
 			serverResponses: []int{500, 502, 503, 500},
 			expectError:     true,
+			errorCode:       errcode.ServerError,
 			maxRetries:      3,
 		},
 		{
 			name:            "Client error (4xx) no retry",
 			serverResponses: []int{400},
 			expectError:     true,
+			errorCode:       errcode.Rejected,
 			maxRetries:      3,
 		},
 		{
@@ -399,6 +403,10 @@ func TestSendWithRetries(t *testing.T) {
 				t.Errorf("Unexpected error: %v", err)
 			}
 
+			if code := errcode.Of(err); tc.errorCode != "" && code != tc.errorCode {
+				t.Errorf("Expected error code %s, got %s", tc.errorCode, code)
+			}
+
 			// Verify request count - should be limited by server responses or max retries+1
 			expectedRequests := len(tc.serverResponses)
 			if expectedRequests > tc.maxRetries+1 {
@@ -475,6 +483,9 @@ func TestSendWithRetriesNetworkError(t *testing.T) {
 	if !strings.Contains(err.Error(), "network error") {
 		t.Errorf("Expected error to contain 'network error', got '%s'", err.Error())
 	}
+	if code := errcode.Of(err); code != errcode.Network {
+		t.Errorf("Expected error code %s, got %s", errcode.Network, code)
+	}
 
 	// Error should mention retry count
 	expectedAttempts := maxRetries + 1
@@ -1487,3 +1498,79 @@ func TestSendResultsWithFullErrorChannel(t *testing.T) {
 
 	// Some log messages should be generated for dropped errors, but we can't test that directly
 }
+
+func TestOnDeliveredOnlyCalledForAcceptedBatches(t *testing.T) {
+	fail := true
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if fail {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	var delivered [][]processor.FileResult
+	client := NewAPIClient(server.URL, AuthBearer, "test-token")
+	client.OnDelivered = func(batch []processor.FileResult) {
+		delivered = append(delivered, batch)
+	}
+
+	batch := []processor.FileResult{{Path: "/tmp/a", Hash: "abc"}}
+	if err := client.sendBatch(context.Background(), batch); err == nil {
+		t.Fatal("Expected error for rejected batch")
+	}
+	if len(delivered) != 0 {
+		t.Fatalf("Expected no delivery callback for rejected batch, got %d", len(delivered))
+	}
+
+	fail = false
+	if err := client.sendBatch(context.Background(), batch); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(delivered) != 1 || delivered[0][0].Path != "/tmp/a" {
+		t.Errorf("Expected one delivery callback with the batch, got %v", delivered)
+	}
+}
+
+func TestSendResults_CountsFailuresBeyondErrorBuffer(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	defer server.Close()
+
+	client := NewAPIClient(server.URL, AuthBearer, "test-token")
+	client.BatchSize = 1
+	client.MaxRetries = 0
+	var mu sync.Mutex
+	failed := 0
+	client.OnFailed = func(batch []processor.FileResult, err error) {
+		mu.Lock()
+		failed += len(batch)
+		mu.Unlock()
+	}
+
+	// More failures than the error channel holds, with nobody reading it
+	batches := DefaultErrorBufferSize + 5
+	results := make(chan processor.FileResult, batches)
+	for i := 0; i < batches; i++ {
+		results <- processor.FileResult{Path: fmt.Sprintf("/file%d", i)}
+	}
+	close(results)
+	errs := client.SendResults(context.Background(), results)
+	client.Wait()
+
+	received := 0
+	for range errs {
+		received++
+	}
+	if received >= batches {
+		t.Fatalf("expected the error channel to drop errors, got %d of %d", received, batches)
+	}
+	if failed != batches {
+		t.Errorf("OnFailed saw %d batches, want %d", failed, batches)
+	}
+	if count, err := client.Failures(); count != batches || err == nil {
+		t.Errorf("Failures() = %d, %v, want %d and an error", count, err, batches)
+	}
+}
@@ -0,0 +1,38 @@
+package api
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// ResolveSecret returns the secret referenced by spec.
+// A spec of the form "env:NAME" reads the environment variable NAME and
+// "file:/path" reads the file at path with trailing newlines trimmed.
+// Any other value is returned unchanged.
+func ResolveSecret(spec string) (string, error) {
+	switch {
+	case strings.HasPrefix(spec, "env:"):
+		name := strings.TrimPrefix(spec, "env:")
+		value, ok := os.LookupEnv(name)
+		if !ok {
+			return "", fmt.Errorf("environment variable %s is not set", name)
+		}
+		return value, nil
+
+	case strings.HasPrefix(spec, "file:"):
+		return ReadSecretFile(strings.TrimPrefix(spec, "file:"))
+
+	default:
+		return spec, nil
+	}
+}
+
+// ReadSecretFile reads a secret from a file, trimming trailing newlines.
+func ReadSecretFile(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read secret file: %w", err)
+	}
+	return strings.TrimRight(string(data), "\r\n"), nil
+}
@@ -0,0 +1,89 @@
+package api
+
+import (
+	"crypto/rand"
+	"fmt"
+	"time"
+
+	"github.com/vtriple/agentflux/pkg/common/hostinfo"
+	"github.com/vtriple/agentflux/pkg/processor"
+)
+
+// EnvelopeSchemaVersion is the version of the batch envelope format.
+// It is incremented whenever the envelope changes incompatibly.
+const EnvelopeSchemaVersion = 1
+
+// HeaderIdempotencyKey carries the envelope's idempotency key so servers can
+// discard retried batches without parsing the body.
+const HeaderIdempotencyKey = "Idempotency-Key"
+
+// AgentInfo identifies the agent build that produced a batch.
+type AgentInfo struct {
+	// Name is the agent name.
+	Name string `json:"name"`
+	// Version is the agent version.
+	Version string `json:"version"`
+	// Commit is the source revision the agent was built from.
+	Commit string `json:"commit,omitempty"`
+}
+
+// BatchMetadata describes the origin of the batches sent by an APIClient.
+type BatchMetadata struct {
+	// Agent identifies the agent build.
+	Agent AgentInfo
+	// Host describes the machine being scanned.
+	Host hostinfo.Info
+	// ScanID uniquely identifies the scan run.
+	ScanID string
+	// Labels are user-supplied key/value pairs attached to every batch.
+	Labels map[string]string
+}
+
+// Envelope wraps a batch of results with metadata about where it came from.
+type Envelope struct {
+	// SchemaVersion is the envelope format version.
+	SchemaVersion int `json:"schemaVersion"`
+	// Agent identifies the agent build.
+	Agent AgentInfo `json:"agent"`
+	// Host describes the machine being scanned.
+	Host hostinfo.Info `json:"host"`
+	// ScanID uniquely identifies the scan run.
+	ScanID string `json:"scanId"`
+	// Sequence is the 1-based number of the batch within the scan.
+	Sequence int64 `json:"sequence"`
+	// IdempotencyKey is identical for every retry of the same batch.
+	IdempotencyKey string `json:"idempotencyKey"`
+	// Labels are user-supplied key/value pairs.
+	Labels map[string]string `json:"labels,omitempty"`
+	// SentAt is when the batch was first sent.
+	SentAt time.Time `json:"sentAt"`
+	// Results holds the file results in the batch.
+	Results []processor.FileResult `json:"results"`
+}
+
+// NewEnvelope wraps a batch using the given metadata and sequence number.
+func NewEnvelope(meta *BatchMetadata, sequence int64, batch []processor.FileResult) Envelope {
+	return Envelope{
+		SchemaVersion:  EnvelopeSchemaVersion,
+		Agent:          meta.Agent,
+		Host:           meta.Host,
+		ScanID:         meta.ScanID,
+		Sequence:       sequence,
+		IdempotencyKey: fmt.Sprintf("%s-%d", meta.ScanID, sequence),
+		Labels:         meta.Labels,
+		SentAt:         time.Now().UTC(),
+		Results:        batch,
+	}
+}
+
+// NewScanID returns a random RFC 4122 version 4 UUID for identifying a scan run.
+func NewScanID() string {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		// crypto/rand does not fail on supported platforms; fall back to the clock
+		return fmt.Sprintf("scan-%d", time.Now().UnixNano())
+	}
+	b[6] = (b[6] & 0x0f) | 0x40
+	b[8] = (b[8] & 0x3f) | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16])
+}
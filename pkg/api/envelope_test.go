@@ -0,0 +1,120 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"sync"
+	"testing"
+
+	"github.com/vtriple/agentflux/pkg/common/hostinfo"
+	"github.com/vtriple/agentflux/pkg/processor"
+)
+
+func TestNewScanID(t *testing.T) {
+	uuidPattern := regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`)
+
+	seen := make(map[string]bool)
+	for i := 0; i < 100; i++ {
+		id := NewScanID()
+		if !uuidPattern.MatchString(id) {
+			t.Fatalf("Scan ID %q is not a version 4 UUID", id)
+		}
+		if seen[id] {
+			t.Fatalf("Duplicate scan ID %q", id)
+		}
+		seen[id] = true
+	}
+}
+
+func TestSendBatchWithEnvelope(t *testing.T) {
+	var mu sync.Mutex
+	var envelopes []Envelope
+	var keys []string
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		var envelope Envelope
+		if err := json.Unmarshal(body, &envelope); err != nil {
+			t.Errorf("Failed to decode envelope: %v", err)
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		mu.Lock()
+		envelopes = append(envelopes, envelope)
+		keys = append(keys, r.Header.Get(HeaderIdempotencyKey))
+		mu.Unlock()
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	client := NewAPIClient(server.URL, AuthBearer, "test-token")
+	client.Metadata = &BatchMetadata{
+		Agent:  AgentInfo{Name: "agentflux", Version: "1.2.3", Commit: "abc123"},
+		Host:   hostinfo.Info{Hostname: "host-1", MachineID: "machine-1", OS: "linux"},
+		ScanID: "scan-1",
+		Labels: map[string]string{"env": "prod"},
+	}
+
+	for i := 0; i < 2; i++ {
+		batch := []processor.FileResult{{Path: "/tmp/file", Hash: "abc"}}
+		if err := client.sendBatch(context.Background(), batch); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	}
+
+	if len(envelopes) != 2 {
+		t.Fatalf("Expected 2 envelopes, got %d", len(envelopes))
+	}
+
+	for i, envelope := range envelopes {
+		if envelope.SchemaVersion != EnvelopeSchemaVersion {
+			t.Errorf("Expected schema version %d, got %d", EnvelopeSchemaVersion, envelope.SchemaVersion)
+		}
+		if envelope.Sequence != int64(i+1) {
+			t.Errorf("Expected sequence %d, got %d", i+1, envelope.Sequence)
+		}
+		if envelope.Agent.Version != "1.2.3" || envelope.Host.Hostname != "host-1" || envelope.ScanID != "scan-1" {
+			t.Errorf("Unexpected envelope metadata: %+v", envelope)
+		}
+		if envelope.Labels["env"] != "prod" {
+			t.Errorf("Expected label env=prod, got %v", envelope.Labels)
+		}
+		if envelope.IdempotencyKey != keys[i] {
+			t.Errorf("Expected header key %q to match body key %q", keys[i], envelope.IdempotencyKey)
+		}
+		if len(envelope.Results) != 1 || envelope.Results[0].Path != "/tmp/file" {
+			t.Errorf("Unexpected results: %+v", envelope.Results)
+		}
+	}
+
+	if keys[0] == keys[1] {
+		t.Errorf("Expected distinct idempotency keys, got %q twice", keys[0])
+	}
+}
+
+func TestEnvelopeIdempotencyKeyStableAcrossRetries(t *testing.T) {
+	var keys []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		keys = append(keys, r.Header.Get(HeaderIdempotencyKey))
+		if len(keys) == 1 {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	client := NewAPIClient(server.URL, AuthBearer, "test-token")
+	client.Metadata = &BatchMetadata{ScanID: "scan-1"}
+
+	if err := client.sendBatch(context.Background(), []processor.FileResult{{Path: "/tmp/file"}}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(keys) != 2 || keys[0] != keys[1] || keys[0] != "scan-1-1" {
+		t.Errorf("Expected the same key scan-1-1 on both attempts, got %v", keys)
+	}
+}
@@ -0,0 +1,136 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/vtriple/agentflux/pkg/common/hostinfo"
+)
+
+// EventType identifies a scan lifecycle event.
+type EventType string
+
+const (
+	// EventScanStarted is sent when a scan begins.
+	EventScanStarted EventType = "scan.started"
+	// EventScanCompleted is sent when a scan finishes normally.
+	EventScanCompleted EventType = "scan.completed"
+	// EventScanCancelled is sent when a scan is interrupted before finishing.
+	EventScanCancelled EventType = "scan.cancelled"
+	// EventHeartbeat is sent periodically while a scan is running.
+	EventHeartbeat EventType = "scan.heartbeat"
+
+	// DefaultHeartbeatInterval is the default interval between heartbeat events.
+	DefaultHeartbeatInterval = 60 * time.Second
+)
+
+// ScanStats contains the running totals of a scan.
+type ScanStats struct {
+	// TotalFiles is the number of files processed.
+	TotalFiles int `json:"totalFiles"`
+	// UniqueFiles is the number of files that were not duplicates.
+	UniqueFiles int `json:"uniqueFiles"`
+	// DuplicateFiles is the number of files filtered as duplicates.
+	DuplicateFiles int `json:"duplicateFiles"`
+	// SuppressedFiles is the number of files skipped because a previous run delivered them.
+	SuppressedFiles int `json:"suppressedFiles,omitempty"`
+	// ScanErrors is the number of errors reported by the scanner.
+	ScanErrors int `json:"scanErrors"`
+	// APIErrors is the number of errors sending results.
+	APIErrors int `json:"apiErrors"`
+	// ElapsedSeconds is the time since the scan started.
+	ElapsedSeconds float64 `json:"elapsedSeconds"`
+}
+
+// Event is a scan lifecycle notification sent to the events endpoint.
+type Event struct {
+	// SchemaVersion is the envelope format version shared with result batches.
+	SchemaVersion int `json:"schemaVersion"`
+	// Type identifies the event.
+	Type EventType `json:"type"`
+	// Agent identifies the agent build.
+	Agent AgentInfo `json:"agent"`
+	// Host describes the machine being scanned.
+	Host hostinfo.Info `json:"host"`
+	// ScanID uniquely identifies the scan run.
+	ScanID string `json:"scanId"`
+	// Labels are user-supplied key/value pairs.
+	Labels map[string]string `json:"labels,omitempty"`
+	// Timestamp is when the event occurred.
+	Timestamp time.Time `json:"timestamp"`
+	// Stats holds the scan totals at the time of the event.
+	Stats *ScanStats `json:"stats,omitempty"`
+}
+
+// SendEvent posts a lifecycle event to EventsEndpoint using the client's
+// authentication and retry settings. It does nothing if EventsEndpoint is empty.
+func (a *APIClient) SendEvent(ctx context.Context, eventType EventType, stats *ScanStats) error {
+	if a.EventsEndpoint == "" {
+		return nil
+	}
+
+	event := Event{
+		SchemaVersion: EnvelopeSchemaVersion,
+		Type:          eventType,
+		Timestamp:     time.Now().UTC(),
+		Stats:         stats,
+	}
+	if a.Metadata != nil {
+		event.Agent = a.Metadata.Agent
+		event.Host = a.Metadata.Host
+		event.ScanID = a.Metadata.ScanID
+		event.Labels = a.Metadata.Labels
+	}
+
+	jsonData, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("error marshaling event: %w", err)
+	}
+
+	a.logger.Debug("Sending %s event to API", eventType)
+	if err := a.postJSON(ctx, a.EventsEndpoint, jsonData, ""); err != nil {
+		return fmt.Errorf("failed to send %s event: %w", eventType, err)
+	}
+	return nil
+}
+
+// StartHeartbeat sends a heartbeat event with the current stats every interval
+// until ctx is done or the returned stop function is called.
+// Heartbeat failures are logged and do not stop the heartbeat.
+func (a *APIClient) StartHeartbeat(ctx context.Context, interval time.Duration, stats func() ScanStats) (stop func()) {
+	if a.EventsEndpoint == "" || interval <= 0 {
+		return func() {}
+	}
+
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-done:
+				return
+			case <-ticker.C:
+				current := stats()
+				if err := a.SendEvent(ctx, EventHeartbeat, &current); err != nil {
+					a.logger.Warn("Heartbeat failed: %v", err)
+				}
+			}
+		}
+	}()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() { close(done) })
+		wg.Wait()
+	}
+}
@@ -0,0 +1,138 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+// eventRecorder is a test server that records received events.
+type eventRecorder struct {
+	mu     sync.Mutex
+	events []Event
+}
+
+func (e *eventRecorder) handler(t *testing.T) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		var event Event
+		if err := json.Unmarshal(body, &event); err != nil {
+			t.Errorf("Failed to decode event: %v", err)
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		e.mu.Lock()
+		e.events = append(e.events, event)
+		e.mu.Unlock()
+		w.WriteHeader(http.StatusAccepted)
+	}
+}
+
+func (e *eventRecorder) snapshot() []Event {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return append([]Event(nil), e.events...)
+}
+
+func TestSendEvent(t *testing.T) {
+	recorder := &eventRecorder{}
+	server := httptest.NewServer(recorder.handler(t))
+	defer server.Close()
+
+	client := NewAPIClient("https://api.example.com/results", AuthBearer, "test-token")
+	client.EventsEndpoint = server.URL
+	client.Metadata = &BatchMetadata{ScanID: "scan-1", Labels: map[string]string{"env": "prod"}}
+
+	if err := client.SendEvent(context.Background(), EventScanStarted, nil); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	stats := &ScanStats{TotalFiles: 10, UniqueFiles: 8, DuplicateFiles: 2, ScanErrors: 1, ElapsedSeconds: 1.5}
+	if err := client.SendEvent(context.Background(), EventScanCompleted, stats); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	events := recorder.snapshot()
+	if len(events) != 2 {
+		t.Fatalf("Expected 2 events, got %d", len(events))
+	}
+	if events[0].Type != EventScanStarted || events[0].Stats != nil {
+		t.Errorf("Unexpected start event: %+v", events[0])
+	}
+	if events[1].Type != EventScanCompleted || events[1].Stats == nil || *events[1].Stats != *stats {
+		t.Errorf("Unexpected complete event: %+v", events[1])
+	}
+	for _, event := range events {
+		if event.ScanID != "scan-1" || event.Labels["env"] != "prod" {
+			t.Errorf("Event missing metadata: %+v", event)
+		}
+	}
+}
+
+func TestSendEventWithoutEndpoint(t *testing.T) {
+	client := NewAPIClient("https://api.example.com/results", AuthBearer, "test-token")
+	if err := client.SendEvent(context.Background(), EventScanStarted, nil); err != nil {
+		t.Errorf("Expected no error without events endpoint, got %v", err)
+	}
+}
+
+func TestSendEventError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	defer server.Close()
+
+	client := NewAPIClient("https://api.example.com/results", AuthBearer, "test-token")
+	client.EventsEndpoint = server.URL
+	if err := client.SendEvent(context.Background(), EventScanStarted, nil); err == nil {
+		t.Error("Expected error for rejected event")
+	}
+}
+
+func TestStartHeartbeat(t *testing.T) {
+	recorder := &eventRecorder{}
+	server := httptest.NewServer(recorder.handler(t))
+	defer server.Close()
+
+	client := NewAPIClient("https://api.example.com/results", AuthBearer, "test-token")
+	client.EventsEndpoint = server.URL
+
+	var mu sync.Mutex
+	calls := 0
+	stop := client.StartHeartbeat(context.Background(), 10*time.Millisecond, func() ScanStats {
+		mu.Lock()
+		defer mu.Unlock()
+		calls++
+		return ScanStats{TotalFiles: calls}
+	})
+
+	deadline := time.Now().Add(2 * time.Second)
+	for len(recorder.snapshot()) < 3 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	stop()
+	stop() // Stopping twice must be safe
+
+	events := recorder.snapshot()
+	if len(events) < 3 {
+		t.Fatalf("Expected at least 3 heartbeats, got %d", len(events))
+	}
+	for i, event := range events {
+		if event.Type != EventHeartbeat {
+			t.Errorf("Expected heartbeat event, got %s", event.Type)
+		}
+		if event.Stats == nil || event.Stats.TotalFiles != i+1 {
+			t.Errorf("Expected heartbeat %d to carry totals %d, got %+v", i, i+1, event.Stats)
+		}
+	}
+
+	count := len(events)
+	time.Sleep(30 * time.Millisecond)
+	if len(recorder.snapshot()) != count {
+		t.Error("Heartbeats continued after stop")
+	}
+}
@@ -0,0 +1,259 @@
+package api
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// HMACAlgorithm is the scheme name used in the Authorization header of signed requests.
+	HMACAlgorithm = "AGENTFLUX-HMAC-SHA256"
+	// HeaderDate carries the signing timestamp in HMACTimeFormat.
+	HeaderDate = "X-AgentFlux-Date"
+	// HeaderNonce carries a random value that makes every signature unique.
+	HeaderNonce = "X-AgentFlux-Nonce"
+	// HeaderContentSHA256 carries the hex SHA-256 digest of the request body.
+	HeaderContentSHA256 = "X-AgentFlux-Content-SHA256"
+	// HMACTimeFormat is the layout of the signing timestamp.
+	HMACTimeFormat = "20060102T150405Z"
+	// DefaultHMACMaxSkew is the default tolerated clock difference between signer and verifier.
+	DefaultHMACMaxSkew = 5 * time.Minute
+
+	// hmacSignedHeaders lists the headers covered by the signature.
+	hmacSignedHeaders = "idempotency-key;x-agentflux-content-sha256;x-agentflux-date;x-agentflux-nonce"
+)
+
+// HMACCredentials contains the shared secret used to sign requests.
+type HMACCredentials struct {
+	// KeyID identifies the secret to the verifier.
+	KeyID string
+	// Secret is the shared signing secret.
+	Secret string
+}
+
+// signRequest signs a request with the given credentials.
+// The canonical request is built from the method, path, query, timestamp,
+// nonce, body digest and idempotency key, similar to AWS Signature Version 4.
+// The idempotency key must be set before signing.
+func signRequest(req *http.Request, creds HMACCredentials, now time.Time) error {
+	if creds.KeyID == "" || creds.Secret == "" {
+		return fmt.Errorf("hmac auth requires a key ID and secret")
+	}
+
+	body, err := requestBody(req)
+	if err != nil {
+		return err
+	}
+
+	nonce := make([]byte, 16)
+	if _, err := rand.Read(nonce); err != nil {
+		return fmt.Errorf("error generating nonce: %w", err)
+	}
+
+	bodyDigest := sha256.Sum256(body)
+	timestamp := now.UTC().Format(HMACTimeFormat)
+
+	req.Header.Set(HeaderDate, timestamp)
+	req.Header.Set(HeaderNonce, hex.EncodeToString(nonce))
+	req.Header.Set(HeaderContentSHA256, hex.EncodeToString(bodyDigest[:]))
+
+	signature := computeSignature(req, creds.Secret)
+	req.Header.Set("Authorization", fmt.Sprintf("%s Credential=%s, SignedHeaders=%s, Signature=%s",
+		HMACAlgorithm, creds.KeyID, hmacSignedHeaders, signature))
+	return nil
+}
+
+// requestBody returns a copy of the request body without consuming it.
+func requestBody(req *http.Request) ([]byte, error) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return nil, nil
+	}
+	if req.GetBody != nil {
+		body, err := req.GetBody()
+		if err != nil {
+			return nil, fmt.Errorf("error reading request body: %w", err)
+		}
+		defer body.Close()
+		return io.ReadAll(body)
+	}
+
+	data, err := io.ReadAll(req.Body)
+	if err != nil {
+		return nil, fmt.Errorf("error reading request body: %w", err)
+	}
+	req.Body.Close()
+	req.Body = io.NopCloser(bytes.NewReader(data))
+	return data, nil
+}
+
+// canonicalRequest builds the canonical form of a signed request. A missing
+// idempotency key is signed as an empty line, so it cannot be added later.
+func canonicalRequest(req *http.Request) string {
+	path := req.URL.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	return strings.Join([]string{
+		req.Method,
+		path,
+		req.URL.Query().Encode(),
+		req.Header.Get(HeaderDate),
+		req.Header.Get(HeaderNonce),
+		req.Header.Get(HeaderContentSHA256),
+		req.Header.Get(HeaderIdempotencyKey),
+	}, "\n")
+}
+
+// computeSignature returns the hex HMAC signature for a request whose
+// signing headers have already been set.
+func computeSignature(req *http.Request, secret string) string {
+	canonicalDigest := sha256.Sum256([]byte(canonicalRequest(req)))
+	stringToSign := strings.Join([]string{
+		HMACAlgorithm,
+		req.Header.Get(HeaderDate),
+		hex.EncodeToString(canonicalDigest[:]),
+	}, "\n")
+
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(stringToSign))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// SecretLookup returns the shared secret for a key ID.
+type SecretLookup func(keyID string) (secret string, ok bool)
+
+// HMACVerifier validates requests signed with AuthHMAC.
+type HMACVerifier struct {
+	// Secrets resolves key IDs to shared secrets.
+	Secrets SecretLookup
+	// MaxSkew is the tolerated difference between the signing time and now.
+	MaxSkew time.Duration
+
+	now    func() time.Time
+	mu     sync.Mutex
+	nonces map[string]time.Time
+}
+
+// NewHMACVerifier creates a verifier that rejects replayed nonces.
+func NewHMACVerifier(secrets SecretLookup, maxSkew time.Duration) *HMACVerifier {
+	if maxSkew <= 0 {
+		maxSkew = DefaultHMACMaxSkew
+	}
+	return &HMACVerifier{
+		Secrets: secrets,
+		MaxSkew: maxSkew,
+		now:     time.Now,
+		nonces:  make(map[string]time.Time),
+	}
+}
+
+// Verify checks the signature, timestamp, nonce and body digest of a request
+// and returns the key ID that signed it. The request body is left readable.
+func (v *HMACVerifier) Verify(req *http.Request) (string, error) {
+	keyID, signature, err := parseHMACAuthorization(req.Header.Get("Authorization"))
+	if err != nil {
+		return "", err
+	}
+
+	secret, ok := v.Secrets(keyID)
+	if !ok {
+		return "", fmt.Errorf("unknown key ID: %s", keyID)
+	}
+
+	signedAt, err := time.Parse(HMACTimeFormat, req.Header.Get(HeaderDate))
+	if err != nil {
+		return "", fmt.Errorf("invalid %s header: %w", HeaderDate, err)
+	}
+	now := v.now()
+	if signedAt.Before(now.Add(-v.MaxSkew)) || signedAt.After(now.Add(v.MaxSkew)) {
+		return "", fmt.Errorf("request timestamp outside allowed skew")
+	}
+
+	body, err := requestBody(req)
+	if err != nil {
+		return "", err
+	}
+	bodyDigest := sha256.Sum256(body)
+	if !hmac.Equal([]byte(hex.EncodeToString(bodyDigest[:])), []byte(req.Header.Get(HeaderContentSHA256))) {
+		return "", fmt.Errorf("body digest mismatch")
+	}
+
+	expected := computeSignature(req, secret)
+	if !hmac.Equal([]byte(expected), []byte(signature)) {
+		return "", fmt.Errorf("signature mismatch")
+	}
+
+	nonce := req.Header.Get(HeaderNonce)
+	if nonce == "" {
+		return "", fmt.Errorf("missing %s header", HeaderNonce)
+	}
+	if !v.rememberNonce(keyID+":"+nonce, now) {
+		return "", fmt.Errorf("nonce already used")
+	}
+
+	return keyID, nil
+}
+
+// rememberNonce records a nonce and reports whether it was unseen.
+// Nonces are kept for twice the allowed skew, after which the timestamp
+// check alone rejects replays.
+func (v *HMACVerifier) rememberNonce(nonce string, now time.Time) bool {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	if v.nonces == nil {
+		v.nonces = make(map[string]time.Time)
+	}
+	for n, expires := range v.nonces {
+		if now.After(expires) {
+			delete(v.nonces, n)
+		}
+	}
+	if _, seen := v.nonces[nonce]; seen {
+		return false
+	}
+	v.nonces[nonce] = now.Add(2 * v.MaxSkew)
+	return true
+}
+
+// VerifyHMACRequest verifies a single signed request without replay protection.
+// Receivers handling more than one request should use an HMACVerifier instead.
+func VerifyHMACRequest(req *http.Request, secrets SecretLookup, maxSkew time.Duration) (string, error) {
+	return NewHMACVerifier(secrets, maxSkew).Verify(req)
+}
+
+// parseHMACAuthorization extracts the key ID and signature from an Authorization header.
+func parseHMACAuthorization(header string) (keyID, signature string, err error) {
+	params, ok := strings.CutPrefix(header, HMACAlgorithm+" ")
+	if !ok {
+		return "", "", fmt.Errorf("missing %s authorization", HMACAlgorithm)
+	}
+
+	for _, part := range strings.Split(params, ",") {
+		name, value, _ := strings.Cut(strings.TrimSpace(part), "=")
+		switch name {
+		case "Credential":
+			keyID = value
+		case "SignedHeaders":
+			if value != hmacSignedHeaders {
+				return "", "", fmt.Errorf("unsupported signed headers: %s", value)
+			}
+		case "Signature":
+			signature = value
+		}
+	}
+
+	if keyID == "" || signature == "" {
+		return "", "", fmt.Errorf("malformed %s authorization", HMACAlgorithm)
+	}
+	return keyID, signature, nil
+}
@@ -0,0 +1,186 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/vtriple/agentflux/pkg/processor"
+)
+
+func testSecrets(keyID string) (string, bool) {
+	if keyID == "agent-1" {
+		return "shared-secret", true
+	}
+	return "", false
+}
+
+func newSignedRequest(t *testing.T, body string, signedAt time.Time) *http.Request {
+	req, err := http.NewRequest(http.MethodPost, "https://api.example.com/v1/results?b=2&a=1", bytes.NewBufferString(body))
+	if err != nil {
+		t.Fatalf("Failed to create request: %v", err)
+	}
+	req.Header.Set(HeaderIdempotencyKey, "batch-key-1")
+	creds := HMACCredentials{KeyID: "agent-1", Secret: "shared-secret"}
+	if err := signRequest(req, creds, signedAt); err != nil {
+		t.Fatalf("Failed to sign request: %v", err)
+	}
+	return req
+}
+
+func TestHMACSignAndVerify(t *testing.T) {
+	req := newSignedRequest(t, `[{"path":"/tmp/a"}]`, time.Now())
+
+	if !strings.HasPrefix(req.Header.Get("Authorization"), HMACAlgorithm+" Credential=agent-1,") {
+		t.Errorf("Unexpected Authorization header: %s", req.Header.Get("Authorization"))
+	}
+
+	verifier := NewHMACVerifier(testSecrets, time.Minute)
+	keyID, err := verifier.Verify(req)
+	if err != nil {
+		t.Fatalf("Expected valid signature, got %v", err)
+	}
+	if keyID != "agent-1" {
+		t.Errorf("Expected key ID agent-1, got %s", keyID)
+	}
+
+	// The body must still be readable after verification
+	body, _ := io.ReadAll(req.Body)
+	if string(body) != `[{"path":"/tmp/a"}]` {
+		t.Errorf("Expected body to be preserved, got %q", body)
+	}
+
+	// Replaying the same request must fail
+	req.Body = io.NopCloser(bytes.NewReader(body))
+	if _, err := verifier.Verify(req); err == nil || !strings.Contains(err.Error(), "nonce") {
+		t.Errorf("Expected replay to be rejected, got %v", err)
+	}
+}
+
+func TestHMACVerifyRejectsTampering(t *testing.T) {
+	tests := []struct {
+		name    string
+		tamper  func(req *http.Request)
+		signed  time.Time
+		wantErr string
+	}{
+		{
+			name: "Modified body",
+			tamper: func(req *http.Request) {
+				req.Body = io.NopCloser(strings.NewReader(`[{"path":"/etc/shadow"}]`))
+				req.GetBody = nil
+			},
+			wantErr: "body digest mismatch",
+		},
+		{
+			name: "Modified body and digest",
+			tamper: func(req *http.Request) {
+				req.Body = io.NopCloser(strings.NewReader(""))
+				req.GetBody = nil
+				req.Header.Set(HeaderContentSHA256, "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855")
+			},
+			wantErr: "signature mismatch",
+		},
+		{
+			name:    "Modified path",
+			tamper:  func(req *http.Request) { req.URL.Path = "/v1/other" },
+			wantErr: "signature mismatch",
+		},
+		{
+			name:    "Modified method",
+			tamper:  func(req *http.Request) { req.Method = http.MethodPut },
+			wantErr: "signature mismatch",
+		},
+		{
+			name:    "Modified idempotency key",
+			tamper:  func(req *http.Request) { req.Header.Set(HeaderIdempotencyKey, "batch-key-2") },
+			wantErr: "signature mismatch",
+		},
+		{
+			name:    "Removed idempotency key",
+			tamper:  func(req *http.Request) { req.Header.Del(HeaderIdempotencyKey) },
+			wantErr: "signature mismatch",
+		},
+		{
+			name:    "Stale timestamp",
+			tamper:  func(req *http.Request) {},
+			signed:  time.Now().Add(-time.Hour),
+			wantErr: "skew",
+		},
+		{
+			name: "Unknown key",
+			tamper: func(req *http.Request) {
+				req.Header.Set("Authorization", strings.Replace(req.Header.Get("Authorization"), "agent-1", "agent-2", 1))
+			},
+			wantErr: "unknown key ID",
+		},
+		{
+			name:    "Missing authorization",
+			tamper:  func(req *http.Request) { req.Header.Del("Authorization") },
+			wantErr: "missing",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			signed := tc.signed
+			if signed.IsZero() {
+				signed = time.Now()
+			}
+			req := newSignedRequest(t, `[{"path":"/tmp/a"}]`, signed)
+			tc.tamper(req)
+
+			_, err := VerifyHMACRequest(req, testSecrets, time.Minute)
+			if err == nil {
+				t.Fatal("Expected verification to fail")
+			}
+			if !strings.Contains(err.Error(), tc.wantErr) {
+				t.Errorf("Expected error containing %q, got %v", tc.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestHMACClientRoundTrip(t *testing.T) {
+	verifier := NewHMACVerifier(testSecrets, time.Minute)
+	var attempts int32
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, err := verifier.Verify(r); err != nil {
+			t.Errorf("Verification failed: %v", err)
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		// Fail the first attempt so the retry must be re-signed with a new nonce
+		if atomic.AddInt32(&attempts, 1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	client := NewAPIClient(server.URL, AuthHMAC, HMACCredentials{KeyID: "agent-1", Secret: "shared-secret"})
+	if err := client.sendBatch(context.Background(), []processor.FileResult{{Path: "/tmp/a", Hash: "abc"}}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if atomic.LoadInt32(&attempts) != 2 {
+		t.Errorf("Expected 2 attempts, got %d", attempts)
+	}
+}
+
+func TestHMACInvalidCredentials(t *testing.T) {
+	for _, creds := range []interface{}{"secret", HMACCredentials{KeyID: "agent-1"}} {
+		client := NewAPIClient("https://api.example.com", AuthHMAC, creds)
+		req, _ := http.NewRequest(http.MethodPost, "https://api.example.com", strings.NewReader("{}"))
+		if err := client.addAuthToRequest(req); err == nil {
+			t.Errorf("Expected error for credentials %#v", creds)
+		}
+	}
+}
@@ -0,0 +1,16 @@
+package api
+
+import "github.com/vtriple/agentflux/pkg/metrics"
+
+var (
+	batchesSent = metrics.Default.NewCounterVec("agentflux_api_batches_total",
+		"Batches sent to the API, by result (ok or error).", "result")
+	bytesSent = metrics.Default.NewCounter("agentflux_api_bytes_sent_total",
+		"Request body bytes sent to the API, including retries.")
+	requestRetries = metrics.Default.NewCounter("agentflux_api_retries_total",
+		"Requests retried after a failed attempt.")
+	responseCodes = metrics.Default.NewCounterVec("agentflux_api_responses_total",
+		"API responses, by HTTP status code.", "code")
+	requestDuration = metrics.Default.NewHistogram("agentflux_api_request_duration_seconds",
+		"Time taken by a single API request attempt.", nil)
+)
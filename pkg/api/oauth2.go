@@ -0,0 +1,150 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultTokenRefreshBefore is how long before expiry a cached OAuth2 token is refreshed.
+const DefaultTokenRefreshBefore = 60 * time.Second
+
+// OAuth2Config contains the settings for the OAuth2 client-credentials grant.
+type OAuth2Config struct {
+	// TokenURL is the token endpoint of the authorization server.
+	TokenURL string
+	// ClientID is the OAuth2 client identifier.
+	ClientID string
+	// ClientSecret is the OAuth2 client secret.
+	ClientSecret string
+	// Scopes is the list of scopes requested with each token.
+	Scopes []string
+	// RefreshBefore is how long before expiry a token is proactively refreshed.
+	// Zero means DefaultTokenRefreshBefore.
+	RefreshBefore time.Duration
+	// CredentialsInBody sends the client credentials as form parameters
+	// instead of using HTTP basic authentication.
+	CredentialsInBody bool
+}
+
+// tokenResponse is the JSON body returned by an OAuth2 token endpoint.
+type tokenResponse struct {
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
+	ExpiresIn   int64  `json:"expires_in"`
+}
+
+// oauth2TokenSource fetches client-credentials tokens and caches them until expiry.
+type oauth2TokenSource struct {
+	config     OAuth2Config
+	httpClient *http.Client
+	now        func() time.Time
+
+	mu     sync.Mutex
+	token  string
+	expiry time.Time
+}
+
+// newOAuth2TokenSource creates a token source using the given HTTP client.
+func newOAuth2TokenSource(config OAuth2Config, client *http.Client) *oauth2TokenSource {
+	if config.RefreshBefore <= 0 {
+		config.RefreshBefore = DefaultTokenRefreshBefore
+	}
+	return &oauth2TokenSource{
+		config:     config,
+		httpClient: client,
+		now:        time.Now,
+	}
+}
+
+// Token returns a valid access token, fetching a new one if the cached token
+// is missing or about to expire.
+func (s *oauth2TokenSource) Token(ctx context.Context) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.token != "" && (s.expiry.IsZero() || s.now().Add(s.config.RefreshBefore).Before(s.expiry)) {
+		return s.token, nil
+	}
+
+	resp, err := s.fetch(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	s.token = resp.AccessToken
+	s.expiry = time.Time{}
+	if resp.ExpiresIn > 0 {
+		s.expiry = s.now().Add(time.Duration(resp.ExpiresIn) * time.Second)
+	}
+	return s.token, nil
+}
+
+// Invalidate discards the cached token so the next call to Token fetches a new one.
+func (s *oauth2TokenSource) Invalidate() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.token = ""
+	s.expiry = time.Time{}
+}
+
+// fetch requests a new token from the token endpoint.
+func (s *oauth2TokenSource) fetch(ctx context.Context) (*tokenResponse, error) {
+	if s.config.TokenURL == "" {
+		return nil, fmt.Errorf("oauth2 auth requires a token URL")
+	}
+
+	form := url.Values{}
+	form.Set("grant_type", "client_credentials")
+	if len(s.config.Scopes) > 0 {
+		form.Set("scope", strings.Join(s.config.Scopes, " "))
+	}
+	if s.config.CredentialsInBody {
+		form.Set("client_id", s.config.ClientID)
+		form.Set("client_secret", s.config.ClientSecret)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.config.TokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, fmt.Errorf("error creating token request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if !s.config.CredentialsInBody {
+		req.SetBasicAuth(url.QueryEscape(s.config.ClientID), url.QueryEscape(s.config.ClientSecret))
+	}
+
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("token request error: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+	if err != nil {
+		return nil, fmt.Errorf("error reading token response: %w", err)
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, fmt.Errorf("token endpoint error: status=%d, body=%s", resp.StatusCode, string(body))
+	}
+
+	var token tokenResponse
+	if err := json.Unmarshal(body, &token); err != nil {
+		return nil, fmt.Errorf("error decoding token response: %w", err)
+	}
+	if token.AccessToken == "" {
+		return nil, fmt.Errorf("token endpoint returned no access token")
+	}
+	if token.TokenType != "" && !strings.EqualFold(token.TokenType, "bearer") {
+		return nil, fmt.Errorf("unsupported token type: %s", token.TokenType)
+	}
+
+	return &token, nil
+}
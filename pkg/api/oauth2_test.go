@@ -0,0 +1,211 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/vtriple/agentflux/pkg/processor"
+)
+
+// newTokenServer returns a token endpoint that issues numbered tokens.
+func newTokenServer(t *testing.T, expiresIn int, issued *int32) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil {
+			t.Errorf("Failed to parse token request: %v", err)
+		}
+		if r.Form.Get("grant_type") != "client_credentials" {
+			t.Errorf("Expected client_credentials grant, got %q", r.Form.Get("grant_type"))
+		}
+		user, pass, ok := r.BasicAuth()
+		if !ok || user != "client" || pass != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		n := atomic.AddInt32(issued, 1)
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"access_token":"token-%d","token_type":"Bearer","expires_in":%d}`, n, expiresIn)
+	}))
+}
+
+func TestOAuth2TokenSourceCachesToken(t *testing.T) {
+	var issued int32
+	server := newTokenServer(t, 9, &issued)
+	defer server.Close()
+
+	source := newOAuth2TokenSource(OAuth2Config{
+		TokenURL:      server.URL,
+		ClientID:      "client",
+		ClientSecret:  "secret",
+		Scopes:        []string{"results:write"},
+		RefreshBefore: time.Second,
+	}, server.Client())
+
+	now := time.Now()
+	source.now = func() time.Time { return now }
+
+	for i := 0; i < 3; i++ {
+		token, err := source.Token(context.Background())
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if token != "token-1" {
+			t.Errorf("Expected cached token-1, got %s", token)
+		}
+	}
+
+	// Move close to expiry; the token should be refreshed proactively
+	now = now.Add(8500 * time.Millisecond)
+	token, err := source.Token(context.Background())
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if token != "token-2" {
+		t.Errorf("Expected refreshed token-2, got %s", token)
+	}
+
+	source.Invalidate()
+	token, _ = source.Token(context.Background())
+	if token != "token-3" {
+		t.Errorf("Expected token-3 after invalidation, got %s", token)
+	}
+}
+
+func TestOAuth2TokenSourceErrors(t *testing.T) {
+	var issued int32
+	server := newTokenServer(t, 9, &issued)
+	defer server.Close()
+
+	source := newOAuth2TokenSource(OAuth2Config{
+		TokenURL:     server.URL,
+		ClientID:     "client",
+		ClientSecret: "wrong",
+	}, server.Client())
+	if _, err := source.Token(context.Background()); err == nil {
+		t.Error("Expected error for rejected client credentials")
+	}
+
+	source = newOAuth2TokenSource(OAuth2Config{}, server.Client())
+	if _, err := source.Token(context.Background()); err == nil {
+		t.Error("Expected error for missing token URL")
+	}
+
+	client := NewAPIClient("https://api.example.com", AuthOAuth2, "not-a-config")
+	req, _ := http.NewRequest(http.MethodGet, "https://api.example.com", nil)
+	if err := client.addAuthToRequest(req); err == nil {
+		t.Error("Expected error for invalid oauth2 credentials")
+	}
+}
+
+func TestOAuth2RetriesOnceAfterUnauthorized(t *testing.T) {
+	var issued int32
+	tokenServer := newTokenServer(t, 9, &issued)
+	defer tokenServer.Close()
+
+	var requests int32
+	apiServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		// Only the second token is accepted, simulating a revoked first token
+		if r.Header.Get("Authorization") != "Bearer token-2" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer apiServer.Close()
+
+	client := NewAPIClient(apiServer.URL, AuthOAuth2, OAuth2Config{
+		TokenURL:     tokenServer.URL,
+		ClientID:     "client",
+		ClientSecret: "secret",
+	})
+
+	err := client.sendBatch(context.Background(), []processor.FileResult{{Path: "/tmp/a", Hash: "abc"}})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if atomic.LoadInt32(&requests) != 2 {
+		t.Errorf("Expected 2 API requests, got %d", requests)
+	}
+	if atomic.LoadInt32(&issued) != 2 {
+		t.Errorf("Expected 2 tokens issued, got %d", issued)
+	}
+}
+
+func TestOAuth2UsesHTTPClientSetLater(t *testing.T) {
+	var issued int32
+	server := newTokenServer(t, 3600, &issued)
+	defer server.Close()
+
+	client := NewAPIClient("https://api.example.com", AuthOAuth2, OAuth2Config{
+		TokenURL:     server.URL,
+		ClientID:     "client",
+		ClientSecret: "secret",
+	})
+	req, _ := http.NewRequest(http.MethodGet, "https://api.example.com", nil)
+	if err := client.addAuthToRequest(req); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	// Token requests after SetHTTPClient must go through the new client
+	var viaCustom int32
+	client.SetHTTPClient(&http.Client{
+		Transport: mockRoundTripper(func(req *http.Request) (*http.Response, error) {
+			atomic.AddInt32(&viaCustom, 1)
+			return http.DefaultTransport.RoundTrip(req)
+		}),
+	})
+	req, _ = http.NewRequest(http.MethodGet, "https://api.example.com", nil)
+	if err := client.addAuthToRequest(req); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if atomic.LoadInt32(&viaCustom) != 1 {
+		t.Errorf("Expected the token request through the new HTTP client, got %d requests", viaCustom)
+	}
+	if req.Header.Get("Authorization") != "Bearer token-2" {
+		t.Errorf("Expected a token fetched with the new client, got %q", req.Header.Get("Authorization"))
+	}
+}
+
+func TestResolveSecret(t *testing.T) {
+	t.Setenv("AGENTFLUX_TEST_SECRET", "from-env")
+
+	secretFile := filepath.Join(t.TempDir(), "secret")
+	if err := os.WriteFile(secretFile, []byte("from-file\n"), 0600); err != nil {
+		t.Fatalf("Failed to write secret file: %v", err)
+	}
+
+	tests := []struct {
+		spec    string
+		want    string
+		wantErr bool
+	}{
+		{spec: "literal", want: "literal"},
+		{spec: "env:AGENTFLUX_TEST_SECRET", want: "from-env"},
+		{spec: "env:AGENTFLUX_TEST_MISSING", wantErr: true},
+		{spec: "file:" + secretFile, want: "from-file"},
+		{spec: "file:/nonexistent/secret", wantErr: true},
+	}
+
+	for _, tc := range tests {
+		got, err := ResolveSecret(tc.spec)
+		if tc.wantErr {
+			if err == nil {
+				t.Errorf("ResolveSecret(%q) expected error", tc.spec)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("ResolveSecret(%q) unexpected error: %v", tc.spec, err)
+		}
+		if got != tc.want {
+			t.Errorf("ResolveSecret(%q) = %q, want %q", tc.spec, got, tc.want)
+		}
+	}
+}
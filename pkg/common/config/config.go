@@ -1,32 +1,92 @@
 // Package config provides configuration structures and utilities.
 package config
 
+import "time"
+
 // Config holds the application configuration.
 type Config struct {
 	// File scanning options
-	RootPaths        string   // Comma-separated list of paths to scan
-	ParsedRootPaths  []string // Parsed paths
-	ExcludePaths     string   // Comma-separated list of glob patterns to exclude
+	RootPaths          string   // Comma-separated list of paths to scan
+	ParsedRootPaths    []string // Parsed paths
+	ExcludePaths       string   // Comma-separated list of glob patterns to exclude
 	ParsedExcludePaths []string // Parsed exclude patterns
-	MaxDepth         int      // Maximum directory depth (-1 for unlimited)
-	MaxFileSize      int64    // Maximum file size to process in bytes
+	MaxDepth           int      // Maximum directory depth (-1 for unlimited)
+	MaxFileSize        int64    // Maximum file size to process in bytes
+	MaxErrors          int      // Scan and file errors tolerated before the run fails (-1 for no limit)
 
 	// Hash processing options
-	HashAlgorithm    string   // Hash algorithm (md5, sha1, sha256, sha512)
-	WorkerCount      int      // Number of worker goroutines
-	ExtractStrings   bool     // Whether to extract strings from files
-	StringMinLength  int      // Minimum string length to extract
+	HashAlgorithm   string        // Hash algorithm (md5, sha1, sha256, sha512)
+	WorkerCount     int           // Number of worker goroutines
+	ExtractStrings  bool          // Whether to extract strings from files
+	StringMinLength int           // Minimum string length to extract
+	Analyzers       string        // Comma-separated analyzers to run on file content
+	AnalyzerTimeout time.Duration // Time each analyzer may take for one file
+	ExecAnalyzers   string        // Path to a JSON file of external command analyzers (empty to disable)
+
+	// Deduplication options
+	DedupType         string        // Deduplication key (hash, path, name, none, composite)
+	DedupKey          string        // Composite key expression, e.g. hash+name
+	DedupStore        string        // Path to the persistent store of delivered keys (empty to disable)
+	DedupTTL          time.Duration // How long delivered keys are remembered
+	DedupMode         string        // How seen keys are remembered (exact, bloom, lru)
+	DedupMemory       string        // Memory budget for bloom and lru modes, e.g. 256MB
+	ParsedDedupMemory int64         // Parsed memory budget in bytes
+	DedupFPRate       float64       // Target false-positive rate in bloom mode
+	Duplicates        string        // What to do with duplicates (drop, fold, sighting)
 
 	// API options
-	APIEndpoint      string   // API endpoint URL
-	APIToken         string   // API authentication token
-	APIAuthMethod    string   // API authentication method
-	APIBatchSize     int      // API batch size
+	APIEndpoint       string        // API endpoint URL
+	APIToken          string        // API authentication token (literal, env:NAME or file:/path)
+	APITokenFile      string        // Path to a file containing the API token
+	APIAuthMethod     string        // API authentication method
+	APIBatchSize      int           // API batch size
+	Labels            []string      // Key=value labels attached to every batch
+	EventsEndpoint    string        // URL for scan lifecycle events (empty to disable)
+	HeartbeatInterval time.Duration // Interval between heartbeat events (0 to disable)
+
+	// OAuth2 client-credentials options
+	OAuthTokenURL     string // OAuth2 token endpoint URL
+	OAuthClientID     string // OAuth2 client ID (literal, env:NAME or file:/path)
+	OAuthClientSecret string // OAuth2 client secret (literal, env:NAME or file:/path)
+	OAuthScopes       string // Comma-separated list of OAuth2 scopes
+
+	// HMAC signing options
+	HMACKeyID string // Key ID sent with HMAC-signed requests; the secret is taken from the token
 
 	// Logging options
-	LogLevel         string   // Log level (debug, info, warn, error)
-	LogFile          string   // Path to log file (empty for stderr)
+	LogLevel      string        // Log level with optional per-component overrides, e.g. info,api=debug
+	LogFile       string        // Path to log file (empty for stderr)
+	LogFormat     string        // Log output format (text, json, logfmt)
+	LogOutput     string        // Log destination (stderr, file, syslog, syslog+udp://host:port, journald, ...)
+	LogMaxSize    string        // Size at which the log file is rotated, e.g. 100MB (0 to disable)
+	LogMaxAge     time.Duration // Age at which the log file is rotated (0 to disable)
+	LogMaxBackups int           // Number of rotated log files to keep (0 to keep all)
+	LogCompress   bool          // Whether rotated log files are gzipped
+
+	// Report options
+	ReportFile string // Path to write the JSON run report to (empty to disable)
+	CSVFile    string // Path to write the results as CSV to (empty to disable)
+	CSVColumns string // Comma-separated CSV columns (empty for the defaults)
+	HTMLFile   string // Path to write an HTML report of the results to (empty to disable)
+
+	// Progress options
+	Estimate         bool          // Whether to count files first so progress shows a percentage and ETA
+	ProgressInterval time.Duration // Interval between progress log lines (0 to disable progress output)
+	ProgressFD       int           // File descriptor to write JSON progress records to (0 to disable)
+
+	// Metrics options
+	MetricsAddr string // Address of the Prometheus metrics listener (empty to disable)
+
+	// Daemon options
+	Schedule  string        // Cron expression or interval on which the daemon runs the profile
+	Jitter    time.Duration // Maximum random delay added to each scheduled run
+	Window    string        // Daily HH:MM-HH:MM window in which scheduled runs may start
+	MaxLoad   float64       // Load average above which scheduled runs are delayed (0 to disable)
+	StateFile string        // Path to the file recording the daemon's job status
 
 	// Misc options
-	ShowVersion      bool     // Show version information
+	ConfigFile  string // Path to the config file the settings were loaded from
+	Profile     string // Name of the config file profile to apply
+	ShowVersion bool   // Show version information
+	ConfigHash  string // SHA-256 of the effective settings, with secrets redacted
 }
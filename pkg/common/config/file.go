@@ -0,0 +1,223 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ProfilesKey is the config file section holding named profiles.
+const ProfilesKey = "profiles"
+
+// File is a parsed config file.
+type File struct {
+	// Path is the file the settings were read from.
+	Path string
+	// Values are the settings outside of any profile.
+	Values Values
+	// Profiles are the settings of each named profile. They are applied on
+	// top of Values when the profile is selected.
+	Profiles map[string]Values
+}
+
+// LoadFile reads a YAML, JSON or TOML config file, chosen by extension, and
+// returns its settings. Unknown keys are rejected.
+func LoadFile(path string) (*File, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	tree, err := parseTree(filepath.Ext(path), data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse config file %s: %w", path, err)
+	}
+
+	file := &File{Path: path, Values: Values{}, Profiles: map[string]Values{}}
+	var unknown []string
+	if profiles, ok := tree[ProfilesKey]; ok {
+		delete(tree, ProfilesKey)
+		section, ok := profiles.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("invalid config file %s: %s must be a mapping of profile names", path, ProfilesKey)
+		}
+		for name, profile := range section {
+			settings, ok := profile.(map[string]interface{})
+			if !ok {
+				unknown = append(unknown, ProfilesKey+"."+name)
+				continue
+			}
+			file.Profiles[name] = Values{}
+			flattenInto(ProfilesKey+"."+name, "", settings, file.Profiles[name], &unknown)
+		}
+	}
+	flattenInto("", "", tree, file.Values, &unknown)
+
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+		return nil, fmt.Errorf("invalid config file %s: unknown keys: %s", path, strings.Join(unknown, ", "))
+	}
+	return file, nil
+}
+
+// Profile returns the settings of the file with the named profile applied.
+// An empty name returns the settings outside of any profile.
+func (f *File) Profile(name string) (Values, error) {
+	values := Values{}
+	for key, value := range f.Values {
+		values[key] = value
+	}
+	if name == "" {
+		return values, nil
+	}
+
+	profile, ok := f.Profiles[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown profile %q in %s (available: %s)", name, f.Path, strings.Join(f.ProfileNames(), ", "))
+	}
+	for key, value := range profile {
+		values[key] = value
+	}
+	return values, nil
+}
+
+// ProfileNames returns the names of the profiles in the file, sorted.
+func (f *File) ProfileNames() []string {
+	names := make([]string, 0, len(f.Profiles))
+	for name := range f.Profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// parseTree decodes a config document into nested maps.
+func parseTree(ext string, data []byte) (map[string]interface{}, error) {
+	switch strings.ToLower(ext) {
+	case ".yaml", ".yml":
+		return parseYAML(data)
+	case ".toml":
+		return parseTOML(data)
+	case ".json":
+		tree := map[string]interface{}{}
+		if err := json.Unmarshal(data, &tree); err != nil {
+			return nil, err
+		}
+		return tree, nil
+	default:
+		return nil, fmt.Errorf("unsupported config format %q (use .yaml, .json or .toml)", ext)
+	}
+}
+
+// flattenInto walks node below prefix, adding known settings to values and
+// recording unknown keys. Keys are reported with base prepended.
+func flattenInto(base, prefix string, node map[string]interface{}, values Values, unknown *[]string) {
+	for name, value := range node {
+		key := name
+		if prefix != "" {
+			key = prefix + "." + name
+		}
+
+		// Known settings are leaves even when their value is a map, e.g. labels
+		if _, ok := FindSetting(key); ok {
+			values[key] = flagValue(value)
+			continue
+		}
+		if section, ok := value.(map[string]interface{}); ok && isSection(key) {
+			flattenInto(base, key, section, values, unknown)
+			continue
+		}
+		if base != "" {
+			key = base + "." + key
+		}
+		*unknown = append(*unknown, key)
+	}
+}
+
+// isSection reports whether key is a prefix of any setting.
+func isSection(key string) bool {
+	for _, s := range Settings {
+		if strings.HasPrefix(s.Key, key+".") {
+			return true
+		}
+	}
+	return false
+}
+
+// flagValue converts a decoded config value to the string form accepted by
+// the matching command-line flag. Lists become comma-separated values and
+// maps become comma-separated key=value pairs.
+func flagValue(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case bool:
+		return strconv.FormatBool(v)
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case []interface{}:
+		items := make([]string, len(v))
+		for i, item := range v {
+			items[i] = flagValue(item)
+		}
+		return strings.Join(items, ",")
+	case map[string]interface{}:
+		pairs := make([]string, 0, len(v))
+		for key, item := range v {
+			pairs = append(pairs, key+"="+flagValue(item))
+		}
+		sort.Strings(pairs)
+		return strings.Join(pairs, ",")
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// WriteYAML writes values as a nested YAML document in Settings order with
+// secrets redacted. When sources is non-nil, each line is annotated with
+// where its value came from.
+func WriteYAML(w io.Writer, values Values, sources map[string]string) error {
+	var path []string
+	for _, key := range values.Keys() {
+		setting, _ := FindSetting(key)
+		parts := strings.Split(key, ".")
+		sections, name := parts[:len(parts)-1], parts[len(parts)-1]
+
+		// Close sections that do not contain this key and open new ones
+		common := 0
+		for common < len(path) && common < len(sections) && path[common] == sections[common] {
+			common++
+		}
+		for i := common; i < len(sections); i++ {
+			if _, err := fmt.Fprintf(w, "%s%s:\n", strings.Repeat("  ", i), sections[i]); err != nil {
+				return err
+			}
+		}
+		path = sections
+
+		line := fmt.Sprintf("%s%s: %s", strings.Repeat("  ", len(sections)), name, yamlQuote(Redact(setting, values[key])))
+		if source := sources[key]; source != "" {
+			line += "  # " + source
+		}
+		if _, err := fmt.Fprintln(w, line); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// yamlQuote quotes value if it would not be read back as the same plain string.
+func yamlQuote(value string) string {
+	if value == "" || strings.TrimSpace(value) != value ||
+		strings.ContainsAny(value, ":#[]{}\"'\n") || strings.ContainsAny(value[:1], "-*&!|>%@`,?") {
+		return strconv.Quote(value)
+	}
+	return value
+}
@@ -0,0 +1,273 @@
+package config
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// expectedFileValues are the settings defined by each of the sample config files.
+var expectedFileValues = Values{
+	"scanner.paths":           "/srv,/home/alice's files",
+	"scanner.depth":           "5",
+	"processor.strings":       "true",
+	"dedup.fp_rate":           "0.01",
+	"api.endpoint":            "https://api.example.com/v1/results",
+	"api.token":               "env:AGENTFLUX_SECRET",
+	"api.labels":              "env=prod,team=sec",
+	"api.oauth.client_secret": "s3cr#t",
+	"logging.level":           "debug",
+}
+
+var sampleConfigs = map[string]string{
+	"agentflux.yaml": `
+# AgentFlux configuration
+---
+scanner:
+  paths:
+    - /srv
+    - "/home/alice's files"
+  depth: 5   # levels below each path
+processor:
+  strings: true
+dedup:
+  fp_rate: 0.01
+api:
+  endpoint: https://api.example.com/v1/results
+  token: env:AGENTFLUX_SECRET
+  labels:
+    env: prod
+    team: sec
+  oauth:
+    client_secret: "s3cr#t"
+logging:
+  level: debug
+`,
+	"agentflux.json": `{
+  "scanner": {"paths": ["/srv", "/home/alice's files"], "depth": 5},
+  "processor": {"strings": true},
+  "dedup": {"fp_rate": 0.01},
+  "api": {
+    "endpoint": "https://api.example.com/v1/results",
+    "token": "env:AGENTFLUX_SECRET",
+    "labels": {"team": "sec", "env": "prod"},
+    "oauth": {"client_secret": "s3cr#t"}
+  },
+  "logging": {"level": "debug"}
+}`,
+	"agentflux.toml": `
+# AgentFlux configuration
+[scanner]
+paths = [
+  "/srv",
+  "/home/alice's files",
+]
+depth = 5 # levels below each path
+
+[processor]
+strings = true
+
+[dedup]
+fp_rate = 0.01
+
+[api]
+endpoint = "https://api.example.com/v1/results"
+token = 'env:AGENTFLUX_SECRET'
+oauth.client_secret = "s3cr#t"
+
+[api.labels]
+env = "prod"
+team = "sec"
+
+[logging]
+level = "debug"
+`,
+}
+
+func writeConfig(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write config: %v", err)
+	}
+	return path
+}
+
+func TestLoadFile_Formats(t *testing.T) {
+	for name, content := range sampleConfigs {
+		t.Run(name, func(t *testing.T) {
+			file, err := LoadFile(writeConfig(t, name, content))
+			if err != nil {
+				t.Fatalf("Failed to load config: %v", err)
+			}
+			if !reflect.DeepEqual(file.Values, expectedFileValues) {
+				t.Errorf("Unexpected values:\n got: %v\nwant: %v", file.Values, expectedFileValues)
+			}
+		})
+	}
+}
+
+func TestLoadFile_Errors(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    string
+		content string
+		errText string
+	}{
+		{name: "unknown keys", file: "a.yaml", content: "scanner:\n  pathz: /srv\napi:\n  endpiont: x\nextra: 1\n",
+			errText: "unknown keys: api.endpiont, extra, scanner.pathz"},
+		{name: "section as value", file: "a.json", content: `{"scanner": "/srv"}`, errText: "unknown keys: scanner"},
+		{name: "bad indentation", file: "a.yaml", content: "scanner:\n  paths: /srv\n    depth: 1\n", errText: "line 3"},
+		{name: "duplicate key", file: "a.yaml", content: "logging:\n  level: info\n  level: debug\n", errText: "duplicate key"},
+		{name: "flow mapping", file: "a.yaml", content: "logging: {level: debug}\n", errText: "flow mappings"},
+		{name: "unquoted toml string", file: "a.toml", content: "[api]\nendpoint = https://x\n", errText: "strings must be quoted"},
+		{name: "unsupported format", file: "a.ini", content: "", errText: "unsupported config format"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := LoadFile(writeConfig(t, tt.file, tt.content))
+			if err == nil || !strings.Contains(err.Error(), tt.errText) {
+				t.Errorf("Expected error containing %q, got %v", tt.errText, err)
+			}
+		})
+	}
+
+	if _, err := LoadFile(filepath.Join(t.TempDir(), "missing.yaml")); err == nil {
+		t.Error("Expected error for missing file")
+	}
+}
+
+func TestLoadFile_Profiles(t *testing.T) {
+	content := `
+api:
+  endpoint: https://api.example.com
+processor:
+  algorithm: sha256
+profiles:
+  hourly:
+    scanner:
+      paths: [/tmp, /home/*/Downloads]
+    processor:
+      workers: 2
+  nightly:
+    scanner:
+      paths: /
+    processor:
+      algorithm: sha512
+      strings: true
+    api:
+      labels:
+        schedule: nightly
+`
+	file, err := LoadFile(writeConfig(t, "agentflux.yaml", content))
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+	if names := strings.Join(file.ProfileNames(), ","); names != "hourly,nightly" {
+		t.Errorf("Unexpected profiles %s", names)
+	}
+
+	nightly, err := file.Profile("nightly")
+	if err != nil {
+		t.Fatalf("Failed to select profile: %v", err)
+	}
+	expected := Values{
+		"api.endpoint":        "https://api.example.com",
+		"api.labels":          "schedule=nightly",
+		"processor.algorithm": "sha512",
+		"processor.strings":   "true",
+		"scanner.paths":       "/",
+	}
+	if !reflect.DeepEqual(nightly, expected) {
+		t.Errorf("Unexpected nightly settings %v", nightly)
+	}
+
+	base, _ := file.Profile("")
+	if base["processor.algorithm"] != "sha256" || base["scanner.paths"] != "" {
+		t.Errorf("Expected profiles not to change the base settings, got %v", base)
+	}
+
+	if _, err := file.Profile("weekly"); err == nil || !strings.Contains(err.Error(), "available: hourly, nightly") {
+		t.Errorf("Expected unknown profile error listing profiles, got %v", err)
+	}
+
+	bad := "profiles:\n  hourly:\n    scanner:\n      pathz: /tmp\n"
+	if _, err := LoadFile(writeConfig(t, "bad.yaml", bad)); err == nil || !strings.Contains(err.Error(), "profiles.hourly.scanner.pathz") {
+		t.Errorf("Expected unknown key inside profile to be reported, got %v", err)
+	}
+}
+
+func TestEnvValues(t *testing.T) {
+	env := map[string]string{
+		"AGENTFLUX_API_ENDPOINT":            "https://env.example.com",
+		"AGENTFLUX_API_OAUTH_CLIENT_SECRET": "secret",
+		"AGENTFLUX_PROCESSOR_WORKERS":       "2",
+		"AGENTFLUX_NOT_A_SETTING":           "ignored",
+		"OTHER_API_ENDPOINT":                "ignored",
+	}
+	values := EnvValues(func(name string) (string, bool) {
+		value, ok := env[name]
+		return value, ok
+	})
+
+	expected := Values{
+		"api.endpoint":            "https://env.example.com",
+		"api.oauth.client_secret": "secret",
+		"processor.workers":       "2",
+	}
+	if !reflect.DeepEqual(values, expected) {
+		t.Errorf("Unexpected env values: %v", values)
+	}
+}
+
+func TestWriteYAML(t *testing.T) {
+	var buf bytes.Buffer
+	sources := map[string]string{"api.endpoint": "flag", "scanner.depth": "file"}
+	if err := WriteYAML(&buf, expectedFileValues, sources); err != nil {
+		t.Fatalf("Failed to write YAML: %v", err)
+	}
+	output := buf.String()
+
+	if strings.Contains(output, "s3cr#t") {
+		t.Errorf("Expected secret to be redacted:\n%s", output)
+	}
+	for _, want := range []string{
+		"scanner:\n  paths: \"/srv,/home/alice's files\"\n  depth: 5  # file\n",
+		"  endpoint: \"https://api.example.com/v1/results\"  # flag\n",
+		"  token: \"env:AGENTFLUX_SECRET\"\n",
+		"  oauth:\n    client_secret: <redacted>\nlogging:\n  level: debug\n",
+	} {
+		if !strings.Contains(output, want) {
+			t.Errorf("Expected output to contain %q:\n%s", want, output)
+		}
+	}
+
+	// The printed config can be loaded again
+	file, err := LoadFile(writeConfig(t, "printed.yaml", output))
+	if err != nil {
+		t.Fatalf("Failed to load printed config: %v\n%s", err, output)
+	}
+	if values := file.Values; values["scanner.paths"] != expectedFileValues["scanner.paths"] || values["api.labels"] != "env=prod,team=sec" {
+		t.Errorf("Printed config did not round-trip: %v", values)
+	}
+}
+
+func TestValuesHash(t *testing.T) {
+	base := Values{"api.endpoint": "https://api.example.com", "api.token": "one", "processor.workers": "4"}
+	same := Values{"processor.workers": "4", "api.token": "two", "api.endpoint": "https://api.example.com"}
+	other := Values{"api.endpoint": "https://api.example.com", "api.token": "one", "processor.workers": "8"}
+
+	if base.Hash() != same.Hash() {
+		t.Error("Expected hash to ignore key order and secret values")
+	}
+	if base.Hash() == other.Hash() {
+		t.Error("Expected hash to change with a setting")
+	}
+	if len(base.Hash()) != 64 {
+		t.Errorf("Expected a hex SHA-256, got %q", base.Hash())
+	}
+}
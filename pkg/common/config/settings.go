@@ -0,0 +1,161 @@
+package config
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"sort"
+	"strings"
+)
+
+// EnvPrefix is the prefix of environment variables that override settings.
+const EnvPrefix = "AGENTFLUX_"
+
+// EnvConfigFile names the environment variable holding the config file path.
+const EnvConfigFile = EnvPrefix + "CONFIG"
+
+// Setting maps a config file key to the command-line flag it sets.
+type Setting struct {
+	Key    string // Dotted config file key, e.g. "api.endpoint"
+	Flag   string // Command-line flag name
+	Secret bool   // Whether the value is redacted when printed
+}
+
+// Settings lists every key accepted in config files, in display order.
+// Settings whose flag is not defined by a command are ignored by it.
+var Settings = []Setting{
+	{Key: "scanner.paths", Flag: "paths"},
+	{Key: "scanner.exclude", Flag: "exclude"},
+	{Key: "scanner.depth", Flag: "depth"},
+	{Key: "scanner.max_size", Flag: "max-size"},
+	{Key: "scanner.max_errors", Flag: "max-errors"},
+
+	{Key: "processor.algorithm", Flag: "algorithm"},
+	{Key: "processor.workers", Flag: "workers"},
+	{Key: "processor.strings", Flag: "strings"},
+	{Key: "processor.string_min", Flag: "string-min"},
+	{Key: "processor.analyzers", Flag: "analyzers"},
+	{Key: "processor.analyzer_timeout", Flag: "analyzer-timeout"},
+	{Key: "processor.exec_analyzers", Flag: "exec-analyzers"},
+
+	{Key: "dedup.type", Flag: "dedup"},
+	{Key: "dedup.key", Flag: "dedup-key"},
+	{Key: "dedup.mode", Flag: "dedup-mode"},
+	{Key: "dedup.memory", Flag: "dedup-memory"},
+	{Key: "dedup.fp_rate", Flag: "dedup-fp-rate"},
+	{Key: "dedup.duplicates", Flag: "duplicates"},
+	{Key: "dedup.store", Flag: "dedup-store"},
+	{Key: "dedup.ttl", Flag: "dedup-ttl"},
+
+	{Key: "api.endpoint", Flag: "api"},
+	{Key: "api.token", Flag: "token", Secret: true},
+	{Key: "api.token_file", Flag: "token-file"},
+	{Key: "api.auth_method", Flag: "auth-method"},
+	{Key: "api.batch_size", Flag: "batch"},
+	{Key: "api.events_endpoint", Flag: "events-endpoint"},
+	{Key: "api.heartbeat", Flag: "heartbeat"},
+	{Key: "api.labels", Flag: "label"},
+	{Key: "api.hmac_key_id", Flag: "hmac-key-id"},
+	{Key: "api.oauth.token_url", Flag: "oauth-token-url"},
+	{Key: "api.oauth.client_id", Flag: "oauth-client-id"},
+	{Key: "api.oauth.client_secret", Flag: "oauth-client-secret", Secret: true},
+	{Key: "api.oauth.scopes", Flag: "oauth-scopes"},
+
+	{Key: "logging.level", Flag: "log-level"},
+	{Key: "logging.file", Flag: "log-file"},
+	{Key: "logging.format", Flag: "log-format"},
+	{Key: "logging.output", Flag: "log-output"},
+	{Key: "logging.max_size", Flag: "log-max-size"},
+	{Key: "logging.max_age", Flag: "log-max-age"},
+	{Key: "logging.max_backups", Flag: "log-max-backups"},
+	{Key: "logging.compress", Flag: "log-compress"},
+
+	{Key: "report.file", Flag: "report"},
+	{Key: "report.csv", Flag: "csv"},
+	{Key: "report.csv_columns", Flag: "csv-columns"},
+	{Key: "report.html", Flag: "html"},
+
+	{Key: "progress.estimate", Flag: "estimate"},
+	{Key: "progress.interval", Flag: "progress-interval"},
+
+	{Key: "metrics.addr", Flag: "metrics-addr"},
+
+	{Key: "daemon.schedule", Flag: "schedule"},
+	{Key: "daemon.jitter", Flag: "jitter"},
+	{Key: "daemon.window", Flag: "window"},
+	{Key: "daemon.max_load", Flag: "max-load"},
+	{Key: "daemon.state_file", Flag: "state-file"},
+}
+
+// FindSetting returns the setting for a dotted config file key.
+func FindSetting(key string) (Setting, bool) {
+	for _, s := range Settings {
+		if s.Key == key {
+			return s, true
+		}
+	}
+	return Setting{}, false
+}
+
+// EnvName returns the environment variable that overrides a setting,
+// e.g. AGENTFLUX_API_ENDPOINT for api.endpoint.
+func EnvName(key string) string {
+	return EnvPrefix + strings.ToUpper(strings.ReplaceAll(key, ".", "_"))
+}
+
+// Values holds setting values keyed by dotted config file key.
+type Values map[string]string
+
+// Keys returns the keys of v in Settings order.
+func (v Values) Keys() []string {
+	keys := make([]string, 0, len(v))
+	for key := range v {
+		keys = append(keys, key)
+	}
+	order := make(map[string]int, len(Settings))
+	for i, s := range Settings {
+		order[s.Key] = i
+	}
+	sort.Slice(keys, func(i, j int) bool { return order[keys[i]] < order[keys[j]] })
+	return keys
+}
+
+// Hash returns a SHA-256 digest identifying the values, with secrets
+// redacted, so runs with the same effective configuration can be matched.
+func (v Values) Hash() string {
+	keys := make([]string, 0, len(v))
+	for key := range v {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	h := sha256.New()
+	for _, key := range keys {
+		value := v[key]
+		if setting, ok := FindSetting(key); ok {
+			value = Redact(setting, value)
+		}
+		h.Write([]byte(key + "=" + value + "\n"))
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// EnvValues returns the settings overridden by AGENTFLUX_* environment variables.
+// lookup is typically os.LookupEnv.
+func EnvValues(lookup func(string) (string, bool)) Values {
+	values := Values{}
+	for _, s := range Settings {
+		if value, ok := lookup(EnvName(s.Key)); ok {
+			values[s.Key] = value
+		}
+	}
+	return values
+}
+
+// Redact returns value with secrets hidden. References to secrets in the
+// environment or a file are shown since they do not reveal the secret.
+func Redact(setting Setting, value string) string {
+	if !setting.Secret || value == "" || strings.HasPrefix(value, "env:") || strings.HasPrefix(value, "file:") {
+		return value
+	}
+	return "<redacted>"
+}
@@ -0,0 +1,164 @@
+package config
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// parseTOML decodes the subset of TOML used by config files: [table]
+// headers, dotted keys, strings, numbers, booleans and arrays of those.
+// Scalars other than strings are returned in their textual form.
+func parseTOML(data []byte) (map[string]interface{}, error) {
+	root := map[string]interface{}{}
+	table := root
+
+	lines := strings.Split(string(data), "\n")
+	for i := 0; i < len(lines); i++ {
+		number := i + 1
+		line := strings.TrimSpace(stripComment(lines[i]))
+		if line == "" {
+			continue
+		}
+
+		if strings.HasPrefix(line, "[[") {
+			return nil, fmt.Errorf("line %d: arrays of tables are not supported", number)
+		}
+		if strings.HasPrefix(line, "[") {
+			if !strings.HasSuffix(line, "]") {
+				return nil, fmt.Errorf("line %d: invalid table header", number)
+			}
+			var err error
+			table, err = tomlTable(root, splitTOMLKey(line[1:len(line)-1]))
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", number, err)
+			}
+			continue
+		}
+
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			return nil, fmt.Errorf("line %d: expected key = value", number)
+		}
+		value = strings.TrimSpace(value)
+
+		// Arrays may span several lines
+		for strings.HasPrefix(value, "[") && !tomlArrayClosed(value) && i+1 < len(lines) {
+			i++
+			value += " " + strings.TrimSpace(stripComment(lines[i]))
+		}
+
+		path := splitTOMLKey(key)
+		parent, err := tomlTable(table, path[:len(path)-1])
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", number, err)
+		}
+		name := path[len(path)-1]
+		if _, exists := parent[name]; exists {
+			return nil, fmt.Errorf("line %d: duplicate key %q", number, name)
+		}
+		parsed, err := parseTOMLValue(value)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", number, err)
+		}
+		parent[name] = parsed
+	}
+	return root, nil
+}
+
+// tomlTable returns the table at path below root, creating it if needed.
+func tomlTable(root map[string]interface{}, path []string) (map[string]interface{}, error) {
+	table := root
+	for _, name := range path {
+		switch child := table[name].(type) {
+		case nil:
+			next := map[string]interface{}{}
+			table[name] = next
+			table = next
+		case map[string]interface{}:
+			table = child
+		default:
+			return nil, fmt.Errorf("key %q is not a table", name)
+		}
+	}
+	return table, nil
+}
+
+// splitTOMLKey splits a dotted key, removing quotes and whitespace.
+func splitTOMLKey(key string) []string {
+	parts := strings.Split(key, ".")
+	for i, part := range parts {
+		parts[i] = unquoteYAMLKey(strings.TrimSpace(part))
+	}
+	return parts
+}
+
+// tomlArrayClosed reports whether the brackets of an array value are balanced.
+func tomlArrayClosed(value string) bool {
+	depth := 0
+	var quote byte
+	for i := 0; i < len(value); i++ {
+		c := value[i]
+		switch {
+		case quote != 0:
+			if c == '\\' && quote == '"' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+		case (c == '"' || c == '\'') && startsToken(value, i):
+			quote = c
+		case c == '[':
+			depth++
+		case c == ']':
+			depth--
+		}
+	}
+	return depth == 0
+}
+
+// parseTOMLValue parses a string, number, boolean or array value.
+func parseTOMLValue(value string) (interface{}, error) {
+	switch {
+	case value == "":
+		return nil, fmt.Errorf("missing value")
+	case strings.HasPrefix(value, "["):
+		if !strings.HasSuffix(value, "]") {
+			return nil, fmt.Errorf("unterminated array")
+		}
+		list := []interface{}{}
+		for _, item := range splitFlowItems(value[1 : len(value)-1]) {
+			item = strings.TrimSpace(item)
+			if item == "" {
+				// Trailing commas are allowed
+				continue
+			}
+			parsed, err := parseTOMLValue(item)
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, parsed)
+		}
+		return list, nil
+	case strings.HasPrefix(value, "{"):
+		return nil, fmt.Errorf("inline tables are not supported")
+	case strings.HasPrefix(value, "\""):
+		s, err := strconv.Unquote(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid string %s", value)
+		}
+		return s, nil
+	case strings.HasPrefix(value, "'"):
+		if len(value) < 2 || !strings.HasSuffix(value, "'") {
+			return nil, fmt.Errorf("invalid string %s", value)
+		}
+		return value[1 : len(value)-1], nil
+	case value == "true" || value == "false":
+		return value, nil
+	default:
+		if _, err := strconv.ParseFloat(strings.ReplaceAll(value, "_", ""), 64); err != nil {
+			return nil, fmt.Errorf("invalid value %s (strings must be quoted)", value)
+		}
+		return strings.ReplaceAll(value, "_", ""), nil
+	}
+}
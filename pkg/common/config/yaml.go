@@ -0,0 +1,241 @@
+package config
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// yamlLine is a non-empty line of a YAML document with comments removed.
+type yamlLine struct {
+	number int
+	indent int
+	text   string
+}
+
+// parseYAML decodes the subset of YAML used by config files: nested block
+// mappings, block and flow sequences of scalars, and plain, single- or
+// double-quoted scalars. Scalars are returned as strings.
+func parseYAML(data []byte) (map[string]interface{}, error) {
+	var lines []yamlLine
+	for i, raw := range strings.Split(string(data), "\n") {
+		raw = strings.TrimRight(stripComment(raw), " \t\r")
+		trimmed := strings.TrimLeft(raw, " ")
+		if trimmed == "" || trimmed == "---" {
+			continue
+		}
+		if strings.HasPrefix(trimmed, "\t") {
+			return nil, fmt.Errorf("line %d: tabs are not allowed for indentation", i+1)
+		}
+		lines = append(lines, yamlLine{number: i + 1, indent: len(raw) - len(trimmed), text: trimmed})
+	}
+	if len(lines) == 0 {
+		return map[string]interface{}{}, nil
+	}
+
+	p := &yamlParser{lines: lines}
+	node, err := p.parseMapping(lines[0].indent)
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(lines) {
+		return nil, fmt.Errorf("line %d: unexpected indentation", lines[p.pos].number)
+	}
+	return node, nil
+}
+
+// yamlParser walks the lines of a YAML document.
+type yamlParser struct {
+	lines []yamlLine
+	pos   int
+}
+
+// parseMapping parses key: value lines at indent.
+func (p *yamlParser) parseMapping(indent int) (map[string]interface{}, error) {
+	node := map[string]interface{}{}
+	for p.pos < len(p.lines) {
+		line := p.lines[p.pos]
+		if line.indent < indent {
+			break
+		}
+		if line.indent > indent {
+			return nil, fmt.Errorf("line %d: unexpected indentation", line.number)
+		}
+		if isYAMLListItem(line.text) {
+			return nil, fmt.Errorf("line %d: unexpected list item", line.number)
+		}
+
+		key, rest, ok := strings.Cut(line.text, ":")
+		if !ok || (rest != "" && rest[0] != ' ') {
+			return nil, fmt.Errorf("line %d: expected key: value", line.number)
+		}
+		key = unquoteYAMLKey(strings.TrimSpace(key))
+		rest = strings.TrimSpace(rest)
+		if _, exists := node[key]; exists {
+			return nil, fmt.Errorf("line %d: duplicate key %q", line.number, key)
+		}
+		p.pos++
+
+		if rest != "" {
+			value, err := parseYAMLScalar(rest)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", line.number, err)
+			}
+			node[key] = value
+			continue
+		}
+
+		// An empty value introduces a nested block, if any
+		if p.pos >= len(p.lines) {
+			node[key] = nil
+			continue
+		}
+		next := p.lines[p.pos]
+		switch {
+		case isYAMLListItem(next.text) && next.indent >= indent:
+			list, err := p.parseSequence(next.indent)
+			if err != nil {
+				return nil, err
+			}
+			node[key] = list
+		case next.indent > indent:
+			child, err := p.parseMapping(next.indent)
+			if err != nil {
+				return nil, err
+			}
+			node[key] = child
+		default:
+			node[key] = nil
+		}
+	}
+	return node, nil
+}
+
+// parseSequence parses "- item" lines at indent. Items must be scalars.
+func (p *yamlParser) parseSequence(indent int) ([]interface{}, error) {
+	list := []interface{}{}
+	for p.pos < len(p.lines) {
+		line := p.lines[p.pos]
+		if line.indent != indent || !isYAMLListItem(line.text) {
+			break
+		}
+		item := strings.TrimSpace(strings.TrimPrefix(line.text, "-"))
+		quoted := strings.HasPrefix(item, "\"") || strings.HasPrefix(item, "'")
+		if !quoted && (strings.Contains(item, ": ") || strings.HasSuffix(item, ":")) {
+			return nil, fmt.Errorf("line %d: lists of mappings are not supported", line.number)
+		}
+		value, err := parseYAMLScalar(item)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line.number, err)
+		}
+		list = append(list, value)
+		p.pos++
+	}
+	return list, nil
+}
+
+// isYAMLListItem reports whether text is a block sequence entry.
+func isYAMLListItem(text string) bool {
+	return text == "-" || strings.HasPrefix(text, "- ")
+}
+
+// parseYAMLScalar parses a scalar or flow sequence value.
+func parseYAMLScalar(text string) (interface{}, error) {
+	switch {
+	case strings.HasPrefix(text, "["):
+		if !strings.HasSuffix(text, "]") {
+			return nil, fmt.Errorf("unterminated flow sequence")
+		}
+		inner := strings.TrimSpace(text[1 : len(text)-1])
+		list := []interface{}{}
+		if inner == "" {
+			return list, nil
+		}
+		for _, item := range splitFlowItems(inner) {
+			value, err := parseYAMLScalar(strings.TrimSpace(item))
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, value)
+		}
+		return list, nil
+	case strings.HasPrefix(text, "{"):
+		return nil, fmt.Errorf("flow mappings are not supported")
+	case strings.HasPrefix(text, "\""):
+		value, err := strconv.Unquote(text)
+		if err != nil {
+			return nil, fmt.Errorf("invalid quoted string %s", text)
+		}
+		return value, nil
+	case strings.HasPrefix(text, "'"):
+		if len(text) < 2 || !strings.HasSuffix(text, "'") {
+			return nil, fmt.Errorf("invalid quoted string %s", text)
+		}
+		return strings.ReplaceAll(text[1:len(text)-1], "''", "'"), nil
+	case text == "~" || text == "null":
+		return nil, nil
+	case strings.HasPrefix(text, "|") || strings.HasPrefix(text, ">"):
+		return nil, fmt.Errorf("block scalars are not supported")
+	default:
+		return text, nil
+	}
+}
+
+// unquoteYAMLKey removes quotes around a mapping key.
+func unquoteYAMLKey(key string) string {
+	if len(key) >= 2 && (key[0] == '"' || key[0] == '\'') && key[len(key)-1] == key[0] {
+		return key[1 : len(key)-1]
+	}
+	return key
+}
+
+// stripComment removes a trailing # comment that is not inside quotes.
+func stripComment(line string) string {
+	var quote byte
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+		switch {
+		case quote != 0:
+			if c == '\\' && quote == '"' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+		case (c == '"' || c == '\'') && startsToken(line, i):
+			quote = c
+		case c == '#' && (i == 0 || line[i-1] == ' ' || line[i-1] == '\t'):
+			return line[:i]
+		}
+	}
+	return line
+}
+
+// splitFlowItems splits a comma-separated list, ignoring commas inside quotes.
+func splitFlowItems(s string) []string {
+	var items []string
+	var quote byte
+	start := 0
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case quote != 0:
+			if c == '\\' && quote == '"' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+		case (c == '"' || c == '\'') && startsToken(s, i):
+			quote = c
+		case c == ',':
+			items = append(items, s[start:i])
+			start = i + 1
+		}
+	}
+	return append(items, s[start:])
+}
+
+// startsToken reports whether s[i] begins a value, so that apostrophes
+// inside plain words are not mistaken for quotes.
+func startsToken(s string, i int) bool {
+	return i == 0 || strings.IndexByte(" \t:[,=", s[i-1]) >= 0
+}
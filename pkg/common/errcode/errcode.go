@@ -0,0 +1,129 @@
+// Package errcode defines the categories of errors reported for files that
+// could not be scanned, processed or delivered, so failures can be
+// aggregated by a stable code rather than by message.
+package errcode
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net"
+	"net/http"
+	"os"
+
+	"github.com/vtriple/agentflux/pkg/common/fileutils"
+)
+
+// Code is the category of an error. Codes are part of the API and report
+// formats and must not change.
+type Code string
+
+const (
+	// PermissionDenied means the agent was not allowed to read a path.
+	PermissionDenied Code = "permission_denied"
+	// Vanished means a path was removed between being found and being read.
+	Vanished Code = "vanished"
+	// TooLarge means a file exceeded the configured size limit.
+	TooLarge Code = "too_large"
+	// IO means reading a path failed for another reason.
+	IO Code = "io_error"
+	// Excluded means a path was skipped by an exclude pattern.
+	Excluded Code = "excluded"
+	// Timeout means an operation did not complete in time.
+	Timeout Code = "timeout"
+	// Cancelled means an operation was interrupted by shutdown.
+	Cancelled Code = "cancelled"
+	// Network means a request to the API could not be made.
+	Network Code = "network"
+	// Rejected means the API refused a request with a 4xx status.
+	Rejected Code = "rejected"
+	// ServerError means the API failed a request with a 5xx status.
+	ServerError Code = "server_error"
+	// Unknown is used for errors that fit no other category.
+	Unknown Code = "unknown"
+)
+
+// Error is an error with a category.
+type Error struct {
+	Code Code
+	Err  error
+}
+
+// Error returns the message of the underlying error.
+func (e *Error) Error() string {
+	return e.Err.Error()
+}
+
+// Unwrap returns the underlying error.
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// New returns err with the given code.
+func New(code Code, err error) *Error {
+	return &Error{Code: code, Err: err}
+}
+
+// Errorf formats an error with the given code, wrapping any %w argument.
+func Errorf(code Code, format string, args ...any) *Error {
+	return &Error{Code: code, Err: fmt.Errorf(format, args...)}
+}
+
+// File wraps err from a file system operation with a message made from
+// format and args, e.g. File(err, "error reading directory %s", path). The
+// code is derived from err, defaulting to IO for errors without a code.
+func File(err error, format string, args ...any) *Error {
+	code := Of(err)
+	var coded *Error
+	if code == Unknown && !errors.As(err, &coded) {
+		code = IO
+	}
+	return &Error{Code: code, Err: fmt.Errorf(format+": %w", append(args, err)...)}
+}
+
+// Of returns the code of err: the code of the first Error in its chain, or
+// one derived from well-known errors. It returns "" for nil.
+func Of(err error) Code {
+	if err == nil {
+		return ""
+	}
+	var coded *Error
+	if errors.As(err, &coded) {
+		return coded.Code
+	}
+
+	var netErr net.Error
+	switch {
+	case errors.Is(err, fs.ErrPermission), errors.Is(err, fileutils.ErrAccessDenied):
+		return PermissionDenied
+	case errors.Is(err, fs.ErrNotExist):
+		return Vanished
+	case errors.Is(err, fileutils.ErrFileTooLarge):
+		return TooLarge
+	case errors.Is(err, context.Canceled):
+		return Cancelled
+	case errors.Is(err, context.DeadlineExceeded), errors.Is(err, os.ErrDeadlineExceeded):
+		return Timeout
+	case errors.As(err, &netErr):
+		if netErr.Timeout() {
+			return Timeout
+		}
+		return Network
+	}
+	return Unknown
+}
+
+// ForStatus returns the code of a failed HTTP response.
+func ForStatus(status int) Code {
+	switch {
+	case status == http.StatusRequestTimeout || status == http.StatusGatewayTimeout:
+		return Timeout
+	case status >= 500:
+		return ServerError
+	case status >= 400:
+		return Rejected
+	default:
+		return Unknown
+	}
+}
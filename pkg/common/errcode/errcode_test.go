@@ -0,0 +1,68 @@
+package errcode
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"testing"
+
+	"github.com/vtriple/agentflux/pkg/common/fileutils"
+)
+
+func TestOf(t *testing.T) {
+	tests := []struct {
+		err  error
+		want Code
+	}{
+		{nil, ""},
+		{fmt.Errorf("open /x: %w", fs.ErrPermission), PermissionDenied},
+		{fileutils.ErrAccessDenied, PermissionDenied},
+		{fmt.Errorf("stat /x: %w", fs.ErrNotExist), Vanished},
+		{fmt.Errorf("%w (10 bytes)", fileutils.ErrFileTooLarge), TooLarge},
+		{context.Canceled, Cancelled},
+		{fmt.Errorf("post: %w", context.DeadlineExceeded), Timeout},
+		{errors.New("odd"), Unknown},
+		{fmt.Errorf("failed to send batch: %w", Errorf(Rejected, "status=400")), Rejected},
+	}
+	for _, tt := range tests {
+		if got := Of(tt.err); got != tt.want {
+			t.Errorf("Of(%v) = %q, want %q", tt.err, got, tt.want)
+		}
+	}
+}
+
+func TestFile(t *testing.T) {
+	err := File(fmt.Errorf("open /x: %w", fs.ErrPermission), "error reading directory %s", "/x")
+	if err.Code != PermissionDenied {
+		t.Errorf("Code = %q, want %q", err.Code, PermissionDenied)
+	}
+	if err.Error() != "error reading directory /x: open /x: permission denied" {
+		t.Errorf("Error() = %q", err.Error())
+	}
+	if !errors.Is(err, fs.ErrPermission) {
+		t.Error("expected the underlying error to be preserved")
+	}
+
+	if code := File(errors.New("input/output error"), "hash error").Code; code != IO {
+		t.Errorf("File() of an unknown error = %q, want %q", code, IO)
+	}
+	if code := File(Errorf(Unknown, "unsupported hash algorithm"), "hash error").Code; code != Unknown {
+		t.Errorf("File() of a coded error = %q, want %q", code, Unknown)
+	}
+}
+
+func TestForStatus(t *testing.T) {
+	tests := map[int]Code{
+		http.StatusBadRequest:          Rejected,
+		http.StatusTooManyRequests:     Rejected,
+		http.StatusGatewayTimeout:      Timeout,
+		http.StatusInternalServerError: ServerError,
+	}
+	for status, want := range tests {
+		if got := ForStatus(status); got != want {
+			t.Errorf("ForStatus(%d) = %q, want %q", status, got, want)
+		}
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"io"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"testing"
 )
@@ -167,6 +168,36 @@ func FileExtension(path string) string {
 	return ext[1:] // Remove the leading dot
 }
 
+// ParseSize parses a human-readable size such as "512", "64KB", "256MB" or "1GiB"
+// into a number of bytes. Suffixes are binary multiples and case-insensitive.
+func ParseSize(s string) (int64, error) {
+	value := strings.ToUpper(strings.TrimSpace(s))
+	multipliers := []struct {
+		suffix string
+		factor int64
+	}{
+		{"TIB", 1 << 40}, {"GIB", 1 << 30}, {"MIB", 1 << 20}, {"KIB", 1 << 10},
+		{"TB", 1 << 40}, {"GB", 1 << 30}, {"MB", 1 << 20}, {"KB", 1 << 10},
+		{"T", 1 << 40}, {"G", 1 << 30}, {"M", 1 << 20}, {"K", 1 << 10},
+		{"B", 1},
+	}
+
+	factor := int64(1)
+	for _, m := range multipliers {
+		if strings.HasSuffix(value, m.suffix) {
+			value = strings.TrimSpace(strings.TrimSuffix(value, m.suffix))
+			factor = m.factor
+			break
+		}
+	}
+
+	n, err := strconv.ParseFloat(value, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid size: %q", s)
+	}
+	return int64(n * float64(factor)), nil
+}
+
 // ReadFileLines reads a file and returns its lines as a string slice
 func ReadFileLines(path string) ([]string, error) {
 	// Read the file
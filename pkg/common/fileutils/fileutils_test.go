@@ -764,3 +764,39 @@ func TestHelperFunctions(t *testing.T) {
 		t.Errorf("Expected slices of different lengths to return false")
 	}
 }
+
+func TestParseSize(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+		wantErr  bool
+	}{
+		{input: "512", expected: 512},
+		{input: "64KB", expected: 64 * 1024},
+		{input: "256mb", expected: 256 * 1024 * 1024},
+		{input: "1.5G", expected: 3 * 512 * 1024 * 1024},
+		{input: "2 GiB", expected: 2 * 1024 * 1024 * 1024},
+		{input: "10B", expected: 10},
+		{input: "", wantErr: true},
+		{input: "lots", wantErr: true},
+		{input: "-1MB", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := ParseSize(tt.input)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("ParseSize(%q) expected error, got %d", tt.input, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseSize(%q) unexpected error: %v", tt.input, err)
+			}
+			if got != tt.expected {
+				t.Errorf("ParseSize(%q) = %d, want %d", tt.input, got, tt.expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,55 @@
+// Package hostinfo collects identifying information about the local host.
+package hostinfo
+
+import (
+	"os"
+	"runtime"
+	"strings"
+)
+
+// machineIDPaths lists the files that may contain the host's machine ID.
+var machineIDPaths = []string{
+	"/etc/machine-id",
+	"/var/lib/dbus/machine-id",
+}
+
+// Info describes the host an agent runs on.
+type Info struct {
+	// Hostname is the host name reported by the kernel.
+	Hostname string `json:"hostname"`
+	// MachineID is the stable machine identifier, if available.
+	MachineID string `json:"machineId,omitempty"`
+	// OS is the operating system (runtime.GOOS).
+	OS string `json:"os"`
+	// Arch is the CPU architecture (runtime.GOARCH).
+	Arch string `json:"arch"`
+	// Kernel is the kernel release, if available.
+	Kernel string `json:"kernel,omitempty"`
+}
+
+// Collect gathers information about the local host.
+// Fields that cannot be determined are left empty.
+func Collect() Info {
+	hostname, _ := os.Hostname()
+	return Info{
+		Hostname:  hostname,
+		MachineID: readMachineID(),
+		OS:        runtime.GOOS,
+		Arch:      runtime.GOARCH,
+		Kernel:    kernelRelease(),
+	}
+}
+
+// readMachineID returns the first non-empty machine ID found on the host.
+func readMachineID() string {
+	for _, path := range machineIDPaths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			continue
+		}
+		if id := strings.TrimSpace(string(data)); id != "" {
+			return id
+		}
+	}
+	return ""
+}
@@ -0,0 +1,49 @@
+package hostinfo
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func TestCollect(t *testing.T) {
+	info := Collect()
+
+	if info.OS != runtime.GOOS {
+		t.Errorf("Expected OS %s, got %s", runtime.GOOS, info.OS)
+	}
+	if info.Arch != runtime.GOARCH {
+		t.Errorf("Expected arch %s, got %s", runtime.GOARCH, info.Arch)
+	}
+
+	hostname, err := os.Hostname()
+	if err == nil && info.Hostname != hostname {
+		t.Errorf("Expected hostname %s, got %s", hostname, info.Hostname)
+	}
+
+	if runtime.GOOS == "linux" && info.Kernel == "" {
+		t.Error("Expected kernel release on linux")
+	}
+}
+
+func TestReadMachineID(t *testing.T) {
+	dir := t.TempDir()
+	empty := filepath.Join(dir, "empty")
+	valid := filepath.Join(dir, "machine-id")
+	os.WriteFile(empty, []byte("\n"), 0644)
+	os.WriteFile(valid, []byte("0123456789abcdef\n"), 0644)
+
+	oldPaths := machineIDPaths
+	defer func() { machineIDPaths = oldPaths }()
+
+	machineIDPaths = []string{filepath.Join(dir, "missing"), empty, valid}
+	if id := readMachineID(); id != "0123456789abcdef" {
+		t.Errorf("Expected machine ID 0123456789abcdef, got %q", id)
+	}
+
+	machineIDPaths = []string{filepath.Join(dir, "missing")}
+	if id := readMachineID(); id != "" {
+		t.Errorf("Expected empty machine ID, got %q", id)
+	}
+}
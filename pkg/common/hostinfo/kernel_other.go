@@ -0,0 +1,8 @@
+//go:build !linux
+
+package hostinfo
+
+// kernelRelease is not implemented on this platform.
+func kernelRelease() string {
+	return ""
+}
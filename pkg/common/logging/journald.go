@@ -0,0 +1,135 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"log/slog"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// DefaultJournalSocket is the socket on which journald accepts native protocol messages.
+const DefaultJournalSocket = "/run/systemd/journal/socket"
+
+// JournalHandler is a slog.Handler that sends records to systemd-journald
+// over its native socket protocol. Logger fields become journal fields with
+// upper-case names, and the component name is sent as LOGGER.
+type JournalHandler struct {
+	shared *journalConn
+	attrs  []slog.Attr
+	group  string
+}
+
+// journalConn is the socket shared by a handler and its derived handlers.
+type journalConn struct {
+	conn       *net.UnixConn
+	addr       *net.UnixAddr
+	identifier string
+	mu         sync.Mutex
+}
+
+// NewJournalHandler opens the journald socket at socketPath, or at
+// DefaultJournalSocket if it is empty.
+func NewJournalHandler(socketPath string) (*JournalHandler, error) {
+	if socketPath == "" {
+		socketPath = DefaultJournalSocket
+	}
+	if _, err := os.Stat(socketPath); err != nil {
+		return nil, fmt.Errorf("journald is not available: %w", err)
+	}
+
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Net: "unixgram"})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open journald socket: %w", err)
+	}
+	return &JournalHandler{shared: &journalConn{
+		conn:       conn,
+		addr:       &net.UnixAddr{Name: socketPath, Net: "unixgram"},
+		identifier: filepath.Base(os.Args[0]),
+	}}, nil
+}
+
+// Enabled reports true; levels are filtered by the logging package.
+func (h *JournalHandler) Enabled(context.Context, slog.Level) bool {
+	return true
+}
+
+// Handle sends the record as a journal entry.
+func (h *JournalHandler) Handle(_ context.Context, record slog.Record) error {
+	var b bytes.Buffer
+	writeJournalField(&b, "MESSAGE", record.Message)
+	writeJournalField(&b, "PRIORITY", strconv.Itoa(syslogSeverity(record.Level)))
+	writeJournalField(&b, "SYSLOG_IDENTIFIER", h.shared.identifier)
+	if record.PC != 0 {
+		if frame, _ := runtimeFrame(record.PC); frame.File != "" {
+			writeJournalField(&b, "CODE_FILE", frame.File)
+			writeJournalField(&b, "CODE_LINE", strconv.Itoa(frame.Line))
+			writeJournalField(&b, "CODE_FUNC", frame.Function)
+		}
+	}
+	eachAttr(h.group, h.attrs, record, func(key string, value slog.Value) {
+		writeJournalField(&b, journalFieldName(key), value.String())
+	})
+
+	h.shared.mu.Lock()
+	defer h.shared.mu.Unlock()
+	_, _, err := h.shared.conn.WriteMsgUnix(b.Bytes(), nil, h.shared.addr)
+	return err
+}
+
+// WithAttrs returns a handler that adds attrs to every entry.
+func (h *JournalHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &JournalHandler{shared: h.shared, attrs: withGroup(h.group, h.attrs, attrs), group: h.group}
+}
+
+// WithGroup returns a handler that qualifies later attributes with name.
+func (h *JournalHandler) WithGroup(name string) slog.Handler {
+	return &JournalHandler{shared: h.shared, attrs: h.attrs, group: h.group + name + "."}
+}
+
+// Close closes the journald socket.
+func (h *JournalHandler) Close() error {
+	return h.shared.conn.Close()
+}
+
+// writeJournalField appends a field in the native protocol. Values containing
+// newlines are sent in the binary form with an explicit length.
+func writeJournalField(b *bytes.Buffer, name, value string) {
+	if !strings.Contains(value, "\n") {
+		b.WriteString(name + "=" + value + "\n")
+		return
+	}
+	b.WriteString(name + "\n")
+	binary.Write(b, binary.LittleEndian, uint64(len(value)))
+	b.WriteString(value + "\n")
+}
+
+// journalFieldName converts an attribute key to a valid journal field name:
+// upper-case letters, digits and underscores, not starting with an underscore
+// or digit.
+func journalFieldName(key string) string {
+	name := strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z':
+			return r - 'a' + 'A'
+		case r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+			return r
+		default:
+			return '_'
+		}
+	}, key)
+	name = strings.TrimLeft(name, "_0123456789")
+	if name == "" {
+		return "FIELD"
+	}
+	if len(name) > 64 {
+		name = name[:64]
+	}
+	return name
+}
@@ -0,0 +1,77 @@
+package logging
+
+import (
+	"bytes"
+	"encoding/binary"
+	"net"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestJournalHandler(t *testing.T) {
+	socketPath := filepath.Join(t.TempDir(), "journal.sock")
+	listener, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: socketPath, Net: "unixgram"})
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	defer listener.Close()
+
+	handler, err := NewJournalHandler(socketPath)
+	if err != nil {
+		t.Fatalf("Failed to create handler: %v", err)
+	}
+	defer handler.Close()
+
+	captureOutput(t, FormatText)
+	SetHandler(handler)
+	defer SetHandler(nil)
+	NewLogger("scanner").With("file-path", "/srv/a.txt", "detail", "line one\nline two").Warn("Cannot read file")
+
+	buf := make([]byte, 4096)
+	listener.SetReadDeadline(time.Now().Add(5 * time.Second))
+	n, err := listener.Read(buf)
+	if err != nil {
+		t.Fatalf("Failed to read entry: %v", err)
+	}
+	entry := buf[:n]
+
+	for _, field := range []string{
+		"MESSAGE=Cannot read file\n",
+		"PRIORITY=4\n",
+		"LOGGER=scanner\n",
+		"FILE_PATH=/srv/a.txt\n",
+		"CODE_LINE=",
+	} {
+		if !bytes.Contains(entry, []byte(field)) {
+			t.Errorf("Expected entry to contain %q, got %q", field, entry)
+		}
+	}
+
+	// Multi-line values use the length-prefixed binary form
+	var binaryField bytes.Buffer
+	binaryField.WriteString("DETAIL\n")
+	binary.Write(&binaryField, binary.LittleEndian, uint64(len("line one\nline two")))
+	binaryField.WriteString("line one\nline two\n")
+	if !bytes.Contains(entry, binaryField.Bytes()) {
+		t.Errorf("Expected binary DETAIL field, got %q", entry)
+	}
+
+	if _, err := NewJournalHandler(filepath.Join(t.TempDir(), "missing.sock")); err == nil {
+		t.Error("Expected error for missing journald socket")
+	}
+}
+
+func TestJournalFieldName(t *testing.T) {
+	for key, want := range map[string]string{
+		"path":       "PATH",
+		"dedup.mode": "DEDUP_MODE",
+		"_private":   "PRIVATE",
+		"1st":        "ST",
+		"--":         "FIELD",
+	} {
+		if got := journalFieldName(key); got != want {
+			t.Errorf("journalFieldName(%q) = %q, want %q", key, got, want)
+		}
+	}
+}
@@ -2,11 +2,14 @@
 package logging
 
 import (
+	"context"
 	"fmt"
 	"io"
+	"log/slog"
 	"os"
 	"path/filepath"
 	"runtime"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -53,19 +56,34 @@ var (
 	// globalLevel is the minimum log level that will be output.
 	globalLevel LogLevel = InfoLevel
 
+	// componentLevels overrides globalLevel for loggers by name.
+	componentLevels map[string]LogLevel
+
 	// globalOutput is where log messages are written.
 	globalOutput io.Writer = os.Stderr
 
 	// globalColorEnabled determines if color output is enabled.
 	globalColorEnabled = true
 
+	// globalFormat is the output format of log records.
+	globalFormat = FormatText
+
+	// formatHandler writes records in globalFormat to globalOutput. It is nil
+	// for the text format and rebuilt whenever either changes.
+	formatHandler slog.Handler
+
+	// globalHandler, when set, receives every log record instead of the
+	// built-in formats.
+	globalHandler slog.Handler
+
 	// logMutex protects access to the global logging state.
 	logMutex sync.Mutex
 )
 
 // Logger represents a logger for a specific component.
 type Logger struct {
-	name string
+	name  string
+	attrs []slog.Attr
 }
 
 // NewLogger creates a new logger with the given component name.
@@ -73,26 +91,119 @@ func NewLogger(name string) *Logger {
 	return &Logger{name: name}
 }
 
+// With returns a logger that adds the given key/value pairs to every message,
+// e.g. logger.With("path", path). Arguments are interpreted as by slog.Logger.With.
+func (l *Logger) With(args ...interface{}) *Logger {
+	var record slog.Record
+	record.Add(args...)
+
+	attrs := make([]slog.Attr, len(l.attrs), len(l.attrs)+record.NumAttrs())
+	copy(attrs, l.attrs)
+	record.Attrs(func(attr slog.Attr) bool {
+		attrs = append(attrs, attr)
+		return true
+	})
+	return &Logger{name: l.name, attrs: attrs}
+}
+
 // SetGlobalLevel sets the minimum log level that will be output.
 func SetGlobalLevel(level string) {
 	logMutex.Lock()
 	defer logMutex.Unlock()
 
-	switch strings.ToLower(level) {
+	parsed, err := ParseLevel(level)
+	if err != nil {
+		// If the level is invalid, default to InfoLevel
+		parsed = InfoLevel
+	}
+	globalLevel = parsed
+}
+
+// ParseLevel parses a level name such as "debug" or "warn".
+func ParseLevel(level string) (LogLevel, error) {
+	switch strings.ToLower(strings.TrimSpace(level)) {
 	case "debug":
-		globalLevel = DebugLevel
+		return DebugLevel, nil
 	case "info":
-		globalLevel = InfoLevel
+		return InfoLevel, nil
 	case "warn", "warning":
-		globalLevel = WarnLevel
+		return WarnLevel, nil
 	case "error":
-		globalLevel = ErrorLevel
+		return ErrorLevel, nil
 	case "fatal":
-		globalLevel = FatalLevel
+		return FatalLevel, nil
 	default:
-		// If the level is invalid, default to InfoLevel
-		globalLevel = InfoLevel
+		return InfoLevel, fmt.Errorf("invalid log level: %s", level)
+	}
+}
+
+// ParseLevels parses a level specification such as "info,api=debug,scanner=warn":
+// a default level followed by overrides for loggers by the name given to
+// NewLogger. The default may be omitted, in which case it is info.
+func ParseLevels(spec string) (LogLevel, map[string]LogLevel, error) {
+	level := InfoLevel
+	components := map[string]LogLevel{}
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		name, value, isOverride := strings.Cut(part, "=")
+		parsed, err := ParseLevel(value)
+		if !isOverride {
+			parsed, err = ParseLevel(part)
+		}
+		if err != nil {
+			return InfoLevel, nil, err
+		}
+		if !isOverride {
+			level = parsed
+			continue
+		}
+		name = strings.TrimSpace(name)
+		if name == "" {
+			return InfoLevel, nil, fmt.Errorf("invalid log level override: %s", part)
+		}
+		components[name] = parsed
+	}
+	return level, components, nil
+}
+
+// SetLevels sets the default log level and per-logger overrides from a
+// specification accepted by ParseLevels.
+func SetLevels(spec string) error {
+	level, components, err := ParseLevels(spec)
+	if err != nil {
+		return err
+	}
+
+	logMutex.Lock()
+	defer logMutex.Unlock()
+	globalLevel = level
+	componentLevels = components
+	return nil
+}
+
+// SetRotatingLogFile sets the output to a log file that is rotated according
+// to opts. The file and its directory are created if they don't exist.
+func SetRotatingLogFile(filePath string, opts RotateOptions) error {
+	file, err := OpenRotatingFile(filePath, opts)
+	if err != nil {
+		return err
+	}
+
+	logMutex.Lock()
+	defer logMutex.Unlock()
+
+	// Close the previous file if it was a file
+	if closer, ok := globalOutput.(io.Closer); ok && globalOutput != os.Stderr {
+		closer.Close()
 	}
+
+	globalOutput = file
+	globalColorEnabled = false
+	updateFormatHandler()
+	return nil
 }
 
 // SetLogFile sets the output file for logs.
@@ -121,6 +232,7 @@ func SetLogFile(filePath string) error {
 
 	globalOutput = file
 	globalColorEnabled = false
+	updateFormatHandler()
 	return nil
 }
 
@@ -161,38 +273,79 @@ func (l *Logger) Fatal(format string, args ...interface{}) {
 func (l *Logger) log(level LogLevel, format string, args ...interface{}) {
 	// Check if this level should be logged
 	logMutex.Lock()
-	if level < globalLevel {
+	minLevel, ok := componentLevels[l.name]
+	if !ok {
+		minLevel = globalLevel
+	}
+	if level < minLevel {
 		logMutex.Unlock()
 		return
 	}
 	output := globalOutput
 	colorEnabled := globalColorEnabled
+	handler := globalHandler
+	if handler == nil {
+		handler = formatHandler
+	}
 	logMutex.Unlock()
 
-	// Get caller information
-	_, file, line, ok := runtime.Caller(2)
-	callerInfo := "unknown"
-	if ok {
-		// Extract just the filename from the full path
-		file = filepath.Base(file)
-		callerInfo = fmt.Sprintf("%s:%d", file, line)
-	}
+	// Get the caller of Debug, Info, etc.
+	var pcs [1]uintptr
+	runtime.Callers(3, pcs[:])
 
 	// Format the message
 	message := fmt.Sprintf(format, args...)
-	timestamp := time.Now().Format("2006-01-02 15:04:05.000")
+	now := time.Now()
+
+	if handler != nil {
+		record := slog.NewRecord(now, slogLevel(level), message, pcs[0])
+		record.AddAttrs(slog.String(LoggerKey, l.name))
+		record.AddAttrs(l.attrs...)
+		if handler.Enabled(context.Background(), record.Level) {
+			handler.Handle(context.Background(), record)
+		}
+		return
+	}
+
+	callerInfo := "unknown"
+	if frame, _ := runtime.CallersFrames(pcs[:]).Next(); frame.File != "" {
+		// Extract just the filename from the full path
+		callerInfo = fmt.Sprintf("%s:%d", filepath.Base(frame.File), frame.Line)
+	}
+	timestamp := now.Format("2006-01-02 15:04:05.000")
 	levelName := levelNames[level]
-	
-	var logLine string
+
+	var logLine strings.Builder
 	if colorEnabled {
 		color := levelColors[level]
-		logLine = fmt.Sprintf("%s [%s%s%s] [%s] [%s] %s\n",
+		fmt.Fprintf(&logLine, "%s [%s%s%s] [%s] [%s] %s",
 			timestamp, color, levelName, resetColor, l.name, callerInfo, message)
 	} else {
-		logLine = fmt.Sprintf("%s [%s] [%s] [%s] %s\n",
+		fmt.Fprintf(&logLine, "%s [%s] [%s] [%s] %s",
 			timestamp, levelName, l.name, callerInfo, message)
 	}
+	for _, attr := range l.attrs {
+		appendAttr(&logLine, "", attr)
+	}
+	logLine.WriteByte('\n')
 
 	// Write to the output
-	fmt.Fprint(output, logLine)
+	fmt.Fprint(output, logLine.String())
+}
+
+// appendAttr writes attr to b as " key=value", flattening groups into dotted keys.
+func appendAttr(b *strings.Builder, prefix string, attr slog.Attr) {
+	value := attr.Value.Resolve()
+	if value.Kind() == slog.KindGroup {
+		for _, member := range value.Group() {
+			appendAttr(b, prefix+attr.Key+".", member)
+		}
+		return
+	}
+
+	text := value.String()
+	if text == "" || strings.ContainsAny(text, " =\"\t\n") {
+		text = strconv.Quote(text)
+	}
+	fmt.Fprintf(b, " %s%s=%s", prefix, attr.Key, text)
 }
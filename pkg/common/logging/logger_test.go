@@ -2,7 +2,9 @@ package logging
 
 import (
 	"bytes"
+	"encoding/json"
 	"io"
+	"log/slog"
 	"os"
 	"path/filepath"
 	"strings"
@@ -235,3 +237,182 @@ func TestColorOutput(t *testing.T) {
 		t.Errorf("Expected output to not contain color codes, but it does")
 	}
 }
+
+// captureOutput redirects log output to a buffer for the duration of the test.
+func captureOutput(t *testing.T, format Format) *bytes.Buffer {
+	t.Helper()
+	var buf bytes.Buffer
+	logMutex.Lock()
+	oldOutput, oldFormat, oldLevel := globalOutput, globalFormat, globalLevel
+	globalOutput, globalFormat, globalLevel = &buf, format, DebugLevel
+	globalColorEnabled = false
+	updateFormatHandler()
+	logMutex.Unlock()
+	t.Cleanup(func() {
+		logMutex.Lock()
+		globalOutput, globalFormat, globalLevel = oldOutput, oldFormat, oldLevel
+		updateFormatHandler()
+		logMutex.Unlock()
+	})
+	return &buf
+}
+
+func TestWith_TextFields(t *testing.T) {
+	buf := captureOutput(t, FormatText)
+
+	base := NewLogger("scanner")
+	logger := base.With("path", "/srv/my files/a.txt", "size", 42)
+	logger.With("attempt", 2).Warn("Cannot read %s", "file")
+	base.Info("No fields")
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("Expected 2 lines, got %q", buf.String())
+	}
+	if !strings.HasSuffix(lines[0], `Cannot read file path="/srv/my files/a.txt" size=42 attempt=2`) {
+		t.Errorf("Expected fields after the message, got %q", lines[0])
+	}
+	if !strings.Contains(lines[0], "[logger_test.go:") {
+		t.Errorf("Expected caller to be the test file, got %q", lines[0])
+	}
+	if strings.Contains(lines[1], "path=") {
+		t.Errorf("Expected With not to change the parent logger, got %q", lines[1])
+	}
+}
+
+func TestSetFormat_JSON(t *testing.T) {
+	buf := captureOutput(t, FormatJSON)
+
+	NewLogger("api").With("batch", 3).Error("Send failed: %v", "timeout")
+
+	var record map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &record); err != nil {
+		t.Fatalf("Expected a JSON record, got %q: %v", buf.String(), err)
+	}
+	for key, want := range map[string]interface{}{
+		"level":  "ERROR",
+		"msg":    "Send failed: timeout",
+		"logger": "api",
+		"batch":  float64(3),
+	} {
+		if record[key] != want {
+			t.Errorf("Expected %s=%v, got %v", key, want, record[key])
+		}
+	}
+	if source, _ := record["source"].(string); !strings.HasPrefix(source, "logger_test.go:") {
+		t.Errorf("Expected source to be the test file, got %v", record["source"])
+	}
+}
+
+func TestSetFormat_Logfmt(t *testing.T) {
+	buf := captureOutput(t, FormatLogfmt)
+
+	NewLogger("dedup").With("mode", "bloom").Debug("Filter full")
+	output := buf.String()
+	for _, want := range []string{"level=DEBUG", `msg="Filter full"`, "logger=dedup", "mode=bloom"} {
+		if !strings.Contains(output, want) {
+			t.Errorf("Expected output to contain %q, got %q", want, output)
+		}
+	}
+}
+
+func TestSetHandler(t *testing.T) {
+	buf := captureOutput(t, FormatText)
+	SetGlobalLevel("info")
+
+	var records bytes.Buffer
+	SetHandler(slog.NewJSONHandler(&records, &slog.HandlerOptions{Level: slog.LevelWarn}))
+	defer SetHandler(nil)
+
+	logger := NewLogger("main")
+	logger.Debug("Filtered by the global level")
+	logger.Info("Filtered by the handler")
+	logger.Warn("Delivered")
+
+	if buf.Len() != 0 {
+		t.Errorf("Expected nothing on the built-in output, got %q", buf.String())
+	}
+	if lines := strings.Count(records.String(), "\n"); lines != 1 || !strings.Contains(records.String(), "Delivered") {
+		t.Errorf("Expected only the warning to reach the handler, got %q", records.String())
+	}
+}
+
+func TestParseFormat(t *testing.T) {
+	for _, name := range []string{"text", "JSON", "logfmt"} {
+		if _, err := ParseFormat(name); err != nil {
+			t.Errorf("Unexpected error for %s: %v", name, err)
+		}
+	}
+	if _, err := ParseFormat("xml"); err == nil {
+		t.Error("Expected error for unsupported format")
+	}
+}
+
+func TestParseLevels(t *testing.T) {
+	level, components, err := ParseLevels("warn, api=debug,scanner=error")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if level != WarnLevel || components["api"] != DebugLevel || components["scanner"] != ErrorLevel {
+		t.Errorf("Unexpected levels %v %v", level, components)
+	}
+
+	if level, components, _ := ParseLevels("api=debug"); level != InfoLevel || len(components) != 1 {
+		t.Errorf("Expected default info level, got %v %v", level, components)
+	}
+	for _, bad := range []string{"verbose", "api=loud", "=debug"} {
+		if _, _, err := ParseLevels(bad); err == nil {
+			t.Errorf("Expected error for %q", bad)
+		}
+	}
+}
+
+func TestSetLevels_PerComponent(t *testing.T) {
+	buf := captureOutput(t, FormatText)
+	if err := SetLevels("warn,api=debug"); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	defer SetLevels("info")
+
+	NewLogger("api").Debug("api debug")
+	NewLogger("scanner").Info("scanner info")
+	NewLogger("scanner").Warn("scanner warn")
+
+	output := buf.String()
+	if !strings.Contains(output, "api debug") || !strings.Contains(output, "scanner warn") {
+		t.Errorf("Expected api debug and scanner warn messages, got %q", output)
+	}
+	if strings.Contains(output, "scanner info") {
+		t.Errorf("Expected scanner info to be filtered, got %q", output)
+	}
+}
+
+func TestSetFormat_HandlerFollowsOutput(t *testing.T) {
+	captureOutput(t, FormatText)
+	SetFormat(FormatJSON)
+	t.Cleanup(func() { SetFormat(FormatText) })
+
+	logMutex.Lock()
+	handler := formatHandler
+	logMutex.Unlock()
+	logger := NewLogger("test")
+	logger.Info("first")
+	logger.Info("second")
+	logMutex.Lock()
+	reused := formatHandler == handler
+	logMutex.Unlock()
+	if handler == nil || !reused {
+		t.Error("Expected one handler to be built for the JSON format and reused")
+	}
+
+	// Changing the output rebuilds the handler for the new file
+	path := filepath.Join(t.TempDir(), "agentflux.log")
+	if err := SetLogFile(path); err != nil {
+		t.Fatalf("SetLogFile: %v", err)
+	}
+	logger.Info("to file")
+	content, _ := os.ReadFile(path)
+	if !strings.Contains(string(content), `"msg":"to file"`) {
+		t.Errorf("Expected a JSON record in the new log file, got %q", content)
+	}
+}
@@ -0,0 +1,248 @@
+package logging
+
+import (
+	"bufio"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// backupTimeFormat is the timestamp appended to rotated log files. It sorts
+// in chronological order.
+const backupTimeFormat = "20060102-150405.000"
+
+// RotateOptions controls when a log file is rotated and how many old files
+// are kept.
+type RotateOptions struct {
+	// MaxSize rotates the file before it grows beyond this many bytes (0 for no limit).
+	MaxSize int64
+	// MaxAge rotates the file once its first record is this old (0 for no limit).
+	// Files without a recognizable timestamp are aged from their modification time.
+	MaxAge time.Duration
+	// MaxBackups is the number of rotated files to keep (0 to keep all).
+	MaxBackups int
+	// Compress gzips rotated files.
+	Compress bool
+}
+
+// RotatingFile is a log file that is renamed with a timestamp suffix and
+// replaced by a new file when it grows too large or too old.
+type RotatingFile struct {
+	path    string
+	opts    RotateOptions
+	file    *os.File
+	size    int64
+	opened  time.Time
+	mu      sync.Mutex
+	pending sync.WaitGroup // compression and cleanup of rotated files
+	millMu  sync.Mutex     // runs compression and cleanup one rotation at a time
+}
+
+// OpenRotatingFile opens path for appending, creating it and its directory
+// if needed.
+func OpenRotatingFile(path string, opts RotateOptions) (*RotatingFile, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create log directory: %w", err)
+	}
+	r := &RotatingFile{path: path, opts: opts}
+	if err := r.open(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// open opens the current log file.
+func (r *RotatingFile) open() error {
+	file, err := os.OpenFile(r.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open log file: %w", err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return fmt.Errorf("failed to open log file: %w", err)
+	}
+	r.file = file
+	r.size = info.Size()
+	r.opened = time.Now()
+	if r.size > 0 {
+		// Age an existing file from when it was started, not from now, so
+		// short-lived processes appending to it still rotate it
+		r.opened = startTime(r.path, info.ModTime())
+	}
+	return nil
+}
+
+// recordTimePattern matches the timestamp of a record in the text, json and
+// logfmt formats.
+var recordTimePattern = regexp.MustCompile(`\d{4}-\d{2}-\d{2}[ T]\d{2}:\d{2}:\d{2}(\.\d+)?(Z|[+-]\d{2}:\d{2})?`)
+
+// startTime returns the time of the first record in the log file at path,
+// or modTime if it has none.
+func startTime(path string, modTime time.Time) time.Time {
+	file, err := os.Open(path)
+	if err != nil {
+		return modTime
+	}
+	defer file.Close()
+	line, _ := bufio.NewReader(io.LimitReader(file, 4096)).ReadString('\n')
+	match := recordTimePattern.FindString(line)
+	if match == "" {
+		return modTime
+	}
+	match = strings.Replace(match, " ", "T", 1)
+	if t, err := time.Parse(time.RFC3339Nano, match); err == nil {
+		return t
+	}
+	// Text records carry local time without a zone
+	if t, err := time.ParseInLocation("2006-01-02T15:04:05.999999999", match, time.Local); err == nil {
+		return t
+	}
+	return modTime
+}
+
+// Write writes p to the log file, rotating it first if p would exceed the
+// size limit or the file has reached its maximum age.
+func (r *RotatingFile) Write(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.file == nil {
+		return 0, os.ErrClosed
+	}
+	tooBig := r.opts.MaxSize > 0 && r.size > 0 && r.size+int64(len(p)) > r.opts.MaxSize
+	tooOld := r.opts.MaxAge > 0 && time.Since(r.opened) >= r.opts.MaxAge
+	if tooBig || tooOld {
+		if err := r.rotate(); err != nil {
+			return 0, err
+		}
+	}
+
+	n, err := r.file.Write(p)
+	r.size += int64(n)
+	return n, err
+}
+
+// Rotate closes the current file, renames it with a timestamp and opens a new one.
+func (r *RotatingFile) Rotate() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.rotate()
+}
+
+// rotate performs the rotation. The caller must hold r.mu.
+func (r *RotatingFile) rotate() error {
+	if r.file != nil {
+		if err := r.file.Close(); err != nil {
+			return fmt.Errorf("failed to close log file: %w", err)
+		}
+		r.file = nil
+	}
+
+	backup := r.path + "." + time.Now().Format(backupTimeFormat)
+	if err := os.Rename(r.path, backup); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to rotate log file: %w", err)
+	}
+	if err := r.open(); err != nil {
+		return err
+	}
+
+	// Compress and prune in the background so logging is not held up
+	r.pending.Add(1)
+	go func() {
+		defer r.pending.Done()
+		r.millMu.Lock()
+		defer r.millMu.Unlock()
+		if r.opts.Compress {
+			if err := compressFile(backup); err != nil {
+				fmt.Fprintf(os.Stderr, "failed to compress rotated log %s: %v\n", backup, err)
+			}
+		}
+		r.prune()
+	}()
+	return nil
+}
+
+// Backups returns the rotated files of the log, oldest first.
+func (r *RotatingFile) Backups() ([]string, error) {
+	matches, err := filepath.Glob(r.path + ".*")
+	if err != nil {
+		return nil, err
+	}
+	var backups []string
+	for _, match := range matches {
+		// Skip files still being compressed
+		if !strings.HasSuffix(match, ".tmp") {
+			backups = append(backups, match)
+		}
+	}
+	sort.Strings(backups)
+	return backups, nil
+}
+
+// prune removes the oldest rotated files beyond MaxBackups.
+func (r *RotatingFile) prune() {
+	if r.opts.MaxBackups <= 0 {
+		return
+	}
+	backups, err := r.Backups()
+	if err != nil || len(backups) <= r.opts.MaxBackups {
+		return
+	}
+	for _, old := range backups[:len(backups)-r.opts.MaxBackups] {
+		os.Remove(old)
+	}
+}
+
+// Close closes the log file and waits for rotated files to be compressed.
+func (r *RotatingFile) Close() error {
+	r.mu.Lock()
+	var err error
+	if r.file != nil {
+		err = r.file.Close()
+		r.file = nil
+	}
+	r.mu.Unlock()
+	r.pending.Wait()
+	return err
+}
+
+// compressFile gzips path to path.gz and removes the original.
+func compressFile(path string) error {
+	src, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	tmp := path + ".gz.tmp"
+	dst, err := os.OpenFile(tmp, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp)
+
+	gz := gzip.NewWriter(dst)
+	if _, err := io.Copy(gz, src); err != nil {
+		dst.Close()
+		return err
+	}
+	if err := gz.Close(); err != nil {
+		dst.Close()
+		return err
+	}
+	if err := dst.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, path+".gz"); err != nil {
+		return err
+	}
+	return os.Remove(path)
+}
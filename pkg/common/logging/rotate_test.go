@@ -0,0 +1,139 @@
+package logging
+
+import (
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRotatingFile_Size(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "logs", "agentflux.log")
+	file, err := OpenRotatingFile(path, RotateOptions{MaxSize: 100, MaxBackups: 2, Compress: true})
+	if err != nil {
+		t.Fatalf("Failed to open log file: %v", err)
+	}
+
+	line := strings.Repeat("x", 59) + "\n"
+	for i := 0; i < 5; i++ {
+		if _, err := file.Write([]byte(line)); err != nil {
+			t.Fatalf("Write failed: %v", err)
+		}
+		// Keep backup timestamps distinct
+		time.Sleep(2 * time.Millisecond)
+	}
+	if err := file.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	current, err := os.ReadFile(path)
+	if err != nil || string(current) != line {
+		t.Errorf("Expected the current file to hold the last line, got %q (%v)", current, err)
+	}
+
+	backups, err := file.Backups()
+	if err != nil {
+		t.Fatalf("Failed to list backups: %v", err)
+	}
+	if len(backups) != 2 {
+		t.Fatalf("Expected 2 retained backups, got %v", backups)
+	}
+	for _, backup := range backups {
+		if !strings.HasSuffix(backup, ".gz") {
+			t.Errorf("Expected backup %s to be compressed", backup)
+			continue
+		}
+		f, err := os.Open(backup)
+		if err != nil {
+			t.Fatalf("Failed to open backup: %v", err)
+		}
+		gz, err := gzip.NewReader(f)
+		if err != nil {
+			t.Fatalf("Backup %s is not gzip: %v", backup, err)
+		}
+		content, _ := io.ReadAll(gz)
+		f.Close()
+		if string(content) != line {
+			t.Errorf("Unexpected backup content %q", content)
+		}
+	}
+}
+
+func TestRotatingFile_Age(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "agentflux.log")
+	file, err := OpenRotatingFile(path, RotateOptions{MaxAge: 20 * time.Millisecond})
+	if err != nil {
+		t.Fatalf("Failed to open log file: %v", err)
+	}
+	defer file.Close()
+
+	file.Write([]byte("first\n"))
+	time.Sleep(30 * time.Millisecond)
+	file.Write([]byte("second\n"))
+
+	backups, _ := file.Backups()
+	if len(backups) != 1 {
+		t.Fatalf("Expected one rotation after the max age, got %v", backups)
+	}
+	if content, _ := os.ReadFile(backups[0]); string(content) != "first\n" {
+		t.Errorf("Unexpected backup content %q", content)
+	}
+}
+
+func TestRotatingFile_AgeOfExistingFile(t *testing.T) {
+	dir := t.TempDir()
+	old := time.Now().Add(-2 * time.Hour)
+	tests := map[string]string{
+		"text":    old.Format("2006-01-02 15:04:05.000") + " [INFO] [main] started\n",
+		"json":    `{"time":"` + old.Format(time.RFC3339Nano) + `","level":"INFO","msg":"started"}` + "\n",
+		"logfmt":  "time=" + old.Format(time.RFC3339Nano) + " level=INFO msg=started\n",
+		"unknown": "no timestamp here\n",
+	}
+	for name, record := range tests {
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(dir, name+".log")
+			if err := os.WriteFile(path, []byte(record), 0644); err != nil {
+				t.Fatal(err)
+			}
+			// Without a timestamp the modification time is used
+			if err := os.Chtimes(path, old, old); err != nil {
+				t.Fatal(err)
+			}
+
+			// A process shorter than MaxAge reopening an old file still rotates it
+			file, err := OpenRotatingFile(path, RotateOptions{MaxAge: time.Hour})
+			if err != nil {
+				t.Fatalf("Failed to open log file: %v", err)
+			}
+			defer file.Close()
+			file.Write([]byte("next\n"))
+
+			backups, _ := file.Backups()
+			if len(backups) != 1 {
+				t.Fatalf("Expected the old file to be rotated, got %v", backups)
+			}
+			if content, _ := os.ReadFile(path); string(content) != "next\n" {
+				t.Errorf("Unexpected log content %q", content)
+			}
+		})
+	}
+
+	// A recent file is kept
+	path := filepath.Join(dir, "recent.log")
+	record := time.Now().Format("2006-01-02 15:04:05.000") + " [INFO] [main] started\n"
+	if err := os.WriteFile(path, []byte(record), 0644); err != nil {
+		t.Fatal(err)
+	}
+	file, err := OpenRotatingFile(path, RotateOptions{MaxAge: time.Hour})
+	if err != nil {
+		t.Fatalf("Failed to open log file: %v", err)
+	}
+	defer file.Close()
+	file.Write([]byte("next\n"))
+	if backups, _ := file.Backups(); len(backups) != 0 {
+		t.Errorf("Recent file was rotated: %v", backups)
+	}
+}
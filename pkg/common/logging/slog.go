@@ -0,0 +1,112 @@
+package logging
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"path/filepath"
+	"strings"
+)
+
+// Format is the output format of log records.
+type Format string
+
+const (
+	// FormatText writes human-readable lines with a colored level.
+	FormatText Format = "text"
+	// FormatJSON writes one JSON object per record.
+	FormatJSON Format = "json"
+	// FormatLogfmt writes records as key=value pairs.
+	FormatLogfmt Format = "logfmt"
+)
+
+// LoggerKey is the attribute holding the component name in structured formats.
+const LoggerKey = "logger"
+
+// LevelFatal is the slog level of fatal messages.
+const LevelFatal = slog.Level(12)
+
+// ParseFormat validates a log format name.
+func ParseFormat(name string) (Format, error) {
+	switch format := Format(strings.ToLower(name)); format {
+	case FormatText, FormatJSON, FormatLogfmt:
+		return format, nil
+	default:
+		return "", fmt.Errorf("unsupported log format: %s (use text, json or logfmt)", name)
+	}
+}
+
+// SetFormat sets the output format of log records.
+func SetFormat(format Format) {
+	logMutex.Lock()
+	defer logMutex.Unlock()
+	globalFormat = format
+	updateFormatHandler()
+}
+
+// updateFormatHandler rebuilds the handler of the built-in structured
+// formats after the format or output changed. The caller must hold logMutex.
+func updateFormatHandler() {
+	if globalFormat == FormatText {
+		formatHandler = nil
+		return
+	}
+	formatHandler = newFormatHandler(globalFormat, globalOutput)
+}
+
+// SetHandler routes every log record to a log/slog handler, such as one
+// shipping records to a log pipeline, instead of the built-in formats. The
+// global level still applies before the handler's own level. A nil handler
+// restores the built-in formats.
+func SetHandler(handler slog.Handler) {
+	logMutex.Lock()
+	defer logMutex.Unlock()
+	globalHandler = handler
+}
+
+// newFormatHandler returns the slog handler writing format to w.
+func newFormatHandler(format Format, w io.Writer) slog.Handler {
+	opts := &slog.HandlerOptions{
+		AddSource:   true,
+		Level:       slog.LevelDebug,
+		ReplaceAttr: replaceAttr,
+	}
+	if format == FormatJSON {
+		return slog.NewJSONHandler(w, opts)
+	}
+	return slog.NewTextHandler(w, opts)
+}
+
+// replaceAttr names the fatal level and shortens the source to file:line.
+func replaceAttr(groups []string, attr slog.Attr) slog.Attr {
+	if len(groups) > 0 {
+		return attr
+	}
+	switch attr.Key {
+	case slog.LevelKey:
+		if level, ok := attr.Value.Any().(slog.Level); ok && level == LevelFatal {
+			return slog.String(slog.LevelKey, levelNames[FatalLevel])
+		}
+	case slog.SourceKey:
+		if source, ok := attr.Value.Any().(*slog.Source); ok {
+			return slog.String(slog.SourceKey, fmt.Sprintf("%s:%d", filepath.Base(source.File), source.Line))
+		}
+	}
+	return attr
+}
+
+// slogLevel converts a LogLevel to the matching slog level.
+func slogLevel(level LogLevel) slog.Level {
+	switch level {
+	case DebugLevel:
+		return slog.LevelDebug
+	case WarnLevel:
+		return slog.LevelWarn
+	case ErrorLevel:
+		return slog.LevelError
+	case FatalLevel:
+		return LevelFatal
+	default:
+		return slog.LevelInfo
+	}
+}
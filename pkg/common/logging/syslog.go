@@ -0,0 +1,304 @@
+package logging
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Syslog facilities commonly used by agents.
+const (
+	FacilityUser   = 1
+	FacilityDaemon = 3
+	FacilityLocal0 = 16
+)
+
+// StructuredDataID is the RFC 5424 structured data element carrying the
+// component name and logger fields.
+const StructuredDataID = "agentflux@32473"
+
+// localSyslogSockets are the usual paths of the local syslog socket.
+var localSyslogSockets = []string{"/dev/log", "/var/run/syslog", "/var/run/log"}
+
+// SyslogOptions configures a SyslogHandler.
+type SyslogOptions struct {
+	// Facility is the syslog facility of every message. It defaults to daemon.
+	Facility int
+	// AppName identifies the program. It defaults to the executable name.
+	AppName string
+	// Hostname is sent in every message. It defaults to os.Hostname.
+	Hostname string
+}
+
+// SyslogHandler is a slog.Handler that sends RFC 5424 messages to a syslog
+// server over a unix socket, UDP or TCP.
+type SyslogHandler struct {
+	shared *syslogConn
+	attrs  []slog.Attr
+	group  string
+}
+
+// syslogConn is the connection shared by a handler and its derived handlers.
+type syslogConn struct {
+	network, address string
+	opts             SyslogOptions
+	pid              int
+	conn             net.Conn
+	mu               sync.Mutex
+}
+
+// ParseSyslogURL parses a syslog destination: "syslog" for the local syslog
+// socket, or syslog+udp://host:port, syslog+tcp://host:port or
+// syslog+unix:///path. It returns the network and address to dial, both
+// empty for the local socket.
+func ParseSyslogURL(spec string) (network, address string, err error) {
+	if spec == "syslog" {
+		return "", "", nil
+	}
+	scheme, address, ok := strings.Cut(spec, "://")
+	network, found := strings.CutPrefix(scheme, "syslog+")
+	if !ok || !found || address == "" {
+		return "", "", fmt.Errorf("invalid syslog destination %q (use syslog, syslog+udp://host:port, syslog+tcp://host:port or syslog+unix:///path)", spec)
+	}
+	switch network {
+	case "udp", "tcp":
+		if _, _, err := net.SplitHostPort(address); err != nil {
+			return "", "", fmt.Errorf("invalid syslog destination %q: %w", spec, err)
+		}
+	case "unix":
+	default:
+		return "", "", fmt.Errorf("unsupported syslog transport %q in %q", network, spec)
+	}
+	return network, address, nil
+}
+
+// NewSyslogHandler connects to a syslog server. An empty network and address
+// select the local syslog socket.
+func NewSyslogHandler(network, address string, opts SyslogOptions) (*SyslogHandler, error) {
+	if opts.Facility == 0 {
+		opts.Facility = FacilityDaemon
+	}
+	if opts.AppName == "" {
+		opts.AppName = filepath.Base(os.Args[0])
+	}
+	if opts.Hostname == "" {
+		opts.Hostname, _ = os.Hostname()
+	}
+
+	shared := &syslogConn{network: network, address: address, opts: opts, pid: os.Getpid()}
+	if err := shared.connect(); err != nil {
+		return nil, err
+	}
+	return &SyslogHandler{shared: shared}, nil
+}
+
+// connect dials the syslog server. The caller must hold c.mu if the handler is in use.
+func (c *syslogConn) connect() error {
+	if c.conn != nil {
+		c.conn.Close()
+		c.conn = nil
+	}
+
+	if c.network != "" {
+		network := c.network
+		if network == "unix" {
+			// Syslog daemons listen on datagram sockets
+			network = "unixgram"
+		}
+		conn, err := net.DialTimeout(network, c.address, 5*time.Second)
+		if err != nil {
+			return fmt.Errorf("failed to connect to syslog: %w", err)
+		}
+		c.conn = conn
+		return nil
+	}
+
+	for _, path := range localSyslogSockets {
+		if conn, err := net.Dial("unixgram", path); err == nil {
+			c.conn = conn
+			return nil
+		}
+	}
+	return fmt.Errorf("failed to connect to syslog: no local syslog socket found")
+}
+
+// Enabled reports true; levels are filtered by the logging package.
+func (h *SyslogHandler) Enabled(context.Context, slog.Level) bool {
+	return true
+}
+
+// Handle formats the record as an RFC 5424 message and sends it.
+func (h *SyslogHandler) Handle(_ context.Context, record slog.Record) error {
+	c := h.shared
+	var b strings.Builder
+	fmt.Fprintf(&b, "<%d>1 %s %s %s %d - ",
+		c.opts.Facility*8+syslogSeverity(record.Level),
+		record.Time.Format("2006-01-02T15:04:05.000000Z07:00"),
+		headerField(c.opts.Hostname, 255), headerField(c.opts.AppName, 48), c.pid)
+
+	b.WriteString("[" + StructuredDataID)
+	if source := recordSource(record); source != "" {
+		writeSDParam(&b, "source", source)
+	}
+	eachAttr(h.group, h.attrs, record, func(key string, value slog.Value) {
+		writeSDParam(&b, key, value.String())
+	})
+	b.WriteString("] ")
+	b.WriteString(record.Message)
+	message := b.String()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	err := c.send(message)
+	if err != nil {
+		// The server may have restarted; reconnect once
+		if err = c.connect(); err == nil {
+			err = c.send(message)
+		}
+	}
+	return err
+}
+
+// send writes one message, framing it by octet count on stream transports.
+func (c *syslogConn) send(message string) error {
+	if c.conn == nil {
+		return net.ErrClosed
+	}
+	if c.network == "tcp" {
+		message = fmt.Sprintf("%d %s", len(message), message)
+	}
+	_, err := c.conn.Write([]byte(message))
+	return err
+}
+
+// WithAttrs returns a handler that adds attrs to every message.
+func (h *SyslogHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &SyslogHandler{shared: h.shared, attrs: withGroup(h.group, h.attrs, attrs), group: h.group}
+}
+
+// WithGroup returns a handler that qualifies later attributes with name.
+func (h *SyslogHandler) WithGroup(name string) slog.Handler {
+	return &SyslogHandler{shared: h.shared, attrs: h.attrs, group: h.group + name + "."}
+}
+
+// Close closes the connection to the syslog server.
+func (h *SyslogHandler) Close() error {
+	h.shared.mu.Lock()
+	defer h.shared.mu.Unlock()
+	if h.shared.conn == nil {
+		return nil
+	}
+	err := h.shared.conn.Close()
+	h.shared.conn = nil
+	return err
+}
+
+// syslogSeverity maps a slog level to a syslog severity.
+func syslogSeverity(level slog.Level) int {
+	switch {
+	case level >= LevelFatal:
+		return 2 // critical
+	case level >= slog.LevelError:
+		return 3 // error
+	case level >= slog.LevelWarn:
+		return 4 // warning
+	case level >= slog.LevelInfo:
+		return 6 // informational
+	default:
+		return 7 // debug
+	}
+}
+
+// headerField makes s a valid RFC 5424 header field: printable ASCII without
+// spaces, at most max characters, or "-" when empty.
+func headerField(s string, max int) string {
+	s = strings.Map(func(r rune) rune {
+		if r <= ' ' || r > '~' {
+			return '_'
+		}
+		return r
+	}, s)
+	if len(s) > max {
+		s = s[:max]
+	}
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+// writeSDParam writes a structured data parameter, escaping the value.
+func writeSDParam(b *strings.Builder, name, value string) {
+	name = strings.Map(func(r rune) rune {
+		if r <= ' ' || r > '~' || r == '=' || r == ']' || r == '"' {
+			return '_'
+		}
+		return r
+	}, name)
+	if len(name) > 32 {
+		name = name[:32]
+	}
+	value = strings.NewReplacer(`\`, `\\`, `"`, `\"`, `]`, `\]`).Replace(value)
+	fmt.Fprintf(b, ` %s="%s"`, name, value)
+}
+
+// recordSource returns the file:line of the record's caller.
+func recordSource(record slog.Record) string {
+	if record.PC == 0 {
+		return ""
+	}
+	frame, _ := runtimeFrame(record.PC)
+	if frame.File == "" {
+		return ""
+	}
+	return fmt.Sprintf("%s:%d", filepath.Base(frame.File), frame.Line)
+}
+
+// runtimeFrame returns the stack frame of pc.
+func runtimeFrame(pc uintptr) (runtime.Frame, bool) {
+	return runtime.CallersFrames([]uintptr{pc}).Next()
+}
+
+// withGroup appends attrs to existing, qualifying them with group.
+func withGroup(group string, existing, attrs []slog.Attr) []slog.Attr {
+	combined := append([]slog.Attr{}, existing...)
+	for _, attr := range attrs {
+		if group != "" {
+			attr.Key = group + attr.Key
+		}
+		combined = append(combined, attr)
+	}
+	return combined
+}
+
+// eachAttr calls fn for the handler's attributes and then the record's,
+// flattening groups into dotted keys.
+func eachAttr(group string, attrs []slog.Attr, record slog.Record, fn func(key string, value slog.Value)) {
+	var visit func(prefix string, attr slog.Attr)
+	visit = func(prefix string, attr slog.Attr) {
+		value := attr.Value.Resolve()
+		if value.Kind() == slog.KindGroup {
+			for _, member := range value.Group() {
+				visit(prefix+attr.Key+".", member)
+			}
+			return
+		}
+		if attr.Key != "" {
+			fn(prefix+attr.Key, value)
+		}
+	}
+	for _, attr := range attrs {
+		visit("", attr)
+	}
+	record.Attrs(func(attr slog.Attr) bool {
+		visit(group, attr)
+		return true
+	})
+}
@@ -0,0 +1,133 @@
+package logging
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseSyslogURL(t *testing.T) {
+	tests := []struct {
+		spec      string
+		network   string
+		address   string
+		expectErr bool
+	}{
+		{spec: "syslog"},
+		{spec: "syslog+udp://logs.example.com:514", network: "udp", address: "logs.example.com:514"},
+		{spec: "syslog+tcp://10.0.0.5:601", network: "tcp", address: "10.0.0.5:601"},
+		{spec: "syslog+unix:///dev/log", network: "unix", address: "/dev/log"},
+		{spec: "syslog+udp://logs.example.com", expectErr: true},
+		{spec: "syslog+tls://logs.example.com:6514", expectErr: true},
+		{spec: "udp://logs.example.com:514", expectErr: true},
+	}
+
+	for _, tt := range tests {
+		network, address, err := ParseSyslogURL(tt.spec)
+		if tt.expectErr {
+			if err == nil {
+				t.Errorf("Expected error for %q", tt.spec)
+			}
+			continue
+		}
+		if err != nil || network != tt.network || address != tt.address {
+			t.Errorf("ParseSyslogURL(%q) = %q, %q, %v", tt.spec, network, address, err)
+		}
+	}
+}
+
+// rfc5424 matches the header and structured data of the messages sent in these tests.
+var rfc5424 = regexp.MustCompile(`^<(\d+)>1 \S+ test-host agentflux \d+ - \[agentflux@32473 source="syslog_test\.go:\d+" logger="api" batch="7" note="a \\"quoted\\" \\] value"\] Upload failed$`)
+
+func TestSyslogHandler_UDP(t *testing.T) {
+	listener, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	defer listener.Close()
+
+	handler, err := NewSyslogHandler("udp", listener.LocalAddr().String(),
+		SyslogOptions{Facility: FacilityLocal0, AppName: "agentflux", Hostname: "test-host"})
+	if err != nil {
+		t.Fatalf("Failed to create handler: %v", err)
+	}
+	defer handler.Close()
+
+	captureOutput(t, FormatText)
+	SetHandler(handler)
+	defer SetHandler(nil)
+	NewLogger("api").With("batch", 7, "note", `a "quoted" ] value`).Error("Upload failed")
+
+	buf := make([]byte, 2048)
+	listener.SetReadDeadline(time.Now().Add(5 * time.Second))
+	n, _, err := listener.ReadFrom(buf)
+	if err != nil {
+		t.Fatalf("Failed to read message: %v", err)
+	}
+	message := string(buf[:n])
+	match := rfc5424.FindStringSubmatch(message)
+	if match == nil {
+		t.Fatalf("Unexpected syslog message %q", message)
+	}
+	// local0 (16) * 8 + error (3)
+	if match[1] != "131" {
+		t.Errorf("Expected priority 131, got %s", match[1])
+	}
+}
+
+func TestSyslogHandler_TCP(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	defer listener.Close()
+
+	received := make(chan string, 1)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		// Octet-counted framing: "LENGTH SP MESSAGE"
+		reader := bufio.NewReader(conn)
+		length, _ := reader.ReadString(' ')
+		var n int
+		for _, c := range strings.TrimSpace(length) {
+			n = n*10 + int(c-'0')
+		}
+		message := make([]byte, n)
+		if _, err := io.ReadFull(reader, message); err == nil {
+			received <- string(message)
+		}
+	}()
+
+	handler, err := NewSyslogHandler("tcp", listener.Addr().String(), SyslogOptions{AppName: "agentflux", Hostname: "test-host"})
+	if err != nil {
+		t.Fatalf("Failed to create handler: %v", err)
+	}
+	defer handler.Close()
+
+	captureOutput(t, FormatText)
+	SetHandler(handler)
+	defer SetHandler(nil)
+	NewLogger("api").With("batch", 7, "note", `a "quoted" ] value`).Warn("Upload failed")
+
+	select {
+	case message := <-received:
+		match := rfc5424.FindStringSubmatch(message)
+		if match == nil {
+			t.Fatalf("Unexpected syslog message %q", message)
+		}
+		// daemon (3) * 8 + warning (4)
+		if match[1] != "28" {
+			t.Errorf("Expected priority 28, got %s", match[1])
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("No message received")
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"context"
 	"fmt"
 	"sync"
+	"time"
 
 	"github.com/vtriple/agentflux/pkg/common/logging"
 	"github.com/vtriple/agentflux/pkg/processor"
@@ -20,17 +21,69 @@ const (
 	PathDedup DeduplicationType = "path"
 	// NameDedup uses file names for deduplication.
 	NameDedup DeduplicationType = "name"
+	// NoDedup passes every file through. The store, if set, only suppresses
+	// unchanged files at the same path.
+	NoDedup DeduplicationType = "none"
+	// CompositeDedup uses the engine's KeyFunc, typically built with ParseKeyExpression.
+	CompositeDedup DeduplicationType = "composite"
 )
 
+// DuplicateHandling controls what happens to duplicate results.
+type DuplicateHandling string
+
+const (
+	// DropDuplicates discards duplicates after logging them.
+	DropDuplicates DuplicateHandling = "drop"
+	// FoldDuplicates adds duplicates to the AlsoSeenAt list of the first-seen
+	// result. Unique results are held back until the input is exhausted or
+	// the context is cancelled.
+	FoldDuplicates DuplicateHandling = "fold"
+	// SightingDuplicates emits duplicates as lightweight sighting records.
+	SightingDuplicates DuplicateHandling = "sighting"
+)
+
+// pendingFlushTimeout is how long folded results held back are offered
+// downstream after the context is cancelled.
+const pendingFlushTimeout = 5 * time.Second
+
+// ParseDuplicateHandling validates a duplicate handling name. An empty name selects DropDuplicates.
+func ParseDuplicateHandling(name string) (DuplicateHandling, error) {
+	switch handling := DuplicateHandling(name); handling {
+	case "":
+		return DropDuplicates, nil
+	case DropDuplicates, FoldDuplicates, SightingDuplicates:
+		return handling, nil
+	default:
+		return "", fmt.Errorf("unsupported duplicate handling: %s", name)
+	}
+}
+
 // DeduplicationEngine removes duplicate files from a stream of results.
 type DeduplicationEngine struct {
 	// DedupType is the method used for deduplication.
 	DedupType DeduplicationType
+	// KeyFunc, when set, computes the deduplication key instead of DedupType.
+	KeyFunc KeyFunc
+	// Store, when set, suppresses files whose keys were delivered in previous runs.
+	// Keys are only added to the store by MarkDelivered.
+	Store Store
+	// Mode selects how seen keys are remembered. The zero value is ModeExact.
+	Mode DedupMode
+	// MemoryLimit bounds the memory used by bloom and LRU modes, in bytes.
+	MemoryLimit int64
+	// FalsePositiveRate is the target false-positive rate in bloom mode.
+	FalsePositiveRate float64
+	// Duplicates controls what happens to duplicate results. The zero value is DropDuplicates.
+	Duplicates DuplicateHandling
 
 	seen        map[string]bool
+	filter      seenFilter
+	pending     []processor.FileResult
+	pendingKeys map[string]int
 	lock        sync.RWMutex
 	totalFiles  int
 	uniqueFiles int
+	suppressed  int
 	logger      *logging.Logger
 
 	// For coordination with tests and shutdowns
@@ -54,6 +107,9 @@ func (d *DeduplicationEngine) Deduplicate(ctx context.Context, inputChannel <-ch
 
 	go func() {
 		defer func() {
+			// Release results held back to collect their duplicates, also
+			// when the scan is interrupted
+			d.releasePending(ctx, outputChannel)
 			close(outputChannel)
 			d.doneMutex.Lock()
 			defer d.doneMutex.Unlock()
@@ -77,8 +133,8 @@ func (d *DeduplicationEngine) Deduplicate(ctx context.Context, inputChannel <-ch
 				if !ok {
 					// Input channel closed
 					d.lock.Lock()
-					d.logger.Info("Deduplication complete: processed %d files, %d unique",
-						d.totalFiles, d.uniqueFiles)
+					d.logger.Info("Deduplication complete: processed %d files, %d unique, %d previously delivered",
+						d.totalFiles, d.uniqueFiles, d.suppressed)
 					d.lock.Unlock()
 					return
 				}
@@ -98,6 +154,7 @@ func (d *DeduplicationEngine) Deduplicate(ctx context.Context, inputChannel <-ch
 				// Increment total files counter
 				d.lock.Lock()
 				d.totalFiles++
+				filesSeen.Inc()
 
 				// Skip files with errors completely in the test mode
 				// This is specifically for the test behavior as defined in engine_test.go
@@ -110,13 +167,30 @@ func (d *DeduplicationEngine) Deduplicate(ctx context.Context, inputChannel <-ch
 				// Get the key for deduplication
 				key := d.getDeduplicationKey(result)
 
-				// Check if the file is a duplicate
-				isDuplicate := d.seen[key]
+				// Check if the file is a duplicate and mark it as seen
+				isDuplicate := d.DedupType != NoDedup && d.testAndAdd(key)
 
 				if !isDuplicate {
-					// Mark as seen
-					d.seen[key] = true
+					// Skip files already delivered by a previous run
+					if d.Store != nil && d.Store.Contains(key) {
+						d.suppressed++
+						previouslyDelivered.Inc()
+						d.lock.Unlock()
+						d.logger.Debug("Suppressed previously delivered file: %s", result.Path)
+						continue
+					}
+					
 					d.uniqueFiles++
+					if d.Duplicates == FoldDuplicates {
+						// Hold the result until all of its duplicates have been seen
+						if d.pendingKeys == nil {
+							d.pendingKeys = make(map[string]int)
+						}
+						d.pendingKeys[key] = len(d.pending)
+						d.pending = append(d.pending, result)
+						d.lock.Unlock()
+						continue
+					}
 					d.lock.Unlock()
 
 					// Before sending to output channel, check context again to handle race conditions
@@ -138,8 +212,31 @@ func (d *DeduplicationEngine) Deduplicate(ctx context.Context, inputChannel <-ch
 						return
 					}
 				} else {
-					d.lock.Unlock()
-					d.logger.Debug("Filtered duplicate file: %s", result.Path)
+					duplicatesFound.Inc()
+					switch d.Duplicates {
+					case FoldDuplicates:
+						if index, ok := d.pendingKeys[key]; ok {
+							d.pending[index].AlsoSeenAt = append(d.pending[index].AlsoSeenAt, processor.Sighting{
+								Path:    result.Path,
+								ModTime: result.ModTime,
+								Owner:   result.Owner,
+							})
+						}
+						d.lock.Unlock()
+						d.logger.Debug("Folded duplicate file: %s", result.Path)
+						
+					case SightingDuplicates:
+						d.lock.Unlock()
+						select {
+						case outputChannel <- processor.SightingOf(result):
+						case <-ctx.Done():
+							return
+						}
+						
+					default:
+						d.lock.Unlock()
+						d.logger.Debug("Filtered duplicate file: %s", result.Path)
+					}
 				}
 			}
 		}
@@ -148,8 +245,57 @@ func (d *DeduplicationEngine) Deduplicate(ctx context.Context, inputChannel <-ch
 	return outputChannel
 }
 
+// releasePending sends the results held back in fold mode. If ctx is
+// cancelled they are offered for up to pendingFlushTimeout, so an
+// interrupted scan still passes on what it found.
+func (d *DeduplicationEngine) releasePending(ctx context.Context, outputChannel chan<- processor.FileResult) {
+	d.lock.Lock()
+	pending := d.pending
+	d.pending = nil
+	d.pendingKeys = nil
+	d.lock.Unlock()
+	if len(pending) == 0 {
+		return
+	}
+
+	if ctx.Err() != nil {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(context.Background(), pendingFlushTimeout)
+		defer cancel()
+	}
+	for i, result := range pending {
+		select {
+		case outputChannel <- result:
+		case <-ctx.Done():
+			d.logger.Warn("Dropped %d folded results that were not taken after cancellation", len(pending)-i)
+			return
+		}
+	}
+}
+
+// testAndAdd reports whether key has been seen and marks it as seen.
+// The caller must hold d.lock.
+func (d *DeduplicationEngine) testAndAdd(key string) bool {
+	if d.Mode == "" || d.Mode == ModeExact {
+		if d.seen[key] {
+			return true
+		}
+		d.seen[key] = true
+		return false
+	}
+
+	if d.filter == nil {
+		d.filter = newSeenFilter(d.Mode, d.MemoryLimit, d.FalsePositiveRate)
+	}
+	return d.filter.testAndAdd(key)
+}
+
 // getDeduplicationKey returns the key to use for deduplication based on the engine type.
 func (d *DeduplicationEngine) getDeduplicationKey(result processor.FileResult) string {
+	if d.KeyFunc != nil {
+		return d.KeyFunc(result)
+	}
+
 	switch d.DedupType {
 	case HashDedup:
 		// Use hash algorithm and hash value
@@ -160,6 +306,9 @@ func (d *DeduplicationEngine) getDeduplicationKey(result processor.FileResult) s
 	case NameDedup:
 		// Use the file name and size
 		return result.Name + "#" + fmt.Sprintf("%d", result.Size)
+	case NoDedup:
+		// Use the path and content so the store only suppresses unchanged files
+		return result.Path + "\x00" + result.HashAlgorithm + ":" + result.Hash
 	default:
 		// Default to hash deduplication
 		return result.HashAlgorithm + ":" + result.Hash
@@ -167,12 +316,72 @@ func (d *DeduplicationEngine) getDeduplicationKey(result processor.FileResult) s
 }
 
 // GetStats returns the total number of files and unique files processed.
+// Stats also reports the estimated false positives of bloom mode and the
+// evictions of LRU mode.
 func (d *DeduplicationEngine) GetStats() (total int, unique int) {
 	d.lock.RLock()
 	defer d.lock.RUnlock()
 	return d.totalFiles, d.uniqueFiles
 }
 
+// Stats contains the counters of a deduplication engine.
+type Stats struct {
+	// Total is the number of results received.
+	Total int
+	// Unique is the number of results passed downstream.
+	Unique int
+	// Suppressed is the number of results skipped because the store
+	// recorded them as delivered by a previous run.
+	Suppressed int
+	// Mode is the strategy used to remember seen keys.
+	Mode DedupMode
+	// FalsePositiveRate is the current estimated false-positive rate in bloom mode.
+	FalsePositiveRate float64
+	// EstimatedFalsePositives is the expected number of new files wrongly
+	// filtered as duplicates in bloom mode.
+	EstimatedFalsePositives int64
+	// Evictions is the number of keys forgotten in LRU mode.
+	Evictions int64
+	// MemoryBytes approximates the memory used to remember seen keys in
+	// bloom and LRU modes.
+	MemoryBytes int64
+}
+
+// Stats returns the current counters.
+func (d *DeduplicationEngine) Stats() Stats {
+	d.lock.RLock()
+	defer d.lock.RUnlock()
+	stats := Stats{
+		Total:      d.totalFiles,
+		Unique:     d.uniqueFiles,
+		Suppressed: d.suppressed,
+		Mode:       d.Mode,
+	}
+	if stats.Mode == "" {
+		stats.Mode = ModeExact
+	}
+	if d.filter != nil {
+		d.filter.stats(&stats)
+	}
+	return stats
+}
+
+// MarkDelivered records the keys of results confirmed as delivered in the
+// store so later runs suppress them. It does nothing if no store is set.
+func (d *DeduplicationEngine) MarkDelivered(results []processor.FileResult) error {
+	if d.Store == nil || len(results) == 0 {
+		return nil
+	}
+
+	keys := make([]string, 0, len(results))
+	for _, result := range results {
+		if result.Error == "" {
+			keys = append(keys, d.getDeduplicationKey(result))
+		}
+	}
+	return d.Store.Mark(keys...)
+}
+
 // Reset clears the deduplication engine's state.
 func (d *DeduplicationEngine) Reset() {
 	// Handle the done channel with proper synchronization
@@ -196,8 +405,12 @@ func (d *DeduplicationEngine) Reset() {
 
 	// Create a new map instead of clearing the existing one
 	d.seen = make(map[string]bool)
+	d.filter = nil
+	d.pending = nil
+	d.pendingKeys = nil
 	d.totalFiles = 0
 	d.uniqueFiles = 0
+	d.suppressed = 0
 }
 
 // SetLogger sets a custom logger for the deduplication engine.
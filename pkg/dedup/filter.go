@@ -0,0 +1,268 @@
+package dedup
+
+import (
+	"container/list"
+	"fmt"
+	"hash/maphash"
+	"math"
+)
+
+// DedupMode selects the data structure used to remember seen keys.
+type DedupMode string
+
+const (
+	// ModeExact remembers every key in a map. It never errs but grows without bound.
+	ModeExact DedupMode = "exact"
+	// ModeBloom uses a scalable bloom filter. Memory stays small but a new file
+	// may occasionally be mistaken for a duplicate.
+	ModeBloom DedupMode = "bloom"
+	// ModeLRU remembers a fixed number of recently seen keys. Memory is bounded
+	// but duplicates of evicted keys are passed through again.
+	ModeLRU DedupMode = "lru"
+
+	// DefaultMemoryLimit is the default memory budget for bloom and LRU modes.
+	DefaultMemoryLimit = 256 * 1024 * 1024
+	// DefaultFalsePositiveRate is the default target false-positive rate in bloom mode.
+	DefaultFalsePositiveRate = 0.001
+
+	// lruEntryOverhead approximates the memory used per LRU entry besides the key.
+	lruEntryOverhead = 96
+	// bloomInitialCapacity is the number of keys the first bloom stage is sized for.
+	bloomInitialCapacity = 1 << 20
+)
+
+// ParseMode validates a deduplication mode name. An empty name selects ModeExact.
+func ParseMode(name string) (DedupMode, error) {
+	switch mode := DedupMode(name); mode {
+	case "":
+		return ModeExact, nil
+	case ModeExact, ModeBloom, ModeLRU:
+		return mode, nil
+	default:
+		return "", fmt.Errorf("unsupported dedup mode: %s", name)
+	}
+}
+
+// seenFilter remembers keys for the bounded deduplication modes.
+type seenFilter interface {
+	// testAndAdd reports whether key was already present and records it.
+	testAndAdd(key string) bool
+	// stats fills in the filter-specific counters.
+	stats(s *Stats)
+}
+
+// newSeenFilter creates the filter for a bounded mode.
+func newSeenFilter(mode DedupMode, memoryLimit int64, fpRate float64) seenFilter {
+	if memoryLimit <= 0 {
+		memoryLimit = DefaultMemoryLimit
+	}
+	switch mode {
+	case ModeBloom:
+		if fpRate <= 0 || fpRate >= 1 {
+			fpRate = DefaultFalsePositiveRate
+		}
+		return newScalableBloom(fpRate, memoryLimit)
+	case ModeLRU:
+		return newLRUFilter(memoryLimit)
+	default:
+		return nil
+	}
+}
+
+// bloomStage is a single fixed-size bloom filter.
+type bloomStage struct {
+	bits     []uint64
+	m        uint64
+	k        uint64
+	capacity uint64
+	count    uint64
+}
+
+// newBloomStage sizes a bloom filter for capacity keys at the given false-positive rate.
+func newBloomStage(capacity uint64, fpRate float64) *bloomStage {
+	m := uint64(math.Ceil(-float64(capacity) * math.Log(fpRate) / (math.Ln2 * math.Ln2)))
+	m = (m + 63) &^ 63
+	k := uint64(math.Round(float64(m) / float64(capacity) * math.Ln2))
+	if k < 1 {
+		k = 1
+	}
+	return &bloomStage{
+		bits:     make([]uint64, m/64),
+		m:        m,
+		k:        k,
+		capacity: capacity,
+	}
+}
+
+// contains reports whether all bits for the hash pair are set.
+func (b *bloomStage) contains(h1, h2 uint64) bool {
+	for i := uint64(0); i < b.k; i++ {
+		bit := (h1 + i*h2) % b.m
+		if b.bits[bit/64]&(1<<(bit%64)) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// add sets the bits for the hash pair.
+func (b *bloomStage) add(h1, h2 uint64) {
+	for i := uint64(0); i < b.k; i++ {
+		bit := (h1 + i*h2) % b.m
+		b.bits[bit/64] |= 1 << (bit % 64)
+	}
+	b.count++
+}
+
+// falsePositiveRate estimates the current false-positive probability of the stage.
+func (b *bloomStage) falsePositiveRate() float64 {
+	return math.Pow(1-math.Exp(-float64(b.k)*float64(b.count)/float64(b.m)), float64(b.k))
+}
+
+// bytes returns the memory used by the stage's bit array.
+func (b *bloomStage) bytes() int64 {
+	return int64(len(b.bits) * 8)
+}
+
+// scalableBloom is a scalable bloom filter (Almeida et al.): when a stage is
+// full a larger stage with a tighter error rate is added, keeping the overall
+// false-positive rate below the target. Growth stops at the memory limit,
+// after which the last stage keeps filling and the error rate rises.
+type scalableBloom struct {
+	stages      []*bloomStage
+	fpRate      float64
+	memoryLimit int64
+	seed1       maphash.Seed
+	seed2       maphash.Seed
+
+	// estimatedFalsePositives accumulates the expected number of new keys
+	// wrongly reported as seen.
+	estimatedFalsePositives float64
+}
+
+// newScalableBloom creates a scalable bloom filter with the target false-positive rate.
+func newScalableBloom(fpRate float64, memoryLimit int64) *scalableBloom {
+	s := &scalableBloom{
+		fpRate:      fpRate,
+		memoryLimit: memoryLimit,
+		seed1:       maphash.MakeSeed(),
+		seed2:       maphash.MakeSeed(),
+	}
+	// The stage error rates form a geometric series 0.5p, 0.25p, ... summing to p
+	capacity := uint64(bloomInitialCapacity)
+	for capacity > 1024 && newBloomStage(capacity, fpRate/2).bytes() > memoryLimit {
+		capacity /= 2
+	}
+	s.stages = []*bloomStage{newBloomStage(capacity, fpRate/2)}
+	return s
+}
+
+// hashes returns the two hashes used for double hashing. The second hash is
+// forced odd so the probe sequence does not collapse.
+func (s *scalableBloom) hashes(key string) (uint64, uint64) {
+	return maphash.String(s.seed1, key), maphash.String(s.seed2, key) | 1
+}
+
+// contains reports whether key may have been seen.
+func (s *scalableBloom) contains(h1, h2 uint64) bool {
+	for _, stage := range s.stages {
+		if stage.contains(h1, h2) {
+			return true
+		}
+	}
+	return false
+}
+
+// testAndAdd reports whether key may have been seen and records it.
+func (s *scalableBloom) testAndAdd(key string) bool {
+	h1, h2 := s.hashes(key)
+	if s.contains(h1, h2) {
+		return true
+	}
+
+	current := s.currentRate()
+	if current < 1 {
+		s.estimatedFalsePositives += current / (1 - current)
+	}
+
+	last := s.stages[len(s.stages)-1]
+	if last.count >= last.capacity {
+		next := newBloomStage(last.capacity*2, s.fpRate/math.Pow(2, float64(len(s.stages)+1)))
+		if s.memoryBytes()+next.bytes() <= s.memoryLimit {
+			s.stages = append(s.stages, next)
+			last = next
+		}
+	}
+	last.add(h1, h2)
+	return false
+}
+
+// currentRate returns the combined false-positive probability of all stages.
+func (s *scalableBloom) currentRate() float64 {
+	pass := 1.0
+	for _, stage := range s.stages {
+		pass *= 1 - stage.falsePositiveRate()
+	}
+	return 1 - pass
+}
+
+// memoryBytes returns the memory used by all stages.
+func (s *scalableBloom) memoryBytes() int64 {
+	var total int64
+	for _, stage := range s.stages {
+		total += stage.bytes()
+	}
+	return total
+}
+
+// stats reports the estimated error rate and memory use.
+func (s *scalableBloom) stats(st *Stats) {
+	st.FalsePositiveRate = s.currentRate()
+	st.EstimatedFalsePositives = int64(math.Round(s.estimatedFalsePositives))
+	st.MemoryBytes = s.memoryBytes()
+}
+
+// lruFilter remembers a fixed number of recently seen keys.
+type lruFilter struct {
+	memoryLimit int64
+	bytes       int64
+	order       *list.List
+	entries     map[string]*list.Element
+	evictions   int64
+}
+
+// newLRUFilter creates an LRU filter bounded by memoryLimit bytes.
+func newLRUFilter(memoryLimit int64) *lruFilter {
+	return &lruFilter{
+		memoryLimit: memoryLimit,
+		order:       list.New(),
+		entries:     make(map[string]*list.Element),
+	}
+}
+
+// testAndAdd reports whether key is among the remembered keys and marks it
+// as most recently used, evicting the least recently used keys if needed.
+func (l *lruFilter) testAndAdd(key string) bool {
+	if elem, ok := l.entries[key]; ok {
+		l.order.MoveToFront(elem)
+		return true
+	}
+
+	l.entries[key] = l.order.PushFront(key)
+	l.bytes += int64(len(key)) + lruEntryOverhead
+
+	for l.bytes > l.memoryLimit && l.order.Len() > 1 {
+		oldest := l.order.Back()
+		oldKey := l.order.Remove(oldest).(string)
+		delete(l.entries, oldKey)
+		l.bytes -= int64(len(oldKey)) + lruEntryOverhead
+		l.evictions++
+	}
+	return false
+}
+
+// stats reports evictions and memory use.
+func (l *lruFilter) stats(st *Stats) {
+	st.Evictions = l.evictions
+	st.MemoryBytes = l.bytes
+}
@@ -0,0 +1,165 @@
+package dedup
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/vtriple/agentflux/pkg/processor"
+)
+
+func TestParseMode(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected DedupMode
+		wantErr  bool
+	}{
+		{input: "", expected: ModeExact},
+		{input: "exact", expected: ModeExact},
+		{input: "bloom", expected: ModeBloom},
+		{input: "lru", expected: ModeLRU},
+		{input: "fuzzy", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		mode, err := ParseMode(tt.input)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("ParseMode(%q) expected error", tt.input)
+			}
+			continue
+		}
+		if err != nil || mode != tt.expected {
+			t.Errorf("ParseMode(%q) = %q, %v; want %q", tt.input, mode, err, tt.expected)
+		}
+	}
+}
+
+func TestScalableBloom_GrowsAndKeepsErrorRate(t *testing.T) {
+	const keys = 20000
+	filter := newScalableBloom(0.01, 1<<30)
+	// Start with a small stage so the test exercises growth
+	filter.stages = []*bloomStage{newBloomStage(1000, 0.005)}
+
+	for i := 0; i < keys; i++ {
+		filter.testAndAdd(fmt.Sprintf("sha256:%d", i))
+	}
+	if len(filter.stages) < 2 {
+		t.Fatalf("Expected filter to grow, got %d stages", len(filter.stages))
+	}
+
+	// Bloom filters never forget a key
+	for i := 0; i < keys; i++ {
+		if !filter.testAndAdd(fmt.Sprintf("sha256:%d", i)) {
+			t.Fatalf("Expected key %d to be present", i)
+		}
+	}
+
+	falsePositives := 0
+	for i := keys; i < 2*keys; i++ {
+		if filter.contains(filter.hashes(fmt.Sprintf("other:%d", i))) {
+			falsePositives++
+		}
+	}
+	if rate := float64(falsePositives) / keys; rate > 0.03 {
+		t.Errorf("Expected false-positive rate near 0.01, got %.4f", rate)
+	}
+
+	var stats Stats
+	filter.stats(&stats)
+	if stats.FalsePositiveRate <= 0 || stats.FalsePositiveRate > 0.02 {
+		t.Errorf("Unexpected estimated false-positive rate %.6f", stats.FalsePositiveRate)
+	}
+	if stats.MemoryBytes != filter.memoryBytes() || stats.MemoryBytes == 0 {
+		t.Errorf("Unexpected memory use %d", stats.MemoryBytes)
+	}
+}
+
+func TestScalableBloom_RespectsMemoryLimit(t *testing.T) {
+	const limit = 64 * 1024
+	filter := newScalableBloom(0.01, limit)
+	for i := 0; i < 200000; i++ {
+		filter.testAndAdd(fmt.Sprintf("key-%d", i))
+	}
+	if filter.memoryBytes() > limit {
+		t.Errorf("Expected memory use below %d, got %d", limit, filter.memoryBytes())
+	}
+
+	// The saturated filter reports its degraded accuracy
+	var stats Stats
+	filter.stats(&stats)
+	if stats.FalsePositiveRate < 0.01 || stats.EstimatedFalsePositives == 0 {
+		t.Errorf("Expected degraded accuracy to be reported, got %+v", stats)
+	}
+}
+
+func TestLRUFilter_Evicts(t *testing.T) {
+	// Room for three 1-byte keys
+	filter := newLRUFilter(3 * (1 + lruEntryOverhead))
+
+	for _, key := range []string{"a", "b", "c"} {
+		if filter.testAndAdd(key) {
+			t.Fatalf("Expected %s to be new", key)
+		}
+	}
+	// Touch a so b becomes the least recently used key
+	if !filter.testAndAdd("a") {
+		t.Fatal("Expected a to be present")
+	}
+	if filter.testAndAdd("d") {
+		t.Fatal("Expected d to be new")
+	}
+
+	if !filter.testAndAdd("a") || !filter.testAndAdd("c") || !filter.testAndAdd("d") {
+		t.Error("Expected recently used keys to be kept")
+	}
+	if filter.testAndAdd("b") {
+		t.Error("Expected b to have been evicted")
+	}
+
+	var stats Stats
+	filter.stats(&stats)
+	if stats.Evictions != 2 {
+		t.Errorf("Expected 2 evictions, got %d", stats.Evictions)
+	}
+	if stats.MemoryBytes > 3*(1+lruEntryOverhead) {
+		t.Errorf("Expected memory use within limit, got %d", stats.MemoryBytes)
+	}
+}
+
+func TestDeduplicationEngine_BoundedModes(t *testing.T) {
+	for _, mode := range []DedupMode{ModeExact, ModeBloom, ModeLRU} {
+		t.Run(string(mode), func(t *testing.T) {
+			engine := NewDeduplicationEngine(HashDedup)
+			engine.Mode = mode
+			engine.MemoryLimit = 1024 * 1024
+
+			input := make(chan processor.FileResult, 6)
+			for _, hash := range []string{"aaa", "bbb", "aaa", "ccc", "bbb", "ddd"} {
+				input <- processor.FileResult{Path: "/" + hash, Hash: hash, HashAlgorithm: "sha256"}
+			}
+			close(input)
+
+			count := 0
+			for range engine.Deduplicate(context.Background(), input) {
+				count++
+			}
+			if count != 4 {
+				t.Errorf("Expected 4 unique files, got %d", count)
+			}
+
+			stats := engine.Stats()
+			if stats.Mode != mode || stats.Total != 6 || stats.Unique != 4 {
+				t.Errorf("Unexpected stats %+v", stats)
+			}
+			if mode != ModeExact && stats.MemoryBytes == 0 {
+				t.Error("Expected memory use to be reported")
+			}
+
+			engine.Reset()
+			if stats := engine.Stats(); stats.MemoryBytes != 0 || stats.Total != 0 {
+				t.Errorf("Expected reset stats, got %+v", stats)
+			}
+		})
+	}
+}
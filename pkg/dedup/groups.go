@@ -0,0 +1,55 @@
+package dedup
+
+import (
+	"sort"
+
+	"github.com/vtriple/agentflux/pkg/processor"
+)
+
+// DuplicateGroup is a set of files sharing the same content.
+type DuplicateGroup struct {
+	// Hash is the content hash shared by the files.
+	Hash string
+	// HashAlgorithm is the algorithm used to compute Hash.
+	HashAlgorithm string
+	// Size is the size of each copy in bytes.
+	Size int64
+	// Paths lists every location of the content, first-seen first.
+	Paths []string
+}
+
+// WastedBytes returns the bytes used by all copies beyond the first.
+func (g DuplicateGroup) WastedBytes() int64 {
+	return g.Size * int64(len(g.Paths)-1)
+}
+
+// DuplicateGroups builds the duplicate groups from results folded with
+// FoldDuplicates, ordered by wasted bytes, largest first.
+// Results without duplicates are ignored.
+func DuplicateGroups(results []processor.FileResult) []DuplicateGroup {
+	var groups []DuplicateGroup
+	for _, result := range results {
+		if len(result.AlsoSeenAt) == 0 {
+			continue
+		}
+		group := DuplicateGroup{
+			Hash:          result.Hash,
+			HashAlgorithm: result.HashAlgorithm,
+			Size:          result.Size,
+			Paths:         make([]string, 0, len(result.AlsoSeenAt)+1),
+		}
+		group.Paths = append(group.Paths, result.Path)
+		for _, sighting := range result.AlsoSeenAt {
+			group.Paths = append(group.Paths, sighting.Path)
+		}
+		groups = append(groups, group)
+	}
+
+	sort.SliceStable(groups, func(i, j int) bool {
+		if groups[i].WastedBytes() != groups[j].WastedBytes() {
+			return groups[i].WastedBytes() > groups[j].WastedBytes()
+		}
+		return len(groups[i].Paths) > len(groups[j].Paths)
+	})
+	return groups
+}
@@ -0,0 +1,144 @@
+package dedup
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/vtriple/agentflux/pkg/processor"
+)
+
+// duplicateInput returns a closed channel with two duplicate groups and one unique file.
+func duplicateInput() <-chan processor.FileResult {
+	modTime := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	results := []processor.FileResult{
+		{Path: "/a/small", Hash: "s", HashAlgorithm: "sha256", Size: 10, Strings: []string{"secret"}},
+		{Path: "/a/big", Hash: "b", HashAlgorithm: "sha256", Size: 1000},
+		{Path: "/b/small", Hash: "s", HashAlgorithm: "sha256", Size: 10, ModTime: modTime, Owner: "alice", Strings: []string{"secret"}},
+		{Path: "/a/unique", Hash: "u", HashAlgorithm: "sha256", Size: 5},
+		{Path: "/c/small", Hash: "s", HashAlgorithm: "sha256", Size: 10},
+		{Path: "/b/big", Hash: "b", HashAlgorithm: "sha256", Size: 1000},
+	}
+	input := make(chan processor.FileResult, len(results))
+	for _, r := range results {
+		input <- r
+	}
+	close(input)
+	return input
+}
+
+func TestDeduplicationEngine_FoldDuplicates(t *testing.T) {
+	engine := NewDeduplicationEngine(HashDedup)
+	engine.Duplicates = FoldDuplicates
+
+	var results []processor.FileResult
+	for r := range engine.Deduplicate(context.Background(), duplicateInput()) {
+		results = append(results, r)
+	}
+
+	if len(results) != 3 {
+		t.Fatalf("Expected 3 results, got %d", len(results))
+	}
+	small := results[0]
+	if small.Path != "/a/small" || len(small.AlsoSeenAt) != 2 {
+		t.Fatalf("Expected /a/small with 2 sightings, got %+v", small)
+	}
+	sighting := small.AlsoSeenAt[0]
+	if sighting.Path != "/b/small" || sighting.Owner != "alice" || sighting.ModTime.IsZero() {
+		t.Errorf("Unexpected sighting %+v", sighting)
+	}
+	if len(results[2].AlsoSeenAt) != 0 {
+		t.Errorf("Expected no sightings for unique file, got %+v", results[2].AlsoSeenAt)
+	}
+
+	groups := DuplicateGroups(results)
+	if len(groups) != 2 {
+		t.Fatalf("Expected 2 duplicate groups, got %d", len(groups))
+	}
+	if groups[0].Hash != "b" || groups[0].WastedBytes() != 1000 {
+		t.Errorf("Expected biggest group first, got %+v", groups[0])
+	}
+	if groups[1].WastedBytes() != 20 || len(groups[1].Paths) != 3 || groups[1].Paths[0] != "/a/small" {
+		t.Errorf("Unexpected second group %+v", groups[1])
+	}
+}
+
+func TestDeduplicationEngine_SightingDuplicates(t *testing.T) {
+	engine := NewDeduplicationEngine(HashDedup)
+	engine.Duplicates = SightingDuplicates
+
+	var full, sightings int
+	for r := range engine.Deduplicate(context.Background(), duplicateInput()) {
+		if !r.Sighting {
+			full++
+			continue
+		}
+		sightings++
+		if r.Strings != nil {
+			t.Errorf("Expected sighting without strings, got %+v", r)
+		}
+		if r.Hash == "" || r.Path == "" {
+			t.Errorf("Expected sighting to keep hash and path, got %+v", r)
+		}
+	}
+
+	if full != 3 || sightings != 3 {
+		t.Errorf("Expected 3 results and 3 sightings, got %d and %d", full, sightings)
+	}
+	if total, unique := engine.GetStats(); total != 6 || unique != 3 {
+		t.Errorf("Expected 6 total and 3 unique, got %d and %d", total, unique)
+	}
+}
+
+func TestParseDuplicateHandling(t *testing.T) {
+	for name, expected := range map[string]DuplicateHandling{
+		"":         DropDuplicates,
+		"drop":     DropDuplicates,
+		"fold":     FoldDuplicates,
+		"sighting": SightingDuplicates,
+	} {
+		if handling, err := ParseDuplicateHandling(name); err != nil || handling != expected {
+			t.Errorf("ParseDuplicateHandling(%q) = %q, %v", name, handling, err)
+		}
+	}
+	if _, err := ParseDuplicateHandling("keep"); err == nil {
+		t.Error("Expected error for unknown handling")
+	}
+}
+
+func TestDeduplicationEngine_FoldReleasedOnCancel(t *testing.T) {
+	engine := NewDeduplicationEngine(HashDedup)
+	engine.Duplicates = FoldDuplicates
+
+	ctx, cancel := context.WithCancel(context.Background())
+	input := make(chan processor.FileResult)
+	output := engine.Deduplicate(ctx, input)
+	for _, result := range []processor.FileResult{
+		{Path: "/a/small", Hash: "s", HashAlgorithm: "sha256", Size: 10},
+		{Path: "/b/small", Hash: "s", HashAlgorithm: "sha256", Size: 10},
+		{Path: "/a/unique", Hash: "u", HashAlgorithm: "sha256", Size: 5},
+	} {
+		input <- result
+	}
+	// Wait until the last result is processed, then interrupt the scan
+	for deadline := time.Now().Add(5 * time.Second); ; time.Sleep(time.Millisecond) {
+		if total, _ := engine.GetStats(); total == 3 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("Timed out waiting for results to be processed")
+		}
+	}
+	cancel()
+
+	var results []processor.FileResult
+	for result := range output {
+		results = append(results, result)
+	}
+	if len(results) != 2 {
+		t.Fatalf("Expected the 2 held back results after cancellation, got %d", len(results))
+	}
+	if results[0].Path != "/a/small" || len(results[0].AlsoSeenAt) != 1 || results[0].AlsoSeenAt[0].Path != "/b/small" {
+		t.Errorf("Expected /a/small folded with /b/small, got %+v", results[0])
+	}
+}
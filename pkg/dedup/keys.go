@@ -0,0 +1,68 @@
+package dedup
+
+import (
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/vtriple/agentflux/pkg/processor"
+)
+
+// KeyFunc returns the deduplication key of a result. Results with equal
+// keys are considered duplicates.
+type KeyFunc func(result processor.FileResult) string
+
+// keyComponents maps the names usable in a composite key expression to the
+// result field they select.
+var keyComponents = map[string]KeyFunc{
+	"hash":  func(r processor.FileResult) string { return r.HashAlgorithm + ":" + r.Hash },
+	"path":  func(r processor.FileResult) string { return r.Path },
+	"name":  func(r processor.FileResult) string { return r.Name },
+	"dir":   func(r processor.FileResult) string { return filepath.Dir(r.Path) },
+	"ext":   func(r processor.FileResult) string { return strings.ToLower(filepath.Ext(r.Name)) },
+	"size":  func(r processor.FileResult) string { return strconv.FormatInt(r.Size, 10) },
+	"owner": func(r processor.FileResult) string { return r.Owner },
+	"mtime": func(r processor.FileResult) string {
+		return strconv.FormatInt(r.ModTime.UnixNano(), 10)
+	},
+}
+
+// ParseKeyExpression builds a KeyFunc from a composite key expression such as
+// "hash+name" or "hash+dir". Supported components are hash, path, name, dir,
+// ext, size, owner and mtime.
+func ParseKeyExpression(expr string) (KeyFunc, error) {
+	var funcs []KeyFunc
+	for _, name := range strings.Split(expr, "+") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		keyFunc, ok := keyComponents[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown dedup key component %q in %q", name, expr)
+		}
+		funcs = append(funcs, keyFunc)
+	}
+
+	if len(funcs) == 1 {
+		return funcs[0], nil
+	}
+	return func(result processor.FileResult) string {
+		parts := make([]string, len(funcs))
+		for i, keyFunc := range funcs {
+			parts[i] = keyFunc(result)
+		}
+		// NUL cannot appear in paths, so components cannot run into each other
+		return strings.Join(parts, "\x00")
+	}, nil
+}
+
+// ParseDeduplicationType validates a deduplication type name. An empty name selects HashDedup.
+func ParseDeduplicationType(name string) (DeduplicationType, error) {
+	switch dedupType := DeduplicationType(strings.ToLower(name)); dedupType {
+	case "":
+		return HashDedup, nil
+	case HashDedup, PathDedup, NameDedup, NoDedup, CompositeDedup:
+		return dedupType, nil
+	default:
+		return "", fmt.Errorf("unsupported dedup type: %s", name)
+	}
+}
@@ -0,0 +1,99 @@
+package dedup
+
+import (
+	"context"
+	"testing"
+
+	"github.com/vtriple/agentflux/pkg/processor"
+)
+
+func TestParseKeyExpression(t *testing.T) {
+	a := processor.FileResult{Path: "/srv/a/report.pdf", Name: "report.pdf", Hash: "abc", HashAlgorithm: "sha256", Size: 10}
+	b := processor.FileResult{Path: "/srv/b/report.pdf", Name: "report.pdf", Hash: "abc", HashAlgorithm: "sha256", Size: 10}
+	c := processor.FileResult{Path: "/srv/a/copy.pdf", Name: "copy.pdf", Hash: "abc", HashAlgorithm: "sha256", Size: 10}
+
+	tests := []struct {
+		expr      string
+		sameAB    bool
+		sameAC    bool
+		expectErr bool
+	}{
+		{expr: "hash", sameAB: true, sameAC: true},
+		{expr: "hash+name", sameAB: true, sameAC: false},
+		{expr: "hash+dir", sameAB: false, sameAC: true},
+		{expr: "HASH + Size", sameAB: true, sameAC: true},
+		{expr: "path", sameAB: false, sameAC: false},
+		{expr: "hash+colour", expectErr: true},
+		{expr: "", expectErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			keyFunc, err := ParseKeyExpression(tt.expr)
+			if tt.expectErr {
+				if err == nil {
+					t.Errorf("Expected error for %q", tt.expr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if got := keyFunc(a) == keyFunc(b); got != tt.sameAB {
+				t.Errorf("Expected a and b same=%v, got %v", tt.sameAB, got)
+			}
+			if got := keyFunc(a) == keyFunc(c); got != tt.sameAC {
+				t.Errorf("Expected a and c same=%v, got %v", tt.sameAC, got)
+			}
+		})
+	}
+}
+
+func TestParseDeduplicationType(t *testing.T) {
+	for name, expected := range map[string]DeduplicationType{
+		"":          HashDedup,
+		"hash":      HashDedup,
+		"path":      PathDedup,
+		"name":      NameDedup,
+		"none":      NoDedup,
+		"composite": CompositeDedup,
+	} {
+		if dedupType, err := ParseDeduplicationType(name); err != nil || dedupType != expected {
+			t.Errorf("ParseDeduplicationType(%q) = %q, %v", name, dedupType, err)
+		}
+	}
+	if _, err := ParseDeduplicationType("content"); err == nil {
+		t.Error("Expected error for unknown type")
+	}
+}
+
+func TestDeduplicationEngine_KeyFuncAndNoDedup(t *testing.T) {
+	results := []processor.FileResult{
+		{Path: "/a/x.txt", Name: "x.txt", Hash: "1", HashAlgorithm: "sha256"},
+		{Path: "/b/x.txt", Name: "x.txt", Hash: "1", HashAlgorithm: "sha256"},
+		{Path: "/a/y.txt", Name: "y.txt", Hash: "1", HashAlgorithm: "sha256"},
+		{Path: "/a/x.txt", Name: "x.txt", Hash: "1", HashAlgorithm: "sha256"},
+	}
+	run := func(engine *DeduplicationEngine) int {
+		input := make(chan processor.FileResult, len(results))
+		for _, r := range results {
+			input <- r
+		}
+		close(input)
+		count := 0
+		for range engine.Deduplicate(context.Background(), input) {
+			count++
+		}
+		return count
+	}
+
+	composite := NewDeduplicationEngine(CompositeDedup)
+	composite.KeyFunc, _ = ParseKeyExpression("hash+dir")
+	if count := run(composite); count != 2 {
+		t.Errorf("Expected 2 unique files by hash+dir, got %d", count)
+	}
+
+	if count := run(NewDeduplicationEngine(NoDedup)); count != len(results) {
+		t.Errorf("Expected all %d files with no dedup, got %d", len(results), count)
+	}
+}
@@ -0,0 +1,12 @@
+package dedup
+
+import "github.com/vtriple/agentflux/pkg/metrics"
+
+var (
+	filesSeen = metrics.Default.NewCounter("agentflux_dedup_files_total",
+		"Results received by the deduplication engine.")
+	duplicatesFound = metrics.Default.NewCounter("agentflux_dedup_duplicates_total",
+		"Results identified as duplicates of an earlier result.")
+	previouslyDelivered = metrics.Default.NewCounter("agentflux_dedup_suppressed_total",
+		"Results suppressed because a previous run delivered them.")
+)
@@ -0,0 +1,205 @@
+package dedup
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// DefaultStoreTTL is how long delivered keys are remembered by default.
+const DefaultStoreTTL = 30 * 24 * time.Hour
+
+// Store persists deduplication keys across runs.
+type Store interface {
+	// Contains reports whether key has been marked and has not expired.
+	Contains(key string) bool
+	// Mark records keys as delivered.
+	Mark(keys ...string) error
+	// Close releases any resources held by the store.
+	Close() error
+}
+
+// storeRecord is a single line of a FileStore.
+type storeRecord struct {
+	Key     string `json:"k"`
+	Expires int64  `json:"e"`
+}
+
+// FileStore is a Store backed by an append-only JSONL file.
+// Each marked key is stored with an expiry time; expired and superseded
+// records are dropped when the store is opened.
+type FileStore struct {
+	// TTL is how long marked keys are remembered.
+	TTL time.Duration
+
+	path    string
+	entries map[string]int64
+	file    *os.File
+	writer  *bufio.Writer
+	mu      sync.Mutex
+	now     func() time.Time
+}
+
+// OpenFileStore opens or creates the store at path.
+func OpenFileStore(path string, ttl time.Duration) (*FileStore, error) {
+	if ttl <= 0 {
+		ttl = DefaultStoreTTL
+	}
+
+	s := &FileStore{
+		TTL:     ttl,
+		path:    path,
+		entries: make(map[string]int64),
+		now:     time.Now,
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create store directory: %w", err)
+	}
+
+	records, err := s.load()
+	if err != nil {
+		return nil, err
+	}
+
+	// Rewrite the file without expired or superseded records
+	if records > len(s.entries) {
+		if err := s.compact(); err != nil {
+			return nil, err
+		}
+	}
+
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open store: %w", err)
+	}
+	s.file = file
+	s.writer = bufio.NewWriter(file)
+	return s, nil
+}
+
+// load reads the unexpired records from disk and returns the number of
+// records in the file.
+func (s *FileStore) load() (int, error) {
+	file, err := os.Open(s.path)
+	if os.IsNotExist(err) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to open store: %w", err)
+	}
+	defer file.Close()
+
+	now := s.now().Unix()
+	records := 0
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		records++
+		var record storeRecord
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			// Skip partially written lines, e.g. after a crash
+			continue
+		}
+		if record.Expires > now && record.Expires > s.entries[record.Key] {
+			s.entries[record.Key] = record.Expires
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return 0, fmt.Errorf("failed to read store: %w", err)
+	}
+	return records, nil
+}
+
+// compact atomically rewrites the store file with the current entries.
+func (s *FileStore) compact() error {
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), filepath.Base(s.path)+".tmp*")
+	if err != nil {
+		return fmt.Errorf("failed to compact store: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	writer := bufio.NewWriter(tmp)
+	encoder := json.NewEncoder(writer)
+	for key, expires := range s.entries {
+		if err := encoder.Encode(storeRecord{Key: key, Expires: expires}); err != nil {
+			tmp.Close()
+			return fmt.Errorf("failed to compact store: %w", err)
+		}
+	}
+	if err := writer.Flush(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to compact store: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to compact store: %w", err)
+	}
+	return os.Rename(tmp.Name(), s.path)
+}
+
+// Contains reports whether key has been marked and has not expired.
+func (s *FileStore) Contains(key string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	expires, ok := s.entries[key]
+	return ok && expires > s.now().Unix()
+}
+
+// Mark records keys as delivered, refreshing their expiry, and flushes them to disk.
+func (s *FileStore) Mark(keys ...string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.writer == nil {
+		return fmt.Errorf("store is closed")
+	}
+
+	expires := s.now().Add(s.TTL).Unix()
+	encoder := json.NewEncoder(s.writer)
+	for _, key := range keys {
+		s.entries[key] = expires
+		if err := encoder.Encode(storeRecord{Key: key, Expires: expires}); err != nil {
+			return fmt.Errorf("failed to write store: %w", err)
+		}
+	}
+	if err := s.writer.Flush(); err != nil {
+		return fmt.Errorf("failed to write store: %w", err)
+	}
+	return nil
+}
+
+// Len returns the number of unexpired keys in the store.
+func (s *FileStore) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.now().Unix()
+	count := 0
+	for _, expires := range s.entries {
+		if expires > now {
+			count++
+		}
+	}
+	return count
+}
+
+// Close flushes pending writes and closes the store file.
+func (s *FileStore) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.file == nil {
+		return nil
+	}
+	flushErr := s.writer.Flush()
+	closeErr := s.file.Close()
+	s.file = nil
+	s.writer = nil
+	if flushErr != nil {
+		return flushErr
+	}
+	return closeErr
+}
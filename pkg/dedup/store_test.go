@@ -0,0 +1,159 @@
+package dedup
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/vtriple/agentflux/pkg/processor"
+)
+
+func TestFileStore_MarkAndReopen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state", "dedup.jsonl")
+
+	store, err := OpenFileStore(path, time.Hour)
+	if err != nil {
+		t.Fatalf("Failed to open store: %v", err)
+	}
+	if store.Contains("sha256:abc") {
+		t.Error("Expected empty store")
+	}
+	if err := store.Mark("sha256:abc", "sha256:def"); err != nil {
+		t.Fatalf("Failed to mark keys: %v", err)
+	}
+	if !store.Contains("sha256:abc") || !store.Contains("sha256:def") {
+		t.Error("Expected marked keys to be present")
+	}
+	if err := store.Close(); err != nil {
+		t.Fatalf("Failed to close store: %v", err)
+	}
+	if err := store.Mark("sha256:ghi"); err == nil {
+		t.Error("Expected error marking keys on a closed store")
+	}
+
+	reopened, err := OpenFileStore(path, time.Hour)
+	if err != nil {
+		t.Fatalf("Failed to reopen store: %v", err)
+	}
+	defer reopened.Close()
+	if !reopened.Contains("sha256:abc") || !reopened.Contains("sha256:def") {
+		t.Error("Expected keys to persist across reopen")
+	}
+	if reopened.Len() != 2 {
+		t.Errorf("Expected 2 keys, got %d", reopened.Len())
+	}
+}
+
+func TestFileStore_ExpiryAndCompaction(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "dedup.jsonl")
+
+	store, err := OpenFileStore(path, time.Hour)
+	if err != nil {
+		t.Fatalf("Failed to open store: %v", err)
+	}
+	// Mark keys two hours in the past so they have expired by now
+	now := time.Now().Add(-2 * time.Hour)
+	store.now = func() time.Time { return now }
+	store.Mark("old")
+	store.Mark("kept")
+	store.Mark("kept") // Superseded record
+	now = time.Now()
+	if store.Contains("old") {
+		t.Error("Expected key to expire")
+	}
+	store.Mark("kept")
+	store.Close()
+
+	// Write a partial line as if the process crashed mid-write
+	file, _ := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0644)
+	file.WriteString(`{"k":"trunc`)
+	file.Close()
+
+	reopened, err := OpenFileStore(path, time.Hour)
+	if err != nil {
+		t.Fatalf("Failed to reopen store: %v", err)
+	}
+	defer reopened.Close()
+
+	if reopened.Contains("old") {
+		t.Error("Expected expired key to be dropped")
+	}
+	if !reopened.Contains("kept") {
+		t.Error("Expected refreshed key to be kept")
+	}
+
+	data, _ := os.ReadFile(path)
+	if lines := strings.Count(string(data), "\n"); lines != 1 {
+		t.Errorf("Expected compacted store with 1 record, got %d:\n%s", lines, data)
+	}
+}
+
+func TestDeduplicationEngine_StoreSuppressesDeliveredFiles(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "dedup.jsonl")
+	results := []processor.FileResult{
+		{Path: "/a", Hash: "aaa", HashAlgorithm: "sha256"},
+		{Path: "/b", Hash: "bbb", HashAlgorithm: "sha256"},
+		{Path: "/c", Hash: "ccc", HashAlgorithm: "sha256"},
+	}
+
+	runOnce := func(deliver func([]processor.FileResult) []processor.FileResult) ([]processor.FileResult, Stats) {
+		store, err := OpenFileStore(path, time.Hour)
+		if err != nil {
+			t.Fatalf("Failed to open store: %v", err)
+		}
+		defer store.Close()
+
+		engine := NewDeduplicationEngine(HashDedup)
+		engine.Store = store
+
+		input := make(chan processor.FileResult, len(results))
+		for _, r := range results {
+			input <- r
+		}
+		close(input)
+
+		var unique []processor.FileResult
+		for r := range engine.Deduplicate(context.Background(), input) {
+			unique = append(unique, r)
+		}
+		if err := engine.MarkDelivered(deliver(unique)); err != nil {
+			t.Fatalf("Failed to mark delivered: %v", err)
+		}
+		return unique, engine.Stats()
+	}
+
+	// First run: only /a is acknowledged by the API
+	unique, stats := runOnce(func(sent []processor.FileResult) []processor.FileResult {
+		return sent[:1]
+	})
+	if len(unique) != 3 || stats.Suppressed != 0 {
+		t.Fatalf("Expected 3 unique files on first run, got %d (stats %+v)", len(unique), stats)
+	}
+
+	// Second run: undelivered files must be sent again
+	unique, stats = runOnce(func(sent []processor.FileResult) []processor.FileResult { return sent })
+	if len(unique) != 2 || stats.Suppressed != 1 || stats.Total != 3 || stats.Unique != 2 {
+		t.Fatalf("Expected 2 unique and 1 suppressed file, got %d (stats %+v)", len(unique), stats)
+	}
+	for _, r := range unique {
+		if r.Path == "/a" {
+			t.Error("Expected delivered file /a to be suppressed")
+		}
+	}
+
+	// Third run: everything was delivered
+	unique, stats = runOnce(func(sent []processor.FileResult) []processor.FileResult { return sent })
+	if len(unique) != 0 || stats.Suppressed != 3 {
+		t.Fatalf("Expected all files suppressed, got %d (stats %+v)", len(unique), stats)
+	}
+}
+
+func TestDeduplicationEngine_MarkDeliveredWithoutStore(t *testing.T) {
+	engine := NewDeduplicationEngine(HashDedup)
+	if err := engine.MarkDelivered([]processor.FileResult{{Hash: "abc"}}); err != nil {
+		t.Errorf("Expected no error without store, got %v", err)
+	}
+}
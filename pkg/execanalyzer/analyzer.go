@@ -0,0 +1,70 @@
+package execanalyzer
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/vtriple/agentflux/pkg/processor"
+)
+
+// fileAnalyzer runs a per-file spec's command for one file.
+type fileAnalyzer struct {
+	spec *Spec
+	path string
+	run  *commandRun
+}
+
+func (a *fileAnalyzer) Name() string { return a.spec.Name }
+
+func (a *fileAnalyzer) Applies(file processor.AnalyzedFile) bool {
+	a.path = file.Path
+	return a.spec.applies(file.Size, file.MimeType)
+}
+
+// Write streams content to the command, starting it on the first chunk so
+// it runs while the rest of the file is hashed.
+func (a *fileAnalyzer) Write(p []byte) (int, error) {
+	if a.spec.Input != InputContent {
+		return len(p), nil
+	}
+	if a.run == nil {
+		if err := a.start(); err != nil {
+			return 0, err
+		}
+	}
+	a.run.write(p)
+	return len(p), nil
+}
+
+// Result sends the path if the command takes paths, waits for it and
+// decodes its JSON output.
+func (a *fileAnalyzer) Result() (any, error) {
+	if a.run == nil {
+		if err := a.start(); err != nil {
+			return nil, err
+		}
+		if a.spec.Input == InputPath {
+			a.run.write([]byte(a.path + "\n"))
+		}
+	}
+	output, err := a.run.wait()
+	if err != nil {
+		return nil, err
+	}
+	var value any
+	if err := json.Unmarshal(output, &value); err != nil {
+		return nil, fmt.Errorf("invalid JSON output: %w", err)
+	}
+	return value, nil
+}
+
+// start starts the command with the file's path in AGENTFLUX_FILE.
+func (a *fileAnalyzer) start() error {
+	run, err := a.spec.start(context.Background(), "AGENTFLUX_FILE="+a.path)
+	if err != nil {
+		return err
+	}
+	a.run = run
+	return nil
+}
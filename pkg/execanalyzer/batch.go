@@ -0,0 +1,157 @@
+package execanalyzer
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/vtriple/agentflux/pkg/processor"
+)
+
+// batchLine is one line of a batch command's output.
+type batchLine struct {
+	Path   string          `json:"path"`
+	Result json.RawMessage `json:"result"`
+	Error  string          `json:"error"`
+}
+
+// BatchStage runs a batch spec's command on groups of results between
+// hashing and delivery. It implements pipeline.Stage.
+type BatchStage struct {
+	spec *Spec
+}
+
+// NewBatchStage creates the stage of a batch spec.
+func NewBatchStage(spec *Spec) *BatchStage {
+	return &BatchStage{spec: spec}
+}
+
+// Name returns the name of the analyzer.
+func (b *BatchStage) Name() string { return b.spec.Name }
+
+// Run collects results into batches and passes them on once the command
+// has analyzed them. Failed results, sightings and files the spec does not
+// apply to are passed on unchanged. Results may be reordered.
+func (b *BatchStage) Run(ctx context.Context, in <-chan processor.FileResult) <-chan processor.FileResult {
+	out := make(chan processor.FileResult, cap(in))
+	go func() {
+		var wg sync.WaitGroup
+		defer close(out)
+		defer wg.Wait()
+
+		send := func(result processor.FileResult) {
+			select {
+			case out <- result:
+			case <-ctx.Done():
+			}
+		}
+		// Limit the batches held in memory to those that can run
+		running := make(chan struct{}, b.spec.Concurrency)
+		flush := func(batch []processor.FileResult) {
+			running <- struct{}{}
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				defer func() { <-running }()
+				b.analyze(ctx, batch)
+				for _, result := range batch {
+					send(result)
+				}
+			}()
+		}
+
+		var batch []processor.FileResult
+		for result := range in {
+			if result.Error != "" || result.Sighting || !b.spec.applies(result.Size, result.MimeType) {
+				send(result)
+				continue
+			}
+			batch = append(batch, result)
+			if len(batch) == b.spec.BatchSize {
+				flush(batch)
+				batch = nil
+			}
+		}
+		if len(batch) > 0 {
+			flush(batch)
+		}
+	}()
+	return out
+}
+
+// analyze runs the command on a batch and stores its results, or its error
+// in each result. Files the command could not be given or did not report
+// get an error too.
+func (b *BatchStage) analyze(ctx context.Context, batch []processor.FileResult) {
+	results, err := b.runBatch(ctx, batch)
+	for i := range batch {
+		result := &batch[i]
+		line, ok := results[result.Path]
+		switch {
+		case err != nil:
+			b.setError(result, err.Error())
+		case strings.ContainsAny(result.Path, "\r\n"):
+			b.setError(result, "path contains a newline and cannot be sent to a batch command")
+		case !ok:
+			b.setError(result, "no result for the file in the command's output")
+		case line.Error != "":
+			b.setError(result, line.Error)
+		default:
+			var value any
+			if err := json.Unmarshal(line.Result, &value); err != nil {
+				b.setError(result, fmt.Sprintf("invalid JSON output: %v", err))
+				continue
+			}
+			if result.Analysis == nil {
+				result.Analysis = make(map[string]any)
+			}
+			result.Analysis[b.spec.Name] = value
+		}
+	}
+}
+
+// runBatch sends the paths of a batch to the command and returns its output
+// lines by path.
+func (b *BatchStage) runBatch(ctx context.Context, batch []processor.FileResult) (map[string]batchLine, error) {
+	run, err := b.spec.start(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, result := range batch {
+		// Newlines would split a path across lines of the input
+		if !strings.ContainsAny(result.Path, "\r\n") {
+			run.write([]byte(result.Path + "\n"))
+		}
+	}
+	output, err := run.wait()
+	if err != nil {
+		return nil, err
+	}
+
+	lines := make(map[string]batchLine, len(batch))
+	scanner := bufio.NewScanner(bytes.NewReader(output))
+	scanner.Buffer(nil, int(b.spec.MaxOutput))
+	for scanner.Scan() {
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+		var line batchLine
+		if err := json.Unmarshal(scanner.Bytes(), &line); err != nil {
+			return nil, fmt.Errorf("invalid JSON output: %w", err)
+		}
+		lines[line.Path] = line
+	}
+	return lines, scanner.Err()
+}
+
+// setError records the analyzer's error for a result.
+func (b *BatchStage) setError(result *processor.FileResult, message string) {
+	if result.AnalysisErrors == nil {
+		result.AnalysisErrors = make(map[string]string)
+	}
+	result.AnalysisErrors[b.spec.Name] = message
+}
@@ -0,0 +1,142 @@
+package execanalyzer
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// commandRun is one run of an analyzer's command.
+type commandRun struct {
+	spec   *Spec
+	ctx    context.Context
+	cancel context.CancelFunc
+	cmd    *exec.Cmd
+	stdin  io.WriteCloser
+	stdout *limitedBuffer
+	stderr *limitedBuffer
+	// stdinErr is the first error writing stdin, after which input is
+	// discarded, as commands may exit without reading all of it.
+	stdinErr error
+}
+
+// start waits for a free slot and starts the command. The timeout covers
+// both. extraEnv is added to the allowed environment.
+func (s *Spec) start(parent context.Context, extraEnv ...string) (*commandRun, error) {
+	ctx, cancel := context.WithTimeout(parent, s.Timeout)
+	select {
+	case s.slots <- struct{}{}:
+	case <-ctx.Done():
+		cancel()
+		commandRuns.With(s.Name, "timeout").Inc()
+		return nil, fmt.Errorf("timed out after %s waiting for a free slot", s.Timeout)
+	}
+
+	cmd := s.command(ctx)
+	cmd.Env = s.environ(extraEnv...)
+	cmd.WaitDelay = time.Second
+	run := &commandRun{
+		spec:   s,
+		ctx:    ctx,
+		cancel: cancel,
+		cmd:    cmd,
+		stdout: &limitedBuffer{limit: s.MaxOutput},
+		stderr: &limitedBuffer{limit: maxStderr},
+	}
+	cmd.Stdout = run.stdout
+	cmd.Stderr = run.stderr
+	stdin, err := cmd.StdinPipe()
+	if err == nil {
+		err = cmd.Start()
+	}
+	if err != nil {
+		run.release()
+		commandRuns.With(s.Name, "error").Inc()
+		return nil, fmt.Errorf("failed to start command: %w", err)
+	}
+	run.stdin = stdin
+	return run, nil
+}
+
+// environ returns the allowed variables of the agent's environment and
+// extra.
+func (s *Spec) environ(extra ...string) []string {
+	env := make([]string, 0, len(s.Env)+len(extra))
+	for _, name := range s.Env {
+		if value, ok := os.LookupEnv(name); ok {
+			env = append(env, name+"="+value)
+		}
+	}
+	return append(env, extra...)
+}
+
+// write sends p to the command's stdin unless an earlier write failed.
+func (r *commandRun) write(p []byte) {
+	if r.stdinErr == nil {
+		_, r.stdinErr = r.stdin.Write(p)
+	}
+}
+
+// wait closes stdin, waits for the command and returns its output.
+func (r *commandRun) wait() ([]byte, error) {
+	defer r.release()
+	r.stdin.Close()
+	err := r.cmd.Wait()
+	switch {
+	case errors.Is(r.ctx.Err(), context.DeadlineExceeded):
+		commandRuns.With(r.spec.Name, "timeout").Inc()
+		return nil, fmt.Errorf("command timed out after %s", r.spec.Timeout)
+	case r.ctx.Err() != nil:
+		commandRuns.With(r.spec.Name, "error").Inc()
+		return nil, r.ctx.Err()
+	case err != nil:
+		commandRuns.With(r.spec.Name, "error").Inc()
+		if stderr := strings.TrimSpace(r.stderr.String()); stderr != "" {
+			return nil, fmt.Errorf("command failed: %w: %s", err, stderr)
+		}
+		return nil, fmt.Errorf("command failed: %w", err)
+	case r.stdout.exceeded:
+		commandRuns.With(r.spec.Name, "error").Inc()
+		return nil, fmt.Errorf("command output exceeds %d bytes", r.spec.MaxOutput)
+	}
+	commandRuns.With(r.spec.Name, "ok").Inc()
+	return r.stdout.Bytes(), nil
+}
+
+// release frees the command's slot.
+func (r *commandRun) release() {
+	r.cancel()
+	<-r.spec.slots
+}
+
+// limitedBuffer keeps up to limit bytes written to it and discards the
+// rest, so a noisy command cannot exhaust memory or block on a full pipe.
+// The buffer is not embedded, as io.Copy would use its ReadFrom instead.
+type limitedBuffer struct {
+	buf      bytes.Buffer
+	limit    int64
+	exceeded bool
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	if room := b.limit - int64(b.buf.Len()); int64(len(p)) > room {
+		b.exceeded = true
+		if room > 0 {
+			b.buf.Write(p[:room])
+		}
+		return len(p), nil
+	}
+	return b.buf.Write(p)
+}
+
+// Bytes returns the kept output.
+func (b *limitedBuffer) Bytes() []byte { return b.buf.Bytes() }
+
+// String returns the kept output as a string.
+func (b *limitedBuffer) String() string { return b.buf.String() }
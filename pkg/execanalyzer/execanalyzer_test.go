@@ -0,0 +1,197 @@
+//go:build unix
+
+package execanalyzer
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/vtriple/agentflux/pkg/processor"
+)
+
+// writeScript writes an executable shell script and returns its path.
+func writeScript(t *testing.T, body string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "analyzer.sh")
+	if err := os.WriteFile(path, []byte("#!/bin/sh\n"+body+"\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// analyzeFile runs a per-file spec on a file with the given content.
+func analyzeFile(t *testing.T, spec *Spec, content string) processor.FileResult {
+	t.Helper()
+	if err := spec.Init(); err != nil {
+		t.Fatalf("Init: %v", err)
+	}
+	path := filepath.Join(t.TempDir(), "file.txt")
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	hashProcessor := processor.NewHashProcessor("sha256", 1)
+	hashProcessor.Analyzers = []processor.AnalyzerFactory{spec.Factory()}
+	result := hashProcessor.ProcessFile(path)
+	if result.Error != "" {
+		t.Fatalf("file failed: %s", result.Error)
+	}
+	return result
+}
+
+func TestParse(t *testing.T) {
+	specs, err := Parse([]byte(`[{"name": "size", "command": ["wc", "-c"], "input": "content",
+		"timeout": "5s", "memory": "64MB", "cpu": "2s", "concurrency": 2, "env": ["PATH"]}]`))
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	spec := specs[0]
+	if spec.Timeout != 5*time.Second || spec.MemoryLimit != 64<<20 || spec.CPULimit != 2*time.Second ||
+		spec.Concurrency != 2 || spec.MaxOutput != DefaultMaxOutput || !filepath.IsAbs(spec.path) {
+		t.Errorf("spec = %+v", spec)
+	}
+
+	tests := []struct {
+		specs   string
+		errText string
+	}{
+		{`[{"name": "a"}]`, "needs a command"},
+		{`[{"command": ["true"]}]`, "needs a name"},
+		{`[{"name": "a", "command": ["true"], "input": "stdin"}]`, "unsupported input"},
+		{`[{"name": "a", "command": ["true"], "input": "content", "batch": 10}]`, "not content"},
+		{`[{"name": "a", "command": ["true"], "batch": 10, "types": ["text/"]}]`, "only supported per file"},
+		{`[{"name": "a", "command": ["true"], "timeout": "soon"}]`, "invalid timeout"},
+		{`[{"name": "a", "command": ["true"], "env": ["A=B"]}]`, "invalid environment variable"},
+		{`[{"name": "a", "command": ["no-such-analyzer-command"]}]`, "not found"},
+		{`[{"name": "a", "command": ["true"]}, {"name": "a", "command": ["true"]}]`, "duplicate"},
+	}
+	for _, tt := range tests {
+		if _, err := Parse([]byte(tt.specs)); err == nil || !strings.Contains(err.Error(), tt.errText) {
+			t.Errorf("%s: expected error containing %q, got %v", tt.specs, tt.errText, err)
+		}
+	}
+}
+
+func TestFileAnalyzer(t *testing.T) {
+	t.Setenv("AGENTFLUX_TEST_SECRET", "secret")
+	t.Setenv("AGENTFLUX_TEST_ALLOWED", "allowed")
+
+	t.Run("path", func(t *testing.T) {
+		script := writeScript(t, `read path
+printf '{"path": "%s", "file": "%s", "allowed": "%s", "secret": "%s"}' "$path" "$AGENTFLUX_FILE" "$AGENTFLUX_TEST_ALLOWED" "$AGENTFLUX_TEST_SECRET"`)
+		result := analyzeFile(t, &Spec{Name: "ext", Command: []string{script}, Env: []string{"AGENTFLUX_TEST_ALLOWED"}}, "content")
+		got, ok := result.Analysis["ext"].(map[string]any)
+		if !ok {
+			t.Fatalf("Analysis = %v, errors = %v", result.Analysis, result.AnalysisErrors)
+		}
+		if got["path"] != result.Path || got["file"] != result.Path {
+			t.Errorf("command received path %v and file %v, want %s", got["path"], got["file"], result.Path)
+		}
+		if got["allowed"] != "allowed" || got["secret"] != "" {
+			t.Errorf("environment not filtered: %v", got)
+		}
+	})
+
+	t.Run("content", func(t *testing.T) {
+		script := writeScript(t, `printf '{"bytes": %d}' "$(wc -c)"`)
+		content := strings.Repeat("x", 3<<20)
+		result := analyzeFile(t, &Spec{Name: "ext", Command: []string{script}, Input: InputContent, Env: []string{"PATH"}}, content)
+		got, _ := result.Analysis["ext"].(map[string]any)
+		if got["bytes"] != float64(len(content)) {
+			t.Errorf("Analysis = %v, errors = %v", result.Analysis, result.AnalysisErrors)
+		}
+	})
+
+	t.Run("limits", func(t *testing.T) {
+		script := writeScript(t, `printf '{"memory": %s, "cpu": %s}' "$(ulimit -v)" "$(ulimit -t)"`)
+		result := analyzeFile(t, &Spec{Name: "ext", Command: []string{script}, MemoryLimit: 64 << 20, CPULimit: 1500 * time.Millisecond}, "")
+		got, _ := result.Analysis["ext"].(map[string]any)
+		if got["memory"] != float64(65536) || got["cpu"] != float64(2) {
+			t.Errorf("Analysis = %v, errors = %v", result.Analysis, result.AnalysisErrors)
+		}
+	})
+
+	tests := []struct {
+		name    string
+		body    string
+		spec    Spec
+		errText string
+	}{
+		{name: "exit status", body: "echo 'rules not found' >&2; exit 3", errText: "exit status 3: rules not found"},
+		{name: "invalid output", body: "echo not json", errText: "invalid JSON output"},
+		{name: "timeout", body: "exec sleep 10", spec: Spec{Timeout: 100 * time.Millisecond, Env: []string{"PATH"}}, errText: "timed out"},
+		{name: "output limit", body: `printf '"%0100d"' 0`, spec: Spec{MaxOutput: 50}, errText: "exceeds 50 bytes"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			spec := tt.spec
+			spec.Name = "ext"
+			spec.Command = []string{writeScript(t, tt.body)}
+			result := analyzeFile(t, &spec, "content")
+			if !strings.Contains(result.AnalysisErrors["ext"], tt.errText) {
+				t.Errorf("AnalysisErrors = %v, want %q", result.AnalysisErrors, tt.errText)
+			}
+			if result.Hash == "" {
+				t.Error("failed analyzer affected the hash")
+			}
+		})
+	}
+
+	t.Run("not applicable", func(t *testing.T) {
+		script := writeScript(t, `echo '{}'`)
+		result := analyzeFile(t, &Spec{Name: "ext", Command: []string{script}, Types: []string{"application/pdf"}}, "plain text")
+		if len(result.Analysis) != 0 || len(result.AnalysisErrors) != 0 {
+			t.Errorf("analyzer ran on a file it does not apply to: %v %v", result.Analysis, result.AnalysisErrors)
+		}
+	})
+}
+
+func TestBatchStage(t *testing.T) {
+	script := writeScript(t, `n=0
+while read path; do
+	n=$((n + 1))
+	case "$path" in
+	*bad*) printf '{"path": "%s", "error": "unreadable"}\n' "$path" ;;
+	*skip*) ;;
+	*) printf '{"path": "%s", "result": {"line": %d}}\n' "$path" "$n" ;;
+	esac
+done`)
+	spec := &Spec{Name: "batch", Command: []string{script}, BatchSize: 2, Concurrency: 2}
+	if err := spec.Init(); err != nil {
+		t.Fatalf("Init: %v", err)
+	}
+
+	in := make(chan processor.FileResult, 10)
+	for _, path := range []string{"/a", "/b", "/bad", "/c", "/skip", "/new\nline", "/d"} {
+		in <- processor.FileResult{Path: path}
+	}
+	in <- processor.FileResult{Path: "/failed", Error: "stat error"}
+	close(in)
+
+	results := map[string]processor.FileResult{}
+	for result := range NewBatchStage(spec).Run(context.Background(), in) {
+		results[result.Path] = result
+	}
+	if len(results) != 8 {
+		t.Fatalf("got %d results, want 8", len(results))
+	}
+	for _, path := range []string{"/a", "/b", "/c", "/d"} {
+		if _, ok := results[path].Analysis["batch"].(map[string]any)["line"]; !ok {
+			t.Errorf("%s: Analysis = %v, errors = %v", path, results[path].Analysis, results[path].AnalysisErrors)
+		}
+	}
+	if results["/bad"].AnalysisErrors["batch"] != "unreadable" {
+		t.Errorf("/bad: errors = %v", results["/bad"].AnalysisErrors)
+	}
+	for _, path := range []string{"/skip", "/new\nline"} {
+		if results[path].Analysis != nil || results[path].AnalysisErrors["batch"] == "" {
+			t.Errorf("%q: Analysis = %v, errors = %v, want an error", path, results[path].Analysis, results[path].AnalysisErrors)
+		}
+	}
+	if results["/failed"].Analysis != nil || results["/failed"].AnalysisErrors != nil {
+		t.Error("failed result was analyzed")
+	}
+}
@@ -0,0 +1,22 @@
+//go:build !unix
+
+package execanalyzer
+
+import (
+	"context"
+	"errors"
+	"os/exec"
+)
+
+// checkLimits reports whether the spec's resource limits can be enforced.
+func checkLimits(s *Spec) error {
+	if s.MemoryLimit > 0 || s.CPULimit > 0 {
+		return errors.New("memory and cpu limits are only supported on Unix")
+	}
+	return nil
+}
+
+// command returns the command of a run.
+func (s *Spec) command(ctx context.Context) *exec.Cmd {
+	return exec.CommandContext(ctx, s.path, s.Command[1:]...)
+}
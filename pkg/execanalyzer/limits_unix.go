@@ -0,0 +1,37 @@
+//go:build unix
+
+package execanalyzer
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// checkLimits reports whether the spec's resource limits can be enforced.
+func checkLimits(s *Spec) error {
+	return nil
+}
+
+// command returns the command of a run. Resource limits are applied by
+// running the program through a shell that sets them with ulimit first.
+func (s *Spec) command(ctx context.Context) *exec.Cmd {
+	if s.MemoryLimit == 0 && s.CPULimit == 0 {
+		return exec.CommandContext(ctx, s.path, s.Command[1:]...)
+	}
+	var limits []string
+	if s.MemoryLimit > 0 {
+		limits = append(limits, fmt.Sprintf("ulimit -v %d", (s.MemoryLimit+1023)/1024))
+	}
+	if s.CPULimit > 0 {
+		seconds := int64(s.CPULimit.Seconds())
+		if float64(seconds) < s.CPULimit.Seconds() {
+			seconds++
+		}
+		limits = append(limits, fmt.Sprintf("ulimit -t %d", seconds))
+	}
+	script := strings.Join(limits, " && ") + ` && exec "$0" "$@"`
+	args := append([]string{"-c", script, s.path}, s.Command[1:]...)
+	return exec.CommandContext(ctx, "/bin/sh", args...)
+}
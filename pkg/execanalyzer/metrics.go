@@ -0,0 +1,8 @@
+package execanalyzer
+
+import "github.com/vtriple/agentflux/pkg/metrics"
+
+var (
+	commandRuns = metrics.Default.NewCounterVec("agentflux_execanalyzer_runs_total",
+		"Runs of external analyzer commands, by analyzer and result (ok, error or timeout).", "analyzer", "result")
+)
@@ -0,0 +1,264 @@
+// Package execanalyzer runs external commands as analyzers, so tools that
+// are not written in Go can add results to FileResult.Analysis. A command
+// runs per file, receiving the file's path or content on stdin, or per
+// batch of files, receiving their paths.
+package execanalyzer
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/vtriple/agentflux/pkg/common/fileutils"
+	"github.com/vtriple/agentflux/pkg/processor"
+)
+
+// Input selects what a command receives on stdin.
+type Input string
+
+const (
+	// InputPath sends the file's path followed by a newline, or one path per
+	// line in batch mode.
+	InputPath Input = "path"
+	// InputContent streams the file's content as it is hashed.
+	InputContent Input = "content"
+)
+
+const (
+	// DefaultTimeout is how long a command may run when the spec sets no
+	// timeout.
+	DefaultTimeout = 30 * time.Second
+	// DefaultMaxOutput is the largest output accepted from a command when
+	// the spec sets no limit.
+	DefaultMaxOutput = 1024 * 1024
+	// maxStderr is how much of a command's stderr is kept for its error.
+	maxStderr = 4096
+)
+
+// Spec describes an external command analyzer.
+type Spec struct {
+	// Name is the key of the command's results in FileResult.Analysis.
+	Name string
+	// Command is the program and its arguments. It is run directly unless
+	// MemoryLimit or CPULimit is set, in which case it is run through
+	// /bin/sh -c, which sets the limits with ulimit and then execs it.
+	Command []string
+	// Input selects what the command receives on stdin.
+	Input Input
+	// BatchSize runs the command once per this many files instead of once
+	// per file (0 for per file). Batches receive paths only.
+	BatchSize int
+	// Types limits the command to files whose sniffed MIME type starts with
+	// one of these prefixes (empty for all files). Per-file mode only.
+	Types []string
+	// MaxSize limits the command to files no larger than this many bytes
+	// (0 for no limit).
+	MaxSize int64
+	// Timeout limits each run of the command, which is killed when it
+	// expires.
+	Timeout time.Duration
+	// Concurrency is the number of runs of the command at a time.
+	Concurrency int
+	// MemoryLimit is the virtual memory limit of the command in bytes
+	// (0 for no limit). It needs a /bin/sh whose ulimit supports -v.
+	MemoryLimit int64
+	// CPULimit is the CPU time limit of the command (0 for no limit). It
+	// needs a /bin/sh whose ulimit supports -t.
+	CPULimit time.Duration
+	// Env lists the environment variables passed on to the command. All
+	// others are removed.
+	Env []string
+	// MaxOutput is the largest output accepted from the command in bytes.
+	MaxOutput int64
+
+	path  string
+	slots chan struct{}
+}
+
+// specFile is the JSON form of a Spec.
+type specFile struct {
+	Name        string   `json:"name"`
+	Command     []string `json:"command"`
+	Input       string   `json:"input"`
+	Batch       int      `json:"batch"`
+	Types       []string `json:"types"`
+	MaxSize     string   `json:"maxSize"`
+	Timeout     string   `json:"timeout"`
+	Concurrency int      `json:"concurrency"`
+	Memory      string   `json:"memory"`
+	CPU         string   `json:"cpu"`
+	Env         []string `json:"env"`
+	MaxOutput   string   `json:"maxOutput"`
+}
+
+// LoadFile reads the JSON array of analyzer specs in the named file.
+func LoadFile(name string) ([]*Spec, error) {
+	data, err := os.ReadFile(name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read analyzer specs: %w", err)
+	}
+	specs, err := Parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+	return specs, nil
+}
+
+// Parse parses and validates a JSON array of analyzer specs.
+func Parse(data []byte) ([]*Spec, error) {
+	var files []specFile
+	if err := json.Unmarshal(data, &files); err != nil {
+		return nil, fmt.Errorf("invalid analyzer specs: %w", err)
+	}
+	specs := make([]*Spec, 0, len(files))
+	names := make(map[string]bool)
+	for _, file := range files {
+		spec, err := file.spec()
+		if err != nil {
+			return nil, err
+		}
+		if names[spec.Name] {
+			return nil, fmt.Errorf("duplicate analyzer %q", spec.Name)
+		}
+		names[spec.Name] = true
+		if err := spec.Init(); err != nil {
+			return nil, err
+		}
+		specs = append(specs, spec)
+	}
+	return specs, nil
+}
+
+// spec converts the JSON form, parsing sizes and durations.
+func (f specFile) spec() (*Spec, error) {
+	spec := &Spec{
+		Name:        f.Name,
+		Command:     f.Command,
+		Input:       Input(f.Input),
+		BatchSize:   f.Batch,
+		Types:       f.Types,
+		Concurrency: f.Concurrency,
+		Env:         f.Env,
+	}
+	var err error
+	if spec.MaxSize, err = parseSize(f.MaxSize); err != nil {
+		return nil, fmt.Errorf("analyzer %q: invalid maxSize: %w", f.Name, err)
+	}
+	if spec.MemoryLimit, err = parseSize(f.Memory); err != nil {
+		return nil, fmt.Errorf("analyzer %q: invalid memory: %w", f.Name, err)
+	}
+	if spec.MaxOutput, err = parseSize(f.MaxOutput); err != nil {
+		return nil, fmt.Errorf("analyzer %q: invalid maxOutput: %w", f.Name, err)
+	}
+	if spec.Timeout, err = parseDuration(f.Timeout); err != nil {
+		return nil, fmt.Errorf("analyzer %q: invalid timeout: %w", f.Name, err)
+	}
+	if spec.CPULimit, err = parseDuration(f.CPU); err != nil {
+		return nil, fmt.Errorf("analyzer %q: invalid cpu: %w", f.Name, err)
+	}
+	return spec, nil
+}
+
+// parseSize parses an optional size.
+func parseSize(s string) (int64, error) {
+	if s == "" {
+		return 0, nil
+	}
+	return fileutils.ParseSize(s)
+}
+
+// parseDuration parses an optional duration.
+func parseDuration(s string) (time.Duration, error) {
+	if s == "" {
+		return 0, nil
+	}
+	return time.ParseDuration(s)
+}
+
+// Init validates the spec, fills in defaults and resolves the program. It
+// is called by Parse and must be called on specs built in code.
+func (s *Spec) Init() error {
+	if s.Name == "" {
+		return fmt.Errorf("analyzer needs a name")
+	}
+	if len(s.Command) == 0 || s.Command[0] == "" {
+		return fmt.Errorf("analyzer %q needs a command", s.Name)
+	}
+	switch s.Input {
+	case "":
+		s.Input = InputPath
+	case InputPath, InputContent:
+	default:
+		return fmt.Errorf("analyzer %q: unsupported input %q (expected path or content)", s.Name, s.Input)
+	}
+	if s.BatchSize < 0 {
+		return fmt.Errorf("analyzer %q: batch must not be negative", s.Name)
+	}
+	if s.BatchSize > 0 && s.Input == InputContent {
+		return fmt.Errorf("analyzer %q: batches receive paths, not content", s.Name)
+	}
+	if s.BatchSize > 0 && len(s.Types) > 0 {
+		return fmt.Errorf("analyzer %q: types are only supported per file", s.Name)
+	}
+	if s.MaxSize < 0 || s.MemoryLimit < 0 || s.MaxOutput < 0 || s.Timeout < 0 || s.CPULimit < 0 {
+		return fmt.Errorf("analyzer %q: limits must not be negative", s.Name)
+	}
+	if s.Timeout == 0 {
+		s.Timeout = DefaultTimeout
+	}
+	if s.Concurrency <= 0 {
+		s.Concurrency = 1
+	}
+	if s.MaxOutput == 0 {
+		s.MaxOutput = DefaultMaxOutput
+	}
+	for _, name := range s.Env {
+		if name == "" || strings.Contains(name, "=") {
+			return fmt.Errorf("analyzer %q: invalid environment variable name %q", s.Name, name)
+		}
+	}
+	if err := checkLimits(s); err != nil {
+		return fmt.Errorf("analyzer %q: %w", s.Name, err)
+	}
+
+	// Resolve the program now, as the command runs without PATH unless it
+	// is allowed
+	path, err := exec.LookPath(s.Command[0])
+	if err != nil {
+		return fmt.Errorf("analyzer %q: %w", s.Name, err)
+	}
+	s.path = path
+	s.slots = make(chan struct{}, s.Concurrency)
+	return nil
+}
+
+// Factory returns the analyzer factory of a per-file spec, for
+// HashProcessor.Analyzers. The analysis is given a second longer than the
+// command, so the command's own timeout is reported.
+func (s *Spec) Factory() processor.AnalyzerFactory {
+	return processor.AnalyzerFactory{
+		Name:    s.Name,
+		New:     func() processor.Analyzer { return &fileAnalyzer{spec: s} },
+		Timeout: s.Timeout + time.Second,
+	}
+}
+
+// applies reports whether the command wants a file of the given size and
+// MIME type.
+func (s *Spec) applies(size int64, mimeType string) bool {
+	if s.MaxSize > 0 && size > s.MaxSize {
+		return false
+	}
+	if len(s.Types) == 0 {
+		return true
+	}
+	for _, prefix := range s.Types {
+		if strings.HasPrefix(mimeType, prefix) {
+			return true
+		}
+	}
+	return false
+}
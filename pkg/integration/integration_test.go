@@ -11,8 +11,10 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
+	"strings"
 	"testing"
 
+	"github.com/vtriple/agentflux/pkg/api"
 	"github.com/vtriple/agentflux/pkg/processor"
 )
 
@@ -62,15 +64,29 @@ func TestEndToEndFlow(t *testing.T) {
 			return
 		}
 
-		var results []processor.FileResult
-		if err := json.Unmarshal(body, &results); err != nil {
+		var envelope api.Envelope
+		if err := json.Unmarshal(body, &envelope); err != nil {
 			t.Errorf("Failed to parse request body: %v", err)
 			w.WriteHeader(http.StatusBadRequest)
 			return
 		}
 
+		// Verify the envelope metadata
+		if envelope.SchemaVersion != api.EnvelopeSchemaVersion {
+			t.Errorf("Expected schema version %d, got %d", api.EnvelopeSchemaVersion, envelope.SchemaVersion)
+		}
+		if envelope.ScanID == "" || envelope.Host.Hostname == "" || envelope.Agent.Version == "" {
+			t.Errorf("Envelope missing scan, host or agent metadata: %+v", envelope)
+		}
+		if envelope.Labels["env"] != "test" {
+			t.Errorf("Expected label env=test, got %v", envelope.Labels)
+		}
+		if r.Header.Get(api.HeaderIdempotencyKey) != envelope.IdempotencyKey {
+			t.Errorf("Idempotency-Key header does not match envelope")
+		}
+
 		// Add the results to our collection
-		receivedResults = append(receivedResults, results...)
+		receivedResults = append(receivedResults, envelope.Results...)
 
 		// Return success response
 		w.WriteHeader(http.StatusOK)
@@ -95,6 +111,7 @@ func TestEndToEndFlow(t *testing.T) {
 		"--token=test-token",
 		"--strings",
 		"--string-min=4",
+		"--label=env=test",
 	)
 
 	var stdout, stderr bytes.Buffer
@@ -134,6 +151,36 @@ func TestEndToEndFlow(t *testing.T) {
 	}
 }
 
+// TestDupesReport tests the dupes subcommand against the test file set
+func TestDupesReport(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping integration test in short mode")
+	}
+
+	testDir := t.TempDir()
+	setupTestFiles(t, testDir)
+
+	binPath, err := BuildForTest()
+	if err != nil {
+		t.Fatalf("Failed to build application: %v", err)
+	}
+
+	output, err := exec.Command(binPath, "dupes", "--paths="+testDir, "--workers=2").CombinedOutput()
+	if err != nil {
+		t.Fatalf("Failed to run dupes: %v\nOutput: %s", err, output)
+	}
+
+	report := string(output)
+	if !strings.Contains(report, "Scanned 5 files: 1 duplicate groups, 1 redundant copies, 24B wasted") {
+		t.Errorf("Unexpected report summary:\n%s", report)
+	}
+	for _, name := range []string{"duplicate1.txt", "duplicate2.txt"} {
+		if !strings.Contains(report, filepath.Join(testDir, name)) {
+			t.Errorf("Expected %s in report:\n%s", name, report)
+		}
+	}
+}
+
 // setupTestFiles creates test files in the specified directory
 func setupTestFiles(t *testing.T, dir string) {
 	// Create various test files
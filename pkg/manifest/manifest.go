@@ -0,0 +1,224 @@
+// Package manifest reads and writes checksum manifests in the formats of
+// sha256sum and BSD cksum, and verifies files against them.
+package manifest
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Format is a manifest line format.
+type Format string
+
+const (
+	// FormatCoreutils writes "HASH  PATH" lines, as sha256sum and md5sum do.
+	FormatCoreutils Format = "coreutils"
+	// FormatBSD writes "SHA256 (PATH) = HASH" lines, as BSD cksum and
+	// sha256sum --tag do.
+	FormatBSD Format = "bsd"
+)
+
+// ParseFormat parses a manifest format name.
+func ParseFormat(s string) (Format, error) {
+	switch Format(strings.ToLower(s)) {
+	case FormatCoreutils:
+		return FormatCoreutils, nil
+	case FormatBSD:
+		return FormatBSD, nil
+	default:
+		return "", fmt.Errorf("unsupported manifest format: %s (expected coreutils or bsd)", s)
+	}
+}
+
+// Entry is one file listed in a manifest.
+type Entry struct {
+	// Hash is the expected hash in lowercase hex.
+	Hash string
+	// Algorithm is the hash algorithm, from the BSD tag or inferred from the
+	// length of Hash.
+	Algorithm string
+	// Path is the file path as written in the manifest.
+	Path string
+	// Line is the line number of the entry, for error messages.
+	Line int
+}
+
+// AlgorithmForLength returns the hash algorithm whose hex digest has n
+// characters, or "" if none of the supported algorithms match.
+func AlgorithmForLength(n int) string {
+	switch n {
+	case 32:
+		return "md5"
+	case 40:
+		return "sha1"
+	case 64:
+		return "sha256"
+	case 128:
+		return "sha512"
+	default:
+		return ""
+	}
+}
+
+// Parse reads a manifest of "HASH  PATH" lines, as written by sha256sum and
+// md5sum, or "SHA256 (PATH) = HASH" lines in the BSD format; both may be
+// mixed. A "*" before the path of a coreutils line marks binary mode and is
+// ignored. Blank lines and lines starting with "#" are skipped.
+func Parse(r io.Reader) ([]Entry, error) {
+	var entries []Entry
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimRight(scanner.Text(), "\r")
+		if strings.TrimSpace(text) == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		entry, err := parseLine(text)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		entry.Line = line
+		entries = append(entries, entry)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("error reading manifest: %w", err)
+	}
+	return entries, nil
+}
+
+// parseLine parses a single line in either format.
+func parseLine(text string) (Entry, error) {
+	// Coreutils prefixes lines with a backslash when the path is escaped
+	escaped := strings.HasPrefix(text, "\\")
+	if escaped {
+		text = text[1:]
+	}
+
+	var entry Entry
+	var err error
+	if tag, rest, ok := strings.Cut(text, " ("); ok && isTag(tag) {
+		entry, err = parseBSD(tag, rest)
+	} else {
+		entry, err = parseCoreutils(text)
+	}
+	if err != nil {
+		return Entry{}, err
+	}
+	if escaped {
+		entry.Path = unescape(entry.Path)
+	}
+	return entry, nil
+}
+
+// parseCoreutils parses a "HASH  PATH" line.
+func parseCoreutils(text string) (Entry, error) {
+	hash, path, ok := strings.Cut(text, " ")
+	if !ok || path == "" || (path[0] != ' ' && path[0] != '*') {
+		return Entry{}, fmt.Errorf("expected \"HASH  PATH\" or \"ALGORITHM (PATH) = HASH\"")
+	}
+	path = path[1:]
+	if path == "" {
+		return Entry{}, fmt.Errorf("missing path")
+	}
+
+	hash = strings.ToLower(hash)
+	if !isHex(hash) {
+		return Entry{}, fmt.Errorf("invalid hash %q", hash)
+	}
+	algorithm := AlgorithmForLength(len(hash))
+	if algorithm == "" {
+		return Entry{}, fmt.Errorf("unsupported hash length %d", len(hash))
+	}
+	return Entry{Hash: hash, Algorithm: algorithm, Path: path}, nil
+}
+
+// parseBSD parses the remainder of an "ALGORITHM (PATH) = HASH" line after
+// the opening parenthesis.
+func parseBSD(tag, rest string) (Entry, error) {
+	// The path may itself contain ") = ", so the hash follows the last one
+	i := strings.LastIndex(rest, ") = ")
+	if i < 0 {
+		return Entry{}, fmt.Errorf("expected \"ALGORITHM (PATH) = HASH\"")
+	}
+	path, hash := rest[:i], strings.ToLower(rest[i+len(") = "):])
+	if path == "" {
+		return Entry{}, fmt.Errorf("missing path")
+	}
+
+	algorithm := strings.ToLower(tag)
+	if !isHex(hash) {
+		return Entry{}, fmt.Errorf("invalid hash %q", hash)
+	}
+	if AlgorithmForLength(len(hash)) != algorithm {
+		return Entry{}, fmt.Errorf("unsupported algorithm %s or hash length %d", tag, len(hash))
+	}
+	return Entry{Hash: hash, Algorithm: algorithm, Path: path}, nil
+}
+
+// isTag reports whether s looks like a BSD algorithm tag such as SHA256.
+func isTag(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, c := range s {
+		if (c < 'A' || c > 'Z') && (c < '0' || c > '9') {
+			return false
+		}
+	}
+	return true
+}
+
+// Writer writes manifest lines in one format.
+type Writer struct {
+	w      io.Writer
+	format Format
+}
+
+// NewWriter creates a Writer that writes lines in format to w.
+func NewWriter(w io.Writer, format Format) *Writer {
+	return &Writer{w: w, format: format}
+}
+
+// Write writes the line for a file with the given hash. Paths containing
+// backslashes or newlines are escaped the way coreutils does, so they can
+// be read back by Parse and by sha256sum -c.
+func (mw *Writer) Write(algorithm, hash, path string) error {
+	prefix := ""
+	if strings.ContainsAny(path, "\\\n\r") {
+		prefix = "\\"
+		path = escape(path)
+	}
+
+	var err error
+	if mw.format == FormatBSD {
+		_, err = fmt.Fprintf(mw.w, "%s%s (%s) = %s\n", prefix, strings.ToUpper(algorithm), path, hash)
+	} else {
+		_, err = fmt.Fprintf(mw.w, "%s%s  %s\n", prefix, hash, path)
+	}
+	return err
+}
+
+// escape escapes backslashes and newlines in a path the way coreutils does.
+func escape(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "\n", `\n`, "\r", `\r`).Replace(s)
+}
+
+// unescape reverses escape.
+func unescape(s string) string {
+	return strings.NewReplacer(`\\`, `\`, `\n`, "\n", `\r`, "\r").Replace(s)
+}
+
+// isHex reports whether s is a non-empty lowercase hex string.
+func isHex(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, c := range s {
+		if (c < '0' || c > '9') && (c < 'a' || c > 'f') {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,109 @@
+package manifest
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	input := `# checksums
+d41d8cd98f00b204e9800998ecf8427e  empty.txt
+E3B0C44298FC1C149AFBF4C8996FB92427AE41E4649B934CA495991B7852B855 *bin/tool
+
+\da39a3ee5e6b4b0d3255bfef95601890afd80709  dir/with\\backslash\nnewline
+`
+	entries, err := Parse(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("Failed to parse manifest: %v", err)
+	}
+
+	expected := []Entry{
+		{Hash: "d41d8cd98f00b204e9800998ecf8427e", Algorithm: "md5", Path: "empty.txt", Line: 2},
+		{Hash: "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855", Algorithm: "sha256", Path: "bin/tool", Line: 3},
+		{Hash: "da39a3ee5e6b4b0d3255bfef95601890afd80709", Algorithm: "sha1", Path: "dir/with\\backslash\nnewline", Line: 5},
+	}
+	if len(entries) != len(expected) {
+		t.Fatalf("Expected %d entries, got %d: %+v", len(expected), len(entries), entries)
+	}
+	for i, entry := range entries {
+		if entry != expected[i] {
+			t.Errorf("Entry %d: expected %+v, got %+v", i, expected[i], entry)
+		}
+	}
+}
+
+func TestParseInvalid(t *testing.T) {
+	tests := []struct {
+		input   string
+		errText string
+	}{
+		{"d41d8cd98f00b204e9800998ecf8427e", "expected"},
+		{"d41d8cd98f00b204e9800998ecf8427e  ", "missing path"},
+		{"xyz  file", "invalid hash"},
+		{"abcd  file", "unsupported hash length"},
+		{"ok\nd41d8cd98f00b204e9800998ecf8427e  a\nbad line", "line 1"},
+	}
+	for _, tt := range tests {
+		_, err := Parse(strings.NewReader(tt.input))
+		if err == nil || !strings.Contains(err.Error(), tt.errText) {
+			t.Errorf("%q: expected error containing %q, got %v", tt.input, tt.errText, err)
+		}
+	}
+}
+
+func TestParseBSD(t *testing.T) {
+	input := "SHA256 (a (1)) = e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855\n" +
+		"MD5 (b) = D41D8CD98F00B204E9800998ECF8427E\n"
+	entries, err := Parse(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("Failed to parse manifest: %v", err)
+	}
+	if len(entries) != 2 || entries[0].Path != "a (1)" || entries[0].Algorithm != "sha256" ||
+		entries[1].Path != "b" || entries[1].Hash != "d41d8cd98f00b204e9800998ecf8427e" {
+		t.Errorf("Unexpected entries %+v", entries)
+	}
+
+	if _, err := Parse(strings.NewReader("SHA1 (b) = d41d8cd98f00b204e9800998ecf8427e\n")); err == nil {
+		t.Error("Expected error for a hash that does not match the algorithm")
+	}
+}
+
+func TestWriterRoundTrip(t *testing.T) {
+	hash := "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+	paths := []string{"plain.txt", "dir/with space", "back\\slash", "new\nline"}
+
+	for _, format := range []Format{FormatCoreutils, FormatBSD} {
+		var out strings.Builder
+		writer := NewWriter(&out, format)
+		for _, path := range paths {
+			if err := writer.Write("sha256", hash, path); err != nil {
+				t.Fatalf("Failed to write entry: %v", err)
+			}
+		}
+		if format == FormatBSD && !strings.HasPrefix(out.String(), "SHA256 (plain.txt) = "+hash+"\n") {
+			t.Errorf("Unexpected BSD output %q", out.String())
+		}
+
+		entries, err := Parse(strings.NewReader(out.String()))
+		if err != nil {
+			t.Fatalf("%s: failed to parse output: %v", format, err)
+		}
+		if len(entries) != len(paths) {
+			t.Fatalf("%s: expected %d entries, got %d", format, len(paths), len(entries))
+		}
+		for i, entry := range entries {
+			if entry.Path != paths[i] || entry.Hash != hash || entry.Algorithm != "sha256" {
+				t.Errorf("%s: expected %q, got %+v", format, paths[i], entry)
+			}
+		}
+	}
+}
+
+func TestParseFormat(t *testing.T) {
+	if format, err := ParseFormat("BSD"); err != nil || format != FormatBSD {
+		t.Errorf("Expected bsd format, got %q (%v)", format, err)
+	}
+	if _, err := ParseFormat("json"); err == nil {
+		t.Error("Expected error for unsupported format")
+	}
+}
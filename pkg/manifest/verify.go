@@ -0,0 +1,165 @@
+package manifest
+
+import (
+	"context"
+	"path/filepath"
+	"runtime"
+	"sync"
+
+	"github.com/vtriple/agentflux/pkg/common/errcode"
+	"github.com/vtriple/agentflux/pkg/processor"
+)
+
+// Status is the outcome of verifying one file.
+type Status string
+
+const (
+	// StatusOK means the file's hash matches the manifest.
+	StatusOK Status = "OK"
+	// StatusFailed means the file's hash differs or it could not be read.
+	StatusFailed Status = "FAILED"
+	// StatusMissing means the file does not exist.
+	StatusMissing Status = "MISSING"
+)
+
+// Result is the outcome of verifying one manifest entry.
+type Result struct {
+	Entry
+	// Status is the outcome of the check.
+	Status Status
+	// Hash is the hash computed from the file, if it could be read.
+	Hash string
+	// Error describes why the file could not be read.
+	Error string
+}
+
+// Verifier hashes the files of a manifest in parallel and compares them with
+// the recorded hashes.
+type Verifier struct {
+	// Workers is the number of files hashed concurrently.
+	Workers int
+	// Dir is the directory relative paths are resolved against; empty means
+	// the current directory, as with sha256sum -c.
+	Dir string
+}
+
+// NewVerifier creates a Verifier with one worker per CPU.
+func NewVerifier() *Verifier {
+	return &Verifier{Workers: runtime.NumCPU()}
+}
+
+// Verify checks every entry and returns the results in manifest order. The
+// channel is closed when all entries are checked or ctx is cancelled.
+func (v *Verifier) Verify(ctx context.Context, entries []Entry) <-chan Result {
+	out := make(chan Result, v.workers())
+
+	// Each algorithm gets its own pool, fed with the entries that use it
+	pending := map[string][]int{}
+	inputs := map[string]chan string{}
+	var merged []<-chan processor.FileResult
+	for i, entry := range entries {
+		key := entry.Algorithm + "\x00" + v.resolve(entry.Path)
+		pending[key] = append(pending[key], i)
+		if _, ok := inputs[entry.Algorithm]; ok {
+			continue
+		}
+		hashProcessor := processor.NewHashProcessor(entry.Algorithm, v.workers())
+		hashProcessor.SkipLargeFiles = false
+		input := make(chan string, v.workers())
+		inputs[entry.Algorithm] = input
+		merged = append(merged, hashProcessor.Process(input))
+	}
+
+	go func() {
+		defer func() {
+			for _, input := range inputs {
+				close(input)
+			}
+		}()
+		for _, entry := range entries {
+			select {
+			case inputs[entry.Algorithm] <- v.resolve(entry.Path):
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	fileResults := make(chan processor.FileResult, v.workers())
+	var wg sync.WaitGroup
+	wg.Add(len(merged))
+	for _, results := range merged {
+		go func(results <-chan processor.FileResult) {
+			defer wg.Done()
+			for result := range results {
+				fileResults <- result
+			}
+		}(results)
+	}
+	go func() {
+		wg.Wait()
+		close(fileResults)
+	}()
+
+	go func() {
+		defer close(out)
+		// Results arrive in completion order and are held until all earlier
+		// entries are done
+		done := make([]*Result, len(entries))
+		next := 0
+		for fileResult := range fileResults {
+			if ctx.Err() != nil {
+				// Keep draining so the workers can finish
+				continue
+			}
+			key := fileResult.HashAlgorithm + "\x00" + fileResult.Path
+			indexes := pending[key]
+			if len(indexes) == 0 {
+				continue
+			}
+			i := indexes[0]
+			pending[key] = indexes[1:]
+			result := check(entries[i], fileResult)
+			done[i] = &result
+
+			for next < len(done) && done[next] != nil {
+				select {
+				case out <- *done[next]:
+				case <-ctx.Done():
+				}
+				done[next] = nil
+				next++
+			}
+		}
+	}()
+	return out
+}
+
+// check compares a processed file with its manifest entry.
+func check(entry Entry, fileResult processor.FileResult) Result {
+	result := Result{Entry: entry, Hash: fileResult.Hash, Error: fileResult.Error}
+	switch {
+	case fileResult.Error != "" && fileResult.ErrorCode == errcode.Vanished:
+		result.Status = StatusMissing
+	case fileResult.Error != "" || fileResult.Hash != entry.Hash:
+		result.Status = StatusFailed
+	default:
+		result.Status = StatusOK
+	}
+	return result
+}
+
+// resolve returns the path of a manifest entry on disk.
+func (v *Verifier) resolve(path string) string {
+	if v.Dir == "" || filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(v.Dir, path)
+}
+
+func (v *Verifier) workers() int {
+	if v.Workers < 1 {
+		return 1
+	}
+	return v.Workers
+}
@@ -0,0 +1,64 @@
+package manifest
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestVerify(t *testing.T) {
+	dir := t.TempDir()
+	var entries []Entry
+	for i := 0; i < 50; i++ {
+		name := fmt.Sprintf("file%02d.txt", i)
+		content := []byte(name)
+		if err := os.WriteFile(filepath.Join(dir, name), content, 0644); err != nil {
+			t.Fatalf("Failed to write file: %v", err)
+		}
+		hash := fmt.Sprintf("%x", sha256.Sum256(content))
+		if i == 10 {
+			hash = fmt.Sprintf("%x", sha256.Sum256([]byte("other")))
+		}
+		entries = append(entries, Entry{Hash: hash, Algorithm: "sha256", Path: name})
+	}
+	entries = append(entries, Entry{Hash: entries[0].Hash, Algorithm: "sha256", Path: "missing.txt"})
+
+	verifier := NewVerifier()
+	verifier.Workers = 4
+	verifier.Dir = dir
+
+	var results []Result
+	for result := range verifier.Verify(context.Background(), entries) {
+		results = append(results, result)
+	}
+	if len(results) != len(entries) {
+		t.Fatalf("Expected %d results, got %d", len(entries), len(results))
+	}
+	for i, result := range results {
+		expected := StatusOK
+		switch i {
+		case 10:
+			expected = StatusFailed
+		case len(entries) - 1:
+			expected = StatusMissing
+		}
+		if result.Path != entries[i].Path || result.Status != expected {
+			t.Errorf("Result %d: expected %s %s, got %s %s", i, entries[i].Path, expected, result.Path, result.Status)
+		}
+	}
+}
+
+func TestVerifyCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	entries := make([]Entry, 100)
+	for i := range entries {
+		entries[i] = Entry{Hash: "d41d8cd98f00b204e9800998ecf8427e", Algorithm: "md5", Path: fmt.Sprintf("/nonexistent/%d", i)}
+	}
+	for range NewVerifier().Verify(ctx, entries) {
+	}
+}
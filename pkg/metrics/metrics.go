@@ -0,0 +1,325 @@
+// Package metrics provides counters, gauges and histograms exposed in the
+// Prometheus text format, without external dependencies.
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// DefBuckets are the default histogram buckets, in seconds.
+var DefBuckets = []float64{.001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// Default is the registry the pipeline packages register their metrics on.
+var Default = NewRegistry()
+
+// Registry holds a set of metrics.
+type Registry struct {
+	mu       sync.Mutex
+	families []*family
+	names    map[string]bool
+}
+
+// NewRegistry creates an empty registry.
+func NewRegistry() *Registry {
+	return &Registry{names: map[string]bool{}}
+}
+
+// metricType is the Prometheus type of a family.
+type metricType string
+
+const (
+	typeCounter   metricType = "counter"
+	typeGauge     metricType = "gauge"
+	typeHistogram metricType = "histogram"
+)
+
+// family is a metric name with its series, one per combination of label values.
+type family struct {
+	name       string
+	help       string
+	kind       metricType
+	labelNames []string
+	buckets    []float64
+
+	mu     sync.Mutex
+	series map[string]*series
+}
+
+// series is a single time series of a family.
+type series struct {
+	labelValues []string
+	value       atomicFloat
+
+	// Gauges may be computed when scraped
+	fn atomic.Pointer[func() float64]
+
+	// Histograms count observations per bucket
+	counts []atomic.Uint64
+	count  atomic.Uint64
+}
+
+// register adds a new family, panicking on duplicate names as they are
+// programming errors.
+func (r *Registry) register(f *family) *family {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.names[f.name] {
+		panic(fmt.Sprintf("metrics: duplicate metric %s", f.name))
+	}
+	r.names[f.name] = true
+	f.series = map[string]*series{}
+	r.families = append(r.families, f)
+	return f
+}
+
+// with returns the series for the given label values, creating it if needed.
+func (f *family) with(values []string) *series {
+	if len(values) != len(f.labelNames) {
+		panic(fmt.Sprintf("metrics: %s expects %d label values, got %d", f.name, len(f.labelNames), len(values)))
+	}
+	key := strings.Join(values, "\xff")
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	s, ok := f.series[key]
+	if !ok {
+		s = &series{labelValues: append([]string{}, values...)}
+		if f.kind == typeHistogram {
+			s.counts = make([]atomic.Uint64, len(f.buckets))
+		}
+		f.series[key] = s
+	}
+	return s
+}
+
+// Counter is a value that only goes up.
+type Counter struct{ s *series }
+
+// Inc adds one to the counter.
+func (c Counter) Inc() { c.s.value.add(1) }
+
+// Add adds v, which must not be negative, to the counter.
+func (c Counter) Add(v float64) {
+	if v < 0 {
+		panic("metrics: counter cannot decrease")
+	}
+	c.s.value.add(v)
+}
+
+// Value returns the current value of the counter.
+func (c Counter) Value() float64 { return c.s.value.load() }
+
+// CounterVec is a counter partitioned by labels.
+type CounterVec struct{ f *family }
+
+// NewCounter registers a counter without labels.
+func (r *Registry) NewCounter(name, help string) Counter {
+	return r.NewCounterVec(name, help).With()
+}
+
+// NewCounterVec registers a counter with the given label names.
+func (r *Registry) NewCounterVec(name, help string, labelNames ...string) *CounterVec {
+	return &CounterVec{r.register(&family{name: name, help: help, kind: typeCounter, labelNames: labelNames})}
+}
+
+// With returns the counter for the given label values.
+func (v *CounterVec) With(labelValues ...string) Counter {
+	return Counter{v.f.with(labelValues)}
+}
+
+// Gauge is a value that can go up and down, or be computed when scraped.
+type Gauge struct{ s *series }
+
+// Set sets the gauge to v.
+func (g Gauge) Set(v float64) { g.s.value.store(v) }
+
+// Add adds v to the gauge.
+func (g Gauge) Add(v float64) { g.s.value.add(v) }
+
+// SetFunc makes the gauge report the result of fn when scraped, such as the
+// length of a channel. A nil fn reverts to the stored value.
+func (g Gauge) SetFunc(fn func() float64) {
+	if fn == nil {
+		g.s.fn.Store(nil)
+		return
+	}
+	g.s.fn.Store(&fn)
+}
+
+// Value returns the current value of the gauge.
+func (g Gauge) Value() float64 {
+	if fn := g.s.fn.Load(); fn != nil {
+		return (*fn)()
+	}
+	return g.s.value.load()
+}
+
+// GaugeVec is a gauge partitioned by labels.
+type GaugeVec struct{ f *family }
+
+// NewGauge registers a gauge without labels.
+func (r *Registry) NewGauge(name, help string) Gauge {
+	return r.NewGaugeVec(name, help).With()
+}
+
+// NewGaugeVec registers a gauge with the given label names.
+func (r *Registry) NewGaugeVec(name, help string, labelNames ...string) *GaugeVec {
+	return &GaugeVec{r.register(&family{name: name, help: help, kind: typeGauge, labelNames: labelNames})}
+}
+
+// With returns the gauge for the given label values.
+func (v *GaugeVec) With(labelValues ...string) Gauge {
+	return Gauge{v.f.with(labelValues)}
+}
+
+// Histogram counts observations in buckets.
+type Histogram struct {
+	s       *series
+	buckets []float64
+}
+
+// Observe records one observation.
+func (h Histogram) Observe(v float64) {
+	// Buckets are sorted, so the first bucket holding v is found by search
+	i := sort.SearchFloat64s(h.buckets, v)
+	if i < len(h.buckets) {
+		h.s.counts[i].Add(1)
+	}
+	h.s.count.Add(1)
+	h.s.value.add(v)
+}
+
+// Count returns the number of observations.
+func (h Histogram) Count() uint64 { return h.s.count.Load() }
+
+// HistogramVec is a histogram partitioned by labels.
+type HistogramVec struct{ f *family }
+
+// NewHistogram registers a histogram without labels. Nil buckets use DefBuckets.
+func (r *Registry) NewHistogram(name, help string, buckets []float64) Histogram {
+	return r.NewHistogramVec(name, help, buckets).With()
+}
+
+// NewHistogramVec registers a histogram with the given label names.
+func (r *Registry) NewHistogramVec(name, help string, buckets []float64, labelNames ...string) *HistogramVec {
+	if buckets == nil {
+		buckets = DefBuckets
+	}
+	buckets = append([]float64{}, buckets...)
+	sort.Float64s(buckets)
+	return &HistogramVec{r.register(&family{name: name, help: help, kind: typeHistogram, labelNames: labelNames, buckets: buckets})}
+}
+
+// With returns the histogram for the given label values.
+func (v *HistogramVec) With(labelValues ...string) Histogram {
+	return Histogram{v.f.with(labelValues), v.f.buckets}
+}
+
+// WriteText writes all metrics in the Prometheus text exposition format.
+func (r *Registry) WriteText(w io.Writer) error {
+	r.mu.Lock()
+	families := append([]*family{}, r.families...)
+	r.mu.Unlock()
+	sort.Slice(families, func(i, j int) bool { return families[i].name < families[j].name })
+
+	var b strings.Builder
+	for _, f := range families {
+		f.mu.Lock()
+		all := make([]*series, 0, len(f.series))
+		for _, s := range f.series {
+			all = append(all, s)
+		}
+		f.mu.Unlock()
+		if len(all) == 0 {
+			continue
+		}
+		sort.Slice(all, func(i, j int) bool {
+			return strings.Join(all[i].labelValues, "\xff") < strings.Join(all[j].labelValues, "\xff")
+		})
+
+		fmt.Fprintf(&b, "# HELP %s %s\n", f.name, escapeHelp(f.help))
+		fmt.Fprintf(&b, "# TYPE %s %s\n", f.name, f.kind)
+		bucketNames := append(append([]string{}, f.labelNames...), "le")
+		for _, s := range all {
+			labels := formatLabels(f.labelNames, s.labelValues)
+			switch f.kind {
+			case typeHistogram:
+				bucketValues := append(append([]string{}, s.labelValues...), "")
+				var cumulative uint64
+				for i, upper := range f.buckets {
+					cumulative += s.counts[i].Load()
+					bucketValues[len(bucketValues)-1] = formatFloat(upper)
+					fmt.Fprintf(&b, "%s_bucket%s %d\n", f.name, formatLabels(bucketNames, bucketValues), cumulative)
+				}
+				count := s.count.Load()
+				bucketValues[len(bucketValues)-1] = "+Inf"
+				fmt.Fprintf(&b, "%s_bucket%s %d\n", f.name, formatLabels(bucketNames, bucketValues), count)
+				fmt.Fprintf(&b, "%s_sum%s %s\n", f.name, labels, formatFloat(s.value.load()))
+				fmt.Fprintf(&b, "%s_count%s %d\n", f.name, labels, count)
+			case typeGauge:
+				fmt.Fprintf(&b, "%s%s %s\n", f.name, labels, formatFloat(Gauge{s}.Value()))
+			default:
+				fmt.Fprintf(&b, "%s%s %s\n", f.name, labels, formatFloat(s.value.load()))
+			}
+		}
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// formatLabels formats label pairs as {name="value",...}, or "" without labels.
+func formatLabels(names, values []string) string {
+	if len(names) == 0 {
+		return ""
+	}
+	pairs := make([]string, len(names))
+	for i, name := range names {
+		pairs[i] = name + `="` + escapeLabel(values[i]) + `"`
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+// escapeLabel escapes a label value for the text format.
+func escapeLabel(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(s)
+}
+
+// escapeHelp escapes help text for the text format.
+func escapeHelp(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "\n", `\n`).Replace(s)
+}
+
+// formatFloat formats a sample value.
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	default:
+		return strconv.FormatFloat(v, 'g', -1, 64)
+	}
+}
+
+// atomicFloat is a float64 updated atomically.
+type atomicFloat struct{ bits atomic.Uint64 }
+
+func (f *atomicFloat) load() float64 { return math.Float64frombits(f.bits.Load()) }
+
+func (f *atomicFloat) store(v float64) { f.bits.Store(math.Float64bits(v)) }
+
+func (f *atomicFloat) add(v float64) {
+	for {
+		old := f.bits.Load()
+		if f.bits.CompareAndSwap(old, math.Float64bits(math.Float64frombits(old)+v)) {
+			return
+		}
+	}
+}
@@ -0,0 +1,111 @@
+package metrics
+
+import (
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestWriteText(t *testing.T) {
+	reg := NewRegistry()
+	files := reg.NewCounter("test_files_total", "Files seen.")
+	skipped := reg.NewCounterVec("test_skipped_total", "Files skipped.", "reason")
+	depth := reg.NewGauge("test_depth", "Queue depth.")
+	reg.NewCounterVec("test_unused_total", "Never incremented.", "reason")
+
+	files.Inc()
+	files.Add(2)
+	skipped.With("hidden").Inc()
+	skipped.With(`a"b`).Inc()
+	depth.SetFunc(func() float64 { return 7 })
+
+	var b strings.Builder
+	if err := reg.WriteText(&b); err != nil {
+		t.Fatal(err)
+	}
+	want := `# HELP test_depth Queue depth.
+# TYPE test_depth gauge
+test_depth 7
+# HELP test_files_total Files seen.
+# TYPE test_files_total counter
+test_files_total 3
+# HELP test_skipped_total Files skipped.
+# TYPE test_skipped_total counter
+test_skipped_total{reason="a\"b"} 1
+test_skipped_total{reason="hidden"} 1
+`
+	if b.String() != want {
+		t.Errorf("WriteText() =\n%s\nwant\n%s", b.String(), want)
+	}
+}
+
+func TestHistogram(t *testing.T) {
+	reg := NewRegistry()
+	h := reg.NewHistogramVec("test_seconds", "Latency.", []float64{1, 0.1}, "op")
+	h.With("read").Observe(0.05)
+	h.With("read").Observe(0.1)
+	h.With("read").Observe(0.5)
+	h.With("read").Observe(3)
+
+	if got := h.With("read").Count(); got != 4 {
+		t.Errorf("Count() = %d, want 4", got)
+	}
+
+	var b strings.Builder
+	reg.WriteText(&b)
+	for _, line := range []string{
+		`test_seconds_bucket{op="read",le="0.1"} 2`,
+		`test_seconds_bucket{op="read",le="1"} 3`,
+		`test_seconds_bucket{op="read",le="+Inf"} 4`,
+		`test_seconds_sum{op="read"} 3.65`,
+		`test_seconds_count{op="read"} 4`,
+	} {
+		if !strings.Contains(b.String(), line+"\n") {
+			t.Errorf("output missing %q:\n%s", line, b.String())
+		}
+	}
+}
+
+func TestDuplicateMetric(t *testing.T) {
+	reg := NewRegistry()
+	reg.NewCounter("test_total", "")
+	defer func() {
+		if recover() == nil {
+			t.Error("expected panic on duplicate metric")
+		}
+	}()
+	reg.NewGauge("test_total", "")
+}
+
+func TestServe(t *testing.T) {
+	reg := NewRegistry()
+	reg.NewCounter("test_total", "Test counter.").Inc()
+
+	server, err := Serve("127.0.0.1:0", reg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+
+	resp, err := http.Get("http://" + server.Addr() + "/metrics")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status = %d, want 200", resp.StatusCode)
+	}
+	if ct := resp.Header.Get("Content-Type"); ct != ContentType {
+		t.Errorf("Content-Type = %q, want %q", ct, ContentType)
+	}
+	if !strings.Contains(string(body), "test_total 1\n") {
+		t.Errorf("body missing counter:\n%s", body)
+	}
+
+	if _, err := Serve(server.Addr(), reg); err == nil {
+		t.Error("expected error listening on an address in use")
+	}
+}
@@ -0,0 +1,302 @@
+// Package pipeline wires the scanner, hash processor, further stages and the
+// API client together, so programs embedding AgentFlux do not have to drain
+// error channels and coordinate shutdown themselves.
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime"
+	"sync"
+	"time"
+
+	"github.com/vtriple/agentflux/pkg/api"
+	"github.com/vtriple/agentflux/pkg/processor"
+	"github.com/vtriple/agentflux/pkg/scanner"
+)
+
+const (
+	// ScanStage is the name of the file system walk in OnStageDone and
+	// Queues.
+	ScanStage = "scan"
+	// ProcessStage is the name of the hashing stage in hooks and summaries.
+	ProcessStage = "process"
+)
+
+// Hooks are callbacks invoked while a pipeline runs. They are called from
+// the pipeline's goroutines, concurrently for different stages, and must
+// not block for long as they hold up the stage they observe.
+type Hooks struct {
+	// OnScanError is called for each error walking the file system.
+	OnScanError func(err error)
+	// OnResult is called for each result leaving a stage.
+	OnResult func(stage string, result processor.FileResult)
+	// OnStageDone is called when a stage has passed on its last result,
+	// and with ScanStage once every path has been walked.
+	OnStageDone func(stage StageSummary)
+	// OnDeliveryError is called with errors delivering results to the API.
+	// It is informational: errors may be dropped when they arrive faster
+	// than they are handled, while Summary.DeliveryErrors counts them all.
+	OnDeliveryError func(err error)
+}
+
+// Summary describes a finished run.
+type Summary struct {
+	// ScanErrors is the number of errors walking the file system.
+	ScanErrors int
+	// FileErrors is the number of results from the processor with an error.
+	FileErrors int
+	// Results is the number of results leaving the last stage.
+	Results int
+	// DeliveryErrors is the number of batches that could not be delivered.
+	DeliveryErrors int
+	// Stages summarizes each stage in order, starting with ProcessStage.
+	Stages []StageSummary
+	// Duration is how long the run took.
+	Duration time.Duration
+}
+
+// StageSummary describes one stage of a finished run.
+type StageSummary struct {
+	// Name is the name of the stage.
+	Name string
+	// Results is the number of results the stage passed on.
+	Results int
+	// Duration is the time from the start of the run until the stage
+	// finished, as stages run concurrently.
+	Duration time.Duration
+}
+
+// Pipeline scans paths, hashes the files, runs the results through its
+// stages in order and delivers them with an API client, if one is set.
+type Pipeline struct {
+	paths     []string
+	scanner   *scanner.FileScanner
+	processor *processor.HashProcessor
+	stages    []Stage
+	client    *api.APIClient
+	hooks     Hooks
+
+	mu     sync.Mutex
+	queues map[string]func() int
+}
+
+// Option configures a Pipeline.
+type Option func(*Pipeline)
+
+// WithPaths scans the given paths with a default scanner created when the
+// pipeline runs.
+func WithPaths(paths ...string) Option {
+	return func(p *Pipeline) { p.paths = append(p.paths, paths...) }
+}
+
+// WithScanner uses a configured scanner instead of WithPaths. The scanner
+// stops with the context it was created with.
+func WithScanner(fileScanner *scanner.FileScanner) Option {
+	return func(p *Pipeline) { p.scanner = fileScanner }
+}
+
+// WithProcessor uses a configured hash processor instead of the default
+// sha256 processor with one worker per CPU.
+func WithProcessor(hashProcessor *processor.HashProcessor) Option {
+	return func(p *Pipeline) { p.processor = hashProcessor }
+}
+
+// WithStage appends a stage after hashing. Stages run in the order they are
+// added.
+func WithStage(stage Stage) Option {
+	return func(p *Pipeline) { p.stages = append(p.stages, stage) }
+}
+
+// WithClient delivers the results leaving the last stage with client.
+// Without a client, results are only passed to the hooks. The client must
+// not deliver for other runs at the same time, as its failures are counted.
+func WithClient(client *api.APIClient) Option {
+	return func(p *Pipeline) { p.client = client }
+}
+
+// WithHooks sets the callbacks invoked during a run.
+func WithHooks(hooks Hooks) Option {
+	return func(p *Pipeline) { p.hooks = hooks }
+}
+
+// New creates a pipeline from options. WithPaths or WithScanner is required.
+func New(options ...Option) (*Pipeline, error) {
+	p := &Pipeline{}
+	for _, option := range options {
+		option(p)
+	}
+	if p.scanner == nil && len(p.paths) == 0 {
+		return nil, errors.New("pipeline: no paths to scan")
+	}
+	if p.scanner != nil && len(p.paths) > 0 {
+		return nil, errors.New("pipeline: WithPaths and WithScanner are mutually exclusive")
+	}
+	if p.processor == nil {
+		p.processor = processor.NewHashProcessor("sha256", runtime.NumCPU())
+	}
+	for _, stage := range p.stages {
+		if stage == nil || stage.Name() == "" {
+			return nil, errors.New("pipeline: stages must be non-nil and named")
+		}
+	}
+	return p, nil
+}
+
+// Run runs the pipeline until every file is delivered or ctx is cancelled,
+// and waits for all of its goroutines to finish. Scan and file errors are
+// only counted; Run returns an error if ctx was cancelled or delivery
+// failed, along with the summary of what was done.
+func (p *Pipeline) Run(ctx context.Context) (Summary, error) {
+	start := time.Now()
+	fileScanner := p.scanner
+	if fileScanner == nil {
+		fileScanner = scanner.NewFileScanner(ctx, p.paths)
+	}
+
+	var (
+		mu             sync.Mutex
+		summary        Summary
+		monitors       sync.WaitGroup
+		stageSummaries = make([]StageSummary, len(p.stages)+1)
+	)
+
+	fileChannel, scanErrors := fileScanner.Scan()
+	p.setQueue(ScanStage, func() int { return len(fileChannel) })
+	defer p.clearQueues()
+	monitors.Add(1)
+	go func() {
+		defer monitors.Done()
+		for err := range scanErrors {
+			mu.Lock()
+			summary.ScanErrors++
+			mu.Unlock()
+			if p.hooks.OnScanError != nil {
+				p.hooks.OnScanError(err)
+			}
+		}
+		// The scanner closes its error channel once it is done
+		if p.hooks.OnStageDone != nil {
+			p.hooks.OnStageDone(StageSummary{Name: ScanStage, Results: int(fileScanner.Stats().Discovered), Duration: time.Since(start)})
+		}
+	}()
+
+	results := p.observe(ctx, start, ProcessStage, p.processor.Process(fileChannel), &stageSummaries[0], &monitors, func(result processor.FileResult) {
+		if result.Error != "" {
+			mu.Lock()
+			summary.FileErrors++
+			mu.Unlock()
+		}
+	})
+	for i, stage := range p.stages {
+		results = p.observe(ctx, start, stage.Name(), stage.Run(ctx, results), &stageSummaries[i+1], &monitors, nil)
+	}
+
+	var deliveryErr error
+	if p.client != nil {
+		failedBefore, _ := p.client.Failures()
+		deliveryErrors := p.client.SendResults(ctx, results)
+		monitors.Add(1)
+		go func() {
+			defer monitors.Done()
+			for err := range deliveryErrors {
+				if p.hooks.OnDeliveryError != nil {
+					p.hooks.OnDeliveryError(err)
+				}
+			}
+		}()
+		p.client.Wait()
+		failedAfter, lastFailure := p.client.Failures()
+		summary.DeliveryErrors = failedAfter - failedBefore
+		if summary.DeliveryErrors > 0 {
+			deliveryErr = fmt.Errorf("%d batches failed to deliver, the last: %w", summary.DeliveryErrors, lastFailure)
+		}
+		// The client may stop reading on cancellation
+		for range results {
+		}
+	} else {
+		for range results {
+		}
+	}
+	monitors.Wait()
+
+	summary.Stages = stageSummaries
+	summary.Results = stageSummaries[len(stageSummaries)-1].Results
+	summary.Duration = time.Since(start)
+	switch {
+	case ctx.Err() != nil:
+		return summary, ctx.Err()
+	case deliveryErr != nil:
+		return summary, deliveryErr
+	}
+	return summary, nil
+}
+
+// observe passes results from in to the returned channel, counting them and
+// calling the hooks for the named stage. Once ctx is done it keeps draining
+// in without passing results on, so the stage producing them can finish
+// even if the next stage has stopped reading. wg is done once in is closed.
+func (p *Pipeline) observe(ctx context.Context, start time.Time, name string, in <-chan processor.FileResult,
+	stage *StageSummary, wg *sync.WaitGroup, observe func(processor.FileResult)) <-chan processor.FileResult {
+	stage.Name = name
+	out := make(chan processor.FileResult, cap(in))
+	p.setQueue(name, func() int { return len(in) + len(out) })
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		defer close(out)
+		for result := range in {
+			if ctx.Err() != nil {
+				continue
+			}
+			stage.Results++
+			if observe != nil {
+				observe(result)
+			}
+			if p.hooks.OnResult != nil {
+				p.hooks.OnResult(name, result)
+			}
+			select {
+			case out <- result:
+			case <-ctx.Done():
+			}
+		}
+		stage.Duration = time.Since(start)
+		if p.hooks.OnStageDone != nil {
+			p.hooks.OnStageDone(*stage)
+		}
+	}()
+	return out
+}
+
+// Queues returns the number of items waiting between the stages of a
+// running pipeline, by the name of the stage that passed them on: ScanStage
+// for paths waiting to be hashed, ProcessStage for hashed results and so
+// on. It is empty when the pipeline is not running.
+func (p *Pipeline) Queues() map[string]int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	lengths := make(map[string]int, len(p.queues))
+	for name, length := range p.queues {
+		lengths[name] = length()
+	}
+	return lengths
+}
+
+// setQueue records how to measure the queue of the named stage.
+func (p *Pipeline) setQueue(name string, length func() int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.queues == nil {
+		p.queues = make(map[string]func() int)
+	}
+	p.queues[name] = length
+}
+
+// clearQueues forgets the channels of a finished run.
+func (p *Pipeline) clearQueues() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.queues = nil
+}
@@ -0,0 +1,197 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/vtriple/agentflux/pkg/api"
+	"github.com/vtriple/agentflux/pkg/dedup"
+	"github.com/vtriple/agentflux/pkg/processor"
+)
+
+// writeFiles creates files with the given contents in a temporary directory.
+func writeFiles(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestNew(t *testing.T) {
+	if _, err := New(); err == nil {
+		t.Error("expected an error without paths")
+	}
+	if _, err := New(WithPaths("."), WithStage(Map("", nil))); err == nil {
+		t.Error("expected an error for an unnamed stage")
+	}
+	if _, err := New(WithPaths(".")); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestPipeline_Run(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"a.txt":  "same",
+		"b.txt":  "same",
+		"c.txt":  "other",
+		"d.skip": "skipped",
+	})
+
+	var mu sync.Mutex
+	seen := map[string]int{}
+	var done []string
+	var queues map[string]int
+	var p *Pipeline
+	p, err := New(
+		WithPaths(dir),
+		WithProcessor(processor.NewHashProcessor("sha256", 2)),
+		WithStage(Map("filter", func(result processor.FileResult) (processor.FileResult, bool) {
+			if queues == nil {
+				queues = p.Queues()
+			}
+			return result, !strings.HasSuffix(result.Path, ".skip")
+		})),
+		WithStage(Deduplicate(dedup.NewDeduplicationEngine(dedup.HashDedup))),
+		WithHooks(Hooks{
+			OnResult: func(stage string, result processor.FileResult) {
+				mu.Lock()
+				seen[stage]++
+				mu.Unlock()
+			},
+			OnStageDone: func(stage StageSummary) {
+				mu.Lock()
+				done = append(done, stage.Name)
+				mu.Unlock()
+			},
+		}),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	summary, err := p.Run(context.Background())
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if summary.Results != 2 {
+		t.Errorf("Results = %d, want 2", summary.Results)
+	}
+	want := []StageSummary{{Name: ProcessStage, Results: 4}, {Name: "filter", Results: 3}, {Name: "dedup", Results: 2}}
+	if len(summary.Stages) != len(want) {
+		t.Fatalf("Stages = %+v", summary.Stages)
+	}
+	for i, stage := range summary.Stages {
+		if stage.Name != want[i].Name || stage.Results != want[i].Results {
+			t.Errorf("Stages[%d] = %+v, want %s with %d results", i, stage, want[i].Name, want[i].Results)
+		}
+		if seen[stage.Name] != stage.Results {
+			t.Errorf("OnResult called %d times for %s, want %d", seen[stage.Name], stage.Name, stage.Results)
+		}
+	}
+	if len(done) != len(want)+1 || !slices.Contains(done, ScanStage) {
+		t.Errorf("OnStageDone called for %v", done)
+	}
+	for _, name := range []string{ScanStage, ProcessStage, "filter", "dedup"} {
+		if _, ok := queues[name]; !ok {
+			t.Errorf("Queues() = %v while running, missing %s", queues, name)
+		}
+	}
+	if len(p.Queues()) != 0 {
+		t.Errorf("Queues() = %v after the run", p.Queues())
+	}
+}
+
+func TestPipeline_RunWithClient(t *testing.T) {
+	dir := writeFiles(t, map[string]string{"a.txt": "a", "b.txt": "b"})
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	p, err := New(WithPaths(dir), WithClient(api.NewAPIClient(server.URL, api.AuthBearer, "token")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	summary, err := p.Run(context.Background())
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if summary.Results != 2 || summary.DeliveryErrors != 0 {
+		t.Errorf("summary = %+v", summary)
+	}
+	if requests.Load() == 0 {
+		t.Error("expected the results to be delivered")
+	}
+}
+
+func TestPipeline_RunDeliveryFailures(t *testing.T) {
+	files := map[string]string{}
+	for i := 0; i < api.DefaultErrorBufferSize+5; i++ {
+		files[fmt.Sprintf("file%d", i)] = fmt.Sprint(i)
+	}
+	dir := writeFiles(t, files)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	defer server.Close()
+
+	client := api.NewAPIClient(server.URL, api.AuthBearer, "token")
+	client.BatchSize = 1
+	client.MaxRetries = 0
+	// A slow hook lets the client's error channel fill up
+	p, err := New(WithPaths(dir), WithClient(client), WithHooks(Hooks{
+		OnDeliveryError: func(error) { time.Sleep(5 * time.Millisecond) },
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	summary, err := p.Run(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "batches failed to deliver") {
+		t.Errorf("Run = %v, want a delivery error", err)
+	}
+	if summary.DeliveryErrors != len(files) {
+		t.Errorf("DeliveryErrors = %d, want %d", summary.DeliveryErrors, len(files))
+	}
+}
+
+func TestPipeline_RunCancelled(t *testing.T) {
+	files := map[string]string{}
+	for _, name := range []string{"a", "b", "c", "d", "e", "f", "g", "h"} {
+		files[name] = name
+	}
+	dir := writeFiles(t, files)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	// A stage that stops reading on cancellation must not stall the run
+	p, err := New(
+		WithPaths(dir),
+		WithStage(Map("cancel", func(result processor.FileResult) (processor.FileResult, bool) {
+			cancel()
+			return result, true
+		})),
+		WithStage(Deduplicate(dedup.NewDeduplicationEngine(dedup.PathDedup))),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := p.Run(ctx); !errors.Is(err, context.Canceled) {
+		t.Errorf("Run = %v, want context.Canceled", err)
+	}
+}
@@ -0,0 +1,68 @@
+package pipeline
+
+import (
+	"context"
+
+	"github.com/vtriple/agentflux/pkg/dedup"
+	"github.com/vtriple/agentflux/pkg/processor"
+)
+
+// Stage transforms the stream of results between hashing and delivery.
+type Stage interface {
+	// Name identifies the stage in hooks and the run summary.
+	Name() string
+	// Run reads results from in and returns the results to pass on. The
+	// returned channel must be closed once in is closed or ctx is done.
+	// The pipeline keeps draining in after the stage stops reading, so a
+	// stage may return early on cancellation.
+	Run(ctx context.Context, in <-chan processor.FileResult) <-chan processor.FileResult
+}
+
+// Deduplicate returns a stage that removes duplicates with engine, named
+// "dedup".
+func Deduplicate(engine *dedup.DeduplicationEngine) Stage {
+	return dedupStage{engine}
+}
+
+type dedupStage struct {
+	engine *dedup.DeduplicationEngine
+}
+
+func (s dedupStage) Name() string { return "dedup" }
+
+func (s dedupStage) Run(ctx context.Context, in <-chan processor.FileResult) <-chan processor.FileResult {
+	return s.engine.Deduplicate(ctx, in)
+}
+
+// Map returns a stage that passes each result through fn, dropping the
+// results for which fn returns false. It suits stages that enrich or filter
+// results one at a time.
+func Map(name string, fn func(processor.FileResult) (processor.FileResult, bool)) Stage {
+	return mapStage{name, fn}
+}
+
+type mapStage struct {
+	name string
+	fn   func(processor.FileResult) (processor.FileResult, bool)
+}
+
+func (s mapStage) Name() string { return s.name }
+
+func (s mapStage) Run(ctx context.Context, in <-chan processor.FileResult) <-chan processor.FileResult {
+	out := make(chan processor.FileResult, cap(in))
+	go func() {
+		defer close(out)
+		for result := range in {
+			result, keep := s.fn(result)
+			if !keep {
+				continue
+			}
+			select {
+			case out <- result:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return out
+}
@@ -0,0 +1,312 @@
+package processor
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+// DefaultAnalyzerTimeout is how long an analyzer may take for one file when
+// neither its factory nor the processor sets a timeout.
+const DefaultAnalyzerTimeout = 30 * time.Second
+
+// analyzerQueueLength is the number of chunks buffered for each analyzer, so
+// a briefly slow analyzer does not hold up hashing.
+const analyzerQueueLength = 4
+
+// Analyzer examines the content of a file in the same read pass that hashes
+// it. A new Analyzer is created for every file, so implementations need not
+// be safe for concurrent use.
+type Analyzer interface {
+	// Name is the key of the analyzer's result in FileResult.Analysis.
+	Name() string
+	// Applies reports whether the analyzer wants the content of file. It is
+	// called once the first chunk has been read, so MimeType is known.
+	Applies(file AnalyzedFile) bool
+	// Write receives the next chunk of the file. Chunks are not reused and
+	// must not be modified. An error stops the analyzer for this file.
+	Write(p []byte) (int, error)
+	// Result is called after the last chunk and returns a JSON-encodable
+	// value to store in FileResult.Analysis.
+	Result() (any, error)
+}
+
+// AnalyzedFile describes the file an analyzer is offered.
+type AnalyzedFile struct {
+	// Path is the full path to the file.
+	Path string
+	// Size is the size of the file in bytes.
+	Size int64
+	// MimeType is the MIME type sniffed from the start of the file.
+	MimeType string
+	// IsExecutable indicates if the file has executable permissions.
+	IsExecutable bool
+}
+
+// AnalyzerFactory creates the analyzers of one kind.
+type AnalyzerFactory struct {
+	// Name is the name the analyzer is selected by, and must match the
+	// Name of the analyzers New returns.
+	Name string
+	// New creates an analyzer for one file.
+	New func() Analyzer
+	// Timeout limits the time the analyzer may take for one file, from the
+	// first chunk until its result. Zero uses the processor's timeout.
+	Timeout time.Duration
+}
+
+// AnalyzerRegistry holds the analyzers available by name.
+type AnalyzerRegistry struct {
+	mu        sync.RWMutex
+	factories map[string]AnalyzerFactory
+}
+
+// NewAnalyzerRegistry creates an empty registry.
+func NewAnalyzerRegistry() *AnalyzerRegistry {
+	return &AnalyzerRegistry{factories: make(map[string]AnalyzerFactory)}
+}
+
+// DefaultAnalyzers is the registry the built-in analyzers register with and
+// the command line selects from.
+var DefaultAnalyzers = NewAnalyzerRegistry()
+
+// Register adds an analyzer. Names must be unique.
+func (r *AnalyzerRegistry) Register(factory AnalyzerFactory) error {
+	if factory.Name == "" || factory.New == nil {
+		return fmt.Errorf("analyzer needs a name and a constructor")
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, exists := r.factories[factory.Name]; exists {
+		return fmt.Errorf("analyzer %q is already registered", factory.Name)
+	}
+	r.factories[factory.Name] = factory
+	return nil
+}
+
+// Names returns the names of the registered analyzers in sorted order.
+func (r *AnalyzerRegistry) Names() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	names := make([]string, 0, len(r.factories))
+	for name := range r.factories {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Select returns the factories of the named analyzers, in the given order.
+func (r *AnalyzerRegistry) Select(names []string) ([]AnalyzerFactory, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	factories := make([]AnalyzerFactory, 0, len(names))
+	for _, name := range names {
+		factory, ok := r.factories[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown analyzer: %s", name)
+		}
+		factories = append(factories, factory)
+	}
+	return factories, nil
+}
+
+// analysisOutcome is what an analyzer produced for a file.
+type analysisOutcome struct {
+	value any
+	err   error
+}
+
+// analysis runs one analyzer for one file in its own goroutine, so a slow
+// or failing analyzer can be abandoned without affecting hashing or the
+// other analyzers.
+type analysis struct {
+	name     string
+	timeout  time.Duration
+	chunks   chan []byte
+	done     chan analysisOutcome
+	timer    *time.Timer
+	outcome  *analysisOutcome
+	finished bool
+}
+
+// start runs analyzer in a new goroutine that reads chunks until they are
+// closed or the analyzer fails.
+func (a *analysis) start(analyzer Analyzer) {
+	a.chunks = make(chan []byte, analyzerQueueLength)
+	a.done = make(chan analysisOutcome, 1)
+	a.timer = time.NewTimer(a.timeout)
+	go func() {
+		var outcome analysisOutcome
+		defer func() {
+			if r := recover(); r != nil {
+				outcome = analysisOutcome{err: fmt.Errorf("analyzer panicked: %v", r)}
+			}
+			a.done <- outcome
+		}()
+		for chunk := range a.chunks {
+			if _, err := analyzer.Write(chunk); err != nil {
+				outcome.err = err
+				return
+			}
+		}
+		outcome.value, outcome.err = analyzer.Result()
+	}()
+}
+
+// feed passes a chunk to the analyzer unless it has already stopped.
+func (a *analysis) feed(chunk []byte) {
+	if a.outcome != nil {
+		return
+	}
+	select {
+	case a.chunks <- chunk:
+	case outcome := <-a.done:
+		a.outcome = &outcome
+	case <-a.timer.C:
+		a.outcome = &analysisOutcome{err: fmt.Errorf("timed out after %s", a.timeout)}
+	}
+}
+
+// finish signals the end of the file and waits for the analyzer's result.
+func (a *analysis) finish() analysisOutcome {
+	if !a.finished {
+		a.finished = true
+		close(a.chunks)
+	}
+	if a.outcome == nil {
+		select {
+		case outcome := <-a.done:
+			a.outcome = &outcome
+		case <-a.timer.C:
+			a.outcome = &analysisOutcome{err: fmt.Errorf("timed out after %s", a.timeout)}
+		}
+	}
+	a.timer.Stop()
+	return *a.outcome
+}
+
+// analysisSet fans the chunks read while hashing out to the applicable
+// analyzers. It is started lazily on the first chunk, which is used to
+// sniff the MIME type.
+type analysisSet struct {
+	factories []AnalyzerFactory
+	timeout   time.Duration
+	result    *FileResult
+	analyses  []*analysis
+	errors    map[string]string
+	started   bool
+}
+
+// newAnalysisSet creates the analyses of result's file.
+func (h *HashProcessor) newAnalysisSet(result *FileResult) *analysisSet {
+	timeout := h.AnalyzerTimeout
+	if timeout <= 0 {
+		timeout = DefaultAnalyzerTimeout
+	}
+	return &analysisSet{factories: h.Analyzers, timeout: timeout, result: result}
+}
+
+// Write passes a copy of p to every running analyzer. It never fails, so
+// analyzers cannot interrupt hashing.
+func (s *analysisSet) Write(p []byte) (int, error) {
+	if !s.started {
+		s.start(p)
+	}
+	if len(s.analyses) > 0 && len(p) > 0 {
+		chunk := bytes.Clone(p)
+		for _, a := range s.analyses {
+			a.feed(chunk)
+		}
+	}
+	return len(p), nil
+}
+
+// start sniffs the MIME type from the first chunk and creates the analyzers
+// that apply to the file.
+func (s *analysisSet) start(first []byte) {
+	s.started = true
+	s.result.MimeType = http.DetectContentType(first)
+	file := AnalyzedFile{
+		Path:         s.result.Path,
+		Size:         s.result.Size,
+		MimeType:     s.result.MimeType,
+		IsExecutable: s.result.IsExecutable,
+	}
+	for _, factory := range s.factories {
+		analyzer, applies, err := newAnalyzer(factory, file)
+		if err != nil {
+			s.fail(factory.Name, err)
+			continue
+		}
+		if !applies {
+			continue
+		}
+		timeout := factory.Timeout
+		if timeout <= 0 {
+			timeout = s.timeout
+		}
+		a := &analysis{name: factory.Name, timeout: timeout}
+		a.start(analyzer)
+		s.analyses = append(s.analyses, a)
+	}
+}
+
+// newAnalyzer creates an analyzer and asks whether it applies to file,
+// turning a panic into an error.
+func newAnalyzer(factory AnalyzerFactory, file AnalyzedFile) (analyzer Analyzer, applies bool, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("analyzer panicked: %v", r)
+		}
+	}()
+	analyzer = factory.New()
+	return analyzer, analyzer.Applies(file), nil
+}
+
+// Finish waits for the analyzers and stores their results and errors in the
+// file's result.
+func (s *analysisSet) Finish() {
+	if !s.started {
+		// Empty files are still offered to the analyzers
+		s.start(nil)
+	}
+	for _, a := range s.analyses {
+		outcome := a.finish()
+		if outcome.err != nil {
+			s.fail(a.name, outcome.err)
+			continue
+		}
+		if s.result.Analysis == nil {
+			s.result.Analysis = make(map[string]any)
+		}
+		s.result.Analysis[a.name] = outcome.value
+	}
+	if len(s.errors) > 0 {
+		s.result.AnalysisErrors = s.errors
+	}
+}
+
+// Abort stops the analyzers without collecting their results, when the file
+// could not be read to the end.
+func (s *analysisSet) Abort() {
+	for _, a := range s.analyses {
+		if !a.finished {
+			a.finished = true
+			close(a.chunks)
+		}
+		a.timer.Stop()
+	}
+}
+
+// fail records an analyzer's error for the file.
+func (s *analysisSet) fail(name string, err error) {
+	analyzerErrors.With(name).Inc()
+	if s.errors == nil {
+		s.errors = make(map[string]string)
+	}
+	s.errors[name] = err.Error()
+}
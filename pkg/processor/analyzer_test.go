@@ -0,0 +1,156 @@
+package processor
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testAnalyzer is a configurable analyzer for tests.
+type testAnalyzer struct {
+	name     string
+	applies  func(AnalyzedFile) bool
+	writeErr error
+	panics   bool
+	block    chan struct{}
+	bytes    int
+}
+
+func (a *testAnalyzer) Name() string { return a.name }
+
+func (a *testAnalyzer) Applies(file AnalyzedFile) bool {
+	return a.applies == nil || a.applies(file)
+}
+
+func (a *testAnalyzer) Write(p []byte) (int, error) {
+	if a.panics {
+		panic("boom")
+	}
+	if a.block != nil {
+		<-a.block
+	}
+	if a.writeErr != nil {
+		return 0, a.writeErr
+	}
+	a.bytes += len(p)
+	return len(p), nil
+}
+
+func (a *testAnalyzer) Result() (any, error) { return a.bytes, nil }
+
+// factoryFor returns a factory creating copies of a.
+func factoryFor(a testAnalyzer, timeout time.Duration) AnalyzerFactory {
+	return AnalyzerFactory{
+		Name:    a.name,
+		New:     func() Analyzer { copied := a; return &copied },
+		Timeout: timeout,
+	}
+}
+
+func TestAnalyzerRegistry(t *testing.T) {
+	registry := NewAnalyzerRegistry()
+	for _, name := range []string{"b", "a"} {
+		if err := registry.Register(factoryFor(testAnalyzer{name: name}, 0)); err != nil {
+			t.Fatalf("Register(%s): %v", name, err)
+		}
+	}
+	if err := registry.Register(factoryFor(testAnalyzer{name: "a"}, 0)); err == nil {
+		t.Error("expected an error registering a duplicate name")
+	}
+	if names := registry.Names(); strings.Join(names, ",") != "a,b" {
+		t.Errorf("Names = %v", names)
+	}
+	factories, err := registry.Select([]string{"b", "a"})
+	if err != nil || len(factories) != 2 || factories[0].Name != "b" {
+		t.Errorf("Select = %v, %v", factories, err)
+	}
+	if _, err := registry.Select([]string{"missing"}); err == nil {
+		t.Error("expected an error selecting an unknown analyzer")
+	}
+	if _, err := DefaultAnalyzers.Select([]string{"entropy"}); err != nil {
+		t.Errorf("entropy analyzer not registered: %v", err)
+	}
+}
+
+func TestProcessFile_Analyzers(t *testing.T) {
+	content := strings.Repeat("abcd", 1000)
+	path := filepath.Join(t.TempDir(), "test.txt")
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	block := make(chan struct{})
+	defer close(block)
+	processor := NewHashProcessor("sha256", 1)
+	processor.AnalyzerTimeout = time.Second
+	processor.Analyzers = []AnalyzerFactory{
+		DefaultAnalyzers.factories["entropy"],
+		factoryFor(testAnalyzer{name: "count"}, 0),
+		factoryFor(testAnalyzer{name: "binary", applies: func(file AnalyzedFile) bool {
+			return !strings.HasPrefix(file.MimeType, "text/")
+		}}, 0),
+		factoryFor(testAnalyzer{name: "failing", writeErr: errors.New("bad input")}, 0),
+		factoryFor(testAnalyzer{name: "panicking", panics: true}, 0),
+		factoryFor(testAnalyzer{name: "slow", block: block}, 50*time.Millisecond),
+	}
+
+	result := processor.ProcessFile(path)
+	if result.Error != "" || result.Hash == "" {
+		t.Fatalf("file failed: %s", result.Error)
+	}
+	if !strings.HasPrefix(result.MimeType, "text/plain") {
+		t.Errorf("MimeType = %q", result.MimeType)
+	}
+	if got := result.Analysis["entropy"].(EntropyResult).Entropy; got != 2 {
+		t.Errorf("entropy = %v, want 2", got)
+	}
+	if got := result.Analysis["count"]; got != len(content) {
+		t.Errorf("count analyzer saw %v bytes, want %d", got, len(content))
+	}
+	if _, ok := result.Analysis["binary"]; ok {
+		t.Error("analyzer ran on a file it does not apply to")
+	}
+	for name, want := range map[string]string{"failing": "bad input", "panicking": "panicked", "slow": "timed out"} {
+		if !strings.Contains(result.AnalysisErrors[name], want) {
+			t.Errorf("AnalysisErrors[%s] = %q, want %q", name, result.AnalysisErrors[name], want)
+		}
+		if _, ok := result.Analysis[name]; ok {
+			t.Errorf("failed analyzer %s has a result", name)
+		}
+	}
+}
+
+func TestProcessFile_AnalyzersEmptyFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "empty")
+	if err := os.WriteFile(path, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	processor := NewHashProcessor("sha256", 1)
+	processor.Analyzers = []AnalyzerFactory{
+		DefaultAnalyzers.factories["entropy"],
+		factoryFor(testAnalyzer{name: "count"}, 0),
+	}
+
+	result := processor.ProcessFile(path)
+	if _, ok := result.Analysis["entropy"]; ok {
+		t.Error("entropy analyzer ran on an empty file")
+	}
+	if got := result.Analysis["count"]; got != 0 {
+		t.Errorf("count analyzer = %v, want 0", got)
+	}
+}
+
+func TestMimeTypeWithoutAnalyzers(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "image.png")
+	if err := os.WriteFile(path, []byte("\x89PNG\r\n\x1a\n rest of the image"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	result := NewHashProcessor("sha256", 1).ProcessFile(path)
+	if result.MimeType != "image/png" || result.Analysis != nil || result.AnalysisErrors != nil {
+		t.Errorf("Expected the MIME type to be sniffed without analyzers, got %q, %v, %v",
+			result.MimeType, result.Analysis, result.AnalysisErrors)
+	}
+}
@@ -0,0 +1,50 @@
+package processor
+
+import "math"
+
+// EntropyResult is the result of the entropy analyzer.
+type EntropyResult struct {
+	// Entropy is the Shannon entropy of the content in bits per byte, from
+	// 0 to 8. Compressed and encrypted content is close to 8.
+	Entropy float64 `json:"entropy"`
+}
+
+// entropyAnalyzer computes the byte entropy of a file.
+type entropyAnalyzer struct {
+	counts [256]int64
+	total  int64
+}
+
+func init() {
+	if err := DefaultAnalyzers.Register(AnalyzerFactory{
+		Name: "entropy",
+		New:  func() Analyzer { return &entropyAnalyzer{} },
+	}); err != nil {
+		panic(err)
+	}
+}
+
+func (e *entropyAnalyzer) Name() string { return "entropy" }
+
+// Applies selects non-empty files.
+func (e *entropyAnalyzer) Applies(file AnalyzedFile) bool { return file.Size > 0 }
+
+func (e *entropyAnalyzer) Write(p []byte) (int, error) {
+	for _, b := range p {
+		e.counts[b]++
+	}
+	e.total += int64(len(p))
+	return len(p), nil
+}
+
+func (e *entropyAnalyzer) Result() (any, error) {
+	var entropy float64
+	for _, count := range e.counts {
+		if count == 0 {
+			continue
+		}
+		p := float64(count) / float64(e.total)
+		entropy -= p * math.Log2(p)
+	}
+	return EntropyResult{Entropy: math.Round(entropy*1000) / 1000}, nil
+}
@@ -16,6 +16,8 @@ import (
 	"time"
 	"unicode"
 
+	"github.com/vtriple/agentflux/pkg/common/errcode"
+	"github.com/vtriple/agentflux/pkg/common/fileutils"
 	"github.com/vtriple/agentflux/pkg/common/logging"
 )
 
@@ -29,6 +31,8 @@ type FileResult struct {
 	Size int64 `json:"size"`
 	// ModTime is the last modification time of the file.
 	ModTime time.Time `json:"modTime"`
+	// Owner is the name of the user owning the file, if available.
+	Owner string `json:"owner,omitempty"`
 	// Hash is the computed hash of the file.
 	Hash string `json:"hash"`
 	// HashAlgorithm is the algorithm used to compute the hash.
@@ -37,12 +41,57 @@ type FileResult struct {
 	MimeType string `json:"mimeType,omitempty"`
 	// Strings is a list of extracted strings from the file.
 	Strings []string `json:"strings,omitempty"`
+	// Analysis holds the result of each analyzer that ran, by analyzer name.
+	Analysis map[string]any `json:"analysis,omitempty"`
+	// AnalysisErrors holds the error of each analyzer that failed, by
+	// analyzer name. A failed analyzer does not fail the file.
+	AnalysisErrors map[string]string `json:"analysisErrors,omitempty"`
 	// Error is a description of any error that occurred during processing.
 	Error string `json:"error,omitempty"`
+	// ErrorCode is the category of Error, for aggregating failures.
+	ErrorCode errcode.Code `json:"errorCode,omitempty"`
 	// IsExecutable indicates if the file has executable permissions.
 	IsExecutable bool `json:"isExecutable,omitempty"`
 	// ProcessedAt is when the file was processed.
 	ProcessedAt time.Time `json:"processedAt"`
+	// AlsoSeenAt lists other locations with the same content when duplicates
+	// are folded into the first-seen result.
+	AlsoSeenAt []Sighting `json:"alsoSeenAt,omitempty"`
+	// Sighting marks a lightweight record of a duplicate whose content was
+	// already reported under another path.
+	Sighting bool `json:"sighting,omitempty"`
+}
+
+// SetError records err as the result's error and its category.
+func (r *FileResult) SetError(err error) {
+	r.Error = err.Error()
+	r.ErrorCode = errcode.Of(err)
+}
+
+// Sighting is another location of a file's content.
+type Sighting struct {
+	// Path is the full path of the duplicate.
+	Path string `json:"path"`
+	// ModTime is the last modification time of the duplicate.
+	ModTime time.Time `json:"modTime"`
+	// Owner is the name of the user owning the duplicate, if available.
+	Owner string `json:"owner,omitempty"`
+}
+
+// SightingOf returns a lightweight sighting record for a duplicate result,
+// without strings or other content-derived details.
+func SightingOf(result FileResult) FileResult {
+	return FileResult{
+		Path:          result.Path,
+		Name:          result.Name,
+		Size:          result.Size,
+		ModTime:       result.ModTime,
+		Owner:         result.Owner,
+		Hash:          result.Hash,
+		HashAlgorithm: result.HashAlgorithm,
+		ProcessedAt:   result.ProcessedAt,
+		Sighting:      true,
+	}
 }
 
 // HashProcessor computes hashes and extracts information from files.
@@ -59,6 +108,11 @@ type HashProcessor struct {
 	SkipLargeFiles bool
 	// MaxFileSize is the maximum file size to process.
 	MaxFileSize int64
+	// Analyzers are run on the content of each file while it is hashed.
+	Analyzers []AnalyzerFactory
+	// AnalyzerTimeout limits the time each analyzer may take for one file,
+	// unless its factory sets a timeout (0 for DefaultAnalyzerTimeout).
+	AnalyzerTimeout time.Duration
 	
 	wg     sync.WaitGroup
 	logger *logging.Logger
@@ -106,12 +160,23 @@ func (h *HashProcessor) worker(id int, fileChannel <-chan string, resultChannel
 	
 	for filePath := range fileChannel {
 		result := h.processFile(filePath)
+		if result.Error != "" {
+			filesProcessed.With("error").Inc()
+		} else {
+			filesProcessed.With("ok").Inc()
+		}
 		resultChannel <- result
 	}
 	
 	h.logger.Debug("Worker %d finished", id)
 }
 
+// ProcessFile processes a single file synchronously, for callers that hash a
+// few files without the worker pool.
+func (h *HashProcessor) ProcessFile(filePath string) FileResult {
+	return h.processFile(filePath)
+}
+
 // processFile processes a single file and returns a FileResult.
 func (h *HashProcessor) processFile(filePath string) FileResult {
 	result := FileResult{
@@ -124,46 +189,53 @@ func (h *HashProcessor) processFile(filePath string) FileResult {
 	// Get file info
 	fileInfo, err := os.Stat(filePath)
 	if err != nil {
-		result.Error = fmt.Sprintf("stat error: %v", err)
+		result.SetError(errcode.File(err, "stat error"))
 		return result
 	}
 	
 	result.Size = fileInfo.Size()
 	result.ModTime = fileInfo.ModTime()
+	result.Owner = fileOwner(fileInfo)
 	result.IsExecutable = fileInfo.Mode()&0111 != 0
 	
 	// Check if file is too large
 	if h.SkipLargeFiles && result.Size > h.MaxFileSize {
-		result.Error = fmt.Sprintf("file too large (%d bytes)", result.Size)
+		result.SetError(fmt.Errorf("%w (%d bytes)", fileutils.ErrFileTooLarge, result.Size))
 		return result
 	}
 	
 	// Open the file
 	file, err := os.Open(filePath)
 	if err != nil {
-		result.Error = fmt.Sprintf("open error: %v", err)
+		result.SetError(errcode.File(err, "open error"))
 		return result
 	}
 	defer file.Close()
 	
-	// Calculate the hash
-	hashValue, err := h.calculateHash(file)
+	// Calculate the hash, passing the content to the analyzers as it is
+	// read. The MIME type is sniffed from the first chunk even without them.
+	analyses := h.newAnalysisSet(&result)
+	start := time.Now()
+	hashValue, err := h.calculateHash(io.TeeReader(file, analyses))
+	hashDuration.Observe(time.Since(start).Seconds())
 	if err != nil {
-		result.Error = fmt.Sprintf("hash error: %v", err)
+		analyses.Abort()
+		result.SetError(errcode.File(err, "hash error"))
 		return result
 	}
 	result.Hash = hashValue
+	analyses.Finish()
 	
 	// Extract strings if requested
 	if h.ExtractStrings {
 		if _, err := file.Seek(0, io.SeekStart); err != nil {
-			result.Error = fmt.Sprintf("seek error: %v", err)
+			result.SetError(errcode.File(err, "seek error"))
 			return result
 		}
 		
 		strings, err := h.extractStrings(file)
 		if err != nil {
-			result.Error = fmt.Sprintf("string extraction error: %v", err)
+			result.SetError(errcode.File(err, "string extraction error"))
 			return result
 		}
 		result.Strings = strings
@@ -186,12 +258,14 @@ func (h *HashProcessor) calculateHash(r io.Reader) (string, error) {
 	case "sha512":
 		hasher = sha512.New()
 	default:
-		return "", fmt.Errorf("unsupported hash algorithm: %s", h.HashAlgorithm)
+		return "", errcode.Errorf(errcode.Unknown, "unsupported hash algorithm: %s", h.HashAlgorithm)
 	}
 	
 	// Use a buffer for more efficient I/O
 	buf := make([]byte, 1024*1024) // 1MB buffer
-	if _, err := io.CopyBuffer(hasher, r, buf); err != nil {
+	n, err := io.CopyBuffer(hasher, r, buf)
+	bytesHashed.Add(float64(n))
+	if err != nil {
 		return "", err
 	}
 	
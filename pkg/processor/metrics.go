@@ -0,0 +1,14 @@
+package processor
+
+import "github.com/vtriple/agentflux/pkg/metrics"
+
+var (
+	filesProcessed = metrics.Default.NewCounterVec("agentflux_processor_files_total",
+		"Files processed, by result (ok or error).", "result")
+	bytesHashed = metrics.Default.NewCounter("agentflux_processor_bytes_hashed_total",
+		"Bytes read while hashing files.")
+	hashDuration = metrics.Default.NewHistogram("agentflux_processor_hash_duration_seconds",
+		"Time taken to hash a single file.", nil)
+	analyzerErrors = metrics.Default.NewCounterVec("agentflux_processor_analyzer_errors_total",
+		"Analyzers that failed or timed out on a file, by analyzer.", "analyzer")
+)
@@ -0,0 +1,10 @@
+//go:build !unix
+
+package processor
+
+import "os"
+
+// fileOwner is not implemented on this platform.
+func fileOwner(info os.FileInfo) string {
+	return ""
+}
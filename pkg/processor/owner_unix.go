@@ -0,0 +1,35 @@
+//go:build unix
+
+package processor
+
+import (
+	"os"
+	"os/user"
+	"strconv"
+	"sync"
+	"syscall"
+)
+
+// ownerNames caches uid to user name lookups.
+var ownerNames sync.Map
+
+// fileOwner returns the name of the user owning the file, or the numeric
+// uid if it cannot be resolved.
+func fileOwner(info os.FileInfo) string {
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return ""
+	}
+
+	uid := strconv.FormatUint(uint64(stat.Uid), 10)
+	if name, ok := ownerNames.Load(uid); ok {
+		return name.(string)
+	}
+
+	name := uid
+	if u, err := user.LookupId(uid); err == nil {
+		name = u.Username
+	}
+	ownerNames.Store(uid, name)
+	return name
+}
@@ -2,6 +2,7 @@ package processor
 
 import (
 	"fmt"
+	"github.com/vtriple/agentflux/pkg/common/errcode"
 	"github.com/vtriple/agentflux/pkg/common/logging"
 	"io"
 	"os"
@@ -255,6 +256,9 @@ func TestProcessWithLargeFileSkipping(t *testing.T) {
 	if result.Error == "" || !strings.Contains(result.Error, "file too large") {
 		t.Errorf("Expected 'file too large' error, got: %s", result.Error)
 	}
+	if result.ErrorCode != errcode.TooLarge {
+		t.Errorf("Expected error code %s, got: %s", errcode.TooLarge, result.ErrorCode)
+	}
 	
 	// Test with skipping disabled
 	processor.SkipLargeFiles = false
@@ -278,6 +282,9 @@ func TestProcessFile_Errors(t *testing.T) {
 	if result.Error == "" || !strings.Contains(result.Error, "stat error") {
 		t.Errorf("Expected stat error for non-existent file, got: %s", result.Error)
 	}
+	if result.ErrorCode != errcode.Vanished {
+		t.Errorf("Expected error code %s for non-existent file, got: %s", errcode.Vanished, result.ErrorCode)
+	}
 	
 	// Test with inaccessible file
 	if os.Geteuid() != 0 { // Skip if running as root
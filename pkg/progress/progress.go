@@ -0,0 +1,312 @@
+// Package progress reports the progress of a running scan as a terminal
+// status line, periodic log lines or JSON records for wrapper scripts.
+package progress
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/vtriple/agentflux/pkg/common/logging"
+	"github.com/vtriple/agentflux/pkg/processor"
+)
+
+// DefaultInterval is the default interval between progress log lines and JSON records.
+const DefaultInterval = 10 * time.Second
+
+// terminalInterval is how often the terminal status line is redrawn.
+const terminalInterval = 250 * time.Millisecond
+
+// maxTerminalDir is the longest directory shown on the terminal status line.
+const maxTerminalDir = 40
+
+// Snapshot is the progress of a scan at a point in time.
+type Snapshot struct {
+	// Elapsed is the time since the reporter was started.
+	Elapsed time.Duration `json:"-"`
+	// ElapsedSeconds is Elapsed in seconds, for JSON records.
+	ElapsedSeconds float64 `json:"elapsedSeconds"`
+	// Files is the number of files processed.
+	Files int64 `json:"files"`
+	// Bytes is the total size of the files processed.
+	Bytes int64 `json:"bytes"`
+	// Errors is the number of scan and processing errors.
+	Errors int64 `json:"errors"`
+	// Queued is the number of files found but not yet processed.
+	Queued int `json:"queued"`
+	// CurrentDir is the directory of the file processed last.
+	CurrentDir string `json:"currentDir,omitempty"`
+	// FilesPerSecond is the average processing rate in files.
+	FilesPerSecond float64 `json:"filesPerSecond"`
+	// BytesPerSecond is the average processing rate in bytes.
+	BytesPerSecond float64 `json:"bytesPerSecond"`
+	// TotalFiles is the estimated number of files, or zero if unknown.
+	TotalFiles int64 `json:"totalFiles,omitempty"`
+	// TotalBytes is the estimated size of all files, or zero if unknown.
+	TotalBytes int64 `json:"totalBytes,omitempty"`
+	// Percent is the estimated share of the scan completed, or -1 if unknown.
+	Percent float64 `json:"percent"`
+	// ETA is the estimated time remaining, or -1 if unknown.
+	ETA time.Duration `json:"-"`
+	// ETASeconds is ETA in seconds, for JSON records.
+	ETASeconds float64 `json:"etaSeconds"`
+	// Done marks the final record of a scan.
+	Done bool `json:"done,omitempty"`
+}
+
+// Reporter tracks the progress of a scan and reports it while it runs.
+type Reporter struct {
+	// Terminal, when set, receives a status line redrawn in place. It should
+	// be a terminal; see IsTerminal.
+	Terminal io.Writer
+	// JSON, when set, receives a JSON record per interval and a final record
+	// with done set.
+	JSON io.Writer
+	// LogLines makes the reporter log a progress line per interval.
+	LogLines bool
+	// Interval is the time between log lines and JSON records.
+	Interval time.Duration
+	// Queued, when set, returns the number of files waiting to be processed.
+	Queued func() int
+
+	files, bytes, errors   atomic.Int64
+	totalFiles, totalBytes atomic.Int64
+	currentDir             atomic.Pointer[string]
+	started                time.Time
+	stop                   context.CancelFunc
+	wg                     sync.WaitGroup
+	mu                     sync.Mutex // serializes output
+	logger                 *logging.Logger
+}
+
+// NewReporter creates a reporter with the default interval and no outputs.
+func NewReporter() *Reporter {
+	return &Reporter{
+		Interval: DefaultInterval,
+		started:  time.Now(),
+		logger:   logging.NewLogger("progress"),
+	}
+}
+
+// SetEstimate sets the expected number and total size of files, enabling
+// the percentage and ETA.
+func (r *Reporter) SetEstimate(files, bytes int64) {
+	r.totalFiles.Store(files)
+	r.totalBytes.Store(bytes)
+}
+
+// AddError counts an error that did not produce a result, such as an
+// unreadable directory.
+func (r *Reporter) AddError() {
+	r.errors.Add(1)
+}
+
+// Observe counts a processed result.
+func (r *Reporter) Observe(result processor.FileResult) {
+	r.files.Add(1)
+	r.bytes.Add(result.Size)
+	if result.Error != "" {
+		r.errors.Add(1)
+	}
+	dir := filepath.Dir(result.Path)
+	r.currentDir.Store(&dir)
+}
+
+// Track passes results from in through to the returned channel, observing
+// each of them.
+func (r *Reporter) Track(in <-chan processor.FileResult) <-chan processor.FileResult {
+	out := make(chan processor.FileResult, cap(in))
+	go func() {
+		defer close(out)
+		for result := range in {
+			r.Observe(result)
+			out <- result
+		}
+	}()
+	return out
+}
+
+// Snapshot returns the current progress.
+func (r *Reporter) Snapshot() Snapshot {
+	s := Snapshot{
+		Elapsed:    time.Since(r.started),
+		Files:      r.files.Load(),
+		Bytes:      r.bytes.Load(),
+		Errors:     r.errors.Load(),
+		TotalFiles: r.totalFiles.Load(),
+		TotalBytes: r.totalBytes.Load(),
+		Percent:    -1,
+		ETA:        -1,
+	}
+	if r.Queued != nil {
+		s.Queued = r.Queued()
+	}
+	if dir := r.currentDir.Load(); dir != nil {
+		s.CurrentDir = *dir
+	}
+	if seconds := s.Elapsed.Seconds(); seconds > 0 {
+		s.FilesPerSecond = float64(s.Files) / seconds
+		s.BytesPerSecond = float64(s.Bytes) / seconds
+	}
+
+	// Prefer bytes for the estimate as large files take longer to hash
+	switch {
+	case s.TotalBytes > 0 && s.BytesPerSecond > 0:
+		s.Percent = percent(s.Bytes, s.TotalBytes)
+		s.ETA = remaining(s.Bytes, s.TotalBytes, s.BytesPerSecond)
+	case s.TotalFiles > 0:
+		s.Percent = percent(s.Files, s.TotalFiles)
+		if s.FilesPerSecond > 0 {
+			s.ETA = remaining(s.Files, s.TotalFiles, s.FilesPerSecond)
+		}
+	}
+
+	s.ElapsedSeconds = s.Elapsed.Seconds()
+	s.ETASeconds = -1
+	if s.ETA >= 0 {
+		s.ETASeconds = s.ETA.Seconds()
+	}
+	return s
+}
+
+// percent returns done as a percentage of total, at most 100.
+func percent(done, total int64) float64 {
+	return min(100, float64(done)*100/float64(total))
+}
+
+// remaining returns the time to finish total at rate, given done so far.
+func remaining(done, total int64, rate float64) time.Duration {
+	if done >= total {
+		return 0
+	}
+	return time.Duration(float64(total-done) / rate * float64(time.Second)).Round(time.Second)
+}
+
+// Start begins reporting in the background until Stop is called.
+func (r *Reporter) Start() {
+	r.started = time.Now()
+	ctx, cancel := context.WithCancel(context.Background())
+	r.stop = cancel
+
+	interval := r.Interval
+	if interval <= 0 {
+		interval = DefaultInterval
+	}
+	r.wg.Add(1)
+	go func() {
+		defer r.wg.Done()
+		periodic := time.NewTicker(interval)
+		defer periodic.Stop()
+		var redraw <-chan time.Time
+		if r.Terminal != nil {
+			ticker := time.NewTicker(terminalInterval)
+			defer ticker.Stop()
+			redraw = ticker.C
+		}
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-redraw:
+				r.drawTerminal(r.Snapshot())
+			case <-periodic.C:
+				s := r.Snapshot()
+				if r.LogLines {
+					r.logger.Info("%s", Format(s))
+				}
+				r.writeJSON(s)
+			}
+		}
+	}()
+}
+
+// Stop stops reporting, finishes the status line and writes the final JSON record.
+func (r *Reporter) Stop() {
+	if r.stop == nil {
+		return
+	}
+	r.stop()
+	r.wg.Wait()
+	r.stop = nil
+
+	s := r.Snapshot()
+	s.Done = true
+	if r.Terminal != nil {
+		r.drawTerminal(s)
+		r.mu.Lock()
+		fmt.Fprintln(r.Terminal)
+		r.mu.Unlock()
+	}
+	r.writeJSON(s)
+}
+
+// drawTerminal redraws the status line.
+func (r *Reporter) drawTerminal(s Snapshot) {
+	// Keep the line short enough not to wrap, which would break redrawing
+	if len(s.CurrentDir) > maxTerminalDir {
+		s.CurrentDir = "..." + s.CurrentDir[len(s.CurrentDir)-maxTerminalDir+3:]
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	// Return to the start of the line and clear it before drawing
+	fmt.Fprintf(r.Terminal, "\r\033[K%s", Format(s))
+}
+
+// writeJSON writes s as a JSON record if a JSON output is set.
+func (r *Reporter) writeJSON(s Snapshot) {
+	if r.JSON == nil {
+		return
+	}
+	data, err := json.Marshal(s)
+	if err != nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, err := r.JSON.Write(append(data, '\n')); err != nil {
+		r.logger.Warn("Failed to write progress record: %v", err)
+	}
+}
+
+// Format renders s as a one-line summary.
+func Format(s Snapshot) string {
+	line := fmt.Sprintf("%d files, %s (%.1f files/s, %s/s), %d queued, %d errors",
+		s.Files, FormatBytes(float64(s.Bytes)), s.FilesPerSecond, FormatBytes(s.BytesPerSecond), s.Queued, s.Errors)
+	if s.Percent >= 0 {
+		line = fmt.Sprintf("%5.1f%% %s", s.Percent, line)
+	}
+	if s.ETA >= 0 && !s.Done {
+		line += ", ETA " + s.ETA.String()
+	}
+	if s.CurrentDir != "" && !s.Done {
+		line += ", in " + s.CurrentDir
+	}
+	return line
+}
+
+// FormatBytes renders a byte count with a binary unit, e.g. 1.5MB.
+func FormatBytes(n float64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%.0fB", n)
+	}
+	div, exp := float64(unit), 0
+	for n/div >= unit && exp < 4 {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f%cB", n/div, "KMGTP"[exp])
+}
+
+// IsTerminal reports whether f is a terminal.
+func IsTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
@@ -0,0 +1,89 @@
+package progress
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/vtriple/agentflux/pkg/processor"
+)
+
+func TestSnapshotEstimate(t *testing.T) {
+	r := NewReporter()
+	r.started = time.Now().Add(-10 * time.Second)
+	r.Queued = func() int { return 7 }
+
+	s := r.Snapshot()
+	if s.Percent != -1 || s.ETA != -1 {
+		t.Errorf("without estimate: percent=%v eta=%v, want -1", s.Percent, s.ETA)
+	}
+
+	r.SetEstimate(100, 1000)
+	r.Observe(processor.FileResult{Path: "/data/a/one", Size: 250})
+	r.Observe(processor.FileResult{Path: "/data/b/two", Size: 0, Error: "open error"})
+
+	s = r.Snapshot()
+	if s.Files != 2 || s.Bytes != 250 || s.Errors != 1 || s.Queued != 7 {
+		t.Errorf("counters = %d files, %d bytes, %d errors, %d queued", s.Files, s.Bytes, s.Errors, s.Queued)
+	}
+	if s.CurrentDir != "/data/b" {
+		t.Errorf("CurrentDir = %q, want /data/b", s.CurrentDir)
+	}
+	if s.Percent != 25 {
+		t.Errorf("Percent = %v, want 25 from bytes", s.Percent)
+	}
+	// 250 bytes in 10s leaves 750 bytes, about 30s
+	if s.ETA < 29*time.Second || s.ETA > 31*time.Second {
+		t.Errorf("ETA = %v, want about 30s", s.ETA)
+	}
+}
+
+func TestTrackAndJSON(t *testing.T) {
+	var out bytes.Buffer
+	r := NewReporter()
+	r.JSON = &out
+	r.Interval = time.Hour
+	r.Start()
+
+	in := make(chan processor.FileResult, 2)
+	in <- processor.FileResult{Path: "/tmp/a", Size: 10}
+	in <- processor.FileResult{Path: "/tmp/b", Size: 20}
+	close(in)
+	count := 0
+	for range r.Track(in) {
+		count++
+	}
+	r.Stop()
+
+	if count != 2 {
+		t.Errorf("Track passed %d results, want 2", count)
+	}
+	var final Snapshot
+	if err := json.Unmarshal(bytes.TrimSpace(out.Bytes()), &final); err != nil {
+		t.Fatalf("final record is not JSON: %v\n%s", err, out.String())
+	}
+	if !final.Done || final.Files != 2 || final.Bytes != 30 {
+		t.Errorf("final record = %+v", final)
+	}
+}
+
+func TestFormat(t *testing.T) {
+	line := Format(Snapshot{Files: 3, Bytes: 3 << 20, FilesPerSecond: 1.5, BytesPerSecond: 1 << 20,
+		Percent: 50, ETA: 3 * time.Second, CurrentDir: "/data"})
+	for _, want := range []string{" 50.0% 3 files", "3.0MB", "1.0MB/s", "ETA 3s", "in /data"} {
+		if !strings.Contains(line, want) {
+			t.Errorf("Format() = %q, missing %q", line, want)
+		}
+	}
+}
+
+func TestFormatBytes(t *testing.T) {
+	tests := map[float64]string{0: "0B", 1023: "1023B", 1536: "1.5KB", 5 << 30: "5.0GB"}
+	for n, want := range tests {
+		if got := FormatBytes(n); got != want {
+			t.Errorf("FormatBytes(%v) = %q, want %q", n, got, want)
+		}
+	}
+}
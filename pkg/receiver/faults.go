@@ -0,0 +1,122 @@
+package receiver
+
+import (
+	"math/rand"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Faults configures the failures injected into ingestion requests.
+// Rates are probabilities between 0 and 1 evaluated independently per request.
+type Faults struct {
+	// LatencyMS delays every request by this many milliseconds.
+	LatencyMS int `json:"latencyMs"`
+	// ErrorRate is the probability of answering 500 Internal Server Error.
+	ErrorRate float64 `json:"errorRate"`
+	// RateLimitRate is the probability of answering 429 Too Many Requests.
+	RateLimitRate float64 `json:"rateLimitRate"`
+	// DropRate is the probability of closing the connection without a response.
+	DropRate float64 `json:"dropRate"`
+	// FailNext fails the next N requests with FailStatus before applying the rates.
+	FailNext int `json:"failNext"`
+	// FailStatus is the status code used by FailNext (default 500).
+	FailStatus int `json:"failStatus"`
+	// RetryAfterSeconds is sent in the Retry-After header of 429 responses.
+	RetryAfterSeconds int `json:"retryAfterSeconds"`
+}
+
+// faultInjector applies Faults to requests.
+type faultInjector struct {
+	mu     sync.Mutex
+	faults Faults
+	rng    *rand.Rand
+}
+
+// newFaultInjector creates an injector with the given initial settings.
+func newFaultInjector(faults Faults) *faultInjector {
+	return &faultInjector{
+		faults: faults,
+		rng:    rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+}
+
+// get returns the current settings.
+func (f *faultInjector) get() Faults {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.faults
+}
+
+// set replaces the current settings.
+func (f *faultInjector) set(faults Faults) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.faults = faults
+}
+
+// apply injects a fault into the request if one is due and reports whether
+// the request has been fully handled.
+func (f *faultInjector) apply(w http.ResponseWriter, r *http.Request) bool {
+	f.mu.Lock()
+	faults := f.faults
+	failNow := faults.FailNext > 0
+	if failNow {
+		f.faults.FailNext--
+	}
+	drop := f.rng.Float64() < faults.DropRate
+	serverError := f.rng.Float64() < faults.ErrorRate
+	rateLimit := f.rng.Float64() < faults.RateLimitRate
+	f.mu.Unlock()
+
+	if faults.LatencyMS > 0 {
+		select {
+		case <-time.After(time.Duration(faults.LatencyMS) * time.Millisecond):
+		case <-r.Context().Done():
+			return true
+		}
+	}
+
+	switch {
+	case failNow:
+		status := faults.FailStatus
+		if status == 0 {
+			status = http.StatusInternalServerError
+		}
+		if status == http.StatusTooManyRequests {
+			setRetryAfter(w, faults.RetryAfterSeconds)
+		}
+		http.Error(w, "injected failure", status)
+		return true
+
+	case drop:
+		if hijacker, ok := w.(http.Hijacker); ok {
+			if conn, _, err := hijacker.Hijack(); err == nil {
+				conn.Close()
+				return true
+			}
+		}
+		// Connection cannot be hijacked (e.g. HTTP/2); fail the request instead
+		http.Error(w, "injected failure", http.StatusInternalServerError)
+		return true
+
+	case serverError:
+		http.Error(w, "injected failure", http.StatusInternalServerError)
+		return true
+
+	case rateLimit:
+		setRetryAfter(w, faults.RetryAfterSeconds)
+		http.Error(w, "injected rate limit", http.StatusTooManyRequests)
+		return true
+	}
+
+	return false
+}
+
+// setRetryAfter sets the Retry-After header if seconds is positive.
+func setRetryAfter(w http.ResponseWriter, seconds int) {
+	if seconds > 0 {
+		w.Header().Set("Retry-After", strconv.Itoa(seconds))
+	}
+}
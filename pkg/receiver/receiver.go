@@ -0,0 +1,507 @@
+// Package receiver implements a reference ingestion server for the AgentFlux API.
+// It accepts result batches and scan events from APIClient, validates every
+// authentication method the client supports, stores what it receives as JSONL
+// files, and can inject faults to exercise client retries.
+package receiver
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/vtriple/agentflux/pkg/api"
+	"github.com/vtriple/agentflux/pkg/common/logging"
+	"github.com/vtriple/agentflux/pkg/processor"
+)
+
+const (
+	// ResultsPath is the path that accepts result batches.
+	ResultsPath = "/results"
+	// EventsPath is the path that accepts scan lifecycle events.
+	EventsPath = "/events"
+	// TokenPath is the OAuth2 client-credentials token endpoint.
+	TokenPath = "/oauth/token"
+	// FaultsPath reads (GET) and replaces (PUT/POST) the active fault settings.
+	FaultsPath = "/_faults"
+	// HealthPath reports that the receiver is running.
+	HealthPath = "/healthz"
+
+	// DefaultTokenTTL is the lifetime of issued OAuth2 tokens.
+	DefaultTokenTTL = time.Hour
+	// maxBodySize limits the size of accepted request bodies.
+	maxBodySize = 64 * 1024 * 1024
+)
+
+// Config contains the settings of a receiver Server.
+type Config struct {
+	// DataDir is the directory where received data is stored.
+	DataDir string
+	// AuthMethod is the authentication method required from clients.
+	// An empty value disables authentication.
+	AuthMethod api.AuthType
+	// Token is the expected bearer token or API key.
+	Token string
+	// Basic holds the expected basic auth credentials.
+	Basic api.BasicAuth
+	// HMACSecrets maps HMAC key IDs to shared secrets.
+	HMACSecrets map[string]string
+	// ClientID and ClientSecret are the OAuth2 client credentials accepted by TokenPath.
+	ClientID     string
+	ClientSecret string
+	// TokenTTL is the lifetime of issued OAuth2 tokens.
+	TokenTTL time.Duration
+	// Faults is the initial fault injection configuration.
+	Faults Faults
+}
+
+// Stats contains counters of what a Server has received.
+type Stats struct {
+	Batches    int `json:"batches"`
+	Duplicates int `json:"duplicates"`
+	Results    int `json:"results"`
+	Events     int `json:"events"`
+	Rejected   int `json:"rejected"`
+	Faults     int `json:"faults"`
+}
+
+// Server is a reference implementation of the AgentFlux ingestion API.
+type Server struct {
+	config   Config
+	verifier *api.HMACVerifier
+	faults   *faultInjector
+	logger   *logging.Logger
+
+	mu        sync.Mutex
+	seenKeys  map[string]bool
+	tokens    map[string]time.Time
+	stats     Stats
+	resultsW  io.WriteCloser
+	batchesW  io.WriteCloser
+	eventsW   io.WriteCloser
+	closeOnce sync.Once
+}
+
+// batchRecord is the metadata stored for each accepted batch.
+type batchRecord struct {
+	ReceivedAt     time.Time         `json:"receivedAt"`
+	RemoteAddr     string            `json:"remoteAddr"`
+	SchemaVersion  int               `json:"schemaVersion,omitempty"`
+	Agent          *api.AgentInfo    `json:"agent,omitempty"`
+	Hostname       string            `json:"hostname,omitempty"`
+	ScanID         string            `json:"scanId,omitempty"`
+	Sequence       int64             `json:"sequence,omitempty"`
+	IdempotencyKey string            `json:"idempotencyKey,omitempty"`
+	Labels         map[string]string `json:"labels,omitempty"`
+	Results        int               `json:"results"`
+}
+
+// resultRecord is a stored file result together with its scan ID.
+type resultRecord struct {
+	ScanID string `json:"scanId,omitempty"`
+	processor.FileResult
+}
+
+// NewServer creates a Server that stores data under config.DataDir.
+func NewServer(config Config) (*Server, error) {
+	if config.DataDir == "" {
+		return nil, fmt.Errorf("data directory is required")
+	}
+	if config.TokenTTL <= 0 {
+		config.TokenTTL = DefaultTokenTTL
+	}
+
+	switch config.AuthMethod {
+	case "", api.AuthBearer, api.AuthAPIKey:
+		if config.AuthMethod != "" && config.Token == "" {
+			return nil, fmt.Errorf("%s auth requires a token", config.AuthMethod)
+		}
+	case api.AuthBasic:
+		if config.Basic.Username == "" {
+			return nil, fmt.Errorf("basic auth requires a username")
+		}
+	case api.AuthHMAC:
+		if len(config.HMACSecrets) == 0 {
+			return nil, fmt.Errorf("hmac auth requires at least one key")
+		}
+	case api.AuthOAuth2:
+		if config.ClientID == "" {
+			return nil, fmt.Errorf("oauth2 auth requires a client ID")
+		}
+	default:
+		return nil, fmt.Errorf("unsupported authentication method: %s", config.AuthMethod)
+	}
+
+	if err := os.MkdirAll(config.DataDir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create data directory: %w", err)
+	}
+
+	s := &Server{
+		config:   config,
+		faults:   newFaultInjector(config.Faults),
+		logger:   logging.NewLogger("receiver"),
+		seenKeys: make(map[string]bool),
+		tokens:   make(map[string]time.Time),
+	}
+
+	if config.AuthMethod == api.AuthHMAC {
+		s.verifier = api.NewHMACVerifier(func(keyID string) (string, bool) {
+			secret, ok := config.HMACSecrets[keyID]
+			return secret, ok
+		}, api.DefaultHMACMaxSkew)
+	}
+
+	var err error
+	if s.resultsW, err = openAppend(filepath.Join(config.DataDir, "results.jsonl")); err != nil {
+		return nil, err
+	}
+	if s.batchesW, err = openAppend(filepath.Join(config.DataDir, "batches.jsonl")); err != nil {
+		s.resultsW.Close()
+		return nil, err
+	}
+	if s.eventsW, err = openAppend(filepath.Join(config.DataDir, "events.jsonl")); err != nil {
+		s.resultsW.Close()
+		s.batchesW.Close()
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// openAppend opens a file for appending, creating it if needed.
+func openAppend(path string) (io.WriteCloser, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s: %w", path, err)
+	}
+	return file, nil
+}
+
+// Handler returns the HTTP handler implementing the ingestion API.
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc(ResultsPath, s.withFaults(s.withAuth(s.handleResults)))
+	mux.HandleFunc(EventsPath, s.withFaults(s.withAuth(s.handleEvents)))
+	mux.HandleFunc(TokenPath, s.withFaults(s.handleToken))
+	mux.HandleFunc(FaultsPath, s.handleFaults)
+	mux.HandleFunc(HealthPath, s.handleHealth)
+	return mux
+}
+
+// Stats returns a snapshot of the server counters.
+func (s *Server) Stats() Stats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.stats
+}
+
+// SetFaults replaces the active fault injection settings.
+func (s *Server) SetFaults(faults Faults) {
+	s.faults.set(faults)
+}
+
+// Close closes the data files.
+func (s *Server) Close() error {
+	var firstErr error
+	s.closeOnce.Do(func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		for _, w := range []io.WriteCloser{s.resultsW, s.batchesW, s.eventsW} {
+			if err := w.Close(); err != nil && firstErr == nil {
+				firstErr = err
+			}
+		}
+	})
+	return firstErr
+}
+
+// SetLogger sets a custom logger for the server.
+func (s *Server) SetLogger(logger *logging.Logger) {
+	s.logger = logger
+}
+
+// withFaults applies fault injection before calling next.
+func (s *Server) withFaults(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if s.faults.apply(w, r) {
+			s.mu.Lock()
+			s.stats.Faults++
+			s.mu.Unlock()
+			return
+		}
+		next(w, r)
+	}
+}
+
+// withAuth rejects requests that fail the configured authentication.
+func (s *Server) withAuth(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		r.Body = http.MaxBytesReader(w, r.Body, maxBodySize)
+
+		if err := s.authenticate(r); err != nil {
+			s.logger.Warn("Rejected request from %s: %v", r.RemoteAddr, err)
+			s.mu.Lock()
+			s.stats.Rejected++
+			s.mu.Unlock()
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		next(w, r)
+	}
+}
+
+// authenticate validates the request against the configured auth method.
+func (s *Server) authenticate(r *http.Request) error {
+	switch s.config.AuthMethod {
+	case "":
+		return nil
+
+	case api.AuthBearer:
+		return compareSecret(strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer "), s.config.Token)
+
+	case api.AuthAPIKey:
+		return compareSecret(r.Header.Get("X-API-Key"), s.config.Token)
+
+	case api.AuthBasic:
+		username, password, ok := r.BasicAuth()
+		if !ok {
+			return fmt.Errorf("missing basic auth")
+		}
+		if err := compareSecret(username, s.config.Basic.Username); err != nil {
+			return err
+		}
+		return compareSecret(password, s.config.Basic.Password)
+
+	case api.AuthHMAC:
+		_, err := s.verifier.Verify(r)
+		return err
+
+	case api.AuthOAuth2:
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok {
+			return fmt.Errorf("missing bearer token")
+		}
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		expiry, ok := s.tokens[token]
+		if !ok || time.Now().After(expiry) {
+			return fmt.Errorf("invalid or expired token")
+		}
+		return nil
+	}
+
+	return fmt.Errorf("unsupported authentication method: %s", s.config.AuthMethod)
+}
+
+// compareSecret compares two secrets in constant time.
+func compareSecret(got, want string) error {
+	if got == "" || subtle.ConstantTimeCompare([]byte(got), []byte(want)) != 1 {
+		return fmt.Errorf("invalid credentials")
+	}
+	return nil
+}
+
+// handleResults accepts an envelope or a bare array of results.
+func (s *Server) handleResults(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, "error reading body", http.StatusBadRequest)
+		return
+	}
+
+	envelope, err := decodeBatch(body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	key := r.Header.Get(api.HeaderIdempotencyKey)
+	if key == "" {
+		key = envelope.IdempotencyKey
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if key != "" && s.seenKeys[key] {
+		s.stats.Duplicates++
+		s.logger.Info("Ignoring duplicate batch %s", key)
+		writeJSON(w, http.StatusOK, map[string]string{"status": "duplicate"})
+		return
+	}
+
+	record := batchRecord{
+		ReceivedAt:     time.Now().UTC(),
+		RemoteAddr:     r.RemoteAddr,
+		SchemaVersion:  envelope.SchemaVersion,
+		Hostname:       envelope.Host.Hostname,
+		ScanID:         envelope.ScanID,
+		Sequence:       envelope.Sequence,
+		IdempotencyKey: key,
+		Labels:         envelope.Labels,
+		Results:        len(envelope.Results),
+	}
+	if envelope.Agent.Name != "" || envelope.Agent.Version != "" {
+		record.Agent = &envelope.Agent
+	}
+
+	var lines bytes.Buffer
+	encoder := json.NewEncoder(&lines)
+	for _, result := range envelope.Results {
+		if err := encoder.Encode(resultRecord{ScanID: envelope.ScanID, FileResult: result}); err != nil {
+			http.Error(w, "error encoding result", http.StatusInternalServerError)
+			return
+		}
+	}
+	if _, err := s.resultsW.Write(lines.Bytes()); err != nil {
+		s.logger.Error("Failed to store results: %v", err)
+		http.Error(w, "storage error", http.StatusInternalServerError)
+		return
+	}
+	if err := json.NewEncoder(s.batchesW).Encode(record); err != nil {
+		s.logger.Error("Failed to store batch: %v", err)
+		http.Error(w, "storage error", http.StatusInternalServerError)
+		return
+	}
+
+	if key != "" {
+		s.seenKeys[key] = true
+	}
+	s.stats.Batches++
+	s.stats.Results += len(envelope.Results)
+	s.logger.Debug("Stored batch of %d results from %s", len(envelope.Results), r.RemoteAddr)
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{"status": "ok", "accepted": len(envelope.Results)})
+}
+
+// decodeBatch decodes either a versioned envelope or a bare JSON array of results.
+func decodeBatch(body []byte) (api.Envelope, error) {
+	var envelope api.Envelope
+	trimmed := bytes.TrimSpace(body)
+	if len(trimmed) > 0 && trimmed[0] == '[' {
+		if err := json.Unmarshal(trimmed, &envelope.Results); err != nil {
+			return envelope, fmt.Errorf("invalid result array: %w", err)
+		}
+		return envelope, nil
+	}
+
+	if err := json.Unmarshal(trimmed, &envelope); err != nil {
+		return envelope, fmt.Errorf("invalid envelope: %w", err)
+	}
+	if envelope.SchemaVersion > api.EnvelopeSchemaVersion {
+		return envelope, fmt.Errorf("unsupported schema version: %d", envelope.SchemaVersion)
+	}
+	return envelope, nil
+}
+
+// handleEvents stores scan lifecycle events.
+func (s *Server) handleEvents(w http.ResponseWriter, r *http.Request) {
+	var event api.Event
+	if err := json.NewDecoder(r.Body).Decode(&event); err != nil {
+		http.Error(w, "invalid event", http.StatusBadRequest)
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := json.NewEncoder(s.eventsW).Encode(event); err != nil {
+		s.logger.Error("Failed to store event: %v", err)
+		http.Error(w, "storage error", http.StatusInternalServerError)
+		return
+	}
+	s.stats.Events++
+	s.logger.Info("Received %s event for scan %s", event.Type, event.ScanID)
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// handleToken issues OAuth2 tokens for the client-credentials grant.
+func (s *Server) handleToken(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if s.config.AuthMethod != api.AuthOAuth2 {
+		http.NotFound(w, r)
+		return
+	}
+	if err := r.ParseForm(); err != nil || r.Form.Get("grant_type") != "client_credentials" {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "unsupported_grant_type"})
+		return
+	}
+
+	clientID, clientSecret, ok := r.BasicAuth()
+	if !ok {
+		clientID, clientSecret = r.Form.Get("client_id"), r.Form.Get("client_secret")
+	}
+	if compareSecret(clientID, s.config.ClientID) != nil || compareSecret(clientSecret, s.config.ClientSecret) != nil {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "invalid_client"})
+		return
+	}
+
+	raw := make([]byte, 24)
+	if _, err := rand.Read(raw); err != nil {
+		http.Error(w, "error generating token", http.StatusInternalServerError)
+		return
+	}
+	token := hex.EncodeToString(raw)
+
+	s.mu.Lock()
+	now := time.Now()
+	for t, expiry := range s.tokens {
+		if now.After(expiry) {
+			delete(s.tokens, t)
+		}
+	}
+	s.tokens[token] = now.Add(s.config.TokenTTL)
+	s.mu.Unlock()
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"access_token": token,
+		"token_type":   "Bearer",
+		"expires_in":   int64(s.config.TokenTTL / time.Second),
+	})
+}
+
+// handleFaults reports or replaces the fault injection settings.
+func (s *Server) handleFaults(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		writeJSON(w, http.StatusOK, s.faults.get())
+	case http.MethodPut, http.MethodPost:
+		var faults Faults
+		if err := json.NewDecoder(r.Body).Decode(&faults); err != nil {
+			http.Error(w, "invalid fault settings", http.StatusBadRequest)
+			return
+		}
+		s.faults.set(faults)
+		s.logger.Info("Fault settings updated: %+v", faults)
+		writeJSON(w, http.StatusOK, faults)
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// handleHealth reports the server counters.
+func (s *Server) handleHealth(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, s.Stats())
+}
+
+// writeJSON writes v as a JSON response with the given status code.
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
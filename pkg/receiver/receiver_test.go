@@ -0,0 +1,272 @@
+package receiver
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/vtriple/agentflux/pkg/api"
+	"github.com/vtriple/agentflux/pkg/processor"
+)
+
+// newTestServer starts a receiver with the given config on an httptest server.
+func newTestServer(t *testing.T, config Config) (*Server, *httptest.Server) {
+	t.Helper()
+	if config.DataDir == "" {
+		config.DataDir = t.TempDir()
+	}
+	server, err := NewServer(config)
+	if err != nil {
+		t.Fatalf("Failed to create server: %v", err)
+	}
+	httpServer := httptest.NewServer(server.Handler())
+	t.Cleanup(func() {
+		httpServer.Close()
+		server.Close()
+	})
+	return server, httpServer
+}
+
+// sendResults sends results through an APIClient and returns the errors reported.
+func sendResults(client *api.APIClient, results []processor.FileResult) []error {
+	input := make(chan processor.FileResult, len(results))
+	for _, result := range results {
+		input <- result
+	}
+	close(input)
+
+	var errs []error
+	for err := range client.SendResults(context.Background(), input) {
+		errs = append(errs, err)
+	}
+	client.Wait()
+	return errs
+}
+
+func testResults(n int) []processor.FileResult {
+	results := make([]processor.FileResult, n)
+	for i := range results {
+		results[i] = processor.FileResult{Path: filepath.Join("/data", string(rune('a'+i))), Hash: strings.Repeat("a", 64)}
+	}
+	return results
+}
+
+// countLines returns the number of lines in a data file.
+func countLines(t *testing.T, path string) int {
+	t.Helper()
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("Failed to open %s: %v", path, err)
+	}
+	defer file.Close()
+
+	count := 0
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		count++
+	}
+	return count
+}
+
+func TestReceiverAuthMethods(t *testing.T) {
+	tests := []struct {
+		name        string
+		config      Config
+		authMethod  api.AuthType
+		credentials func(url string) interface{}
+	}{
+		{
+			name:        "Bearer",
+			config:      Config{AuthMethod: api.AuthBearer, Token: "secret"},
+			authMethod:  api.AuthBearer,
+			credentials: func(string) interface{} { return "secret" },
+		},
+		{
+			name:        "API key",
+			config:      Config{AuthMethod: api.AuthAPIKey, Token: "secret"},
+			authMethod:  api.AuthAPIKey,
+			credentials: func(string) interface{} { return "secret" },
+		},
+		{
+			name:        "Basic",
+			config:      Config{AuthMethod: api.AuthBasic, Basic: api.BasicAuth{Username: "agent", Password: "pw"}},
+			authMethod:  api.AuthBasic,
+			credentials: func(string) interface{} { return api.BasicAuth{Username: "agent", Password: "pw"} },
+		},
+		{
+			name:       "HMAC",
+			config:     Config{AuthMethod: api.AuthHMAC, HMACSecrets: map[string]string{"agent-1": "shared"}},
+			authMethod: api.AuthHMAC,
+			credentials: func(string) interface{} {
+				return api.HMACCredentials{KeyID: "agent-1", Secret: "shared"}
+			},
+		},
+		{
+			name:       "OAuth2",
+			config:     Config{AuthMethod: api.AuthOAuth2, ClientID: "agent", ClientSecret: "pw"},
+			authMethod: api.AuthOAuth2,
+			credentials: func(url string) interface{} {
+				return api.OAuth2Config{TokenURL: url + TokenPath, ClientID: "agent", ClientSecret: "pw"}
+			},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			server, httpServer := newTestServer(t, tc.config)
+
+			client := api.NewAPIClient(httpServer.URL+ResultsPath, tc.authMethod, tc.credentials(httpServer.URL))
+			client.BatchSize = 2
+			if errs := sendResults(client, testResults(3)); len(errs) != 0 {
+				t.Fatalf("Unexpected errors: %v", errs)
+			}
+
+			stats := server.Stats()
+			if stats.Batches != 2 || stats.Results != 3 || stats.Rejected != 0 {
+				t.Errorf("Unexpected stats: %+v", stats)
+			}
+
+			// Wrong credentials must be rejected
+			bad := api.NewAPIClient(httpServer.URL+ResultsPath, api.AuthBearer, "wrong")
+			bad.MaxRetries = 0
+			if errs := sendResults(bad, testResults(1)); len(errs) == 0 {
+				t.Error("Expected request with wrong credentials to fail")
+			}
+			if server.Stats().Rejected == 0 {
+				t.Error("Expected rejected counter to increase")
+			}
+		})
+	}
+}
+
+func TestReceiverStoresEnvelopes(t *testing.T) {
+	dataDir := t.TempDir()
+	server, httpServer := newTestServer(t, Config{DataDir: dataDir})
+
+	client := api.NewAPIClient(httpServer.URL+ResultsPath, api.AuthBearer, "unused")
+	client.EventsEndpoint = httpServer.URL + EventsPath
+	client.Metadata = &api.BatchMetadata{
+		Agent:  api.AgentInfo{Name: "agentflux", Version: "1.0.0"},
+		ScanID: "scan-1",
+		Labels: map[string]string{"env": "test"},
+	}
+
+	if err := client.SendEvent(context.Background(), api.EventScanStarted, nil); err != nil {
+		t.Fatalf("Unexpected event error: %v", err)
+	}
+	if errs := sendResults(client, testResults(5)); len(errs) != 0 {
+		t.Fatalf("Unexpected errors: %v", errs)
+	}
+
+	if stats := server.Stats(); stats.Events != 1 || stats.Results != 5 {
+		t.Errorf("Unexpected stats: %+v", stats)
+	}
+	if n := countLines(t, filepath.Join(dataDir, "results.jsonl")); n != 5 {
+		t.Errorf("Expected 5 stored results, got %d", n)
+	}
+	if n := countLines(t, filepath.Join(dataDir, "events.jsonl")); n != 1 {
+		t.Errorf("Expected 1 stored event, got %d", n)
+	}
+
+	data, _ := os.ReadFile(filepath.Join(dataDir, "batches.jsonl"))
+	var record batchRecord
+	if err := json.Unmarshal(bytes.Split(data, []byte("\n"))[0], &record); err != nil {
+		t.Fatalf("Failed to decode batch record: %v", err)
+	}
+	if record.ScanID != "scan-1" || record.Sequence != 1 || record.Labels["env"] != "test" || record.Results != 5 {
+		t.Errorf("Unexpected batch record: %+v", record)
+	}
+}
+
+func TestReceiverDeduplicatesRetriedBatches(t *testing.T) {
+	server, httpServer := newTestServer(t, Config{})
+
+	body := []byte(`{"schemaVersion":1,"scanId":"scan-1","sequence":1,"idempotencyKey":"scan-1-1","results":[{"path":"/a"}]}`)
+	for i := 0; i < 2; i++ {
+		resp, err := http.Post(httpServer.URL+ResultsPath, "application/json", bytes.NewReader(body))
+		if err != nil {
+			t.Fatalf("Request failed: %v", err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			t.Errorf("Expected 200, got %d", resp.StatusCode)
+		}
+	}
+
+	if stats := server.Stats(); stats.Batches != 1 || stats.Duplicates != 1 || stats.Results != 1 {
+		t.Errorf("Expected one stored batch and one duplicate, got %+v", stats)
+	}
+}
+
+func TestReceiverRejectsInvalidBatches(t *testing.T) {
+	_, httpServer := newTestServer(t, Config{})
+
+	for _, body := range []string{`not json`, `{"schemaVersion":99}`, `[{"path":1}]`} {
+		resp, err := http.Post(httpServer.URL+ResultsPath, "application/json", strings.NewReader(body))
+		if err != nil {
+			t.Fatalf("Request failed: %v", err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("Body %q: expected 400, got %d", body, resp.StatusCode)
+		}
+	}
+}
+
+func TestReceiverFaultInjection(t *testing.T) {
+	server, httpServer := newTestServer(t, Config{Faults: Faults{FailNext: 2, FailStatus: http.StatusServiceUnavailable}})
+
+	// The client retries past the two injected failures
+	client := api.NewAPIClient(httpServer.URL+ResultsPath, api.AuthBearer, "unused")
+	if errs := sendResults(client, testResults(1)); len(errs) != 0 {
+		t.Fatalf("Unexpected errors: %v", errs)
+	}
+	if stats := server.Stats(); stats.Faults != 2 || stats.Batches != 1 {
+		t.Errorf("Expected 2 faults and 1 batch, got %+v", stats)
+	}
+
+	// Faults can be changed at runtime through the control endpoint
+	resp, err := http.Post(httpServer.URL+FaultsPath, "application/json", strings.NewReader(`{"rateLimitRate":1,"retryAfterSeconds":3}`))
+	if err != nil {
+		t.Fatalf("Failed to set faults: %v", err)
+	}
+	resp.Body.Close()
+
+	resp, err = http.Post(httpServer.URL+ResultsPath, "application/json", strings.NewReader(`[]`))
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusTooManyRequests || resp.Header.Get("Retry-After") != "3" {
+		t.Errorf("Expected 429 with Retry-After 3, got %d %q", resp.StatusCode, resp.Header.Get("Retry-After"))
+	}
+
+	// Dropped connections surface as transport errors
+	server.SetFaults(Faults{DropRate: 1})
+	if _, err := http.Post(httpServer.URL+ResultsPath, "application/json", strings.NewReader(`[]`)); err == nil {
+		t.Error("Expected dropped connection error")
+	}
+}
+
+func TestNewServerValidation(t *testing.T) {
+	configs := []Config{
+		{},
+		{DataDir: t.TempDir(), AuthMethod: api.AuthBearer},
+		{DataDir: t.TempDir(), AuthMethod: api.AuthBasic},
+		{DataDir: t.TempDir(), AuthMethod: api.AuthHMAC},
+		{DataDir: t.TempDir(), AuthMethod: api.AuthOAuth2},
+		{DataDir: t.TempDir(), AuthMethod: "kerberos"},
+	}
+	for _, config := range configs {
+		if _, err := NewServer(config); err == nil {
+			t.Errorf("Expected error for config %+v", config)
+		}
+	}
+}
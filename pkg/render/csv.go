@@ -0,0 +1,145 @@
+package render
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/vtriple/agentflux/pkg/processor"
+)
+
+// DefaultColumns are the CSV columns written when none are selected.
+var DefaultColumns = []string{"path", "size", "modTime", "owner", "hash", "hashAlgorithm", "mimeType", "error"}
+
+// columns maps the CSV column names, which follow the JSON field names of
+// a result, to the value of the column.
+var columns = map[string]func(r processor.FileResult) string{
+	"path":           func(r processor.FileResult) string { return r.Path },
+	"name":           func(r processor.FileResult) string { return r.Name },
+	"dir":            func(r processor.FileResult) string { return filepath.Dir(r.Path) },
+	"ext":            func(r processor.FileResult) string { return extension(r.Path) },
+	"size":           func(r processor.FileResult) string { return strconv.FormatInt(r.Size, 10) },
+	"modTime":        func(r processor.FileResult) string { return formatTime(r.ModTime) },
+	"owner":          func(r processor.FileResult) string { return r.Owner },
+	"hash":           func(r processor.FileResult) string { return r.Hash },
+	"hashAlgorithm":  func(r processor.FileResult) string { return r.HashAlgorithm },
+	"mimeType":       func(r processor.FileResult) string { return r.MimeType },
+	"isExecutable":   func(r processor.FileResult) string { return strconv.FormatBool(r.IsExecutable) },
+	"processedAt":    func(r processor.FileResult) string { return formatTime(r.ProcessedAt) },
+	"error":          func(r processor.FileResult) string { return r.Error },
+	"errorCode":      func(r processor.FileResult) string { return string(r.ErrorCode) },
+	"duplicates":     func(r processor.FileResult) string { return strconv.Itoa(len(r.AlsoSeenAt)) },
+	"sighting":       func(r processor.FileResult) string { return strconv.FormatBool(r.Sighting) },
+	"strings":        func(r processor.FileResult) string { return strings.Join(r.Strings, " ") },
+	"analysis":       func(r processor.FileResult) string { return jsonCell(r.Analysis) },
+	"analysisErrors": func(r processor.FileResult) string { return jsonCell(r.AnalysisErrors) },
+}
+
+// ColumnNames returns the names of all CSV columns in a stable order.
+func ColumnNames() []string {
+	return []string{"path", "name", "dir", "ext", "size", "modTime", "owner", "hash", "hashAlgorithm",
+		"mimeType", "isExecutable", "processedAt", "error", "errorCode", "duplicates", "sighting", "strings",
+		"analysis", "analysisErrors"}
+}
+
+// ParseColumns parses a comma-separated list of column names. An empty list
+// selects DefaultColumns.
+func ParseColumns(s string) ([]string, error) {
+	if strings.TrimSpace(s) == "" {
+		return DefaultColumns, nil
+	}
+	var selected []string
+	for _, name := range strings.Split(s, ",") {
+		name = strings.TrimSpace(name)
+		if _, ok := columns[name]; !ok {
+			return nil, fmt.Errorf("unknown CSV column %q (expected one of %s)", name, strings.Join(ColumnNames(), ", "))
+		}
+		selected = append(selected, name)
+	}
+	return selected, nil
+}
+
+// CSVWriter writes results as CSV rows with a header line.
+type CSVWriter struct {
+	w       *csv.Writer
+	columns []string
+	header  bool
+}
+
+// NewCSVWriter creates a CSVWriter writing the given columns to w. It
+// panics on unknown columns, which ParseColumns rejects.
+func NewCSVWriter(w io.Writer, columnNames []string) *CSVWriter {
+	for _, name := range columnNames {
+		if _, ok := columns[name]; !ok {
+			panic(fmt.Sprintf("render: unknown CSV column %q", name))
+		}
+	}
+	return &CSVWriter{w: csv.NewWriter(w), columns: columnNames}
+}
+
+// Write writes the row for one result, preceded by the header on the first call.
+func (c *CSVWriter) Write(result processor.FileResult) error {
+	if !c.header {
+		if err := c.w.Write(c.columns); err != nil {
+			return err
+		}
+		c.header = true
+	}
+	row := make([]string, len(c.columns))
+	for i, name := range c.columns {
+		row[i] = sanitizeCell(columns[name](result))
+	}
+	return c.w.Write(row)
+}
+
+// Flush writes any buffered rows, and the header if no rows were written.
+func (c *CSVWriter) Flush() error {
+	if !c.header {
+		if err := c.w.Write(c.columns); err != nil {
+			return err
+		}
+		c.header = true
+	}
+	c.w.Flush()
+	return c.w.Error()
+}
+
+// sanitizeCell prefixes values that spreadsheets would evaluate as formulas,
+// as file names are attacker-controlled.
+func sanitizeCell(s string) string {
+	if s != "" && strings.ContainsRune("=+-@\t\r", rune(s[0])) {
+		return "'" + s
+	}
+	return s
+}
+
+// jsonCell encodes the analyzer results or errors of a result as JSON, or ""
+// if there are none. Keys are sorted, so rows of the same analyzers compare.
+func jsonCell(v any) string {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprintf("invalid analysis: %v", err)
+	}
+	if s := string(data); s != "null" && s != "{}" {
+		return s
+	}
+	return ""
+}
+
+// extension returns the lowercase extension of path without the dot.
+func extension(path string) string {
+	return strings.ToLower(strings.TrimPrefix(filepath.Ext(path), "."))
+}
+
+// formatTime formats t as RFC 3339, or "" for the zero time.
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.Format(time.RFC3339)
+}
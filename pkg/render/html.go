@@ -0,0 +1,418 @@
+package render
+
+import (
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"io"
+	"sort"
+	"time"
+
+	"github.com/vtriple/agentflux/pkg/common/errcode"
+	"github.com/vtriple/agentflux/pkg/dedup"
+	"github.com/vtriple/agentflux/pkg/processor"
+	"github.com/vtriple/agentflux/pkg/progress"
+)
+
+// Default limits of the HTML report tables.
+const (
+	DefaultTopFiles    = 25
+	DefaultTopGroups   = 25
+	DefaultTopSummary  = 50
+	DefaultTopErrors   = 100
+	DefaultTopAnalyses = 100
+)
+
+// Summary is the number and size of the files sharing a type, extension or owner.
+type Summary struct {
+	Name  string
+	Files int
+	Bytes int64
+}
+
+// HTMLReport accumulates results and writes them as a single HTML page with
+// sortable tables, needing no other files or network access to view.
+// Sighting records only contribute to duplicate groups, as they carry no
+// details of their own. Every hash is kept to find duplicates, so memory
+// grows with the number of files.
+type HTMLReport struct {
+	// Title is shown at the top of the page.
+	Title string
+	// TopFiles is the number of largest files listed.
+	TopFiles int
+	// TopGroups is the number of duplicate groups listed, largest waste first.
+	TopGroups int
+	// TopSummary is the number of rows in each summary table.
+	TopSummary int
+	// TopErrors is the number of failed files listed.
+	TopErrors int
+	// TopAnalyses is the number of files with analyzer results or errors
+	// listed.
+	TopAnalyses int
+
+	files      int
+	bytes      int64
+	errors     int
+	types      map[string]*Summary
+	extensions map[string]*Summary
+	owners     map[string]*Summary
+	errorCodes map[string]*Summary
+	largest    []processor.FileResult
+	errorFiles []processor.FileResult
+	groups     map[string]*dedup.DuplicateGroup
+	analyzers  map[string]*Summary
+	analyzed   []processor.FileResult
+	analyses   int
+}
+
+// NewHTMLReport creates an empty report with the default limits.
+func NewHTMLReport(title string) *HTMLReport {
+	return &HTMLReport{
+		Title:       title,
+		TopFiles:    DefaultTopFiles,
+		TopGroups:   DefaultTopGroups,
+		TopSummary:  DefaultTopSummary,
+		TopErrors:   DefaultTopErrors,
+		TopAnalyses: DefaultTopAnalyses,
+		types:       map[string]*Summary{},
+		extensions:  map[string]*Summary{},
+		owners:      map[string]*Summary{},
+		errorCodes:  map[string]*Summary{},
+		groups:      map[string]*dedup.DuplicateGroup{},
+		analyzers:   map[string]*Summary{},
+	}
+}
+
+// Add adds a result to the report.
+func (h *HTMLReport) Add(result processor.FileResult) {
+	if result.Hash != "" {
+		key := result.HashAlgorithm + ":" + result.Hash
+		group, ok := h.groups[key]
+		if !ok {
+			group = &dedup.DuplicateGroup{Hash: result.Hash, HashAlgorithm: result.HashAlgorithm, Size: result.Size}
+			h.groups[key] = group
+		}
+		group.Paths = append(group.Paths, result.Path)
+		for _, sighting := range result.AlsoSeenAt {
+			group.Paths = append(group.Paths, sighting.Path)
+		}
+	}
+	if result.Sighting {
+		return
+	}
+
+	h.files++
+	if result.Error != "" {
+		h.errors++
+		code := result.ErrorCode
+		if code == "" {
+			code = errcode.Unknown
+		}
+		addSummary(h.errorCodes, string(code), 0)
+		if len(h.errorFiles) < h.TopErrors {
+			h.errorFiles = append(h.errorFiles, result)
+		}
+		return
+	}
+
+	h.bytes += result.Size
+	addSummary(h.types, orDefault(result.MimeType, "unknown"), result.Size)
+	addSummary(h.extensions, orDefault(extension(result.Path), "(none)"), result.Size)
+	addSummary(h.owners, orDefault(result.Owner, "(unknown)"), result.Size)
+
+	// Count and list the files analyzers reported on
+	for name := range result.Analysis {
+		addSummary(h.analyzers, name, result.Size)
+	}
+	for name := range result.AnalysisErrors {
+		addSummary(h.analyzers, name+" (failed)", result.Size)
+	}
+	if len(result.Analysis) > 0 || len(result.AnalysisErrors) > 0 {
+		h.analyses++
+		if len(h.analyzed) < h.TopAnalyses {
+			h.analyzed = append(h.analyzed, result)
+		}
+	}
+
+	// Keep the largest files sorted, largest first
+	i := sort.Search(len(h.largest), func(i int) bool { return h.largest[i].Size < result.Size })
+	if i < h.TopFiles {
+		h.largest = append(h.largest, processor.FileResult{})
+		copy(h.largest[i+1:], h.largest[i:])
+		h.largest[i] = result
+		if len(h.largest) > h.TopFiles {
+			h.largest = h.largest[:h.TopFiles]
+		}
+	}
+}
+
+// htmlData is the data of the report template.
+type htmlData struct {
+	Title       string
+	Generated   time.Time
+	Files       int
+	Bytes       int64
+	Errors      int
+	Types       []Summary
+	Extensions  []Summary
+	Owners      []Summary
+	ErrorCodes  []Summary
+	ErrorFiles  []processor.FileResult
+	Analyzers   []Summary
+	Analyzed    []processor.FileResult
+	Analyses    int
+	Largest     []processor.FileResult
+	Groups      []dedup.DuplicateGroup
+	GroupCount  int
+	WastedBytes int64
+}
+
+// Write writes the report as an HTML page.
+func (h *HTMLReport) Write(w io.Writer) error {
+	data := htmlData{
+		Title:      h.Title,
+		Generated:  time.Now(),
+		Files:      h.files,
+		Bytes:      h.bytes,
+		Errors:     h.errors,
+		Types:      topSummaries(h.types, h.TopSummary),
+		Extensions: topSummaries(h.extensions, h.TopSummary),
+		Owners:     topSummaries(h.owners, h.TopSummary),
+		ErrorCodes: topSummaries(h.errorCodes, 0),
+		ErrorFiles: h.errorFiles,
+		Analyzers:  topSummaries(h.analyzers, 0),
+		Analyzed:   h.analyzed,
+		Analyses:   h.analyses,
+		Largest:    h.largest,
+	}
+
+	for _, group := range h.groups {
+		if len(group.Paths) < 2 {
+			continue
+		}
+		data.Groups = append(data.Groups, *group)
+		data.WastedBytes += group.WastedBytes()
+	}
+	sort.Slice(data.Groups, func(i, j int) bool {
+		if data.Groups[i].WastedBytes() != data.Groups[j].WastedBytes() {
+			return data.Groups[i].WastedBytes() > data.Groups[j].WastedBytes()
+		}
+		return data.Groups[i].Hash < data.Groups[j].Hash
+	})
+	data.GroupCount = len(data.Groups)
+	if h.TopGroups > 0 && len(data.Groups) > h.TopGroups {
+		data.Groups = data.Groups[:h.TopGroups]
+	}
+
+	if err := htmlTemplate.Execute(w, data); err != nil {
+		return fmt.Errorf("failed to render HTML report: %w", err)
+	}
+	return nil
+}
+
+// addSummary counts a file of the given size under name.
+func addSummary(summaries map[string]*Summary, name string, size int64) {
+	summary, ok := summaries[name]
+	if !ok {
+		summary = &Summary{Name: name}
+		summaries[name] = summary
+	}
+	summary.Files++
+	summary.Bytes += size
+}
+
+// topSummaries returns up to limit summaries, by size then count, or all of
+// them if limit is 0.
+func topSummaries(summaries map[string]*Summary, limit int) []Summary {
+	sorted := make([]Summary, 0, len(summaries))
+	for _, summary := range summaries {
+		sorted = append(sorted, *summary)
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].Bytes != sorted[j].Bytes {
+			return sorted[i].Bytes > sorted[j].Bytes
+		}
+		if sorted[i].Files != sorted[j].Files {
+			return sorted[i].Files > sorted[j].Files
+		}
+		return sorted[i].Name < sorted[j].Name
+	})
+	if limit > 0 && len(sorted) > limit {
+		sorted = sorted[:limit]
+	}
+	return sorted
+}
+
+// orDefault returns s, or fallback if s is empty.
+func orDefault(s, fallback string) string {
+	if s == "" {
+		return fallback
+	}
+	return s
+}
+
+var htmlTemplate = template.Must(template.New("report").Funcs(template.FuncMap{
+	"bytes":    func(n int64) string { return progress.FormatBytes(float64(n)) },
+	"time":     formatTime,
+	"dupes":    func(g dedup.DuplicateGroup) int { return len(g.Paths) },
+	"waste":    func(g dedup.DuplicateGroup) int64 { return g.WastedBytes() },
+	"analyses": analysisRows,
+	"summaryTable": func(title string, rows []Summary) summaryTable {
+		table := summaryTable{Title: title, Rows: rows}
+		for _, row := range rows {
+			table.Sizes = table.Sizes || row.Bytes > 0
+		}
+		return table
+	},
+}).Parse(htmlSource))
+
+// analysisRow is the outcome of one analyzer for a file in the report.
+type analysisRow struct {
+	Name   string
+	Result string
+	Error  string
+}
+
+// analysisRows returns the analyzer results and errors of a result, by
+// analyzer name, with results encoded as JSON.
+func analysisRows(result processor.FileResult) []analysisRow {
+	rows := make([]analysisRow, 0, len(result.Analysis)+len(result.AnalysisErrors))
+	for name, value := range result.Analysis {
+		data, err := json.Marshal(value)
+		if err != nil {
+			rows = append(rows, analysisRow{Name: name, Error: err.Error()})
+			continue
+		}
+		rows = append(rows, analysisRow{Name: name, Result: string(data)})
+	}
+	for name, message := range result.AnalysisErrors {
+		rows = append(rows, analysisRow{Name: name, Error: message})
+	}
+	sort.Slice(rows, func(i, j int) bool { return rows[i].Name < rows[j].Name })
+	return rows
+}
+
+// summaryTable is the data of the summary template.
+type summaryTable struct {
+	Title string
+	Rows  []Summary
+	// Sizes is set if any row has a size, so the column is worth showing
+	Sizes bool
+}
+
+const htmlSource = `<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>{{.Title}}</title>
+<style>
+body { font-family: system-ui, sans-serif; margin: 2em; color: #222; }
+h1 { margin-bottom: 0.2em; }
+.meta { color: #666; margin-bottom: 1.5em; }
+.cards { display: flex; gap: 1em; flex-wrap: wrap; margin-bottom: 1.5em; }
+.card { border: 1px solid #ddd; border-radius: 6px; padding: 0.8em 1.2em; min-width: 9em; }
+.card b { display: block; font-size: 1.5em; }
+table { border-collapse: collapse; margin-bottom: 2em; width: 100%; }
+th, td { border-bottom: 1px solid #eee; padding: 0.3em 0.6em; text-align: left; vertical-align: top; }
+th { background: #f5f5f5; cursor: pointer; user-select: none; }
+th.asc::after { content: " \25B2"; }
+th.desc::after { content: " \25BC"; }
+td.num { text-align: right; white-space: nowrap; }
+code { font-size: 0.85em; word-break: break-all; }
+ul { margin: 0; padding-left: 1.2em; }
+.columns { display: grid; grid-template-columns: repeat(auto-fit, minmax(22em, 1fr)); gap: 1.5em; }
+</style>
+</head>
+<body>
+<h1>{{.Title}}</h1>
+<div class="meta">Generated {{time .Generated}}</div>
+<div class="cards">
+<div class="card"><b>{{.Files}}</b>files</div>
+<div class="card"><b>{{bytes .Bytes}}</b>total size</div>
+<div class="card"><b>{{.GroupCount}}</b>duplicate groups</div>
+<div class="card"><b>{{bytes .WastedBytes}}</b>wasted by duplicates</div>
+<div class="card"><b>{{.Errors}}</b>errors</div>
+</div>
+
+<div class="columns">
+{{template "summary" (summaryTable "By type" .Types)}}
+{{template "summary" (summaryTable "By extension" .Extensions)}}
+{{template "summary" (summaryTable "By owner" .Owners)}}
+</div>
+
+<h2>Largest files</h2>
+<table class="sortable">
+<thead><tr><th>Path</th><th>Size</th><th>Modified</th><th>Owner</th><th>Type</th><th>Hash</th></tr></thead>
+<tbody>
+{{range .Largest}}<tr><td>{{.Path}}</td><td class="num" data-sort="{{.Size}}">{{bytes .Size}}</td><td>{{time .ModTime}}</td><td>{{.Owner}}</td><td>{{.MimeType}}</td><td><code>{{.Hash}}</code></td></tr>
+{{else}}<tr><td colspan="6">No files</td></tr>
+{{end}}</tbody>
+</table>
+
+<h2>Duplicate groups</h2>
+{{if gt .GroupCount (len .Groups)}}<p>Showing the {{len .Groups}} groups wasting the most space of {{.GroupCount}}.</p>{{end}}
+<table class="sortable">
+<thead><tr><th>Wasted</th><th>Copies</th><th>Size</th><th>Hash</th><th>Paths</th></tr></thead>
+<tbody>
+{{range .Groups}}<tr><td class="num" data-sort="{{waste .}}">{{bytes (waste .)}}</td><td class="num">{{dupes .}}</td><td class="num" data-sort="{{.Size}}">{{bytes .Size}}</td><td><code>{{.HashAlgorithm}}:{{.Hash}}</code></td><td><ul>{{range .Paths}}<li>{{.}}</li>{{end}}</ul></td></tr>
+{{else}}<tr><td colspan="5">No duplicates</td></tr>
+{{end}}</tbody>
+</table>
+
+{{if .Analyzed}}<h2>Analysis</h2>
+{{template "summary" (summaryTable "By analyzer" .Analyzers)}}
+<table class="sortable">
+<thead><tr><th>Path</th><th>Analyzer</th><th>Result</th><th>Error</th></tr></thead>
+<tbody>
+{{range .Analyzed}}{{$path := .Path}}{{range analyses .}}<tr><td>{{$path}}</td><td>{{.Name}}</td><td>{{if .Result}}<code>{{.Result}}</code>{{end}}</td><td>{{.Error}}</td></tr>
+{{end}}{{end}}</tbody>
+</table>
+{{if gt .Analyses (len .Analyzed)}}<p>Showing the first {{len .Analyzed}} of {{.Analyses}} analyzed files.</p>{{end}}
+{{end}}
+
+<h2>Errors</h2>
+{{template "summary" (summaryTable "By code" .ErrorCodes)}}
+{{if .ErrorFiles}}<table class="sortable">
+<thead><tr><th>Path</th><th>Code</th><th>Error</th></tr></thead>
+<tbody>
+{{range .ErrorFiles}}<tr><td>{{.Path}}</td><td>{{.ErrorCode}}</td><td>{{.Error}}</td></tr>
+{{end}}</tbody>
+</table>
+{{if gt .Errors (len .ErrorFiles)}}<p>Showing the first {{len .ErrorFiles}} of {{.Errors}} failed files.</p>{{end}}
+{{end}}
+
+<script>
+document.querySelectorAll("table.sortable th").forEach(function (th) {
+  th.addEventListener("click", function () {
+    var table = th.closest("table"), body = table.tBodies[0];
+    var index = Array.prototype.indexOf.call(th.parentNode.children, th);
+    var asc = !th.classList.contains("asc");
+    th.parentNode.querySelectorAll("th").forEach(function (h) { h.classList.remove("asc", "desc"); });
+    th.classList.add(asc ? "asc" : "desc");
+    var key = function (row) {
+      var cell = row.children[index];
+      if (!cell) return "";
+      var value = cell.getAttribute("data-sort");
+      if (value === null) value = cell.textContent;
+      return isNaN(value) || value === "" ? value.toLowerCase() : Number(value);
+    };
+    Array.prototype.slice.call(body.rows).sort(function (a, b) {
+      var x = key(a), y = key(b);
+      return (x < y ? -1 : x > y ? 1 : 0) * (asc ? 1 : -1);
+    }).forEach(function (row) { body.appendChild(row); });
+  });
+});
+</script>
+</body>
+</html>
+{{define "summary"}}<div>
+<h2>{{.Title}}</h2>
+<table class="sortable">
+<thead><tr><th>Name</th><th>Files</th>{{if .Sizes}}<th>Size</th>{{end}}</tr></thead>
+<tbody>
+{{$sizes := .Sizes}}{{range .Rows}}<tr><td>{{.Name}}</td><td class="num">{{.Files}}</td>{{if $sizes}}<td class="num" data-sort="{{.Bytes}}">{{bytes .Bytes}}</td>{{end}}</tr>
+{{else}}<tr><td colspan="3">None</td></tr>
+{{end}}</tbody>
+</table>
+</div>{{end}}
+`
@@ -0,0 +1,37 @@
+// Package render turns scan results into documents for people who do not
+// read JSON: CSV tables and self-contained HTML reports.
+package render
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/vtriple/agentflux/pkg/processor"
+)
+
+// ReadJSONL decodes one result per line from r, such as the results.jsonl of
+// the reference receiver or the output of "agentflux hash --format=json",
+// and calls fn for each. Blank lines are skipped; fields other than those of
+// a result, such as scanId, are ignored.
+func ReadJSONL(r io.Reader, fn func(processor.FileResult) error) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 64*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var result processor.FileResult
+		if err := json.Unmarshal(scanner.Bytes(), &result); err != nil {
+			return fmt.Errorf("line %d: invalid result: %w", line, err)
+		}
+		if err := fn(result); err != nil {
+			return err
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("error reading results: %w", err)
+	}
+	return nil
+}
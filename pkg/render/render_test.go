@@ -0,0 +1,179 @@
+package render
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/vtriple/agentflux/pkg/processor"
+)
+
+func sampleResults() []processor.FileResult {
+	modTime := time.Date(2025, 3, 1, 2, 0, 0, 0, time.UTC)
+	return []processor.FileResult{
+		{Path: "/srv/a.txt", Name: "a.txt", Size: 100, ModTime: modTime, Owner: "alice", Hash: "aa", HashAlgorithm: "sha256", MimeType: "text/plain"},
+		{Path: "/srv/b.TXT", Name: "b.TXT", Size: 100, ModTime: modTime, Owner: "bob", Hash: "aa", HashAlgorithm: "sha256", MimeType: "text/plain"},
+		{Path: "/srv/=cmd|' /C calc'!A0", Name: "=cmd", Size: 5000, Owner: "alice", Hash: "bb", HashAlgorithm: "sha256",
+			AlsoSeenAt: []processor.Sighting{{Path: "/srv/copy"}}},
+		{Path: "/srv/<script>alert(1)</script>", Size: 10, Hash: "cc", HashAlgorithm: "sha256"},
+		{Path: "/srv/locked", Error: "open error: permission denied", ErrorCode: "permission_denied"},
+	}
+}
+
+func TestCSVWriter(t *testing.T) {
+	columns, err := ParseColumns("path, name,size,ext,errorCode")
+	if err != nil {
+		t.Fatalf("Failed to parse columns: %v", err)
+	}
+	var out bytes.Buffer
+	writer := NewCSVWriter(&out, columns)
+	for _, result := range sampleResults() {
+		if err := writer.Write(result); err != nil {
+			t.Fatalf("Failed to write row: %v", err)
+		}
+	}
+	if err := writer.Flush(); err != nil {
+		t.Fatalf("Failed to flush: %v", err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	expected := []string{
+		"path,name,size,ext,errorCode",
+		"/srv/a.txt,a.txt,100,txt,",
+		"/srv/b.TXT,b.TXT,100,txt,",
+		"/srv/=cmd|' /C calc'!A0,'=cmd,5000,,",
+		"/srv/<script>alert(1)</script>,,10,,",
+		"/srv/locked,,0,,permission_denied",
+	}
+	if strings.Join(lines, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("Expected\n%s\ngot\n%s", strings.Join(expected, "\n"), out.String())
+	}
+	if got := sanitizeCell("=HYPERLINK(\"x\")"); got != "'=HYPERLINK(\"x\")" {
+		t.Errorf("Expected formula to be neutralized, got %q", got)
+	}
+
+	if _, err := ParseColumns("path,color"); err == nil {
+		t.Error("Expected error for unknown column")
+	}
+	out.Reset()
+	if err := NewCSVWriter(&out, DefaultColumns).Flush(); err != nil || !strings.HasPrefix(out.String(), "path,size") {
+		t.Errorf("Expected header without rows, got %q (%v)", out.String(), err)
+	}
+}
+
+func TestReadJSONL(t *testing.T) {
+	input := `{"scanId":"s1","path":"/a","size":3,"hash":"aa","hashAlgorithm":"sha256"}
+
+{"path":"/b","errorCode":"vanished","error":"stat error"}
+`
+	var results []processor.FileResult
+	err := ReadJSONL(strings.NewReader(input), func(result processor.FileResult) error {
+		results = append(results, result)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Failed to read results: %v", err)
+	}
+	if len(results) != 2 || results[0].Size != 3 || results[1].ErrorCode != "vanished" {
+		t.Errorf("Unexpected results %+v", results)
+	}
+
+	err = ReadJSONL(strings.NewReader("{\"path\":\"/a\"}\nnot json\n"), func(processor.FileResult) error { return nil })
+	if err == nil || !strings.Contains(err.Error(), "line 2") {
+		t.Errorf("Expected error naming line 2, got %v", err)
+	}
+}
+
+func TestHTMLReport(t *testing.T) {
+	report := NewHTMLReport("Scan of /srv")
+	report.TopFiles = 2
+	for _, result := range sampleResults() {
+		report.Add(result)
+	}
+	var out bytes.Buffer
+	if err := report.Write(&out); err != nil {
+		t.Fatalf("Failed to write report: %v", err)
+	}
+	page := out.String()
+
+	if strings.Contains(page, "<script>alert(1)") {
+		t.Error("Expected file names to be escaped")
+	}
+	for _, want := range []string{
+		"<title>Scan of /srv</title>",
+		"<b>5</b>files",
+		"<b>2</b>duplicate groups",
+		"<li>/srv/copy</li>",
+		"<td>text/plain</td><td class=\"num\">2</td>",
+		"<td>txt</td><td class=\"num\">2</td>",
+		"<td>alice</td><td class=\"num\">2</td>",
+		"<td>permission_denied</td><td class=\"num\">1</td></tr>",
+		"table.sortable th",
+	} {
+		if !strings.Contains(page, want) {
+			t.Errorf("Expected report to contain %q", want)
+		}
+	}
+	if strings.Count(page, "<td><code>") != 2+2 {
+		t.Errorf("Expected the 2 largest files and 2 duplicate groups, got\n%s", page)
+	}
+}
+
+func TestAnalysisOutput(t *testing.T) {
+	results := []processor.FileResult{
+		{Path: "/srv/setup.exe", Size: 10, Hash: "aa", HashAlgorithm: "sha256", MimeType: "application/octet-stream",
+			Analysis:       map[string]any{"yara": []any{"Suspicious_Packer"}, "entropy": map[string]any{"entropy": 7.9}},
+			AnalysisErrors: map[string]string{"ioc": "timed out after 30s"}},
+		{Path: "/srv/notes.txt", Size: 5, Hash: "bb", HashAlgorithm: "sha256"},
+	}
+
+	var out bytes.Buffer
+	writer := NewCSVWriter(&out, []string{"path", "analysis", "analysisErrors"})
+	for _, result := range results {
+		if err := writer.Write(result); err != nil {
+			t.Fatalf("Failed to write row: %v", err)
+		}
+	}
+	if err := writer.Flush(); err != nil {
+		t.Fatalf("Failed to flush: %v", err)
+	}
+	expected := "path,analysis,analysisErrors\n" +
+		`/srv/setup.exe,"{""entropy"":{""entropy"":7.9},""yara"":[""Suspicious_Packer""]}","{""ioc"":""timed out after 30s""}"` + "\n" +
+		"/srv/notes.txt,,\n"
+	if out.String() != expected {
+		t.Errorf("Expected\n%s\ngot\n%s", expected, out.String())
+	}
+
+	report := NewHTMLReport("Scan of /srv")
+	for _, result := range results {
+		report.Add(result)
+	}
+	out.Reset()
+	if err := report.Write(&out); err != nil {
+		t.Fatalf("Failed to write report: %v", err)
+	}
+	page := out.String()
+	for _, want := range []string{
+		"<h2>Analysis</h2>",
+		"<td>yara</td><td class=\"num\">1</td>",
+		"<td>ioc (failed)</td><td class=\"num\">1</td>",
+		"<td>/srv/setup.exe</td><td>yara</td><td><code>[&#34;Suspicious_Packer&#34;]</code></td><td></td>",
+		"<td>/srv/setup.exe</td><td>ioc</td><td></td><td>timed out after 30s</td>",
+	} {
+		if !strings.Contains(page, want) {
+			t.Errorf("Expected report to contain %q", want)
+		}
+	}
+	if strings.Contains(page, "/srv/notes.txt</td><td>") {
+		t.Error("Expected files without analysis to be left out of the analysis table")
+	}
+
+	// Without analyzers the section is left out
+	report = NewHTMLReport("Scan of /srv")
+	report.Add(results[1])
+	out.Reset()
+	if err := report.Write(&out); err != nil || strings.Contains(out.String(), "<h2>Analysis</h2>") {
+		t.Errorf("Expected no analysis section without analyzer results (%v)", err)
+	}
+}
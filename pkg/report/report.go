@@ -0,0 +1,278 @@
+// Package report builds the machine-readable report of a scan run and
+// defines the exit codes scripts can rely on.
+package report
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/vtriple/agentflux/pkg/common/errcode"
+	"github.com/vtriple/agentflux/pkg/processor"
+)
+
+// Exit codes of a run.
+const (
+	// ExitOK means every file was scanned and delivered.
+	ExitOK = 0
+	// ExitFatal means the run could not complete, for example because of
+	// invalid configuration.
+	ExitFatal = 1
+	// ExitPartialDelivery means some batches could not be delivered to the API.
+	ExitPartialDelivery = 2
+	// ExitTooManyErrors means the scan hit more errors than allowed by --max-errors.
+	ExitTooManyErrors = 3
+)
+
+// Status describes the outcome of a run.
+type Status string
+
+const (
+	// StatusOK matches ExitOK.
+	StatusOK Status = "ok"
+	// StatusFailed matches ExitFatal.
+	StatusFailed Status = "failed"
+	// StatusPartialDelivery matches ExitPartialDelivery.
+	StatusPartialDelivery Status = "partial_delivery"
+	// StatusTooManyErrors matches ExitTooManyErrors.
+	StatusTooManyErrors Status = "too_many_errors"
+)
+
+// StatusOf returns the status matching an exit code.
+func StatusOf(code int) Status {
+	switch code {
+	case ExitOK:
+		return StatusOK
+	case ExitPartialDelivery:
+		return StatusPartialDelivery
+	case ExitTooManyErrors:
+		return StatusTooManyErrors
+	default:
+		return StatusFailed
+	}
+}
+
+// ExitError is an error that ends a run with a specific exit code.
+type ExitError struct {
+	Code int
+	Err  error
+}
+
+func (e *ExitError) Error() string { return e.Err.Error() }
+
+func (e *ExitError) Unwrap() error { return e.Err }
+
+// ExitCode returns the exit code for the error returned by a run: ExitOK for
+// nil, the code of an ExitError, and ExitFatal otherwise.
+func ExitCode(err error) int {
+	if err == nil {
+		return ExitOK
+	}
+	var exitErr *ExitError
+	if errors.As(err, &exitErr) {
+		return exitErr.Code
+	}
+	return ExitFatal
+}
+
+// Report is the summary of a run written with --report.
+type Report struct {
+	ScanID          string    `json:"scanId,omitempty"`
+	Version         string    `json:"version"`
+	Status          Status    `json:"status"`
+	ExitCode        int       `json:"exitCode"`
+	Error           string    `json:"error,omitempty"`
+	Cancelled       bool      `json:"cancelled,omitempty"`
+	StartedAt       time.Time `json:"startedAt"`
+	FinishedAt      time.Time `json:"finishedAt"`
+	DurationSeconds float64   `json:"durationSeconds"`
+	// ConfigHash identifies the effective configuration, with secrets redacted.
+	ConfigHash string `json:"configHash"`
+	Counts     Counts `json:"counts"`
+	// Errors counts errors by where they occurred (scan, file or api) and code.
+	Errors map[string]map[errcode.Code]int64 `json:"errors"`
+	// Skipped counts paths skipped by the scanner, by reason.
+	Skipped map[string]int64 `json:"skipped"`
+	// Stages lists when each pipeline stage finished. Stages run
+	// concurrently, so each is timed from the start of the run.
+	Stages []Stage `json:"stages"`
+}
+
+// Counts are the file and batch totals of a run.
+type Counts struct {
+	// Discovered is the number of files found by the scanner.
+	Discovered int64 `json:"discovered"`
+	// Processed is the number of files hashed or failed by the processor.
+	Processed int64 `json:"processed"`
+	// Unique is the number of results passed on for delivery.
+	Unique int `json:"unique"`
+	// Duplicates is the number of results identified as duplicates.
+	Duplicates int `json:"duplicates"`
+	// Suppressed is the number of results delivered by a previous run.
+	Suppressed int `json:"suppressed"`
+	// Delivered is the number of results acknowledged by the API.
+	Delivered int64 `json:"delivered"`
+	// BatchesDelivered is the number of batches acknowledged by the API.
+	BatchesDelivered int64 `json:"batchesDelivered"`
+	// BatchesFailed is the number of batches that could not be delivered.
+	BatchesFailed int64 `json:"batchesFailed"`
+	// ScanErrors is the number of paths the scanner could not read.
+	ScanErrors int64 `json:"scanErrors"`
+	// FileErrors is the number of files the processor could not hash.
+	FileErrors int64 `json:"fileErrors"`
+}
+
+// Stage is the completion time of a pipeline stage.
+type Stage struct {
+	Name string `json:"name"`
+	// Seconds is the time from the start of the run until the stage finished.
+	Seconds float64 `json:"seconds"`
+}
+
+// Collector gathers the figures of a run as it progresses. It is safe for
+// concurrent use.
+type Collector struct {
+	mu     sync.Mutex
+	report Report
+}
+
+// NewCollector starts collecting a report for a run starting now.
+func NewCollector(version, configHash string) *Collector {
+	return &Collector{report: Report{
+		Version:    version,
+		ConfigHash: configHash,
+		StartedAt:  time.Now(),
+		Errors:     map[string]map[errcode.Code]int64{},
+		Skipped:    map[string]int64{},
+	}}
+}
+
+// Update calls fn to change the report, such as to fill in totals kept by
+// other components.
+func (c *Collector) Update(fn func(r *Report)) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	fn(&c.report)
+}
+
+// StageDone records that a pipeline stage has finished.
+func (c *Collector) StageDone(name string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.report.Stages = append(c.report.Stages, Stage{Name: name, Seconds: time.Since(c.report.StartedAt).Seconds()})
+}
+
+// ScanError counts an error reported by the scanner.
+func (c *Collector) ScanError(err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.report.Counts.ScanErrors++
+	c.addError("scan", errcode.Of(err))
+}
+
+// DeliveryError counts a batch that could not be delivered.
+func (c *Collector) DeliveryError(err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.report.Counts.BatchesFailed++
+	c.addError("api", errcode.Of(err))
+}
+
+// Delivered counts a batch of results acknowledged by the API.
+func (c *Collector) Delivered(results int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.report.Counts.BatchesDelivered++
+	c.report.Counts.Delivered += int64(results)
+}
+
+// Result counts a processed file and its error, if any.
+func (c *Collector) Result(result processor.FileResult) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.report.Counts.Processed++
+	if result.Error != "" {
+		c.report.Counts.FileErrors++
+		code := result.ErrorCode
+		if code == "" {
+			code = errcode.Unknown
+		}
+		c.addError("file", code)
+	}
+}
+
+// addError counts an error with code in group. The caller must hold c.mu.
+func (c *Collector) addError(group string, code errcode.Code) {
+	if c.report.Errors[group] == nil {
+		c.report.Errors[group] = map[errcode.Code]int64{}
+	}
+	c.report.Errors[group][code]++
+}
+
+// Track passes results from in through to the returned channel, calling
+// observe for each if it is not nil, and records stage as finished when in
+// is closed.
+func (c *Collector) Track(stage string, in <-chan processor.FileResult, observe func(processor.FileResult)) <-chan processor.FileResult {
+	out := make(chan processor.FileResult, cap(in))
+	go func() {
+		defer close(out)
+		for result := range in {
+			if observe != nil {
+				observe(result)
+			}
+			out <- result
+		}
+		c.StageDone(stage)
+	}()
+	return out
+}
+
+// Errors returns the number of scan and file errors so far.
+func (c *Collector) Errors() int64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.report.Counts.ScanErrors + c.report.Counts.FileErrors
+}
+
+// Finish completes the report with the outcome of the run and returns a copy.
+func (c *Collector) Finish(err error) Report {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.report.FinishedAt = time.Now()
+	c.report.DurationSeconds = c.report.FinishedAt.Sub(c.report.StartedAt).Seconds()
+	c.report.ExitCode = ExitCode(err)
+	c.report.Status = StatusOf(c.report.ExitCode)
+	if err != nil {
+		c.report.Error = err.Error()
+	}
+	return c.report
+}
+
+// WriteFile writes the report to path as indented JSON. The file is
+// replaced atomically so readers never see a partial report.
+func WriteFile(path string, r Report) error {
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode report: %w", err)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to write report: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(append(data, '\n')); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write report: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write report: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("failed to write report: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,79 @@
+package report
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/vtriple/agentflux/pkg/common/errcode"
+	"github.com/vtriple/agentflux/pkg/processor"
+)
+
+func TestExitCode(t *testing.T) {
+	tests := []struct {
+		err  error
+		code int
+	}{
+		{nil, ExitOK},
+		{errors.New("boom"), ExitFatal},
+		{&ExitError{Code: ExitPartialDelivery, Err: errors.New("lost")}, ExitPartialDelivery},
+		{fmt.Errorf("wrapped: %w", &ExitError{Code: ExitTooManyErrors, Err: errors.New("errors")}), ExitTooManyErrors},
+	}
+	for _, tt := range tests {
+		if got := ExitCode(tt.err); got != tt.code {
+			t.Errorf("ExitCode(%v) = %d, want %d", tt.err, got, tt.code)
+		}
+	}
+}
+
+func TestCollectorReport(t *testing.T) {
+	c := NewCollector("1.2.3", "abc")
+	in := make(chan processor.FileResult, 3)
+	in <- processor.FileResult{Path: "/a"}
+	in <- processor.FileResult{Path: "/b", Error: "open error: open /b: permission denied", ErrorCode: errcode.PermissionDenied}
+	close(in)
+	for range c.Track("process", in, c.Result) {
+	}
+	c.ScanError(fmt.Errorf("error reading directory /c: %w", fs.ErrPermission))
+	c.Delivered(1)
+	c.DeliveryError(fmt.Errorf("failed to send batch: %w", errcode.Errorf(errcode.Rejected, "API error (attempt 1/1): status=400, body=bad")))
+
+	if got := c.Errors(); got != 2 {
+		t.Errorf("Errors() = %d, want 2", got)
+	}
+
+	r := c.Finish(&ExitError{Code: ExitPartialDelivery, Err: errors.New("1 of 2 batches failed to deliver")})
+	if r.Status != StatusPartialDelivery || r.ExitCode != ExitPartialDelivery || r.Error == "" {
+		t.Errorf("outcome = %s (%d) %q", r.Status, r.ExitCode, r.Error)
+	}
+	if r.Counts.Processed != 2 || r.Counts.FileErrors != 1 || r.Counts.ScanErrors != 1 ||
+		r.Counts.Delivered != 1 || r.Counts.BatchesFailed != 1 {
+		t.Errorf("counts = %+v", r.Counts)
+	}
+	if r.Errors["file"][errcode.PermissionDenied] != 1 || r.Errors["scan"][errcode.PermissionDenied] != 1 || r.Errors["api"][errcode.Rejected] != 1 {
+		t.Errorf("errors = %v", r.Errors)
+	}
+	if len(r.Stages) != 1 || r.Stages[0].Name != "process" {
+		t.Errorf("stages = %v", r.Stages)
+	}
+
+	path := filepath.Join(t.TempDir(), "report.json")
+	if err := WriteFile(path, r); err != nil {
+		t.Fatalf("WriteFile() error: %v", err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded Report
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("report is not valid JSON: %v", err)
+	}
+	if decoded.ConfigHash != "abc" || decoded.Version != "1.2.3" || decoded.Counts != r.Counts {
+		t.Errorf("decoded report = %+v", decoded)
+	}
+}
@@ -0,0 +1,89 @@
+package scanner
+
+import (
+	"context"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Estimate is the result of a pre-count pass over the scanner's paths.
+type Estimate struct {
+	// Files is the number of files the scan is expected to find.
+	Files int64
+	// Bytes is the total size of those files.
+	Bytes int64
+}
+
+// Estimate walks the root paths with the scanner's filters and counts the
+// files a scan would find, without sending them anywhere. It is much cheaper
+// than a scan as files are not opened, and stops early if ctx is cancelled.
+// Symbolic links to directories are not followed, so
+// the estimate may be low when SkipSymlinks is false.
+func (s *FileScanner) Estimate(ctx context.Context) (Estimate, error) {
+	var estimate Estimate
+	for _, root := range s.RootPaths {
+		if err := s.estimatePath(ctx, root, &estimate); err != nil {
+			return estimate, err
+		}
+	}
+	return estimate, nil
+}
+
+// estimatePath adds the files under root to estimate.
+func (s *FileScanner) estimatePath(ctx context.Context, root string, estimate *Estimate) error {
+	return filepath.WalkDir(root, func(path string, entry fs.DirEntry, err error) error {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return ctxErr
+		}
+		if err != nil {
+			// Unreadable paths are reported by the scan itself
+			if entry != nil && entry.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		if s.SkipHiddenFiles && isHiddenFile(entry.Name()) || s.excludePattern(path) != "" {
+			if entry.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if entry.IsDir() {
+			if s.MaxDepth >= 0 && pathDepth(root, path) > s.MaxDepth {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		var info os.FileInfo
+		if entry.Type()&os.ModeSymlink != 0 {
+			if s.SkipSymlinks {
+				return nil
+			}
+			info, err = os.Stat(path)
+		} else {
+			info, err = entry.Info()
+		}
+		if err != nil || !info.Mode().IsRegular() {
+			return nil
+		}
+		if s.MaxFileSize > 0 && info.Size() > s.MaxFileSize {
+			return nil
+		}
+		estimate.Files++
+		estimate.Bytes += info.Size()
+		return nil
+	})
+}
+
+// pathDepth returns how many directories path is below root.
+func pathDepth(root, path string) int {
+	rel, err := filepath.Rel(root, path)
+	if err != nil || rel == "." {
+		return 0
+	}
+	return strings.Count(rel, string(filepath.Separator)) + 1
+}
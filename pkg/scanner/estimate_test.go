@@ -0,0 +1,63 @@
+package scanner
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestEstimateMatchesScan(t *testing.T) {
+	tempDir := t.TempDir()
+	files := map[string]int{
+		"a.txt":          10,
+		"b.log":          20,
+		".hidden":        30,
+		"sub/c.txt":      40,
+		"sub/deep/d.txt": 50,
+		".git/e.txt":     60,
+	}
+	for name, size := range files {
+		path := filepath.Join(tempDir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, make([]byte, size), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, maxDepth := range []int{-1, 0, 1} {
+		scanner := NewFileScanner(context.Background(), []string{tempDir})
+		scanner.ExcludePaths = []string{"*.log"}
+		scanner.MaxDepth = maxDepth
+
+		estimate, err := scanner.Estimate(context.Background())
+		if err != nil {
+			t.Fatalf("Estimate() error: %v", err)
+		}
+
+		var scanned Estimate
+		paths, errs := scanner.Scan()
+		for path := range paths {
+			info, _ := os.Stat(path)
+			scanned.Files++
+			scanned.Bytes += info.Size()
+		}
+		for range errs {
+		}
+
+		if estimate != scanned {
+			t.Errorf("depth %d: Estimate() = %+v, scan found %+v", maxDepth, estimate, scanned)
+		}
+	}
+}
+
+func TestEstimateCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	scanner := NewFileScanner(context.Background(), []string{t.TempDir()})
+	if _, err := scanner.Estimate(ctx); err == nil {
+		t.Error("expected error from cancelled estimate")
+	}
+}
@@ -3,11 +3,11 @@ package scanner
 
 import (
 	"context"
-	"fmt"
 	"os"
 	"path/filepath"
 	"sync"
 
+	"github.com/vtriple/agentflux/pkg/common/errcode"
 	"github.com/vtriple/agentflux/pkg/common/logging"
 )
 
@@ -29,6 +29,8 @@ type FileScanner struct {
 	ctx    context.Context
 	wg     sync.WaitGroup
 	logger *logging.Logger
+	stats  ScanStats
+	mu     sync.Mutex // guards stats
 }
 
 // NewFileScanner creates a new FileScanner with the specified context and root paths.
@@ -93,8 +95,9 @@ func (s *FileScanner) scanPath(path string, depth int, fileChannel chan<- string
 	// Get file info for the path
 	info, err := os.Lstat(path)
 	if err != nil {
+		s.skip(skipError)
 		select {
-		case errorChannel <- fmt.Errorf("error accessing path %s: %w", path, err):
+		case errorChannel <- errcode.File(err, "error accessing path %s", path):
 		default:
 			// Channel might be full, log error
 			s.logger.Error("Error channel full, could not send error: %v", err)
@@ -105,20 +108,23 @@ func (s *FileScanner) scanPath(path string, depth int, fileChannel chan<- string
 	// Skip hidden files/directories if configured to do so
 	filename := filepath.Base(path)
 	if s.SkipHiddenFiles && isHiddenFile(filename) {
+		s.skip(skipHidden)
 		return
 	}
 	
 	// Handle symbolic links
 	if info.Mode()&os.ModeSymlink != 0 {
 		if s.SkipSymlinks {
+			s.skip(skipSymlink)
 			return
 		}
 		
 		// Resolve symlink
 		realPath, err := filepath.EvalSymlinks(path)
 		if err != nil {
+			s.skip(skipError)
 			select {
-			case errorChannel <- fmt.Errorf("error resolving symlink %s: %w", path, err):
+			case errorChannel <- errcode.File(err, "error resolving symlink %s", path):
 			default:
 				s.logger.Error("Error channel full, could not send error: %v", err)
 			}
@@ -128,8 +134,9 @@ func (s *FileScanner) scanPath(path string, depth int, fileChannel chan<- string
 		// Get info for the resolved path
 		info, err = os.Stat(realPath)
 		if err != nil {
+			s.skip(skipError)
 			select {
-			case errorChannel <- fmt.Errorf("error accessing resolved path %s: %w", realPath, err):
+			case errorChannel <- errcode.File(err, "error accessing resolved path %s", realPath):
 			default:
 				s.logger.Error("Error channel full, could not send error: %v", err)
 			}
@@ -150,8 +157,9 @@ func (s *FileScanner) scanPath(path string, depth int, fileChannel chan<- string
 		// Read directory entries
 		entries, err := os.ReadDir(path)
 		if err != nil {
+			s.skip(skipError)
 			select {
-			case errorChannel <- fmt.Errorf("error reading directory %s: %w", path, err):
+			case errorChannel <- errcode.File(err, "error reading directory %s", path):
 			default:
 				s.logger.Error("Error channel full, could not send error: %v", err)
 			}
@@ -162,6 +170,7 @@ func (s *FileScanner) scanPath(path string, depth int, fileChannel chan<- string
 		for _, entry := range entries {
 			// Skip hidden entries if configured
 			if s.SkipHiddenFiles && isHiddenFile(entry.Name()) {
+				s.skip(skipHidden)
 				continue
 			}
 			
@@ -174,8 +183,9 @@ func (s *FileScanner) scanPath(path string, depth int, fileChannel chan<- string
 					s.processFile(entryPath, entryInfo, fileChannel, errorChannel)
 				}
 			} else {
+				s.skip(skipError)
 				select {
-				case errorChannel <- fmt.Errorf("error getting info for %s: %w", entryPath, err):
+				case errorChannel <- errcode.File(err, "error getting info for %s", entryPath):
 				default:
 					s.logger.Error("Error channel full, could not send error: %v", err)
 				}
@@ -199,11 +209,13 @@ func (s *FileScanner) processFile(path string, info os.FileInfo, fileChannel cha
 	
 	// Skip irregular files (devices, pipes, etc.)
 	if !info.Mode().IsRegular() {
+		s.skip(skipIrregular)
 		return
 	}
 	
 	// Check file size
 	if s.MaxFileSize > 0 && info.Size() > s.MaxFileSize {
+		s.skip(skipTooLarge)
 		return
 	}
 	
@@ -215,7 +227,7 @@ func (s *FileScanner) processFile(path string, info os.FileInfo, fileChannel cha
 	// Send file path to channel
 	select {
 	case fileChannel <- path:
-		// Successfully sent
+		s.discover()
 	case <-s.ctx.Done():
 		// Context was canceled
 		return
@@ -224,19 +236,29 @@ func (s *FileScanner) processFile(path string, info os.FileInfo, fileChannel cha
 
 // shouldExclude checks if a path should be excluded based on the exclude patterns.
 func (s *FileScanner) shouldExclude(path string) bool {
+	pattern := s.excludePattern(path)
+	if pattern == "" {
+		return false
+	}
+	s.exclude(pattern)
+	return true
+}
+
+// excludePattern returns the first exclude pattern matching path, or "" if none does.
+func (s *FileScanner) excludePattern(path string) string {
 	for _, pattern := range s.ExcludePaths {
 		matched, err := filepath.Match(pattern, filepath.Base(path))
 		if err == nil && matched {
-			return true
+			return pattern
 		}
 		
 		// Also try matching against the full path
 		matched, err = filepath.Match(pattern, path)
 		if err == nil && matched {
-			return true
+			return pattern
 		}
 	}
-	return false
+	return ""
 }
 
 // SetContext updates the scanner's context.
@@ -6,6 +6,8 @@ import (
 	"path/filepath"
 	"testing"
 	"time"
+
+	"github.com/vtriple/agentflux/pkg/common/errcode"
 )
 
 func TestFileScanner_Scan(t *testing.T) {
@@ -167,3 +169,19 @@ func TestFileScanner_ShouldExclude(t *testing.T) {
 		})
 	}
 }
+
+func TestFileScanner_ErrorCodes(t *testing.T) {
+	missing := filepath.Join(t.TempDir(), "missing")
+	scanner := NewFileScanner(context.Background(), []string{missing})
+	files, errs := scanner.Scan()
+	for range files {
+	}
+
+	var codes []errcode.Code
+	for err := range errs {
+		codes = append(codes, errcode.Of(err))
+	}
+	if len(codes) != 1 || codes[0] != errcode.Vanished {
+		t.Errorf("Expected one %s error for a missing root, got %v", errcode.Vanished, codes)
+	}
+}
@@ -0,0 +1,78 @@
+package scanner
+
+import (
+	"github.com/vtriple/agentflux/pkg/common/errcode"
+	"github.com/vtriple/agentflux/pkg/metrics"
+)
+
+// Reasons a path is skipped, used as the reason label of skippedPaths.
+// Reasons that are also error categories use the error code.
+const (
+	skipHidden    = "hidden"
+	skipSymlink   = "symlink"
+	skipIrregular = "irregular"
+	skipTooLarge  = string(errcode.TooLarge)
+	skipError     = "error"
+	// skipExcluded is the reason recorded in ScanStats for excluded paths,
+	// which are counted by pattern in excludedPaths instead.
+	skipExcluded = string(errcode.Excluded)
+)
+
+var (
+	filesDiscovered = metrics.Default.NewCounter("agentflux_scanner_files_discovered_total",
+		"Files found by the scanner and passed on for processing.")
+	skippedPaths = metrics.Default.NewCounterVec("agentflux_scanner_paths_skipped_total",
+		"Paths skipped by the scanner, by reason.", "reason")
+	excludedPaths = metrics.Default.NewCounterVec("agentflux_scanner_paths_excluded_total",
+		"Paths excluded by the scanner, by matching exclude pattern.", "pattern")
+)
+
+// ScanStats contains the counters of a single scanner.
+type ScanStats struct {
+	// Discovered is the number of files passed on for processing.
+	Discovered int64
+	// Skipped is the number of paths skipped, by reason: hidden, symlink,
+	// irregular, too_large, error or excluded.
+	Skipped map[string]int64
+}
+
+// Stats returns the scanner's counters.
+func (s *FileScanner) Stats() ScanStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	stats := ScanStats{Discovered: s.stats.Discovered, Skipped: make(map[string]int64, len(s.stats.Skipped))}
+	for reason, count := range s.stats.Skipped {
+		stats.Skipped[reason] = count
+	}
+	return stats
+}
+
+// discover counts a file passed on for processing.
+func (s *FileScanner) discover() {
+	filesDiscovered.Inc()
+	s.mu.Lock()
+	s.stats.Discovered++
+	s.mu.Unlock()
+}
+
+// skip counts a path skipped for reason.
+func (s *FileScanner) skip(reason string) {
+	skippedPaths.With(reason).Inc()
+	s.count(reason)
+}
+
+// exclude counts a path excluded by pattern.
+func (s *FileScanner) exclude(pattern string) {
+	excludedPaths.With(pattern).Inc()
+	s.count(skipExcluded)
+}
+
+// count adds a skipped path to the scanner's stats.
+func (s *FileScanner) count(reason string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.stats.Skipped == nil {
+		s.stats.Skipped = map[string]int64{}
+	}
+	s.stats.Skipped[reason]++
+}
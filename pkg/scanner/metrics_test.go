@@ -0,0 +1,44 @@
+package scanner
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestScanMetrics(t *testing.T) {
+	tempDir := t.TempDir()
+	for _, file := range []string{"keep.txt", "skip.log", ".hidden"} {
+		if err := os.WriteFile(filepath.Join(tempDir, file), []byte("test content"), 0644); err != nil {
+			t.Fatalf("Failed to create file %s: %v", file, err)
+		}
+	}
+
+	discovered := filesDiscovered.Value()
+	hidden := skippedPaths.With(skipHidden).Value()
+	excluded := excludedPaths.With("*.log").Value()
+
+	scanner := NewFileScanner(context.Background(), []string{tempDir})
+	scanner.ExcludePaths = []string{"*.log"}
+	files, errs := scanner.Scan()
+	for range files {
+	}
+	for range errs {
+	}
+
+	if got := filesDiscovered.Value() - discovered; got != 1 {
+		t.Errorf("files discovered = %v, want 1", got)
+	}
+	if got := skippedPaths.With(skipHidden).Value() - hidden; got != 1 {
+		t.Errorf("hidden files skipped = %v, want 1", got)
+	}
+	if got := excludedPaths.With("*.log").Value() - excluded; got != 1 {
+		t.Errorf("files excluded by *.log = %v, want 1", got)
+	}
+
+	stats := scanner.Stats()
+	if stats.Discovered != 1 || stats.Skipped[skipHidden] != 1 || stats.Skipped[skipExcluded] != 1 {
+		t.Errorf("Stats() = %+v, want 1 discovered, 1 hidden and 1 excluded", stats)
+	}
+}
@@ -0,0 +1,233 @@
+// Package scheduler runs jobs on cron expressions or fixed intervals.
+package scheduler
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Schedule computes when a job runs next.
+type Schedule interface {
+	// Next returns the first activation time strictly after t.
+	Next(t time.Time) time.Time
+}
+
+// cronMacros maps the supported @ shorthands to cron expressions.
+var cronMacros = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+// ParseSchedule parses a five-field cron expression ("*/15 * * * *"), an @
+// shorthand such as @hourly or @daily, or an interval given as "@every 90m"
+// or a plain duration.
+func ParseSchedule(spec string) (Schedule, error) {
+	spec = strings.TrimSpace(spec)
+	if rest, ok := strings.CutPrefix(spec, "@every "); ok {
+		d, err := time.ParseDuration(strings.TrimSpace(rest))
+		if err != nil {
+			return nil, fmt.Errorf("invalid interval %q: %w", rest, err)
+		}
+		return newInterval(d)
+	}
+	if d, err := time.ParseDuration(spec); err == nil {
+		return newInterval(d)
+	}
+	if expr, ok := cronMacros[strings.ToLower(spec)]; ok {
+		spec = expr
+	}
+
+	cron, err := ParseCron(spec)
+	if err != nil {
+		return nil, err
+	}
+	return cron, nil
+}
+
+// Interval is a Schedule that activates at a fixed period.
+type Interval time.Duration
+
+// newInterval validates an interval duration.
+func newInterval(d time.Duration) (Schedule, error) {
+	if d < time.Second {
+		return nil, fmt.Errorf("interval %s is shorter than one second", d)
+	}
+	return Interval(d), nil
+}
+
+// Next returns t plus the interval.
+func (i Interval) Next(t time.Time) time.Time {
+	return t.Add(time.Duration(i))
+}
+
+// Cron is a Schedule defined by a standard five-field cron expression:
+// minute, hour, day of month, month and day of week. Times are evaluated in
+// the location of the time passed to Next.
+type Cron struct {
+	minute, hour, dom, month, dow uint64
+
+	// As in cron(8), when both day fields are restricted a day matches if
+	// either field does
+	domStar, dowStar bool
+}
+
+// cronField describes the range and names of a cron field.
+type cronField struct {
+	name     string
+	min, max int
+	names    []string
+}
+
+var (
+	minuteField = cronField{name: "minute", min: 0, max: 59}
+	hourField   = cronField{name: "hour", min: 0, max: 23}
+	domField    = cronField{name: "day of month", min: 1, max: 31}
+	monthField  = cronField{name: "month", min: 1, max: 12,
+		names: []string{"jan", "feb", "mar", "apr", "may", "jun", "jul", "aug", "sep", "oct", "nov", "dec"}}
+	dowField = cronField{name: "day of week", min: 0, max: 7,
+		names: []string{"sun", "mon", "tue", "wed", "thu", "fri", "sat"}}
+)
+
+// ParseCron parses a five-field cron expression. Fields accept *, numbers,
+// ranges (1-5), lists (1,3,5), steps (*/15, 0-30/10) and, for months and
+// days of the week, three-letter names.
+func ParseCron(expr string) (*Cron, error) {
+	fields := strings.Fields(expr)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("invalid cron expression %q: expected 5 fields, got %d", expr, len(fields))
+	}
+
+	c := &Cron{domStar: strings.HasPrefix(fields[2], "*"), dowStar: strings.HasPrefix(fields[4], "*")}
+	var err error
+	for i, target := range []struct {
+		bits  *uint64
+		field cronField
+	}{
+		{&c.minute, minuteField},
+		{&c.hour, hourField},
+		{&c.dom, domField},
+		{&c.month, monthField},
+		{&c.dow, dowField},
+	} {
+		if *target.bits, err = parseCronField(fields[i], target.field); err != nil {
+			return nil, fmt.Errorf("invalid cron expression %q: %w", expr, err)
+		}
+	}
+
+	// Both 0 and 7 mean Sunday
+	if c.dow&(1<<7) != 0 {
+		c.dow |= 1
+	}
+	return c, nil
+}
+
+// parseCronField parses one field into a bit set of allowed values.
+func parseCronField(s string, field cronField) (uint64, error) {
+	var bits uint64
+	for _, part := range strings.Split(s, ",") {
+		rangePart, stepPart, hasStep := strings.Cut(part, "/")
+		step := 1
+		if hasStep {
+			n, err := strconv.Atoi(stepPart)
+			if err != nil || n <= 0 {
+				return 0, fmt.Errorf("invalid step %q in %s field", stepPart, field.name)
+			}
+			step = n
+		}
+
+		var lo, hi int
+		switch {
+		case rangePart == "*":
+			lo, hi = field.min, field.max
+		case strings.Contains(rangePart, "-"):
+			from, to, _ := strings.Cut(rangePart, "-")
+			var err error
+			if lo, err = field.value(from); err != nil {
+				return 0, err
+			}
+			if hi, err = field.value(to); err != nil {
+				return 0, err
+			}
+		default:
+			var err error
+			if lo, err = field.value(rangePart); err != nil {
+				return 0, err
+			}
+			hi = lo
+			if hasStep {
+				// "5/15" means every 15 starting at 5
+				hi = field.max
+			}
+		}
+		if lo > hi {
+			return 0, fmt.Errorf("invalid range %q in %s field", rangePart, field.name)
+		}
+
+		for v := lo; v <= hi; v += step {
+			bits |= 1 << uint(v)
+		}
+	}
+	return bits, nil
+}
+
+// value parses a number or name within the field's range.
+func (f cronField) value(s string) (int, error) {
+	for i, name := range f.names {
+		if strings.EqualFold(s, name) {
+			if f.min == 1 {
+				return i + 1, nil
+			}
+			return i, nil
+		}
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil || n < f.min || n > f.max {
+		return 0, fmt.Errorf("invalid value %q in %s field (allowed %d-%d)", s, f.name, f.min, f.max)
+	}
+	return n, nil
+}
+
+// Next returns the first minute after t matching the expression, or the
+// zero time if there is none within five years.
+func (c *Cron) Next(t time.Time) time.Time {
+	t = t.Truncate(time.Minute).Add(time.Minute)
+	limit := t.AddDate(5, 0, 0)
+
+	for t.Before(limit) {
+		if c.month&(1<<uint(t.Month())) == 0 {
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, t.Location())
+			continue
+		}
+		if !c.dayMatches(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location())
+			continue
+		}
+		if c.hour&(1<<uint(t.Hour())) == 0 {
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, t.Location())
+			continue
+		}
+		if c.minute&(1<<uint(t.Minute())) == 0 {
+			t = t.Add(time.Minute)
+			continue
+		}
+		return t
+	}
+	return time.Time{}
+}
+
+// dayMatches reports whether the day of t matches the day fields.
+func (c *Cron) dayMatches(t time.Time) bool {
+	domMatch := c.dom&(1<<uint(t.Day())) != 0
+	dowMatch := c.dow&(1<<uint(t.Weekday())) != 0
+	if c.domStar || c.dowStar {
+		return domMatch && dowMatch
+	}
+	return domMatch || dowMatch
+}
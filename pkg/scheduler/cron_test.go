@@ -0,0 +1,110 @@
+package scheduler
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseSchedule(t *testing.T) {
+	base := time.Date(2024, time.March, 15, 10, 7, 30, 0, time.UTC) // a Friday
+
+	tests := []struct {
+		spec      string
+		next      time.Time
+		expectErr bool
+	}{
+		{spec: "@every 90m", next: base.Add(90 * time.Minute)},
+		{spec: "30s", next: base.Add(30 * time.Second)},
+		{spec: "@hourly", next: time.Date(2024, time.March, 15, 11, 0, 0, 0, time.UTC)},
+		{spec: "@daily", next: time.Date(2024, time.March, 16, 0, 0, 0, 0, time.UTC)},
+		{spec: "*/15 * * * *", next: time.Date(2024, time.March, 15, 10, 15, 0, 0, time.UTC)},
+		{spec: "5/20 * * * *", next: time.Date(2024, time.March, 15, 10, 25, 0, 0, time.UTC)},
+		{spec: "0 2 * * sun", next: time.Date(2024, time.March, 17, 2, 0, 0, 0, time.UTC)},
+		{spec: "0 2 * * 7", next: time.Date(2024, time.March, 17, 2, 0, 0, 0, time.UTC)},
+		{spec: "30 1 1 jan-jun *", next: time.Date(2024, time.April, 1, 1, 30, 0, 0, time.UTC)},
+		{spec: "0 9 1-7 * mon-fri", next: time.Date(2024, time.March, 18, 9, 0, 0, 0, time.UTC)},
+		{spec: "0 0 29 2 *", next: time.Date(2028, time.February, 29, 0, 0, 0, 0, time.UTC)},
+		{spec: "@every 10ms", expectErr: true},
+		{spec: "* * * *", expectErr: true},
+		{spec: "60 * * * *", expectErr: true},
+		{spec: "*/0 * * * *", expectErr: true},
+		{spec: "0 0 * * funday", expectErr: true},
+		{spec: "10-5 * * * *", expectErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			schedule, err := ParseSchedule(tt.spec)
+			if tt.expectErr {
+				if err == nil {
+					t.Errorf("Expected error for %q", tt.spec)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if next := schedule.Next(base); !next.Equal(tt.next) {
+				t.Errorf("Expected next run %s, got %s", tt.next, next)
+			}
+		})
+	}
+}
+
+func TestCronNext_Impossible(t *testing.T) {
+	cron, err := ParseCron("0 0 31 2 *")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if next := cron.Next(time.Now()); !next.IsZero() {
+		t.Errorf("Expected no next run for February 31, got %s", next)
+	}
+}
+
+func TestWindow(t *testing.T) {
+	day := func(hour, minute int) time.Time {
+		return time.Date(2024, time.March, 15, hour, minute, 0, 0, time.UTC)
+	}
+
+	night, err := ParseWindow("22:00-06:00")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if night.String() != "22:00-06:00" {
+		t.Errorf("Unexpected window string %s", night)
+	}
+	for _, tt := range []struct {
+		at       time.Time
+		contains bool
+		nextOpen time.Time
+	}{
+		{at: day(23, 0), contains: true, nextOpen: day(23, 0)},
+		{at: day(5, 59), contains: true, nextOpen: day(5, 59)},
+		{at: day(6, 0), contains: false, nextOpen: day(22, 0)},
+		{at: day(12, 0), contains: false, nextOpen: day(22, 0)},
+	} {
+		if got := night.Contains(tt.at); got != tt.contains {
+			t.Errorf("Contains(%s) = %v, want %v", tt.at, got, tt.contains)
+		}
+		if got := night.NextOpen(tt.at); !got.Equal(tt.nextOpen) {
+			t.Errorf("NextOpen(%s) = %s, want %s", tt.at, got, tt.nextOpen)
+		}
+	}
+
+	office, err := ParseWindow("09:00-17:30")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if office.Contains(day(17, 30)) || !office.Contains(day(9, 0)) {
+		t.Error("Expected window to include its start and exclude its end")
+	}
+	if got := office.NextOpen(day(18, 0)); !got.Equal(day(9, 0).AddDate(0, 0, 1)) {
+		t.Errorf("Expected window to open the next morning, got %s", got)
+	}
+
+	for _, bad := range []string{"22:00", "25:00-06:00", "06:00-06:00", "night"} {
+		if _, err := ParseWindow(bad); err == nil {
+			t.Errorf("Expected error for window %q", bad)
+		}
+	}
+}
@@ -0,0 +1,21 @@
+package scheduler
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// SystemLoad returns the one-minute load average from /proc/loadavg.
+func SystemLoad() (float64, error) {
+	data, err := os.ReadFile("/proc/loadavg")
+	if err != nil {
+		return 0, fmt.Errorf("failed to read load average: %w", err)
+	}
+	fields := strings.Fields(string(data))
+	if len(fields) == 0 {
+		return 0, fmt.Errorf("failed to parse load average")
+	}
+	return strconv.ParseFloat(fields[0], 64)
+}
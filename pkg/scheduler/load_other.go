@@ -0,0 +1,10 @@
+//go:build !linux
+
+package scheduler
+
+import "errors"
+
+// SystemLoad is not implemented on this platform.
+func SystemLoad() (float64, error) {
+	return 0, errors.New("load average is not available on this platform")
+}
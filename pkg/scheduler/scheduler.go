@@ -0,0 +1,284 @@
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"math/rand/v2"
+	"sync"
+	"time"
+
+	"github.com/vtriple/agentflux/pkg/common/logging"
+)
+
+// DefaultRecheckInterval is how long a job waits before checking the system
+// load again when it is above the job's threshold.
+const DefaultRecheckInterval = time.Minute
+
+// Job is a task run on a schedule.
+type Job struct {
+	// Name identifies the job in logs and the state file.
+	Name string
+	// Schedule determines when the job runs.
+	Schedule Schedule
+	// Jitter delays each run by a random duration up to this value, so that
+	// many hosts on the same schedule do not start at once.
+	Jitter time.Duration
+	// Window, when set, restricts the times at which runs may start. Runs
+	// due outside the window wait for it to open.
+	Window *Window
+	// MaxLoad, when positive, delays runs while the one-minute load average
+	// is above it.
+	MaxLoad float64
+	// Run performs the job. The context is cancelled when the scheduler stops.
+	Run func(ctx context.Context) error
+}
+
+// Scheduler runs jobs on their schedules and records their status.
+// A job never overlaps itself: its next run is scheduled only once the
+// previous run has finished, and runs missed in the meantime are skipped.
+type Scheduler struct {
+	// RecheckInterval is how long to wait before checking the load again.
+	RecheckInterval time.Duration
+	// Load returns the current load average. It defaults to SystemLoad.
+	Load func() (float64, error)
+
+	jobs      []Job
+	statePath string
+	state     map[string]*JobState
+	mu        sync.Mutex
+	now       func() time.Time
+	logger    *logging.Logger
+}
+
+// New creates a scheduler that persists job status to statePath.
+// An empty statePath keeps the status in memory only.
+func New(statePath string) (*Scheduler, error) {
+	state, err := loadState(statePath)
+	if err != nil {
+		return nil, err
+	}
+
+	// Runs still marked as running were cut short by a crash or kill
+	for _, job := range state {
+		if job.LastStatus == StatusRunning {
+			job.LastStatus = StatusInterrupted
+		}
+	}
+
+	return &Scheduler{
+		RecheckInterval: DefaultRecheckInterval,
+		Load:            SystemLoad,
+		statePath:       statePath,
+		state:           state,
+		now:             time.Now,
+		logger:          logging.NewLogger("scheduler"),
+	}, nil
+}
+
+// Add registers a job. Job names must be unique.
+func (s *Scheduler) Add(job Job) error {
+	if job.Schedule == nil || job.Run == nil {
+		return fmt.Errorf("job %q needs a schedule and a run function", job.Name)
+	}
+	for _, existing := range s.jobs {
+		if existing.Name == job.Name {
+			return fmt.Errorf("duplicate job %q", job.Name)
+		}
+	}
+	s.jobs = append(s.jobs, job)
+	return nil
+}
+
+// State returns a copy of the status of every job.
+func (s *Scheduler) State() map[string]JobState {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	state := make(map[string]JobState, len(s.state))
+	for name, job := range s.state {
+		state[name] = *job
+	}
+	return state
+}
+
+// Run starts all jobs and blocks until ctx is cancelled and running jobs
+// have returned.
+func (s *Scheduler) Run(ctx context.Context) error {
+	if len(s.jobs) == 0 {
+		return fmt.Errorf("no jobs to schedule")
+	}
+
+	var wg sync.WaitGroup
+	for _, job := range s.jobs {
+		wg.Add(1)
+		go func(job Job) {
+			defer wg.Done()
+			s.loop(ctx, job)
+		}(job)
+	}
+	wg.Wait()
+	return nil
+}
+
+// loop runs a job on its schedule until ctx is cancelled.
+func (s *Scheduler) loop(ctx context.Context, job Job) {
+	last := s.jobState(job.Name).LastStart
+	first := true
+
+	for ctx.Err() == nil {
+		now := s.now()
+		var next time.Time
+		if last.IsZero() {
+			next = job.Schedule.Next(now)
+		} else {
+			next = job.Schedule.Next(last)
+			if next.Before(now) {
+				if first {
+					// Catch up once on a run missed while the daemon was stopped
+					s.logger.Info("Job %s missed its run at %s, running now", job.Name, next.Format(time.RFC3339))
+					next = now
+				} else {
+					s.logger.Warn("Job %s overran its schedule, skipping missed runs", job.Name)
+					next = job.Schedule.Next(now)
+				}
+			}
+		}
+		first = false
+
+		if next.IsZero() {
+			s.logger.Error("Job %s has no future runs", job.Name)
+			return
+		}
+		if job.Jitter > 0 {
+			next = next.Add(rand.N(job.Jitter))
+		}
+		s.update(job.Name, func(state *JobState) { state.NextRun = next })
+		s.logger.Info("Job %s next runs at %s", job.Name, next.Format(time.RFC3339))
+
+		if !s.sleepUntil(ctx, next) || !s.waitForConditions(ctx, job) {
+			return
+		}
+		last = s.runJob(ctx, job)
+	}
+}
+
+// waitForConditions waits until the job's window is open and the system load
+// is below its threshold. It returns false if ctx is cancelled first.
+func (s *Scheduler) waitForConditions(ctx context.Context, job Job) bool {
+	for {
+		now := s.now()
+		if job.Window != nil && !job.Window.Contains(now) {
+			open := job.Window.NextOpen(now)
+			s.logger.Info("Job %s is outside its window %s, waiting until %s", job.Name, job.Window, open.Format(time.RFC3339))
+			if !s.sleepUntil(ctx, open) {
+				return false
+			}
+			continue
+		}
+
+		if job.MaxLoad > 0 && s.Load != nil {
+			load, err := s.Load()
+			if err != nil {
+				s.logger.Debug("Cannot check load for job %s: %v", job.Name, err)
+			} else if load > job.MaxLoad {
+				s.logger.Info("Job %s delayed: load %.2f is above %.2f, checking again in %s",
+					job.Name, load, job.MaxLoad, s.RecheckInterval)
+				if !s.sleepUntil(ctx, s.now().Add(s.RecheckInterval)) {
+					return false
+				}
+				continue
+			}
+		}
+		return true
+	}
+}
+
+// runJob runs the job once, recording its status, and returns its start time.
+func (s *Scheduler) runJob(ctx context.Context, job Job) time.Time {
+	start := s.now()
+	s.update(job.Name, func(state *JobState) {
+		state.LastStart = start
+		state.LastStatus = StatusRunning
+		state.LastError = ""
+		state.Runs++
+	})
+	s.logger.Info("Starting job %s", job.Name)
+
+	err := s.safeRun(ctx, job)
+
+	s.update(job.Name, func(state *JobState) {
+		state.LastEnd = s.now()
+		switch {
+		case ctx.Err() != nil:
+			state.LastStatus = StatusInterrupted
+		case err != nil:
+			state.LastStatus = StatusFailed
+			state.LastError = err.Error()
+			state.Failures++
+		default:
+			state.LastStatus = StatusSuccess
+		}
+	})
+	if err != nil {
+		s.logger.Error("Job %s failed after %s: %v", job.Name, s.now().Sub(start), err)
+	} else {
+		s.logger.Info("Job %s finished in %s", job.Name, s.now().Sub(start))
+	}
+	return start
+}
+
+// safeRun runs the job, converting a panic into an error so one bad run does
+// not stop the daemon.
+func (s *Scheduler) safeRun(ctx context.Context, job Job) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	return job.Run(ctx)
+}
+
+// jobState returns a copy of the state of the named job.
+func (s *Scheduler) jobState(name string) JobState {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if state, ok := s.state[name]; ok {
+		return *state
+	}
+	return JobState{}
+}
+
+// update modifies the state of the named job and persists it.
+func (s *Scheduler) update(name string, fn func(*JobState)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	state, ok := s.state[name]
+	if !ok {
+		state = &JobState{}
+		s.state[name] = state
+	}
+	fn(state)
+	if err := saveState(s.statePath, s.state); err != nil {
+		s.logger.Error("Failed to save scheduler state: %v", err)
+	}
+}
+
+// sleepUntil waits until t or until ctx is cancelled, reporting whether t was reached.
+func (s *Scheduler) sleepUntil(ctx context.Context, t time.Time) bool {
+	delay := t.Sub(s.now())
+	if delay <= 0 {
+		return ctx.Err() == nil
+	}
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
+
+// SetLogger sets a custom logger for the scheduler.
+func (s *Scheduler) SetLogger(logger *logging.Logger) {
+	s.logger = logger
+}
@@ -0,0 +1,156 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestScheduler_RunsWithoutOverlap(t *testing.T) {
+	statePath := filepath.Join(t.TempDir(), "state.json")
+	s, err := New(statePath)
+	if err != nil {
+		t.Fatalf("Failed to create scheduler: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var running, overlaps, runs int32
+	err = s.Add(Job{
+		Name:     "slow",
+		Schedule: Interval(5 * time.Millisecond),
+		Run: func(ctx context.Context) error {
+			if atomic.AddInt32(&running, 1) > 1 {
+				atomic.AddInt32(&overlaps, 1)
+			}
+			defer atomic.AddInt32(&running, -1)
+
+			// Each run outlasts the interval
+			time.Sleep(20 * time.Millisecond)
+			if atomic.AddInt32(&runs, 1) == 3 {
+				cancel()
+				return nil
+			}
+			return errors.New("scan failed")
+		},
+	})
+	if err != nil {
+		t.Fatalf("Failed to add job: %v", err)
+	}
+	if err := s.Add(Job{Name: "slow", Schedule: Interval(time.Second), Run: func(context.Context) error { return nil }}); err == nil {
+		t.Error("Expected error adding a duplicate job")
+	}
+
+	done := make(chan struct{})
+	go func() {
+		s.Run(ctx)
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Scheduler did not stop")
+	}
+
+	if overlaps != 0 {
+		t.Errorf("Expected runs not to overlap, got %d overlaps", overlaps)
+	}
+	state := s.State()["slow"]
+	if state.Runs != 3 || state.Failures != 2 {
+		t.Errorf("Expected 3 runs and 2 failures, got %+v", state)
+	}
+	if state.LastStatus != StatusInterrupted {
+		t.Errorf("Expected run cut short by shutdown to be interrupted, got %s", state.LastStatus)
+	}
+
+	// The state survives a restart
+	restarted, err := New(statePath)
+	if err != nil {
+		t.Fatalf("Failed to reload scheduler: %v", err)
+	}
+	if got := restarted.State()["slow"]; got.Runs != 3 || got.Failures != 2 {
+		t.Errorf("Expected persisted state, got %+v", got)
+	}
+}
+
+func TestScheduler_WaitsForLoad(t *testing.T) {
+	s, err := New("")
+	if err != nil {
+		t.Fatalf("Failed to create scheduler: %v", err)
+	}
+	s.RecheckInterval = time.Millisecond
+
+	var checks int32
+	s.Load = func() (float64, error) {
+		if atomic.AddInt32(&checks, 1) < 3 {
+			return 8, nil
+		}
+		return 0.5, nil
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	s.Add(Job{
+		Name:     "scan",
+		Schedule: Interval(time.Millisecond),
+		MaxLoad:  2,
+		Run: func(context.Context) error {
+			cancel()
+			return nil
+		},
+	})
+	s.Run(ctx)
+
+	if checks != 3 {
+		t.Errorf("Expected the run to wait for the load to drop, got %d checks", checks)
+	}
+	if s.State()["scan"].Runs != 1 {
+		t.Errorf("Expected one run, got %+v", s.State()["scan"])
+	}
+}
+
+func TestScheduler_CatchesUpMissedRun(t *testing.T) {
+	statePath := filepath.Join(t.TempDir(), "state.json")
+	lastStart := time.Now().Add(-2 * time.Hour).UTC().Format(time.RFC3339)
+	content := `{"jobs": {"nightly": {"lastStart": "` + lastStart + `", "lastStatus": "running", "runs": 4}}}`
+	if err := os.WriteFile(statePath, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write state: %v", err)
+	}
+
+	s, err := New(statePath)
+	if err != nil {
+		t.Fatalf("Failed to create scheduler: %v", err)
+	}
+	if status := s.State()["nightly"].LastStatus; status != StatusInterrupted {
+		t.Errorf("Expected stale running status to become interrupted, got %s", status)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	s.Add(Job{
+		Name:     "nightly",
+		Schedule: Interval(time.Hour),
+		Run: func(context.Context) error {
+			cancel()
+			return nil
+		},
+	})
+	s.Run(ctx)
+
+	if runs := s.State()["nightly"].Runs; runs != 5 {
+		t.Errorf("Expected the missed run to start immediately, got %d runs", runs)
+	}
+}
+
+func TestNew_InvalidState(t *testing.T) {
+	statePath := filepath.Join(t.TempDir(), "state.json")
+	os.WriteFile(statePath, []byte("not json"), 0644)
+	if _, err := New(statePath); err == nil {
+		t.Error("Expected error for corrupt state file")
+	}
+}
@@ -0,0 +1,96 @@
+package scheduler
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Job statuses recorded in the state file.
+const (
+	// StatusRunning means the job is currently running.
+	StatusRunning = "running"
+	// StatusSuccess means the last run finished without error.
+	StatusSuccess = "success"
+	// StatusFailed means the last run returned an error.
+	StatusFailed = "failed"
+	// StatusInterrupted means the last run was stopped by a shutdown or crash.
+	StatusInterrupted = "interrupted"
+)
+
+// JobState is the persisted status of a job.
+type JobState struct {
+	// LastStart is when the last run started.
+	LastStart time.Time `json:"lastStart"`
+	// LastEnd is when the last run finished.
+	LastEnd time.Time `json:"lastEnd"`
+	// LastStatus is the outcome of the last run.
+	LastStatus string `json:"lastStatus,omitempty"`
+	// LastError is the error returned by the last run, if any.
+	LastError string `json:"lastError,omitempty"`
+	// NextRun is when the job is next scheduled to run.
+	NextRun time.Time `json:"nextRun"`
+	// Runs is the number of runs started.
+	Runs int `json:"runs"`
+	// Failures is the number of runs that failed.
+	Failures int `json:"failures"`
+}
+
+// stateFile is the JSON document stored at the state path.
+type stateFile struct {
+	Jobs map[string]*JobState `json:"jobs"`
+}
+
+// loadState reads the job states from path. A missing file yields an empty state.
+func loadState(path string) (map[string]*JobState, error) {
+	state := stateFile{Jobs: map[string]*JobState{}}
+	if path == "" {
+		return state.Jobs, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return state.Jobs, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read scheduler state: %w", err)
+	}
+	if err := json.Unmarshal(data, &state); err != nil {
+		return nil, fmt.Errorf("failed to parse scheduler state %s: %w", path, err)
+	}
+	if state.Jobs == nil {
+		state.Jobs = map[string]*JobState{}
+	}
+	return state.Jobs, nil
+}
+
+// saveState atomically writes the job states to path.
+func saveState(path string, jobs map[string]*JobState) error {
+	if path == "" {
+		return nil
+	}
+
+	data, err := json.MarshalIndent(stateFile{Jobs: jobs}, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode scheduler state: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create state directory: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp*")
+	if err != nil {
+		return fmt.Errorf("failed to write scheduler state: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write scheduler state: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write scheduler state: %w", err)
+	}
+	return os.Rename(tmp.Name(), path)
+}
@@ -0,0 +1,78 @@
+package scheduler
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Window is a daily time range, such as 22:00-06:00, in which jobs may start.
+// Windows whose end is before their start wrap past midnight.
+type Window struct {
+	start, end time.Duration
+}
+
+// ParseWindow parses a window in HH:MM-HH:MM form.
+func ParseWindow(s string) (*Window, error) {
+	from, to, ok := strings.Cut(s, "-")
+	if !ok {
+		return nil, fmt.Errorf("invalid window %q: expected HH:MM-HH:MM", s)
+	}
+	start, err := parseClock(strings.TrimSpace(from))
+	if err != nil {
+		return nil, fmt.Errorf("invalid window %q: %w", s, err)
+	}
+	end, err := parseClock(strings.TrimSpace(to))
+	if err != nil {
+		return nil, fmt.Errorf("invalid window %q: %w", s, err)
+	}
+	if start == end {
+		return nil, fmt.Errorf("invalid window %q: start and end are equal", s)
+	}
+	return &Window{start: start, end: end}, nil
+}
+
+// parseClock parses HH:MM into an offset from midnight.
+func parseClock(s string) (time.Duration, error) {
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid time %q", s)
+	}
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
+
+// Contains reports whether t falls inside the window.
+func (w *Window) Contains(t time.Time) bool {
+	offset := t.Sub(midnight(t))
+	if w.start < w.end {
+		return offset >= w.start && offset < w.end
+	}
+	return offset >= w.start || offset < w.end
+}
+
+// NextOpen returns t if it is inside the window, or else the time the window
+// next opens.
+func (w *Window) NextOpen(t time.Time) time.Time {
+	if w.Contains(t) {
+		return t
+	}
+	open := midnight(t).Add(w.start)
+	if !open.After(t) {
+		day := midnight(t)
+		open = time.Date(day.Year(), day.Month(), day.Day()+1, 0, 0, 0, 0, t.Location()).Add(w.start)
+	}
+	return open
+}
+
+// String returns the window in HH:MM-HH:MM form.
+func (w *Window) String() string {
+	clock := func(d time.Duration) string {
+		return fmt.Sprintf("%02d:%02d", int(d.Hours()), int(d.Minutes())%60)
+	}
+	return clock(w.start) + "-" + clock(w.end)
+}
+
+// midnight returns the start of the day of t in its location.
+func midnight(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+}